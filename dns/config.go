@@ -0,0 +1,68 @@
+package dns
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/supergiant-hq/xnet/tun"
+)
+
+const (
+	DefaultDomain   = "xnet"
+	DefaultTTL      = time.Second * 30
+	DefaultPort     = 53
+	upstreamTimeout = time.Second * 3
+	maxPacketSize   = 4096
+	minPacketSize   = 512
+)
+
+// DNS Server Config
+type Config struct {
+	// Listen Address
+	Addr *net.UDPAddr
+	// Domain under which peers are resolved
+	// A peer is resolved as <client-id>.<domain> or <tag>.<domain>
+	Domain string
+	// TTL of the answers
+	TTL time.Duration
+	// Upstream resolvers (host:port) to forward other names to
+	// Queries for other names are refused if empty
+	Upstreams []string
+}
+
+// Create a Config which binds the Server to the address of a TUN Device
+func NewConfigForTun(td *tun.TunDevice) (config Config, err error) {
+	if !td.Active {
+		err = fmt.Errorf("dns: tun device not active")
+		return
+	}
+
+	config = Config{
+		Addr: &net.UDPAddr{
+			IP:   td.Config.IP,
+			Port: DefaultPort,
+		},
+	}
+	return
+}
+
+func (c *Config) init() (err error) {
+	if c.Addr == nil {
+		if c.Addr, err = net.ResolveUDPAddr("udp", fmt.Sprintf(":%d", DefaultPort)); err != nil {
+			return
+		}
+	}
+
+	c.Domain = strings.ToLower(strings.Trim(c.Domain, "."))
+	if len(c.Domain) == 0 {
+		c.Domain = DefaultDomain
+	}
+
+	if c.TTL == 0 {
+		c.TTL = DefaultTTL
+	}
+
+	return
+}
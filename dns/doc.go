@@ -0,0 +1,2 @@
+// Package dns provides a DNS Server which resolves overlay peer names
+package dns
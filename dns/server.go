@@ -0,0 +1,371 @@
+package dns
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/supergiant-hq/xnet/model"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Registry provides the Records of peers
+// It's implemented by brokerc.Registry and brokers.Server
+type Registry interface {
+	// Get the Record of a Client
+	Record(id string) (record *model.ClientRecord, ok bool)
+	// Get the Records of Clients having a Tag
+	RecordsWithTag(tag string) (records []*model.ClientRecord)
+}
+
+// DNS Server
+type Server struct {
+	config   Config
+	registry Registry
+	suffix   string
+
+	conn *net.UDPConn
+
+	// Exit Channel
+	Exit chan bool
+	// Closed Status
+	Closed bool
+	mutex  sync.Mutex
+	log    *logrus.Entry
+}
+
+// Create a DNS Server
+func New(log *logrus.Logger, config Config, registry Registry) (s *Server, err error) {
+	if err = config.init(); err != nil {
+		return
+	}
+
+	s = &Server{
+		config:   config,
+		registry: registry,
+		suffix:   "." + config.Domain + ".",
+
+		Exit: make(chan bool, 1),
+		log:  log.WithField("prefix", "DNS"),
+	}
+	return
+}
+
+// Start listening for queries
+func (s *Server) Listen() (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.Closed {
+		return fmt.Errorf("server closed")
+	}
+
+	if s.conn, err = net.ListenUDP("udp", s.config.Addr); err != nil {
+		return
+	}
+
+	go s.handleQueries()
+
+	s.log.Infof("Server started: %s domain(%s)", s.config.Addr.String(), s.config.Domain)
+	return
+}
+
+func (s *Server) handleQueries() {
+	for {
+		buf := make([]byte, maxPacketSize)
+		n, addr, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			if !s.Closed {
+				s.log.Errorln("Error reading query:", err.Error())
+				go s.Close()
+			}
+			return
+		}
+
+		go s.handleQuery(buf[:n], addr)
+	}
+}
+
+func (s *Server) handleQuery(req []byte, addr *net.UDPAddr) {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(req)
+	if err != nil {
+		s.log.Debugln("Invalid query:", err.Error())
+		return
+	}
+	question, err := parser.Question()
+	if err != nil {
+		s.log.Debugln("Invalid question:", err.Error())
+		return
+	}
+
+	// EDNS(0) OPT record of the query
+	var opt *dnsmessage.ResourceHeader
+	parser.SkipAllQuestions()
+	parser.SkipAllAnswers()
+	parser.SkipAllAuthorities()
+	if additionals, err := parser.AllAdditionals(); err == nil {
+		for _, additional := range additionals {
+			if additional.Header.Type == dnsmessage.TypeOPT {
+				opt = &additional.Header
+				break
+			}
+		}
+	}
+
+	name := strings.ToLower(question.Name.String())
+	if name != s.config.Domain+"." && !strings.HasSuffix(name, s.suffix) {
+		s.forward(req, header, question, opt, addr)
+		return
+	}
+
+	limit := minPacketSize
+	if opt != nil && int(opt.Class) > limit {
+		limit = int(opt.Class)
+	}
+	if limit > maxPacketSize {
+		limit = maxPacketSize
+	}
+
+	answers, additionals, rcode := s.resolve(question, strings.TrimSuffix(name, s.suffix))
+	res, err := s.buildResponse(header, question, opt, rcode, answers, additionals, false)
+	if err == nil && len(res) > limit {
+		res, err = s.buildResponse(header, question, opt, rcode, nil, nil, true)
+	}
+	if err != nil {
+		s.log.Errorln("Error building response:", err.Error())
+		return
+	}
+
+	s.conn.WriteToUDP(res, addr)
+}
+
+func (s *Server) lookup(name string) (records []*model.ClientRecord) {
+	if record, ok := s.registry.Record(name); ok {
+		return []*model.ClientRecord{record}
+	}
+	return s.registry.RecordsWithTag(name)
+}
+
+func (s *Server) resolve(question dnsmessage.Question, name string) (answers []dnsmessage.Resource, additionals []dnsmessage.Resource, rcode dnsmessage.RCode) {
+	// SRV: _service._proto.<name>
+	service := ""
+	if labels := strings.SplitN(name, ".", 3); len(labels) == 3 && strings.HasPrefix(labels[0], "_") && strings.HasPrefix(labels[1], "_") {
+		service = labels[0] + "." + labels[1]
+		name = labels[2]
+	}
+
+	records := s.lookup(name)
+	if len(records) == 0 {
+		return nil, nil, dnsmessage.RCodeNameError
+	}
+
+	if len(service) > 0 {
+		if question.Type != dnsmessage.TypeSRV {
+			return
+		}
+		for _, record := range records {
+			port, ok := record.Services[service]
+			if !ok {
+				continue
+			}
+			target, err := dnsmessage.NewName(strings.ToLower(record.Id) + s.suffix)
+			if err != nil {
+				continue
+			}
+			answers = append(answers, dnsmessage.Resource{
+				Header: s.resourceHeader(question.Name, dnsmessage.TypeSRV),
+				Body: &dnsmessage.SRVResource{
+					Priority: 0,
+					Weight:   1,
+					Port:     uint16(port),
+					Target:   target,
+				},
+			})
+			additionals = append(additionals, s.addressResources(target, record, dnsmessage.TypeA)...)
+			additionals = append(additionals, s.addressResources(target, record, dnsmessage.TypeAAAA)...)
+		}
+		return
+	}
+
+	for _, record := range records {
+		answers = append(answers, s.addressResources(question.Name, record, question.Type)...)
+	}
+	return
+}
+
+func (s *Server) addressResources(name dnsmessage.Name, record *model.ClientRecord, qtype dnsmessage.Type) (resources []dnsmessage.Resource) {
+	for _, addr := range record.OverlayAddresses {
+		ip := net.ParseIP(addr.Ip)
+		if ip == nil {
+			continue
+		}
+
+		if ip4 := ip.To4(); ip4 != nil && qtype == dnsmessage.TypeA {
+			body := &dnsmessage.AResource{}
+			copy(body.A[:], ip4)
+			resources = append(resources, dnsmessage.Resource{
+				Header: s.resourceHeader(name, dnsmessage.TypeA),
+				Body:   body,
+			})
+		} else if ip.To4() == nil && qtype == dnsmessage.TypeAAAA {
+			body := &dnsmessage.AAAAResource{}
+			copy(body.AAAA[:], ip.To16())
+			resources = append(resources, dnsmessage.Resource{
+				Header: s.resourceHeader(name, dnsmessage.TypeAAAA),
+				Body:   body,
+			})
+		}
+	}
+	return
+}
+
+func (s *Server) resourceHeader(name dnsmessage.Name, rtype dnsmessage.Type) dnsmessage.ResourceHeader {
+	return dnsmessage.ResourceHeader{
+		Name:  name,
+		Type:  rtype,
+		Class: dnsmessage.ClassINET,
+		TTL:   uint32(s.config.TTL / time.Second),
+	}
+}
+
+// Response to a query
+// An OPT record advertising maxPacketSize is added if the query has one (opt)
+func (s *Server) buildResponse(
+	reqHeader dnsmessage.Header,
+	question dnsmessage.Question,
+	opt *dnsmessage.ResourceHeader,
+	rcode dnsmessage.RCode,
+	answers []dnsmessage.Resource,
+	additionals []dnsmessage.Resource,
+	truncated bool,
+) (res []byte, err error) {
+	builder := dnsmessage.NewBuilder(make([]byte, 0, minPacketSize), dnsmessage.Header{
+		ID:                 reqHeader.ID,
+		Response:           true,
+		OpCode:             reqHeader.OpCode,
+		Authoritative:      true,
+		Truncated:          truncated,
+		RecursionDesired:   reqHeader.RecursionDesired,
+		RecursionAvailable: len(s.config.Upstreams) > 0,
+		RCode:              rcode,
+	})
+	builder.EnableCompression()
+
+	if err = builder.StartQuestions(); err != nil {
+		return
+	}
+	if err = builder.Question(question); err != nil {
+		return
+	}
+
+	if err = builder.StartAnswers(); err != nil {
+		return
+	}
+	for _, answer := range answers {
+		if err = appendResource(&builder, answer); err != nil {
+			return
+		}
+	}
+
+	if err = builder.StartAdditionals(); err != nil {
+		return
+	}
+	for _, additional := range additionals {
+		if err = appendResource(&builder, additional); err != nil {
+			return
+		}
+	}
+	if opt != nil {
+		var header dnsmessage.ResourceHeader
+		if err = header.SetEDNS0(maxPacketSize, dnsmessage.RCodeSuccess, opt.DNSSECAllowed()); err != nil {
+			return
+		}
+		if err = builder.OPTResource(header, dnsmessage.OPTResource{}); err != nil {
+			return
+		}
+	}
+
+	return builder.Finish()
+}
+
+func appendResource(builder *dnsmessage.Builder, resource dnsmessage.Resource) error {
+	switch body := resource.Body.(type) {
+	case *dnsmessage.AResource:
+		return builder.AResource(resource.Header, *body)
+	case *dnsmessage.AAAAResource:
+		return builder.AAAAResource(resource.Header, *body)
+	case *dnsmessage.SRVResource:
+		return builder.SRVResource(resource.Header, *body)
+	default:
+		return fmt.Errorf("unsupported resource type: %v", resource.Header.Type)
+	}
+}
+
+func (s *Server) forward(req []byte, header dnsmessage.Header, question dnsmessage.Question, opt *dnsmessage.ResourceHeader, addr *net.UDPAddr) {
+	for _, upstream := range s.config.Upstreams {
+		res, err := s.exchange(upstream, req)
+		if err != nil {
+			s.log.Debugf("Error forwarding query to (%s): %v", upstream, err.Error())
+			continue
+		}
+
+		s.conn.WriteToUDP(res, addr)
+		return
+	}
+
+	rcode := dnsmessage.RCodeRefused
+	if len(s.config.Upstreams) > 0 {
+		rcode = dnsmessage.RCodeServerFailure
+	}
+	if res, err := s.buildResponse(header, question, opt, rcode, nil, nil, false); err == nil {
+		s.conn.WriteToUDP(res, addr)
+	}
+}
+
+func (s *Server) exchange(upstream string, req []byte) (res []byte, err error) {
+	conn, err := net.DialTimeout("udp", upstream, upstreamTimeout)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(upstreamTimeout))
+	if _, err = conn.Write(req); err != nil {
+		return
+	}
+
+	buf := make([]byte, maxPacketSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return
+	}
+
+	return buf[:n], nil
+}
+
+// Close Server
+func (s *Server) Close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.Closed {
+		return
+	}
+	s.Closed = true
+
+	if s.conn != nil {
+		s.conn.Close()
+	}
+
+	select {
+	case s.Exit <- true:
+	default:
+	}
+
+	s.log.Warnln("Server shutdown complete")
+}
@@ -0,0 +1,309 @@
+package dns
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/supergiant-hq/xnet/model"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Registry of Records by Client ID
+type testRegistry map[string]*model.ClientRecord
+
+func (r testRegistry) Record(id string) (record *model.ClientRecord, ok bool) {
+	record, ok = r[id]
+	return
+}
+
+func (r testRegistry) RecordsWithTag(tag string) (records []*model.ClientRecord) {
+	for _, record := range r {
+		if _, ok := record.Tags[tag]; ok {
+			records = append(records, record)
+		}
+	}
+	return
+}
+
+func newTestRegistry() testRegistry {
+	r := testRegistry{
+		"a": {
+			Id:               "a",
+			Tags:             map[string]string{"web": ""},
+			OverlayAddresses: []*model.OverlayAddress{{Ip: "100.64.0.1"}, {Ip: "fd00::1"}},
+			Services:         map[string]uint32{"_http._tcp": 8080},
+		},
+		"b": {
+			Id:               "b",
+			Tags:             map[string]string{"web": ""},
+			OverlayAddresses: []*model.OverlayAddress{{Ip: "100.64.0.2"}},
+		},
+		"c": {
+			Id:               "c",
+			OverlayAddresses: []*model.OverlayAddress{{Ip: "fd00::3"}},
+		},
+	}
+	// Answers for the tag exceed the minimum packet size
+	for i := 0; i < 40; i++ {
+		id := fmt.Sprintf("many%d", i)
+		r[id] = &model.ClientRecord{
+			Id:               id,
+			Tags:             map[string]string{"many": ""},
+			OverlayAddresses: []*model.OverlayAddress{{Ip: fmt.Sprintf("100.64.1.%d", i)}},
+		}
+	}
+	return r
+}
+
+func newTestServer(t *testing.T, upstreams ...string) *net.UDPAddr {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	s, err := New(log, Config{
+		Addr:      &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)},
+		Upstreams: upstreams,
+	}, newTestRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Listen(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	return s.conn.LocalAddr().(*net.UDPAddr)
+}
+
+// Upstream resolver answering every A query with 192.0.2.1
+func newTestUpstream(t *testing.T) string {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, maxPacketSize)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			var parser dnsmessage.Parser
+			header, err := parser.Start(buf[:n])
+			if err != nil {
+				continue
+			}
+			question, err := parser.Question()
+			if err != nil {
+				continue
+			}
+			msg := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: header.ID, Response: true},
+				Questions: []dnsmessage.Question{question},
+				Answers: []dnsmessage.Resource{{
+					Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+					Body:   &dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}},
+				}},
+			}
+			if res, err := msg.Pack(); err == nil {
+				conn.WriteToUDP(res, addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+// Send a query and parse the response
+func query(t *testing.T, addr *net.UDPAddr, name string, qtype dnsmessage.Type, edns bool) *dnsmessage.Message {
+	t.Helper()
+
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: 7, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{
+			Name:  dnsmessage.MustNewName(name),
+			Type:  qtype,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	if edns {
+		var header dnsmessage.ResourceHeader
+		header.SetEDNS0(maxPacketSize, dnsmessage.RCodeSuccess, false)
+		msg.Additionals = append(msg.Additionals, dnsmessage.Resource{Header: header, Body: &dnsmessage.OPTResource{}})
+	}
+	req, err := msg.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * upstreamTimeout))
+	if _, err = conn.Write(req); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, maxPacketSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := &dnsmessage.Message{}
+	if err = res.Unpack(buf[:n]); err != nil {
+		t.Fatal(err)
+	}
+	if res.ID != msg.ID || !res.Response {
+		t.Fatalf("response id(%d) response(%v)", res.ID, res.Response)
+	}
+	return res
+}
+
+// Answers of a response as strings, sorted
+func answers(resources []dnsmessage.Resource) (values []string) {
+	for _, r := range resources {
+		switch body := r.Body.(type) {
+		case *dnsmessage.AResource:
+			values = append(values, net.IP(body.A[:]).String())
+		case *dnsmessage.AAAAResource:
+			values = append(values, net.IP(body.AAAA[:]).String())
+		case *dnsmessage.SRVResource:
+			values = append(values, fmt.Sprintf("%s:%d", body.Target.String(), body.Port))
+		}
+	}
+	sort.Strings(values)
+	return
+}
+
+func TestServerResolve(t *testing.T) {
+	addr := newTestServer(t)
+
+	tests := []struct {
+		name        string
+		qname       string
+		qtype       dnsmessage.Type
+		rcode       dnsmessage.RCode
+		answers     []string
+		additionals []string
+	}{
+		{"A by id", "a.xnet.", dnsmessage.TypeA, dnsmessage.RCodeSuccess, []string{"100.64.0.1"}, nil},
+		{"AAAA by id", "a.xnet.", dnsmessage.TypeAAAA, dnsmessage.RCodeSuccess, []string{"fd00::1"}, nil},
+		{"A by tag", "web.xnet.", dnsmessage.TypeA, dnsmessage.RCodeSuccess, []string{"100.64.0.1", "100.64.0.2"}, nil},
+		{"A of IPv6 peer", "c.xnet.", dnsmessage.TypeA, dnsmessage.RCodeSuccess, nil, nil},
+		{"mixed case", "A.XNet.", dnsmessage.TypeA, dnsmessage.RCodeSuccess, []string{"100.64.0.1"}, nil},
+		{"SRV", "_http._tcp.a.xnet.", dnsmessage.TypeSRV, dnsmessage.RCodeSuccess, []string{"a.xnet.:8080"}, []string{"100.64.0.1", "fd00::1"}},
+		{"SRV by tag", "_http._tcp.web.xnet.", dnsmessage.TypeSRV, dnsmessage.RCodeSuccess, []string{"a.xnet.:8080"}, []string{"100.64.0.1", "fd00::1"}},
+		{"SRV of other service", "_ssh._tcp.a.xnet.", dnsmessage.TypeSRV, dnsmessage.RCodeSuccess, nil, nil},
+		{"A of service", "_http._tcp.a.xnet.", dnsmessage.TypeA, dnsmessage.RCodeSuccess, nil, nil},
+		{"NXDOMAIN", "missing.xnet.", dnsmessage.TypeA, dnsmessage.RCodeNameError, nil, nil},
+		{"NXDOMAIN of service", "_http._tcp.missing.xnet.", dnsmessage.TypeSRV, dnsmessage.RCodeNameError, nil, nil},
+		{"other domain without upstreams", "example.com.", dnsmessage.TypeA, dnsmessage.RCodeRefused, nil, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := query(t, addr, test.qname, test.qtype, false)
+			if res.RCode != test.rcode {
+				t.Fatalf("rcode %v, want %v", res.RCode, test.rcode)
+			}
+			if got := answers(res.Answers); fmt.Sprint(got) != fmt.Sprint(test.answers) {
+				t.Fatalf("answers %v, want %v", got, test.answers)
+			}
+			if got := answers(res.Additionals); fmt.Sprint(got) != fmt.Sprint(test.additionals) {
+				t.Fatalf("additionals %v, want %v", got, test.additionals)
+			}
+		})
+	}
+}
+
+func TestServerForward(t *testing.T) {
+	// Nothing listens on the port of a closed socket
+	closed, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := closed.LocalAddr().String()
+	closed.Close()
+
+	tests := []struct {
+		name      string
+		upstreams []string
+		rcode     dnsmessage.RCode
+		answers   []string
+	}{
+		{"upstream", []string{newTestUpstream(t)}, dnsmessage.RCodeSuccess, []string{"192.0.2.1"}},
+		{"next upstream", []string{unreachable, newTestUpstream(t)}, dnsmessage.RCodeSuccess, []string{"192.0.2.1"}},
+		{"upstream unreachable", []string{unreachable}, dnsmessage.RCodeServerFailure, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			addr := newTestServer(t, test.upstreams...)
+
+			res := query(t, addr, "example.com.", dnsmessage.TypeA, false)
+			if res.RCode != test.rcode {
+				t.Fatalf("rcode %v, want %v", res.RCode, test.rcode)
+			}
+			if got := answers(res.Answers); fmt.Sprint(got) != fmt.Sprint(test.answers) {
+				t.Fatalf("answers %v, want %v", got, test.answers)
+			}
+
+			// Names of peers are not forwarded
+			if res := query(t, addr, "a.xnet.", dnsmessage.TypeA, false); !res.RecursionAvailable || len(res.Answers) != 1 {
+				t.Fatalf("peer answers %d recursion available(%v)", len(res.Answers), res.RecursionAvailable)
+			}
+		})
+	}
+}
+
+// The OPT record of a query is answered with one and raises the size limit of the response
+func TestServerEDNS(t *testing.T) {
+	addr := newTestServer(t)
+
+	tests := []struct {
+		name      string
+		qname     string
+		edns      bool
+		truncated bool
+		answers   int
+	}{
+		{"without OPT", "a.xnet.", false, false, 1},
+		{"with OPT", "a.xnet.", true, false, 1},
+		{"NXDOMAIN with OPT", "missing.xnet.", true, false, 0},
+		{"other domain with OPT", "example.com.", true, false, 0},
+		{"truncated without OPT", "many.xnet.", false, true, 0},
+		{"large with OPT", "many.xnet.", true, false, 40},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := query(t, addr, test.qname, dnsmessage.TypeA, test.edns)
+			if res.Truncated != test.truncated {
+				t.Fatalf("truncated %v, want %v", res.Truncated, test.truncated)
+			}
+			if len(res.Answers) != test.answers {
+				t.Fatalf("answers %d, want %d", len(res.Answers), test.answers)
+			}
+
+			var opt *dnsmessage.Resource
+			for i := range res.Additionals {
+				if res.Additionals[i].Header.Type == dnsmessage.TypeOPT {
+					opt = &res.Additionals[i]
+				}
+			}
+			if (opt != nil) != test.edns {
+				t.Fatalf("OPT record %v, want %v", opt != nil, test.edns)
+			}
+			if opt != nil && int(opt.Header.Class) != maxPacketSize {
+				t.Fatalf("OPT payload size %d, want %d", opt.Header.Class, maxPacketSize)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package ipam
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	DefaultLeaseDuration = time.Hour * 24
+	reapInterval         = time.Minute
+)
+
+// IPAM Config
+type Config struct {
+	// IPv4 Network to lease addresses from
+	// Leave nil to disable IPv4 leases
+	IPv4 *net.IPNet
+	// IPv6 Network to lease addresses from
+	// Leave nil to disable IPv6 leases
+	IPv6 *net.IPNet
+	// Duration for which a lease is retained after the client disconnects
+	LeaseDuration time.Duration
+	// Static reservations (Client ID -> IP Addresses)
+	Reservations map[string][]net.IP
+	// Lease persistence
+	// Leases are kept in memory if nil
+	Store Store
+}
+
+// Create IPAM Config from CIDR strings
+// Either of the CIDRs can be empty
+func NewConfig(cidr4, cidr6 string) (config Config, err error) {
+	if len(cidr4) > 0 {
+		if _, config.IPv4, err = net.ParseCIDR(cidr4); err != nil {
+			return Config{}, fmt.Errorf("ipam: invalid ipv4 network(%s): %w", cidr4, err)
+		}
+		if config.IPv4.IP.To4() == nil {
+			return Config{}, fmt.Errorf("ipam: %s: %w", cidr4, ErrorInvalidNetwork)
+		}
+	}
+
+	if len(cidr6) > 0 {
+		if _, config.IPv6, err = net.ParseCIDR(cidr6); err != nil {
+			return Config{}, fmt.Errorf("ipam: invalid ipv6 network(%s): %w", cidr6, err)
+		}
+		if config.IPv6.IP.To4() != nil {
+			return Config{}, fmt.Errorf("ipam: %s: %w", cidr6, ErrorInvalidNetwork)
+		}
+	}
+
+	return
+}
+
+func (c *Config) init() (err error) {
+	if c.IPv4 == nil && c.IPv6 == nil {
+		return fmt.Errorf("ipam: at least one network is required: %w", ErrorInvalidNetwork)
+	}
+
+	if c.IPv4 != nil {
+		if ones, bits := c.IPv4.Mask.Size(); bits != net.IPv4len*8 || ones > 30 {
+			return fmt.Errorf("ipam: ipv4(%s): %w", c.IPv4.String(), ErrorInvalidNetwork)
+		}
+	}
+
+	if c.IPv6 != nil {
+		if ones, bits := c.IPv6.Mask.Size(); bits != net.IPv6len*8 || ones > 126 {
+			return fmt.Errorf("ipam: ipv6(%s): %w", c.IPv6.String(), ErrorInvalidNetwork)
+		}
+	}
+
+	if c.LeaseDuration == 0 {
+		c.LeaseDuration = DefaultLeaseDuration
+	}
+
+	if c.Reservations == nil {
+		c.Reservations = make(map[string][]net.IP)
+	}
+
+	return
+}
@@ -0,0 +1,2 @@
+// Package ipam provides functionality to manage overlay IP Address leases
+package ipam
@@ -0,0 +1,11 @@
+package ipam
+
+import "errors"
+
+var (
+	ErrorPoolExhausted  = errors.New("address pool exhausted")
+	ErrorConflict       = errors.New("address conflict")
+	ErrorOutOfRange     = errors.New("address out of range")
+	ErrorLeaseNotFound  = errors.New("lease not found")
+	ErrorInvalidNetwork = errors.New("invalid network")
+)
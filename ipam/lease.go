@@ -0,0 +1,47 @@
+package ipam
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// Lease binds overlay IP Addresses to a Client
+type Lease struct {
+	// Client ID
+	ClientId string `json:"clientId"`
+	// IPv4 Address
+	IPv4 net.IP `json:"ipv4,omitempty"`
+	// IPv6 Address
+	IPv6 net.IP `json:"ipv6,omitempty"`
+	// Lease is a static reservation
+	Static bool `json:"static"`
+	// Client holding the Lease is connected
+	Active bool `json:"active"`
+	// Time after which an inactive Lease is reclaimed
+	Expiry time.Time `json:"expiry"`
+}
+
+func (l *Lease) expired(now time.Time) bool {
+	return !l.Static && !l.Active && now.After(l.Expiry)
+}
+
+func (l *Lease) ips() (ips []net.IP) {
+	if l.IPv4 != nil {
+		ips = append(ips, l.IPv4)
+	}
+	if l.IPv6 != nil {
+		ips = append(ips, l.IPv6)
+	}
+	return
+}
+
+func (l *Lease) clone() *Lease {
+	c := *l
+	return &c
+}
+
+// Stringify
+func (l *Lease) String() string {
+	return fmt.Sprintf("client(%s) ipv4(%v) ipv6(%v) static(%v) active(%v)", l.ClientId, l.IPv4, l.IPv6, l.Static, l.Active)
+}
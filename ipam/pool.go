@@ -0,0 +1,380 @@
+package ipam
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/supergiant-hq/xnet/util"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Pool hands out overlay IP Addresses to Clients
+type Pool struct {
+	config Config
+
+	leases map[string]*Lease
+	// IP Address -> Client ID
+	addrs map[string]string
+
+	ticker *util.Ticker
+	mutex  sync.Mutex
+	log    *logrus.Entry
+}
+
+// Create a Pool
+// Leases are restored from the Store if one is configured
+func New(log *logrus.Logger, config Config) (p *Pool, err error) {
+	if err = config.init(); err != nil {
+		return
+	}
+
+	p = &Pool{
+		config: config,
+		leases: make(map[string]*Lease),
+		addrs:  make(map[string]string),
+		log:    log.WithField("prefix", "IPAM"),
+	}
+
+	for clientId, ips := range config.Reservations {
+		if err = p.reserve(clientId, ips); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = p.restore(); err != nil {
+		return nil, err
+	}
+
+	p.ticker = util.NewTicker(reapInterval, p.reap)
+	p.ticker.Start()
+
+	return
+}
+
+func (p *Pool) restore() (err error) {
+	if p.config.Store == nil {
+		return
+	}
+
+	leases, err := p.config.Store.Load()
+	if err != nil {
+		return fmt.Errorf("ipam: error loading leases: %w", err)
+	}
+
+	now := time.Now()
+	for _, lease := range leases {
+		if lease.expired(now) {
+			continue
+		}
+		// Reservations from the Config take precedence
+		if _, ok := p.leases[lease.ClientId]; ok {
+			continue
+		}
+
+		lease.Active = false
+		if !lease.Static && lease.Expiry.IsZero() {
+			lease.Expiry = now.Add(p.config.LeaseDuration)
+		}
+		if err := p.checkAvailable(lease.ClientId, lease.ips()); err != nil {
+			p.log.Warnf("Dropping lease %s: %v", lease.String(), err)
+			continue
+		}
+		p.bind(lease)
+	}
+
+	p.log.Infof("Restored %d leases", len(p.leases))
+	return
+}
+
+// Returns the Client's Lease, allocating one if needed, and marks it active
+func (p *Pool) Acquire(clientId string) (lease *Lease, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	l, ok := p.leases[clientId]
+	if !ok {
+		l = &Lease{ClientId: clientId}
+	}
+
+	// A reservation of one family is completed by a dynamic address of the other
+	ipv4, ipv6 := l.IPv4, l.IPv6
+	if p.config.IPv4 != nil && ipv4 == nil {
+		if ipv4, err = p.allocate(clientId, p.config.IPv4); err != nil {
+			return
+		}
+	}
+	if p.config.IPv6 != nil && ipv6 == nil {
+		if ipv6, err = p.allocate(clientId, p.config.IPv6); err != nil {
+			return
+		}
+	}
+	l.IPv4, l.IPv6 = ipv4, ipv6
+	l.Active = true
+	l.Expiry = time.Time{}
+	p.bind(l)
+	p.persist()
+
+	if !ok {
+		p.log.Infoln("Lease acquired:", l.String())
+	}
+	return l.clone(), nil
+}
+
+// Marks the Client's Lease as inactive
+// The addresses are reclaimed once the lease duration elapses
+func (p *Pool) Release(clientId string) (err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	l, ok := p.leases[clientId]
+	if !ok {
+		return fmt.Errorf("ipam: client(%s): %w", clientId, ErrorLeaseNotFound)
+	}
+
+	l.Active = false
+	l.Expiry = time.Now().Add(p.config.LeaseDuration)
+	p.persist()
+
+	p.log.Infoln("Lease released:", l.String())
+	return
+}
+
+// Reserve IP Addresses for a Client
+// Addresses held by inactive dynamic leases are reclaimed
+func (p *Pool) Reserve(clientId string, ips []net.IP) (err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if err = p.reserve(clientId, ips); err != nil {
+		return
+	}
+	p.persist()
+
+	return
+}
+
+func (p *Pool) reserve(clientId string, ips []net.IP) (err error) {
+	l := &Lease{
+		ClientId: clientId,
+		Static:   true,
+	}
+	for _, ip := range ips {
+		switch {
+		case p.config.IPv4 != nil && p.config.IPv4.Contains(ip) && ip.To4() != nil:
+			l.IPv4 = ip.To4()
+		case p.config.IPv6 != nil && p.config.IPv6.Contains(ip) && ip.To4() == nil:
+			l.IPv6 = ip.To16()
+		default:
+			return fmt.Errorf("ipam: ip(%s): %w", ip.String(), ErrorOutOfRange)
+		}
+	}
+
+	for _, ip := range l.ips() {
+		owner, ok := p.addrs[ip.String()]
+		if !ok || owner == clientId {
+			continue
+		}
+		if ol := p.leases[owner]; ol.Static || ol.Active {
+			return fmt.Errorf("ipam: ip(%s) held by client(%s): %w", ip.String(), owner, ErrorConflict)
+		}
+		p.unbind(owner)
+		p.log.Warnf("Reclaimed lease of client(%s) for reservation", owner)
+	}
+
+	if existing, ok := p.leases[clientId]; ok {
+		// Addresses of a family which is not reserved are kept
+		if l.IPv4 == nil {
+			l.IPv4 = existing.IPv4
+		}
+		if l.IPv6 == nil {
+			l.IPv6 = existing.IPv6
+		}
+		l.Active = existing.Active
+		p.unbind(clientId)
+	}
+	p.bind(l)
+
+	p.log.Infoln("Reserved:", l.String())
+	return
+}
+
+// Remove a Client's reservation
+// An active Client keeps its addresses as a dynamic lease
+func (p *Pool) Unreserve(clientId string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	l, ok := p.leases[clientId]
+	if !ok || !l.Static {
+		return
+	}
+
+	if l.Active {
+		l.Static = false
+	} else {
+		p.unbind(clientId)
+	}
+	p.persist()
+}
+
+// Get the Lease held by a Client
+func (p *Pool) Get(clientId string) (lease *Lease, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	l, ok := p.leases[clientId]
+	if !ok {
+		return nil, fmt.Errorf("ipam: client(%s): %w", clientId, ErrorLeaseNotFound)
+	}
+	return l.clone(), nil
+}
+
+// Get all Leases
+func (p *Pool) Leases() (leases []*Lease) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, l := range p.leases {
+		leases = append(leases, l.clone())
+	}
+	return
+}
+
+// Returns the Lease addresses with the prefix of their network
+func (p *Pool) Networks(lease *Lease) (networks []*net.IPNet) {
+	if lease.IPv4 != nil && p.config.IPv4 != nil {
+		networks = append(networks, &net.IPNet{IP: lease.IPv4, Mask: p.config.IPv4.Mask})
+	}
+	if lease.IPv6 != nil && p.config.IPv6 != nil {
+		networks = append(networks, &net.IPNet{IP: lease.IPv6, Mask: p.config.IPv6.Mask})
+	}
+	return
+}
+
+func (p *Pool) checkAvailable(clientId string, ips []net.IP) error {
+	for _, ip := range ips {
+		inRange := (p.config.IPv4 != nil && p.config.IPv4.Contains(ip)) ||
+			(p.config.IPv6 != nil && p.config.IPv6.Contains(ip))
+		if !inRange {
+			return fmt.Errorf("ipam: ip(%s): %w", ip.String(), ErrorOutOfRange)
+		}
+		if owner, ok := p.addrs[ip.String()]; ok && owner != clientId {
+			return fmt.Errorf("ipam: ip(%s) held by client(%s): %w", ip.String(), owner, ErrorConflict)
+		}
+	}
+	return nil
+}
+
+// Picks a free address starting at an offset derived from the Client ID
+// so that a Client tends to get the same address across lease expiry
+func (p *Pool) allocate(clientId string, network *net.IPNet) (ip net.IP, err error) {
+	ones, bits := network.Mask.Size()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	// Exclude the network address, and the broadcast address for IPv4
+	usable := new(big.Int).Sub(size, big.NewInt(1))
+	if bits == net.IPv4len*8 {
+		usable.Sub(usable, big.NewInt(1))
+	}
+
+	sum := sha256.Sum256([]byte(clientId))
+	start := new(big.Int).Mod(new(big.Int).SetBytes(sum[:]), usable)
+
+	base := new(big.Int).SetBytes(network.IP)
+	offset := new(big.Int).Set(start)
+	for tries := 0; tries <= len(p.addrs); tries++ {
+		candidate := new(big.Int).Add(base, new(big.Int).Add(offset, big.NewInt(1)))
+		ip = bigToIP(candidate, len(network.IP))
+		if _, taken := p.addrs[ip.String()]; !taken {
+			return
+		}
+
+		offset.Add(offset, big.NewInt(1))
+		if offset.Cmp(usable) >= 0 {
+			offset.SetInt64(0)
+		}
+		if offset.Cmp(start) == 0 {
+			break
+		}
+	}
+
+	return nil, fmt.Errorf("ipam: network(%s): %w", network.String(), ErrorPoolExhausted)
+}
+
+func bigToIP(n *big.Int, size int) net.IP {
+	b := n.Bytes()
+	ip := make(net.IP, size)
+	copy(ip[size-len(b):], b)
+	return ip
+}
+
+func (p *Pool) bind(l *Lease) {
+	p.leases[l.ClientId] = l
+	for _, ip := range l.ips() {
+		p.addrs[ip.String()] = l.ClientId
+	}
+}
+
+func (p *Pool) unbind(clientId string) {
+	l, ok := p.leases[clientId]
+	if !ok {
+		return
+	}
+	for _, ip := range l.ips() {
+		delete(p.addrs, ip.String())
+	}
+	delete(p.leases, clientId)
+}
+
+func (p *Pool) reap() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := time.Now()
+	reaped := 0
+	for clientId, l := range p.leases {
+		if l.expired(now) {
+			p.unbind(clientId)
+			reaped++
+		}
+	}
+
+	if reaped > 0 {
+		p.log.Infof("Reclaimed %d expired leases", reaped)
+		p.persist()
+	}
+}
+
+func (p *Pool) persist() {
+	if p.config.Store == nil {
+		return
+	}
+
+	leases := make([]*Lease, 0, len(p.leases))
+	for _, l := range p.leases {
+		leases = append(leases, l)
+	}
+	if err := p.config.Store.Save(leases); err != nil {
+		p.log.Errorln("Error saving leases:", err.Error())
+	}
+}
+
+// Stop reclaiming leases and persist the current state
+func (p *Pool) Close() {
+	p.ticker.Stop()
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, l := range p.leases {
+		if l.Active {
+			l.Active = false
+			l.Expiry = time.Now().Add(p.config.LeaseDuration)
+		}
+	}
+	p.persist()
+}
@@ -0,0 +1,344 @@
+package ipam
+
+import (
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newTestPool(t *testing.T, config Config) *Pool {
+	t.Helper()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	p, err := New(log, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.ticker.Stop)
+	return p
+}
+
+func mustConfig(t *testing.T, cidr4, cidr6 string) Config {
+	t.Helper()
+
+	config, err := NewConfig(cidr4, cidr6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func TestNewConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		cidr4   string
+		cidr6   string
+		wantErr bool
+	}{
+		{"ipv4", "10.0.0.0/24", "", false},
+		{"dual stack", "10.0.0.0/24", "fd00::/64", false},
+		{"malformed", "10.0.0.0/33", "", true},
+		{"ipv6 as ipv4", "fd00::/64", "", true},
+		{"ipv4 as ipv6", "", "10.0.0.0/24", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewConfig(tt.cidr4, tt.cidr6); (err != nil) != tt.wantErr {
+				t.Fatalf("NewConfig(%q, %q) err = %v, wantErr %v", tt.cidr4, tt.cidr6, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPoolAcquire(t *testing.T) {
+	p := newTestPool(t, mustConfig(t, "10.0.0.0/29", "fd00::/64"))
+
+	a, err := p.Acquire("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.config.IPv4.Contains(a.IPv4) || !p.config.IPv6.Contains(a.IPv6) || !a.Active {
+		t.Fatalf("unexpected lease: %s", a)
+	}
+
+	again, err := p.Acquire("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.IPv4.Equal(a.IPv4) || !again.IPv6.Equal(a.IPv6) {
+		t.Fatalf("lease changed: %s -> %s", a, again)
+	}
+
+	// A /29 has 6 usable addresses
+	seen := map[string]string{a.IPv4.String(): "a"}
+	for _, id := range []string{"b", "c", "d", "e", "f"} {
+		l, err := p.Acquire(id)
+		if err != nil {
+			t.Fatalf("acquire %s: %v", id, err)
+		}
+		if owner, ok := seen[l.IPv4.String()]; ok {
+			t.Fatalf("ip(%s) of %s already held by %s", l.IPv4, id, owner)
+		}
+		if l.IPv4.Equal(net.ParseIP("10.0.0.0")) || l.IPv4.Equal(net.ParseIP("10.0.0.7")) {
+			t.Fatalf("reserved address leased: %s", l.IPv4)
+		}
+		seen[l.IPv4.String()] = id
+	}
+
+	if _, err := p.Acquire("g"); !errors.Is(err, ErrorPoolExhausted) {
+		t.Fatalf("err = %v, want %v", err, ErrorPoolExhausted)
+	}
+}
+
+func TestPoolRelease(t *testing.T) {
+	config := mustConfig(t, "10.0.0.0/30", "")
+	config.LeaseDuration = time.Millisecond
+	p := newTestPool(t, config)
+
+	if err := p.Release("a"); !errors.Is(err, ErrorLeaseNotFound) {
+		t.Fatalf("err = %v, want %v", err, ErrorLeaseNotFound)
+	}
+
+	a, err := p.Acquire("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Acquire("b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Release("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := p.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Active || l.Expiry.IsZero() {
+		t.Fatalf("released lease still active: %s", l)
+	}
+
+	// The address is retained until the lease expires
+	if _, err := p.Acquire("c"); !errors.Is(err, ErrorPoolExhausted) {
+		t.Fatalf("err = %v, want %v", err, ErrorPoolExhausted)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	p.reap()
+
+	if _, err := p.Get("a"); !errors.Is(err, ErrorLeaseNotFound) {
+		t.Fatalf("err = %v, want %v", err, ErrorLeaseNotFound)
+	}
+	c, err := p.Acquire("c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.IPv4.Equal(a.IPv4) {
+		t.Fatalf("ip = %s, want reclaimed %s", c.IPv4, a.IPv4)
+	}
+}
+
+func TestPoolReserve(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(p *Pool)
+		ips     []net.IP
+		wantErr error
+	}{
+		{
+			name: "free address",
+			ips:  []net.IP{net.ParseIP("10.0.0.5"), net.ParseIP("fd00::5")},
+		},
+		{
+			name:    "out of range",
+			ips:     []net.IP{net.ParseIP("10.0.1.5")},
+			wantErr: ErrorOutOfRange,
+		},
+		{
+			name: "held by active lease",
+			setup: func(p *Pool) {
+				p.Acquire("b")
+			},
+			ips:     []net.IP{net.ParseIP("10.0.0.5")},
+			wantErr: ErrorConflict,
+		},
+		{
+			name: "held by reservation",
+			setup: func(p *Pool) {
+				p.Reserve("b", []net.IP{net.ParseIP("10.0.0.5")})
+			},
+			ips:     []net.IP{net.ParseIP("10.0.0.5")},
+			wantErr: ErrorConflict,
+		},
+		{
+			name: "reclaimed from inactive lease",
+			setup: func(p *Pool) {
+				p.Acquire("b")
+				p.Release("b")
+			},
+			ips: []net.IP{net.ParseIP("10.0.0.5")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPool(t, mustConfig(t, "10.0.0.0/24", "fd00::/64"))
+			if tt.setup != nil {
+				// The lease of b is placed on the reserved address
+				p.mutex.Lock()
+				p.bind(&Lease{ClientId: "b", IPv4: net.ParseIP("10.0.0.5").To4()})
+				p.mutex.Unlock()
+				tt.setup(p)
+			}
+
+			err := p.Reserve("a", tt.ips)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			l, err := p.Acquire("a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !l.Static || !l.IPv4.Equal(tt.ips[0]) {
+				t.Fatalf("unexpected lease: %s", l)
+			}
+		})
+	}
+}
+
+func TestPoolUnreserve(t *testing.T) {
+	p := newTestPool(t, mustConfig(t, "10.0.0.0/24", ""))
+	ips := []net.IP{net.ParseIP("10.0.0.5")}
+
+	if err := p.Reserve("a", ips); err != nil {
+		t.Fatal(err)
+	}
+	p.Unreserve("a")
+	if _, err := p.Get("a"); !errors.Is(err, ErrorLeaseNotFound) {
+		t.Fatalf("err = %v, want %v", err, ErrorLeaseNotFound)
+	}
+
+	// An active Client keeps its addresses
+	if err := p.Reserve("a", ips); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Acquire("a"); err != nil {
+		t.Fatal(err)
+	}
+	p.Unreserve("a")
+	l, err := p.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Static || !l.IPv4.Equal(ips[0]) {
+		t.Fatalf("unexpected lease: %s", l)
+	}
+}
+
+func TestPoolStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "leases.json")
+
+	config := mustConfig(t, "10.0.0.0/24", "fd00::/64")
+	config.Store = NewFileStore(path)
+	p := newTestPool(t, config)
+
+	a, err := p.Acquire("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Reserve("b", []net.IP{net.ParseIP("10.0.0.9")}); err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+
+	leases, err := NewFileStore(path).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 2 {
+		t.Fatalf("stored %d leases, want 2", len(leases))
+	}
+
+	restored := newTestPool(t, config)
+
+	ra, err := restored.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ra.IPv4.Equal(a.IPv4) || !ra.IPv6.Equal(a.IPv6) || ra.Active {
+		t.Fatalf("restored lease %s, want inactive %s", ra, a)
+	}
+
+	rb, err := restored.Get("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rb.Static || !rb.IPv4.Equal(net.ParseIP("10.0.0.9")) {
+		t.Fatalf("unexpected restored reservation: %s", rb)
+	}
+
+	// Restored addresses are not leased to other Clients
+	if owner := restored.addrs[a.IPv4.String()]; owner != "a" {
+		t.Fatalf("ip(%s) held by %q, want a", a.IPv4, owner)
+	}
+}
+
+func TestFileStoreMissing(t *testing.T) {
+	leases, err := NewFileStore(filepath.Join(t.TempDir(), "missing.json")).Load()
+	if err != nil || len(leases) != 0 {
+		t.Fatalf("Load() = %v, %v, want no leases", leases, err)
+	}
+}
+
+func TestPoolReserveFamily(t *testing.T) {
+	p := newTestPool(t, mustConfig(t, "10.0.0.0/24", "fd00::/64"))
+
+	a, err := p.Acquire("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The dynamic IPv6 address is kept by an IPv4 reservation
+	if err := p.Reserve("a", []net.IP{net.ParseIP("10.0.0.9")}); err != nil {
+		t.Fatal(err)
+	}
+	l, err := p.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.Static || !l.IPv4.Equal(net.ParseIP("10.0.0.9")) || !l.IPv6.Equal(a.IPv6) {
+		t.Fatalf("unexpected lease: %s", l)
+	}
+	if owner := p.addrs[a.IPv4.String()]; owner != "" {
+		t.Fatalf("previous ip(%s) held by %q", a.IPv4, owner)
+	}
+
+	// A Client reserved one family acquires an address of the other
+	if err := p.Reserve("b", []net.IP{net.ParseIP("fd00::b")}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := p.Acquire("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !b.Static || !b.IPv6.Equal(net.ParseIP("fd00::b")) || !p.config.IPv4.Contains(b.IPv4) {
+		t.Fatalf("unexpected lease: %s", b)
+	}
+	if owner := p.addrs[b.IPv4.String()]; owner != "b" {
+		t.Fatalf("ip(%s) held by %q, want b", b.IPv4, owner)
+	}
+}
@@ -0,0 +1,70 @@
+package ipam
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Store persists Leases across restarts
+type Store interface {
+	// Load all persisted Leases
+	Load() (leases []*Lease, err error)
+	// Replace the persisted Leases
+	Save(leases []*Lease) error
+}
+
+// FileStore persists Leases as JSON to a file
+type FileStore struct {
+	// File Path
+	Path string
+}
+
+// Create a FileStore
+func NewFileStore(path string) *FileStore {
+	return &FileStore{
+		Path: path,
+	}
+}
+
+// Load Leases from the file
+// A missing file yields no Leases
+func (s *FileStore) Load() (leases []*Lease, err error) {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+		return
+	}
+
+	err = json.Unmarshal(data, &leases)
+	return
+}
+
+// Save Leases to the file
+// The file is written atomically by renaming a temporary file
+func (s *FileStore) Save(leases []*Lease) (err error) {
+	data, err := json.MarshalIndent(leases, "", "  ")
+	if err != nil {
+		return
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return
+	}
+	if err = tmp.Close(); err != nil {
+		return
+	}
+
+	return os.Rename(tmp.Name(), s.Path)
+}
@@ -90,7 +90,8 @@ type ClientData struct {
 	//	*ClientData_BrokerCtx
 	//	*ClientData_RelayCtx
 	//	*ClientData_P2PCtx
-	Ctx isClientData_Ctx `protobuf_oneof:"ctx"`
+	Ctx              isClientData_Ctx  `protobuf_oneof:"ctx"`
+	OverlayAddresses []*OverlayAddress `protobuf:"bytes,10,rep,name=overlayAddresses,proto3" json:"overlayAddresses,omitempty"`
 }
 
 func (x *ClientData) Reset() {
@@ -195,6 +196,13 @@ func (x *ClientData) GetP2PCtx() *P2PClientContext {
 	return nil
 }
 
+func (x *ClientData) GetOverlayAddresses() []*OverlayAddress {
+	if x != nil {
+		return x.OverlayAddresses
+	}
+	return nil
+}
+
 type isClientData_Ctx interface {
 	isClientData_Ctx()
 }
@@ -203,45 +211,879 @@ type ClientData_BrokerCtx struct {
 	BrokerCtx *BrokerClientContext `protobuf:"bytes,7,opt,name=brokerCtx,proto3,oneof"`
 }
 
-type ClientData_RelayCtx struct {
-	RelayCtx *RelayClientContext `protobuf:"bytes,8,opt,name=relayCtx,proto3,oneof"`
+type ClientData_RelayCtx struct {
+	RelayCtx *RelayClientContext `protobuf:"bytes,8,opt,name=relayCtx,proto3,oneof"`
+}
+
+type ClientData_P2PCtx struct {
+	P2PCtx *P2PClientContext `protobuf:"bytes,9,opt,name=p2pCtx,proto3,oneof"`
+}
+
+func (*ClientData_BrokerCtx) isClientData_Ctx() {}
+
+func (*ClientData_RelayCtx) isClientData_Ctx() {}
+
+func (*ClientData_P2PCtx) isClientData_Ctx() {}
+
+type OverlayAddress struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Ip     string `protobuf:"bytes,1,opt,name=ip,proto3" json:"ip,omitempty"`
+	Prefix uint32 `protobuf:"varint,2,opt,name=prefix,proto3" json:"prefix,omitempty"`
+}
+
+func (x *OverlayAddress) Reset() {
+	*x = OverlayAddress{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_model_client_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *OverlayAddress) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OverlayAddress) ProtoMessage() {}
+
+func (x *OverlayAddress) ProtoReflect() protoreflect.Message {
+	mi := &file_model_client_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OverlayAddress.ProtoReflect.Descriptor instead.
+func (*OverlayAddress) Descriptor() ([]byte, []int) {
+	return file_model_client_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *OverlayAddress) GetIp() string {
+	if x != nil {
+		return x.Ip
+	}
+	return ""
+}
+
+func (x *OverlayAddress) GetPrefix() uint32 {
+	if x != nil {
+		return x.Prefix
+	}
+	return 0
+}
+
+type ClientPing struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Time string `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
+	Rtt  int64  `protobuf:"varint,2,opt,name=rtt,proto3" json:"rtt,omitempty"`
+	Load uint32 `protobuf:"varint,3,opt,name=load,proto3" json:"load,omitempty"`
+}
+
+func (x *ClientPing) Reset() {
+	*x = ClientPing{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_model_client_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClientPing) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientPing) ProtoMessage() {}
+
+func (x *ClientPing) ProtoReflect() protoreflect.Message {
+	mi := &file_model_client_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientPing.ProtoReflect.Descriptor instead.
+func (*ClientPing) Descriptor() ([]byte, []int) {
+	return file_model_client_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ClientPing) GetTime() string {
+	if x != nil {
+		return x.Time
+	}
+	return ""
+}
+
+func (x *ClientPing) GetRtt() int64 {
+	if x != nil {
+		return x.Rtt
+	}
+	return 0
+}
+
+func (x *ClientPing) GetLoad() uint32 {
+	if x != nil {
+		return x.Load
+	}
+	return 0
+}
+
+type ClientSearch struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id  string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Tag string `protobuf:"bytes,2,opt,name=tag,proto3" json:"tag,omitempty"`
+}
+
+func (x *ClientSearch) Reset() {
+	*x = ClientSearch{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_model_client_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClientSearch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientSearch) ProtoMessage() {}
+
+func (x *ClientSearch) ProtoReflect() protoreflect.Message {
+	mi := &file_model_client_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientSearch.ProtoReflect.Descriptor instead.
+func (*ClientSearch) Descriptor() ([]byte, []int) {
+	return file_model_client_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ClientSearch) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ClientSearch) GetTag() string {
+	if x != nil {
+		return x.Tag
+	}
+	return ""
+}
+
+type Clients struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Status  bool           `protobuf:"varint,1,opt,name=status,proto3" json:"status,omitempty"`
+	Message string         `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Clients []string       `protobuf:"bytes,3,rep,name=clients,proto3" json:"clients,omitempty"`
+	Matches []*ClientMatch `protobuf:"bytes,4,rep,name=matches,proto3" json:"matches,omitempty"`
+}
+
+func (x *Clients) Reset() {
+	*x = Clients{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_model_client_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Clients) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Clients) ProtoMessage() {}
+
+func (x *Clients) ProtoReflect() protoreflect.Message {
+	mi := &file_model_client_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Clients.ProtoReflect.Descriptor instead.
+func (*Clients) Descriptor() ([]byte, []int) {
+	return file_model_client_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Clients) GetStatus() bool {
+	if x != nil {
+		return x.Status
+	}
+	return false
+}
+
+func (x *Clients) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Clients) GetClients() []string {
+	if x != nil {
+		return x.Clients
+	}
+	return nil
+}
+
+func (x *Clients) GetMatches() []*ClientMatch {
+	if x != nil {
+		return x.Matches
+	}
+	return nil
+}
+
+type ClientMatch struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id      string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Address string `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	Load    uint32 `protobuf:"varint,3,opt,name=load,proto3" json:"load,omitempty"`
+}
+
+func (x *ClientMatch) Reset() {
+	*x = ClientMatch{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_model_client_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClientMatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientMatch) ProtoMessage() {}
+
+func (x *ClientMatch) ProtoReflect() protoreflect.Message {
+	mi := &file_model_client_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientMatch.ProtoReflect.Descriptor instead.
+func (*ClientMatch) Descriptor() ([]byte, []int) {
+	return file_model_client_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ClientMatch) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ClientMatch) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *ClientMatch) GetLoad() uint32 {
+	if x != nil {
+		return x.Load
+	}
+	return 0
+}
+
+type ClientRecord struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id               string            `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Address          string            `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	Tags             map[string]string `protobuf:"bytes,3,rep,name=tags,proto3" json:"tags,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	OverlayAddresses []*OverlayAddress `protobuf:"bytes,4,rep,name=overlayAddresses,proto3" json:"overlayAddresses,omitempty"`
+	Services         map[string]uint32 `protobuf:"bytes,5,rep,name=services,proto3" json:"services,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+	Routes           []*SubnetRoute    `protobuf:"bytes,6,rep,name=routes,proto3" json:"routes,omitempty"`
+	Exit             bool              `protobuf:"varint,7,opt,name=exit,proto3" json:"exit,omitempty"`
+}
+
+func (x *ClientRecord) Reset() {
+	*x = ClientRecord{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_model_client_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClientRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientRecord) ProtoMessage() {}
+
+func (x *ClientRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_model_client_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientRecord.ProtoReflect.Descriptor instead.
+func (*ClientRecord) Descriptor() ([]byte, []int) {
+	return file_model_client_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ClientRecord) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ClientRecord) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *ClientRecord) GetTags() map[string]string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *ClientRecord) GetOverlayAddresses() []*OverlayAddress {
+	if x != nil {
+		return x.OverlayAddresses
+	}
+	return nil
+}
+
+func (x *ClientRecord) GetServices() map[string]uint32 {
+	if x != nil {
+		return x.Services
+	}
+	return nil
+}
+
+func (x *ClientRecord) GetRoutes() []*SubnetRoute {
+	if x != nil {
+		return x.Routes
+	}
+	return nil
+}
+
+func (x *ClientRecord) GetExit() bool {
+	if x != nil {
+		return x.Exit
+	}
+	return false
+}
+
+type ClientRecordsQuery struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Subscribe bool `protobuf:"varint,1,opt,name=subscribe,proto3" json:"subscribe,omitempty"`
+}
+
+func (x *ClientRecordsQuery) Reset() {
+	*x = ClientRecordsQuery{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_model_client_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClientRecordsQuery) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientRecordsQuery) ProtoMessage() {}
+
+func (x *ClientRecordsQuery) ProtoReflect() protoreflect.Message {
+	mi := &file_model_client_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientRecordsQuery.ProtoReflect.Descriptor instead.
+func (*ClientRecordsQuery) Descriptor() ([]byte, []int) {
+	return file_model_client_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ClientRecordsQuery) GetSubscribe() bool {
+	if x != nil {
+		return x.Subscribe
+	}
+	return false
+}
+
+type ClientRecords struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Status  bool            `protobuf:"varint,1,opt,name=status,proto3" json:"status,omitempty"`
+	Message string          `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Full    bool            `protobuf:"varint,3,opt,name=full,proto3" json:"full,omitempty"`
+	Records []*ClientRecord `protobuf:"bytes,4,rep,name=records,proto3" json:"records,omitempty"`
+	Removed []string        `protobuf:"bytes,5,rep,name=removed,proto3" json:"removed,omitempty"`
+}
+
+func (x *ClientRecords) Reset() {
+	*x = ClientRecords{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_model_client_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClientRecords) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientRecords) ProtoMessage() {}
+
+func (x *ClientRecords) ProtoReflect() protoreflect.Message {
+	mi := &file_model_client_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientRecords.ProtoReflect.Descriptor instead.
+func (*ClientRecords) Descriptor() ([]byte, []int) {
+	return file_model_client_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ClientRecords) GetStatus() bool {
+	if x != nil {
+		return x.Status
+	}
+	return false
+}
+
+func (x *ClientRecords) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *ClientRecords) GetFull() bool {
+	if x != nil {
+		return x.Full
+	}
+	return false
+}
+
+func (x *ClientRecords) GetRecords() []*ClientRecord {
+	if x != nil {
+		return x.Records
+	}
+	return nil
+}
+
+func (x *ClientRecords) GetRemoved() []string {
+	if x != nil {
+		return x.Removed
+	}
+	return nil
+}
+
+type SubnetRoute struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Network  string `protobuf:"bytes,1,opt,name=network,proto3" json:"network,omitempty"`
+	Priority uint32 `protobuf:"varint,2,opt,name=priority,proto3" json:"priority,omitempty"`
+}
+
+func (x *SubnetRoute) Reset() {
+	*x = SubnetRoute{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_model_client_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SubnetRoute) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubnetRoute) ProtoMessage() {}
+
+func (x *SubnetRoute) ProtoReflect() protoreflect.Message {
+	mi := &file_model_client_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubnetRoute.ProtoReflect.Descriptor instead.
+func (*SubnetRoute) Descriptor() ([]byte, []int) {
+	return file_model_client_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *SubnetRoute) GetNetwork() string {
+	if x != nil {
+		return x.Network
+	}
+	return ""
+}
+
+func (x *SubnetRoute) GetPriority() uint32 {
+	if x != nil {
+		return x.Priority
+	}
+	return 0
+}
+
+type ClientRoutes struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Routes []*SubnetRoute `protobuf:"bytes,1,rep,name=routes,proto3" json:"routes,omitempty"`
+	Exit   bool           `protobuf:"varint,2,opt,name=exit,proto3" json:"exit,omitempty"`
+}
+
+func (x *ClientRoutes) Reset() {
+	*x = ClientRoutes{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_model_client_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClientRoutes) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientRoutes) ProtoMessage() {}
+
+func (x *ClientRoutes) ProtoReflect() protoreflect.Message {
+	mi := &file_model_client_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientRoutes.ProtoReflect.Descriptor instead.
+func (*ClientRoutes) Descriptor() ([]byte, []int) {
+	return file_model_client_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ClientRoutes) GetRoutes() []*SubnetRoute {
+	if x != nil {
+		return x.Routes
+	}
+	return nil
+}
+
+func (x *ClientRoutes) GetExit() bool {
+	if x != nil {
+		return x.Exit
+	}
+	return false
+}
+
+type RouteConflict struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Network string `protobuf:"bytes,1,opt,name=network,proto3" json:"network,omitempty"`
+	Reason  string `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+}
+
+func (x *RouteConflict) Reset() {
+	*x = RouteConflict{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_model_client_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RouteConflict) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RouteConflict) ProtoMessage() {}
+
+func (x *RouteConflict) ProtoReflect() protoreflect.Message {
+	mi := &file_model_client_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RouteConflict.ProtoReflect.Descriptor instead.
+func (*RouteConflict) Descriptor() ([]byte, []int) {
+	return file_model_client_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *RouteConflict) GetNetwork() string {
+	if x != nil {
+		return x.Network
+	}
+	return ""
+}
+
+func (x *RouteConflict) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type ClientRoutesStatus struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Status    bool             `protobuf:"varint,1,opt,name=status,proto3" json:"status,omitempty"`
+	Message   string           `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Accepted  []*SubnetRoute   `protobuf:"bytes,3,rep,name=accepted,proto3" json:"accepted,omitempty"`
+	Conflicts []*RouteConflict `protobuf:"bytes,4,rep,name=conflicts,proto3" json:"conflicts,omitempty"`
+	Exit      bool             `protobuf:"varint,5,opt,name=exit,proto3" json:"exit,omitempty"`
+}
+
+func (x *ClientRoutesStatus) Reset() {
+	*x = ClientRoutesStatus{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_model_client_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClientRoutesStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientRoutesStatus) ProtoMessage() {}
+
+func (x *ClientRoutesStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_model_client_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientRoutesStatus.ProtoReflect.Descriptor instead.
+func (*ClientRoutesStatus) Descriptor() ([]byte, []int) {
+	return file_model_client_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ClientRoutesStatus) GetStatus() bool {
+	if x != nil {
+		return x.Status
+	}
+	return false
+}
+
+func (x *ClientRoutesStatus) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *ClientRoutesStatus) GetAccepted() []*SubnetRoute {
+	if x != nil {
+		return x.Accepted
+	}
+	return nil
+}
+
+func (x *ClientRoutesStatus) GetConflicts() []*RouteConflict {
+	if x != nil {
+		return x.Conflicts
+	}
+	return nil
+}
+
+func (x *ClientRoutesStatus) GetExit() bool {
+	if x != nil {
+		return x.Exit
+	}
+	return false
+}
+
+type PortRange struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Start uint32 `protobuf:"varint,1,opt,name=start,proto3" json:"start,omitempty"`
+	End   uint32 `protobuf:"varint,2,opt,name=end,proto3" json:"end,omitempty"`
+}
+
+func (x *PortRange) Reset() {
+	*x = PortRange{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_model_client_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PortRange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PortRange) ProtoMessage() {}
+
+func (x *PortRange) ProtoReflect() protoreflect.Message {
+	mi := &file_model_client_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PortRange.ProtoReflect.Descriptor instead.
+func (*PortRange) Descriptor() ([]byte, []int) {
+	return file_model_client_proto_rawDescGZIP(), []int{14}
 }
 
-type ClientData_P2PCtx struct {
-	P2PCtx *P2PClientContext `protobuf:"bytes,9,opt,name=p2pCtx,proto3,oneof"`
+func (x *PortRange) GetStart() uint32 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
 }
 
-func (*ClientData_BrokerCtx) isClientData_Ctx() {}
-
-func (*ClientData_RelayCtx) isClientData_Ctx() {}
-
-func (*ClientData_P2PCtx) isClientData_Ctx() {}
+func (x *PortRange) GetEnd() uint32 {
+	if x != nil {
+		return x.End
+	}
+	return 0
+}
 
-type ClientPing struct {
+type FilterRule struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Time string `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
+	Allow        bool         `protobuf:"varint,1,opt,name=allow,proto3" json:"allow,omitempty"`
+	Peers        []string     `protobuf:"bytes,2,rep,name=peers,proto3" json:"peers,omitempty"`
+	Tags         []string     `protobuf:"bytes,3,rep,name=tags,proto3" json:"tags,omitempty"`
+	Sources      []string     `protobuf:"bytes,4,rep,name=sources,proto3" json:"sources,omitempty"`
+	Destinations []string     `protobuf:"bytes,5,rep,name=destinations,proto3" json:"destinations,omitempty"`
+	Protocol     string       `protobuf:"bytes,6,opt,name=protocol,proto3" json:"protocol,omitempty"`
+	Ports        []*PortRange `protobuf:"bytes,7,rep,name=ports,proto3" json:"ports,omitempty"`
+	Log          bool         `protobuf:"varint,8,opt,name=log,proto3" json:"log,omitempty"`
 }
 
-func (x *ClientPing) Reset() {
-	*x = ClientPing{}
+func (x *FilterRule) Reset() {
+	*x = FilterRule{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_model_client_proto_msgTypes[2]
+		mi := &file_model_client_proto_msgTypes[15]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *ClientPing) String() string {
+func (x *FilterRule) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ClientPing) ProtoMessage() {}
+func (*FilterRule) ProtoMessage() {}
 
-func (x *ClientPing) ProtoReflect() protoreflect.Message {
-	mi := &file_model_client_proto_msgTypes[2]
+func (x *FilterRule) ProtoReflect() protoreflect.Message {
+	mi := &file_model_client_proto_msgTypes[15]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -252,44 +1094,90 @@ func (x *ClientPing) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ClientPing.ProtoReflect.Descriptor instead.
-func (*ClientPing) Descriptor() ([]byte, []int) {
-	return file_model_client_proto_rawDescGZIP(), []int{2}
+// Deprecated: Use FilterRule.ProtoReflect.Descriptor instead.
+func (*FilterRule) Descriptor() ([]byte, []int) {
+	return file_model_client_proto_rawDescGZIP(), []int{15}
 }
 
-func (x *ClientPing) GetTime() string {
+func (x *FilterRule) GetAllow() bool {
 	if x != nil {
-		return x.Time
+		return x.Allow
+	}
+	return false
+}
+
+func (x *FilterRule) GetPeers() []string {
+	if x != nil {
+		return x.Peers
+	}
+	return nil
+}
+
+func (x *FilterRule) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *FilterRule) GetSources() []string {
+	if x != nil {
+		return x.Sources
+	}
+	return nil
+}
+
+func (x *FilterRule) GetDestinations() []string {
+	if x != nil {
+		return x.Destinations
+	}
+	return nil
+}
+
+func (x *FilterRule) GetProtocol() string {
+	if x != nil {
+		return x.Protocol
 	}
 	return ""
 }
 
-type ClientSearch struct {
+func (x *FilterRule) GetPorts() []*PortRange {
+	if x != nil {
+		return x.Ports
+	}
+	return nil
+}
+
+func (x *FilterRule) GetLog() bool {
+	if x != nil {
+		return x.Log
+	}
+	return false
+}
+
+type FilterPolicyQuery struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
-
-	Id  string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Tag string `protobuf:"bytes,2,opt,name=tag,proto3" json:"tag,omitempty"`
 }
 
-func (x *ClientSearch) Reset() {
-	*x = ClientSearch{}
+func (x *FilterPolicyQuery) Reset() {
+	*x = FilterPolicyQuery{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_model_client_proto_msgTypes[3]
+		mi := &file_model_client_proto_msgTypes[16]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *ClientSearch) String() string {
+func (x *FilterPolicyQuery) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ClientSearch) ProtoMessage() {}
+func (*FilterPolicyQuery) ProtoMessage() {}
 
-func (x *ClientSearch) ProtoReflect() protoreflect.Message {
-	mi := &file_model_client_proto_msgTypes[3]
+func (x *FilterPolicyQuery) ProtoReflect() protoreflect.Message {
+	mi := &file_model_client_proto_msgTypes[16]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -300,52 +1188,41 @@ func (x *ClientSearch) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ClientSearch.ProtoReflect.Descriptor instead.
-func (*ClientSearch) Descriptor() ([]byte, []int) {
-	return file_model_client_proto_rawDescGZIP(), []int{3}
-}
-
-func (x *ClientSearch) GetId() string {
-	if x != nil {
-		return x.Id
-	}
-	return ""
-}
-
-func (x *ClientSearch) GetTag() string {
-	if x != nil {
-		return x.Tag
-	}
-	return ""
+// Deprecated: Use FilterPolicyQuery.ProtoReflect.Descriptor instead.
+func (*FilterPolicyQuery) Descriptor() ([]byte, []int) {
+	return file_model_client_proto_rawDescGZIP(), []int{16}
 }
 
-type Clients struct {
+type FilterPolicy struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Status  bool     `protobuf:"varint,1,opt,name=status,proto3" json:"status,omitempty"`
-	Message string   `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
-	Clients []string `protobuf:"bytes,3,rep,name=clients,proto3" json:"clients,omitempty"`
+	Status       bool          `protobuf:"varint,1,opt,name=status,proto3" json:"status,omitempty"`
+	Message      string        `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Enabled      bool          `protobuf:"varint,3,opt,name=enabled,proto3" json:"enabled,omitempty"`
+	Rules        []*FilterRule `protobuf:"bytes,4,rep,name=rules,proto3" json:"rules,omitempty"`
+	DefaultAllow bool          `protobuf:"varint,5,opt,name=defaultAllow,proto3" json:"defaultAllow,omitempty"`
+	LogDenied    bool          `protobuf:"varint,6,opt,name=logDenied,proto3" json:"logDenied,omitempty"`
 }
 
-func (x *Clients) Reset() {
-	*x = Clients{}
+func (x *FilterPolicy) Reset() {
+	*x = FilterPolicy{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_model_client_proto_msgTypes[4]
+		mi := &file_model_client_proto_msgTypes[17]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *Clients) String() string {
+func (x *FilterPolicy) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Clients) ProtoMessage() {}
+func (*FilterPolicy) ProtoMessage() {}
 
-func (x *Clients) ProtoReflect() protoreflect.Message {
-	mi := &file_model_client_proto_msgTypes[4]
+func (x *FilterPolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_model_client_proto_msgTypes[17]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -356,32 +1233,53 @@ func (x *Clients) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Clients.ProtoReflect.Descriptor instead.
-func (*Clients) Descriptor() ([]byte, []int) {
-	return file_model_client_proto_rawDescGZIP(), []int{4}
+// Deprecated: Use FilterPolicy.ProtoReflect.Descriptor instead.
+func (*FilterPolicy) Descriptor() ([]byte, []int) {
+	return file_model_client_proto_rawDescGZIP(), []int{17}
 }
 
-func (x *Clients) GetStatus() bool {
+func (x *FilterPolicy) GetStatus() bool {
 	if x != nil {
 		return x.Status
 	}
 	return false
 }
 
-func (x *Clients) GetMessage() string {
+func (x *FilterPolicy) GetMessage() string {
 	if x != nil {
 		return x.Message
 	}
 	return ""
 }
 
-func (x *Clients) GetClients() []string {
+func (x *FilterPolicy) GetEnabled() bool {
 	if x != nil {
-		return x.Clients
+		return x.Enabled
+	}
+	return false
+}
+
+func (x *FilterPolicy) GetRules() []*FilterRule {
+	if x != nil {
+		return x.Rules
 	}
 	return nil
 }
 
+func (x *FilterPolicy) GetDefaultAllow() bool {
+	if x != nil {
+		return x.DefaultAllow
+	}
+	return false
+}
+
+func (x *FilterPolicy) GetLogDenied() bool {
+	if x != nil {
+		return x.LogDenied
+	}
+	return false
+}
+
 var File_model_client_proto protoreflect.FileDescriptor
 
 var file_model_client_proto_rawDesc = []byte{
@@ -400,7 +1298,7 @@ var file_model_client_proto_rawDesc = []byte{
 	0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20,
 	0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75,
 	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02,
-	0x38, 0x01, 0x22, 0xeb, 0x03, 0x0a, 0x0a, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x44, 0x61, 0x74,
+	0x38, 0x01, 0x22, 0xae, 0x04, 0x0a, 0x0a, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x44, 0x61, 0x74,
 	0x61, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28,
 	0x08, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73,
 	0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73,
@@ -423,26 +1321,141 @@ var file_model_client_proto_rawDesc = []byte{
 	0x79, 0x43, 0x74, 0x78, 0x12, 0x31, 0x0a, 0x06, 0x70, 0x32, 0x70, 0x43, 0x74, 0x78, 0x18, 0x09,
 	0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x2e, 0x50, 0x32, 0x50,
 	0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x48, 0x00, 0x52,
-	0x06, 0x70, 0x32, 0x70, 0x43, 0x74, 0x78, 0x1a, 0x37, 0x0a, 0x09, 0x54, 0x61, 0x67, 0x73, 0x45,
+	0x06, 0x70, 0x32, 0x70, 0x43, 0x74, 0x78, 0x12, 0x41, 0x0a, 0x10, 0x6f, 0x76, 0x65, 0x72, 0x6c,
+	0x61, 0x79, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x65, 0x73, 0x18, 0x0a, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x15, 0x2e, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x2e, 0x4f, 0x76, 0x65, 0x72, 0x6c, 0x61,
+	0x79, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x52, 0x10, 0x6f, 0x76, 0x65, 0x72, 0x6c, 0x61,
+	0x79, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x65, 0x73, 0x1a, 0x37, 0x0a, 0x09, 0x54, 0x61,
+	0x67, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a,
+	0x02, 0x38, 0x01, 0x1a, 0x37, 0x0a, 0x09, 0x44, 0x61, 0x74, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79,
+	0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b,
+	0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x42, 0x05, 0x0a, 0x03,
+	0x63, 0x74, 0x78, 0x22, 0x38, 0x0a, 0x0e, 0x4f, 0x76, 0x65, 0x72, 0x6c, 0x61, 0x79, 0x41, 0x64,
+	0x64, 0x72, 0x65, 0x73, 0x73, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x02, 0x69, 0x70, 0x12, 0x16, 0x0a, 0x06, 0x70, 0x72, 0x65, 0x66, 0x69, 0x78, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x06, 0x70, 0x72, 0x65, 0x66, 0x69, 0x78, 0x22, 0x46, 0x0a,
+	0x0a, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x50, 0x69, 0x6e, 0x67, 0x12, 0x12, 0x0a, 0x04, 0x74,
+	0x69, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x12,
+	0x10, 0x0a, 0x03, 0x72, 0x74, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x03, 0x72, 0x74,
+	0x74, 0x12, 0x12, 0x0a, 0x04, 0x6c, 0x6f, 0x61, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52,
+	0x04, 0x6c, 0x6f, 0x61, 0x64, 0x22, 0x30, 0x0a, 0x0c, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x53,
+	0x65, 0x61, 0x72, 0x63, 0x68, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x10, 0x0a, 0x03, 0x74, 0x61, 0x67, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x03, 0x74, 0x61, 0x67, 0x22, 0x83, 0x01, 0x0a, 0x07, 0x43, 0x6c, 0x69, 0x65,
+	0x6e, 0x74, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x6d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65,
+	0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x73,
+	0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x07, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x73, 0x12,
+	0x2c, 0x0a, 0x07, 0x6d, 0x61, 0x74, 0x63, 0x68, 0x65, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x12, 0x2e, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4d,
+	0x61, 0x74, 0x63, 0x68, 0x52, 0x07, 0x6d, 0x61, 0x74, 0x63, 0x68, 0x65, 0x73, 0x22, 0x4b, 0x0a,
+	0x0b, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4d, 0x61, 0x74, 0x63, 0x68, 0x12, 0x0e, 0x0a, 0x02,
+	0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x18, 0x0a, 0x07,
+	0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x61,
+	0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x6c, 0x6f, 0x61, 0x64, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x6c, 0x6f, 0x61, 0x64, 0x22, 0xa3, 0x03, 0x0a, 0x0c, 0x43,
+	0x6c, 0x69, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x12, 0x0e, 0x0a, 0x02, 0x69,
+	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x61,
+	0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x61, 0x64,
+	0x64, 0x72, 0x65, 0x73, 0x73, 0x12, 0x31, 0x0a, 0x04, 0x74, 0x61, 0x67, 0x73, 0x18, 0x03, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x2e, 0x43, 0x6c, 0x69, 0x65,
+	0x6e, 0x74, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x2e, 0x54, 0x61, 0x67, 0x73, 0x45, 0x6e, 0x74,
+	0x72, 0x79, 0x52, 0x04, 0x74, 0x61, 0x67, 0x73, 0x12, 0x41, 0x0a, 0x10, 0x6f, 0x76, 0x65, 0x72,
+	0x6c, 0x61, 0x79, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x65, 0x73, 0x18, 0x04, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x15, 0x2e, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x2e, 0x4f, 0x76, 0x65, 0x72, 0x6c,
+	0x61, 0x79, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x52, 0x10, 0x6f, 0x76, 0x65, 0x72, 0x6c,
+	0x61, 0x79, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x65, 0x73, 0x12, 0x3d, 0x0a, 0x08, 0x73,
+	0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x18, 0x05, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x21, 0x2e,
+	0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x63, 0x6f,
+	0x72, 0x64, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79,
+	0x52, 0x08, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x12, 0x2a, 0x0a, 0x06, 0x72, 0x6f,
+	0x75, 0x74, 0x65, 0x73, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x6d, 0x6f, 0x64,
+	0x65, 0x6c, 0x2e, 0x53, 0x75, 0x62, 0x6e, 0x65, 0x74, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x52, 0x06,
+	0x72, 0x6f, 0x75, 0x74, 0x65, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x65, 0x78, 0x69, 0x74, 0x18, 0x07,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x04, 0x65, 0x78, 0x69, 0x74, 0x1a, 0x37, 0x0a, 0x09, 0x54, 0x61,
+	0x67, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a,
+	0x02, 0x38, 0x01, 0x1a, 0x3b, 0x0a, 0x0d, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x73, 0x45,
 	0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28,
 	0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18,
-	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01,
-	0x1a, 0x37, 0x0a, 0x09, 0x44, 0x61, 0x74, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a,
-	0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12,
-	0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05,
-	0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x42, 0x05, 0x0a, 0x03, 0x63, 0x74, 0x78,
-	0x22, 0x20, 0x0a, 0x0a, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x50, 0x69, 0x6e, 0x67, 0x12, 0x12,
-	0x0a, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x69,
-	0x6d, 0x65, 0x22, 0x30, 0x0a, 0x0c, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x53, 0x65, 0x61, 0x72,
-	0x63, 0x68, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02,
-	0x69, 0x64, 0x12, 0x10, 0x0a, 0x03, 0x74, 0x61, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x03, 0x74, 0x61, 0x67, 0x22, 0x55, 0x0a, 0x07, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x73, 0x12,
-	0x16, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52,
-	0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61,
-	0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67,
-	0x65, 0x12, 0x18, 0x0a, 0x07, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x03, 0x20, 0x03,
-	0x28, 0x09, 0x52, 0x07, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x73, 0x42, 0x08, 0x5a, 0x06, 0x2f,
-	0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01,
+	0x22, 0x32, 0x0a, 0x12, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64,
+	0x73, 0x51, 0x75, 0x65, 0x72, 0x79, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x75, 0x62, 0x73, 0x63, 0x72,
+	0x69, 0x62, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x73, 0x75, 0x62, 0x73, 0x63,
+	0x72, 0x69, 0x62, 0x65, 0x22, 0x9e, 0x01, 0x0a, 0x0d, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x52,
+	0x65, 0x63, 0x6f, 0x72, 0x64, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x18,
+	0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x66, 0x75, 0x6c, 0x6c,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x04, 0x66, 0x75, 0x6c, 0x6c, 0x12, 0x2d, 0x0a, 0x07,
+	0x72, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x13, 0x2e,
+	0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x63, 0x6f,
+	0x72, 0x64, 0x52, 0x07, 0x72, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x72,
+	0x65, 0x6d, 0x6f, 0x76, 0x65, 0x64, 0x18, 0x05, 0x20, 0x03, 0x28, 0x09, 0x52, 0x07, 0x72, 0x65,
+	0x6d, 0x6f, 0x76, 0x65, 0x64, 0x22, 0x43, 0x0a, 0x0b, 0x53, 0x75, 0x62, 0x6e, 0x65, 0x74, 0x52,
+	0x6f, 0x75, 0x74, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x6e, 0x65, 0x74, 0x77, 0x6f, 0x72, 0x6b, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6e, 0x65, 0x74, 0x77, 0x6f, 0x72, 0x6b, 0x12, 0x1a,
+	0x0a, 0x08, 0x70, 0x72, 0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x08, 0x70, 0x72, 0x69, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x22, 0x4e, 0x0a, 0x0c, 0x43, 0x6c,
+	0x69, 0x65, 0x6e, 0x74, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x73, 0x12, 0x2a, 0x0a, 0x06, 0x72, 0x6f,
+	0x75, 0x74, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x6d, 0x6f, 0x64,
+	0x65, 0x6c, 0x2e, 0x53, 0x75, 0x62, 0x6e, 0x65, 0x74, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x52, 0x06,
+	0x72, 0x6f, 0x75, 0x74, 0x65, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x65, 0x78, 0x69, 0x74, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x04, 0x65, 0x78, 0x69, 0x74, 0x22, 0x41, 0x0a, 0x0d, 0x52, 0x6f,
+	0x75, 0x74, 0x65, 0x43, 0x6f, 0x6e, 0x66, 0x6c, 0x69, 0x63, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x6e,
+	0x65, 0x74, 0x77, 0x6f, 0x72, 0x6b, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6e, 0x65,
+	0x74, 0x77, 0x6f, 0x72, 0x6b, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x22, 0xbe, 0x01,
+	0x0a, 0x12, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x73, 0x53, 0x74,
+	0x61, 0x74, 0x75, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x18, 0x0a, 0x07,
+	0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x2e, 0x0a, 0x08, 0x61, 0x63, 0x63, 0x65, 0x70, 0x74,
+	0x65, 0x64, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x6d, 0x6f, 0x64, 0x65, 0x6c,
+	0x2e, 0x53, 0x75, 0x62, 0x6e, 0x65, 0x74, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x52, 0x08, 0x61, 0x63,
+	0x63, 0x65, 0x70, 0x74, 0x65, 0x64, 0x12, 0x32, 0x0a, 0x09, 0x63, 0x6f, 0x6e, 0x66, 0x6c, 0x69,
+	0x63, 0x74, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x6d, 0x6f, 0x64, 0x65,
+	0x6c, 0x2e, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x43, 0x6f, 0x6e, 0x66, 0x6c, 0x69, 0x63, 0x74, 0x52,
+	0x09, 0x63, 0x6f, 0x6e, 0x66, 0x6c, 0x69, 0x63, 0x74, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x65, 0x78,
+	0x69, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x04, 0x65, 0x78, 0x69, 0x74, 0x22, 0x33,
+	0x0a, 0x09, 0x50, 0x6f, 0x72, 0x74, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x73,
+	0x74, 0x61, 0x72, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x73, 0x74, 0x61, 0x72,
+	0x74, 0x12, 0x10, 0x0a, 0x03, 0x65, 0x6e, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x03,
+	0x65, 0x6e, 0x64, 0x22, 0xe0, 0x01, 0x0a, 0x0a, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x52, 0x75,
+	0x6c, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x61, 0x6c, 0x6c, 0x6f, 0x77, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x08, 0x52, 0x05, 0x61, 0x6c, 0x6c, 0x6f, 0x77, 0x12, 0x14, 0x0a, 0x05, 0x70, 0x65, 0x65, 0x72,
+	0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x05, 0x70, 0x65, 0x65, 0x72, 0x73, 0x12, 0x12,
+	0x0a, 0x04, 0x74, 0x61, 0x67, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x74, 0x61,
+	0x67, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x73, 0x18, 0x04, 0x20,
+	0x03, 0x28, 0x09, 0x52, 0x07, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x73, 0x12, 0x22, 0x0a, 0x0c,
+	0x64, 0x65, 0x73, 0x74, 0x69, 0x6e, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x05, 0x20, 0x03,
+	0x28, 0x09, 0x52, 0x0c, 0x64, 0x65, 0x73, 0x74, 0x69, 0x6e, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73,
+	0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x18, 0x06, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x08, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x12, 0x26, 0x0a, 0x05,
+	0x70, 0x6f, 0x72, 0x74, 0x73, 0x18, 0x07, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x6d, 0x6f,
+	0x64, 0x65, 0x6c, 0x2e, 0x50, 0x6f, 0x72, 0x74, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x52, 0x05, 0x70,
+	0x6f, 0x72, 0x74, 0x73, 0x12, 0x10, 0x0a, 0x03, 0x6c, 0x6f, 0x67, 0x18, 0x08, 0x20, 0x01, 0x28,
+	0x08, 0x52, 0x03, 0x6c, 0x6f, 0x67, 0x22, 0x13, 0x0a, 0x11, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72,
+	0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x51, 0x75, 0x65, 0x72, 0x79, 0x22, 0xc5, 0x01, 0x0a, 0x0c,
+	0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x12, 0x16, 0x0a, 0x06,
+	0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x73, 0x74,
+	0x61, 0x74, 0x75, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x18,
+	0x0a, 0x07, 0x65, 0x6e, 0x61, 0x62, 0x6c, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x07, 0x65, 0x6e, 0x61, 0x62, 0x6c, 0x65, 0x64, 0x12, 0x27, 0x0a, 0x05, 0x72, 0x75, 0x6c, 0x65,
+	0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x2e,
+	0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x52, 0x75, 0x6c, 0x65, 0x52, 0x05, 0x72, 0x75, 0x6c, 0x65,
+	0x73, 0x12, 0x22, 0x0a, 0x0c, 0x64, 0x65, 0x66, 0x61, 0x75, 0x6c, 0x74, 0x41, 0x6c, 0x6c, 0x6f,
+	0x77, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0c, 0x64, 0x65, 0x66, 0x61, 0x75, 0x6c, 0x74,
+	0x41, 0x6c, 0x6c, 0x6f, 0x77, 0x12, 0x1c, 0x0a, 0x09, 0x6c, 0x6f, 0x67, 0x44, 0x65, 0x6e, 0x69,
+	0x65, 0x64, 0x18, 0x06, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x6c, 0x6f, 0x67, 0x44, 0x65, 0x6e,
+	0x69, 0x65, 0x64, 0x42, 0x08, 0x5a, 0x06, 0x2f, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x62, 0x06, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -457,32 +1470,59 @@ func file_model_client_proto_rawDescGZIP() []byte {
 	return file_model_client_proto_rawDescData
 }
 
-var file_model_client_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_model_client_proto_msgTypes = make([]protoimpl.MessageInfo, 23)
 var file_model_client_proto_goTypes = []interface{}{
 	(*ClientValidateData)(nil),  // 0: model.ClientValidateData
 	(*ClientData)(nil),          // 1: model.ClientData
-	(*ClientPing)(nil),          // 2: model.ClientPing
-	(*ClientSearch)(nil),        // 3: model.ClientSearch
-	(*Clients)(nil),             // 4: model.Clients
-	nil,                         // 5: model.ClientValidateData.DataEntry
-	nil,                         // 6: model.ClientData.TagsEntry
-	nil,                         // 7: model.ClientData.DataEntry
-	(*BrokerClientContext)(nil), // 8: model.BrokerClientContext
-	(*RelayClientContext)(nil),  // 9: model.RelayClientContext
-	(*P2PClientContext)(nil),    // 10: model.P2PClientContext
+	(*OverlayAddress)(nil),      // 2: model.OverlayAddress
+	(*ClientPing)(nil),          // 3: model.ClientPing
+	(*ClientSearch)(nil),        // 4: model.ClientSearch
+	(*Clients)(nil),             // 5: model.Clients
+	(*ClientMatch)(nil),         // 6: model.ClientMatch
+	(*ClientRecord)(nil),        // 7: model.ClientRecord
+	(*ClientRecordsQuery)(nil),  // 8: model.ClientRecordsQuery
+	(*ClientRecords)(nil),       // 9: model.ClientRecords
+	(*SubnetRoute)(nil),         // 10: model.SubnetRoute
+	(*ClientRoutes)(nil),        // 11: model.ClientRoutes
+	(*RouteConflict)(nil),       // 12: model.RouteConflict
+	(*ClientRoutesStatus)(nil),  // 13: model.ClientRoutesStatus
+	(*PortRange)(nil),           // 14: model.PortRange
+	(*FilterRule)(nil),          // 15: model.FilterRule
+	(*FilterPolicyQuery)(nil),   // 16: model.FilterPolicyQuery
+	(*FilterPolicy)(nil),        // 17: model.FilterPolicy
+	nil,                         // 18: model.ClientValidateData.DataEntry
+	nil,                         // 19: model.ClientData.TagsEntry
+	nil,                         // 20: model.ClientData.DataEntry
+	nil,                         // 21: model.ClientRecord.TagsEntry
+	nil,                         // 22: model.ClientRecord.ServicesEntry
+	(*BrokerClientContext)(nil), // 23: model.BrokerClientContext
+	(*RelayClientContext)(nil),  // 24: model.RelayClientContext
+	(*P2PClientContext)(nil),    // 25: model.P2PClientContext
 }
 var file_model_client_proto_depIdxs = []int32{
-	5,  // 0: model.ClientValidateData.data:type_name -> model.ClientValidateData.DataEntry
-	6,  // 1: model.ClientData.tags:type_name -> model.ClientData.TagsEntry
-	7,  // 2: model.ClientData.data:type_name -> model.ClientData.DataEntry
-	8,  // 3: model.ClientData.brokerCtx:type_name -> model.BrokerClientContext
-	9,  // 4: model.ClientData.relayCtx:type_name -> model.RelayClientContext
-	10, // 5: model.ClientData.p2pCtx:type_name -> model.P2PClientContext
-	6,  // [6:6] is the sub-list for method output_type
-	6,  // [6:6] is the sub-list for method input_type
-	6,  // [6:6] is the sub-list for extension type_name
-	6,  // [6:6] is the sub-list for extension extendee
-	0,  // [0:6] is the sub-list for field type_name
+	18, // 0: model.ClientValidateData.data:type_name -> model.ClientValidateData.DataEntry
+	19, // 1: model.ClientData.tags:type_name -> model.ClientData.TagsEntry
+	20, // 2: model.ClientData.data:type_name -> model.ClientData.DataEntry
+	23, // 3: model.ClientData.brokerCtx:type_name -> model.BrokerClientContext
+	24, // 4: model.ClientData.relayCtx:type_name -> model.RelayClientContext
+	25, // 5: model.ClientData.p2pCtx:type_name -> model.P2PClientContext
+	2,  // 6: model.ClientData.overlayAddresses:type_name -> model.OverlayAddress
+	6,  // 7: model.Clients.matches:type_name -> model.ClientMatch
+	21, // 8: model.ClientRecord.tags:type_name -> model.ClientRecord.TagsEntry
+	2,  // 9: model.ClientRecord.overlayAddresses:type_name -> model.OverlayAddress
+	22, // 10: model.ClientRecord.services:type_name -> model.ClientRecord.ServicesEntry
+	10, // 11: model.ClientRecord.routes:type_name -> model.SubnetRoute
+	7,  // 12: model.ClientRecords.records:type_name -> model.ClientRecord
+	10, // 13: model.ClientRoutes.routes:type_name -> model.SubnetRoute
+	10, // 14: model.ClientRoutesStatus.accepted:type_name -> model.SubnetRoute
+	12, // 15: model.ClientRoutesStatus.conflicts:type_name -> model.RouteConflict
+	14, // 16: model.FilterRule.ports:type_name -> model.PortRange
+	15, // 17: model.FilterPolicy.rules:type_name -> model.FilterRule
+	18, // [18:18] is the sub-list for method output_type
+	18, // [18:18] is the sub-list for method input_type
+	18, // [18:18] is the sub-list for extension type_name
+	18, // [18:18] is the sub-list for extension extendee
+	0,  // [0:18] is the sub-list for field type_name
 }
 
 func init() { file_model_client_proto_init() }
@@ -519,7 +1559,7 @@ func file_model_client_proto_init() {
 			}
 		}
 		file_model_client_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ClientPing); i {
+			switch v := v.(*OverlayAddress); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -531,7 +1571,7 @@ func file_model_client_proto_init() {
 			}
 		}
 		file_model_client_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ClientSearch); i {
+			switch v := v.(*ClientPing); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -543,6 +1583,18 @@ func file_model_client_proto_init() {
 			}
 		}
 		file_model_client_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClientSearch); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_model_client_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*Clients); i {
 			case 0:
 				return &v.state
@@ -554,6 +1606,150 @@ func file_model_client_proto_init() {
 				return nil
 			}
 		}
+		file_model_client_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClientMatch); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_model_client_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClientRecord); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_model_client_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClientRecordsQuery); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_model_client_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClientRecords); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_model_client_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SubnetRoute); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_model_client_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClientRoutes); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_model_client_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RouteConflict); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_model_client_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClientRoutesStatus); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_model_client_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PortRange); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_model_client_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*FilterRule); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_model_client_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*FilterPolicyQuery); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_model_client_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*FilterPolicy); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	file_model_client_proto_msgTypes[1].OneofWrappers = []interface{}{
 		(*ClientData_BrokerCtx)(nil),
@@ -566,7 +1762,7 @@ func file_model_client_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_model_client_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   8,
+			NumMessages:   23,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -17,6 +17,13 @@ const (
 	MessageTypeClientSearch   = network.MessageType("network-client-search")
 	MessageTypeClients        = network.MessageType("network-clients")
 
+	MessageTypeClientRecordsQuery = network.MessageType("network-client-records-query")
+	MessageTypeClientRecords      = network.MessageType("network-client-records")
+	MessageTypeClientRoutes       = network.MessageType("network-client-routes")
+	MessageTypeClientRoutesStatus = network.MessageType("network-client-routes-status")
+	MessageTypeFilterPolicyQuery  = network.MessageType("network-filter-policy-query")
+	MessageTypeFilterPolicy       = network.MessageType("network-filter-policy")
+
 	MessageTypeStreamConnectionData   = network.MessageType("network-stream-conn-data")
 	MessageTypeStreamConnectionStatus = network.MessageType("network-stream-conn-status")
 
@@ -53,6 +60,19 @@ func Unmarshal(mtype network.MessageType) (body proto.Message, err error) {
 	case MessageTypeClients:
 		body = &Clients{}
 
+	case MessageTypeClientRecordsQuery:
+		body = &ClientRecordsQuery{}
+	case MessageTypeClientRecords:
+		body = &ClientRecords{}
+	case MessageTypeClientRoutes:
+		body = &ClientRoutes{}
+	case MessageTypeClientRoutesStatus:
+		body = &ClientRoutesStatus{}
+	case MessageTypeFilterPolicyQuery:
+		body = &FilterPolicyQuery{}
+	case MessageTypeFilterPolicy:
+		body = &FilterPolicy{}
+
 	case MessageTypeStreamConnectionData:
 		body = &StreamConnectionData{}
 	case MessageTypeStreamConnectionStatus:
@@ -135,9 +135,10 @@ type P2PPeerData struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Id        string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Address   string   `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
-	Addresses []string `protobuf:"bytes,3,rep,name=addresses,proto3" json:"addresses,omitempty"`
+	Id        string            `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Address   string            `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	Addresses []string          `protobuf:"bytes,3,rep,name=addresses,proto3" json:"addresses,omitempty"`
+	Tags      map[string]string `protobuf:"bytes,4,rep,name=tags,proto3" json:"tags,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
 }
 
 func (x *P2PPeerData) Reset() {
@@ -193,15 +194,24 @@ func (x *P2PPeerData) GetAddresses() []string {
 	return nil
 }
 
+func (x *P2PPeerData) GetTags() map[string]string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
 type P2PConnectionRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Id           string       `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Mode         string       `protobuf:"bytes,2,opt,name=mode,proto3" json:"mode,omitempty"`
-	RelayAddress string       `protobuf:"bytes,3,opt,name=relayAddress,proto3" json:"relayAddress,omitempty"`
-	Peer         *P2PPeerData `protobuf:"bytes,4,opt,name=peer,proto3" json:"peer,omitempty"`
+	Id           string            `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Mode         string            `protobuf:"bytes,2,opt,name=mode,proto3" json:"mode,omitempty"`
+	RelayAddress string            `protobuf:"bytes,3,opt,name=relayAddress,proto3" json:"relayAddress,omitempty"`
+	Peer         *P2PPeerData      `protobuf:"bytes,4,opt,name=peer,proto3" json:"peer,omitempty"`
+	Bond         *P2PBond          `protobuf:"bytes,5,opt,name=bond,proto3" json:"bond,omitempty"`
+	Metadata     map[string]string `protobuf:"bytes,6,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
 }
 
 func (x *P2PConnectionRequest) Reset() {
@@ -264,6 +274,91 @@ func (x *P2PConnectionRequest) GetPeer() *P2PPeerData {
 	return nil
 }
 
+func (x *P2PConnectionRequest) GetBond() *P2PBond {
+	if x != nil {
+		return x.Bond
+	}
+	return nil
+}
+
+func (x *P2PConnectionRequest) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+type P2PBond struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id           string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Mode         string `protobuf:"bytes,2,opt,name=mode,proto3" json:"mode,omitempty"`
+	DirectWeight uint32 `protobuf:"varint,3,opt,name=directWeight,proto3" json:"directWeight,omitempty"`
+	RelayWeight  uint32 `protobuf:"varint,4,opt,name=relayWeight,proto3" json:"relayWeight,omitempty"`
+}
+
+func (x *P2PBond) Reset() {
+	*x = P2PBond{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_model_p2p_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *P2PBond) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*P2PBond) ProtoMessage() {}
+
+func (x *P2PBond) ProtoReflect() protoreflect.Message {
+	mi := &file_model_p2p_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use P2PBond.ProtoReflect.Descriptor instead.
+func (*P2PBond) Descriptor() ([]byte, []int) {
+	return file_model_p2p_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *P2PBond) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *P2PBond) GetMode() string {
+	if x != nil {
+		return x.Mode
+	}
+	return ""
+}
+
+func (x *P2PBond) GetDirectWeight() uint32 {
+	if x != nil {
+		return x.DirectWeight
+	}
+	return 0
+}
+
+func (x *P2PBond) GetRelayWeight() uint32 {
+	if x != nil {
+		return x.RelayWeight
+	}
+	return 0
+}
+
 type P2PConnectionData struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -276,12 +371,13 @@ type P2PConnectionData struct {
 	Message      string       `protobuf:"bytes,5,opt,name=message,proto3" json:"message,omitempty"`
 	Peer         *P2PPeerData `protobuf:"bytes,6,opt,name=peer,proto3" json:"peer,omitempty"`
 	RelayAddress string       `protobuf:"bytes,7,opt,name=relayAddress,proto3" json:"relayAddress,omitempty"`
+	Rejected     bool         `protobuf:"varint,8,opt,name=rejected,proto3" json:"rejected,omitempty"`
 }
 
 func (x *P2PConnectionData) Reset() {
 	*x = P2PConnectionData{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_model_p2p_proto_msgTypes[4]
+		mi := &file_model_p2p_proto_msgTypes[5]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -294,7 +390,7 @@ func (x *P2PConnectionData) String() string {
 func (*P2PConnectionData) ProtoMessage() {}
 
 func (x *P2PConnectionData) ProtoReflect() protoreflect.Message {
-	mi := &file_model_p2p_proto_msgTypes[4]
+	mi := &file_model_p2p_proto_msgTypes[5]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -307,7 +403,7 @@ func (x *P2PConnectionData) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use P2PConnectionData.ProtoReflect.Descriptor instead.
 func (*P2PConnectionData) Descriptor() ([]byte, []int) {
-	return file_model_p2p_proto_rawDescGZIP(), []int{4}
+	return file_model_p2p_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *P2PConnectionData) GetId() string {
@@ -359,6 +455,13 @@ func (x *P2PConnectionData) GetRelayAddress() string {
 	return ""
 }
 
+func (x *P2PConnectionData) GetRejected() bool {
+	if x != nil {
+		return x.Rejected
+	}
+	return false
+}
+
 type P2PConnectionStatus struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -372,7 +475,7 @@ type P2PConnectionStatus struct {
 func (x *P2PConnectionStatus) Reset() {
 	*x = P2PConnectionStatus{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_model_p2p_proto_msgTypes[5]
+		mi := &file_model_p2p_proto_msgTypes[6]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -385,7 +488,7 @@ func (x *P2PConnectionStatus) String() string {
 func (*P2PConnectionStatus) ProtoMessage() {}
 
 func (x *P2PConnectionStatus) ProtoReflect() protoreflect.Message {
-	mi := &file_model_p2p_proto_msgTypes[5]
+	mi := &file_model_p2p_proto_msgTypes[6]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -398,7 +501,7 @@ func (x *P2PConnectionStatus) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use P2PConnectionStatus.ProtoReflect.Descriptor instead.
 func (*P2PConnectionStatus) Descriptor() ([]byte, []int) {
-	return file_model_p2p_proto_rawDescGZIP(), []int{5}
+	return file_model_p2p_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *P2PConnectionStatus) GetId() string {
@@ -433,7 +536,7 @@ type P2PRelayServers struct {
 func (x *P2PRelayServers) Reset() {
 	*x = P2PRelayServers{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_model_p2p_proto_msgTypes[6]
+		mi := &file_model_p2p_proto_msgTypes[7]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -446,7 +549,7 @@ func (x *P2PRelayServers) String() string {
 func (*P2PRelayServers) ProtoMessage() {}
 
 func (x *P2PRelayServers) ProtoReflect() protoreflect.Message {
-	mi := &file_model_p2p_proto_msgTypes[6]
+	mi := &file_model_p2p_proto_msgTypes[7]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -459,7 +562,7 @@ func (x *P2PRelayServers) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use P2PRelayServers.ProtoReflect.Descriptor instead.
 func (*P2PRelayServers) Descriptor() ([]byte, []int) {
-	return file_model_p2p_proto_rawDescGZIP(), []int{6}
+	return file_model_p2p_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *P2PRelayServers) GetServers() []string {
@@ -486,7 +589,7 @@ type P2PRelayConnectionData struct {
 func (x *P2PRelayConnectionData) Reset() {
 	*x = P2PRelayConnectionData{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_model_p2p_proto_msgTypes[7]
+		mi := &file_model_p2p_proto_msgTypes[8]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -499,7 +602,7 @@ func (x *P2PRelayConnectionData) String() string {
 func (*P2PRelayConnectionData) ProtoMessage() {}
 
 func (x *P2PRelayConnectionData) ProtoReflect() protoreflect.Message {
-	mi := &file_model_p2p_proto_msgTypes[7]
+	mi := &file_model_p2p_proto_msgTypes[8]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -512,7 +615,7 @@ func (x *P2PRelayConnectionData) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use P2PRelayConnectionData.ProtoReflect.Descriptor instead.
 func (*P2PRelayConnectionData) Descriptor() ([]byte, []int) {
-	return file_model_p2p_proto_rawDescGZIP(), []int{7}
+	return file_model_p2p_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *P2PRelayConnectionData) GetStatus() bool {
@@ -576,7 +679,7 @@ type P2PRelayPeersStatus struct {
 func (x *P2PRelayPeersStatus) Reset() {
 	*x = P2PRelayPeersStatus{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_model_p2p_proto_msgTypes[8]
+		mi := &file_model_p2p_proto_msgTypes[9]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -589,7 +692,7 @@ func (x *P2PRelayPeersStatus) String() string {
 func (*P2PRelayPeersStatus) ProtoMessage() {}
 
 func (x *P2PRelayPeersStatus) ProtoReflect() protoreflect.Message {
-	mi := &file_model_p2p_proto_msgTypes[8]
+	mi := &file_model_p2p_proto_msgTypes[9]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -602,7 +705,7 @@ func (x *P2PRelayPeersStatus) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use P2PRelayPeersStatus.ProtoReflect.Descriptor instead.
 func (*P2PRelayPeersStatus) Descriptor() ([]byte, []int) {
-	return file_model_p2p_proto_rawDescGZIP(), []int{8}
+	return file_model_p2p_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *P2PRelayPeersStatus) GetStatus() bool {
@@ -631,7 +734,7 @@ type P2PRelayOpenStream struct {
 func (x *P2PRelayOpenStream) Reset() {
 	*x = P2PRelayOpenStream{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_model_p2p_proto_msgTypes[9]
+		mi := &file_model_p2p_proto_msgTypes[10]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -644,7 +747,7 @@ func (x *P2PRelayOpenStream) String() string {
 func (*P2PRelayOpenStream) ProtoMessage() {}
 
 func (x *P2PRelayOpenStream) ProtoReflect() protoreflect.Message {
-	mi := &file_model_p2p_proto_msgTypes[9]
+	mi := &file_model_p2p_proto_msgTypes[10]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -657,7 +760,7 @@ func (x *P2PRelayOpenStream) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use P2PRelayOpenStream.ProtoReflect.Descriptor instead.
 func (*P2PRelayOpenStream) Descriptor() ([]byte, []int) {
-	return file_model_p2p_proto_rawDescGZIP(), []int{9}
+	return file_model_p2p_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *P2PRelayOpenStream) GetMetadata() map[string]string {
@@ -687,7 +790,7 @@ type P2PRelayStreamInfo struct {
 func (x *P2PRelayStreamInfo) Reset() {
 	*x = P2PRelayStreamInfo{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_model_p2p_proto_msgTypes[10]
+		mi := &file_model_p2p_proto_msgTypes[11]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -700,7 +803,7 @@ func (x *P2PRelayStreamInfo) String() string {
 func (*P2PRelayStreamInfo) ProtoMessage() {}
 
 func (x *P2PRelayStreamInfo) ProtoReflect() protoreflect.Message {
-	mi := &file_model_p2p_proto_msgTypes[10]
+	mi := &file_model_p2p_proto_msgTypes[11]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -713,7 +816,7 @@ func (x *P2PRelayStreamInfo) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use P2PRelayStreamInfo.ProtoReflect.Descriptor instead.
 func (*P2PRelayStreamInfo) Descriptor() ([]byte, []int) {
-	return file_model_p2p_proto_rawDescGZIP(), []int{10}
+	return file_model_p2p_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *P2PRelayStreamInfo) GetStatus() bool {
@@ -737,6 +840,243 @@ func (x *P2PRelayStreamInfo) GetId() string {
 	return ""
 }
 
+type P2PAnnouncement struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id        string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Port      int32  `protobuf:"varint,2,opt,name=port,proto3" json:"port,omitempty"`
+	Key       []byte `protobuf:"bytes,3,opt,name=key,proto3" json:"key,omitempty"`
+	Timestamp int64  `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Signature []byte `protobuf:"bytes,5,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (x *P2PAnnouncement) Reset() {
+	*x = P2PAnnouncement{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_model_p2p_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *P2PAnnouncement) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*P2PAnnouncement) ProtoMessage() {}
+
+func (x *P2PAnnouncement) ProtoReflect() protoreflect.Message {
+	mi := &file_model_p2p_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use P2PAnnouncement.ProtoReflect.Descriptor instead.
+func (*P2PAnnouncement) Descriptor() ([]byte, []int) {
+	return file_model_p2p_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *P2PAnnouncement) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *P2PAnnouncement) GetPort() int32 {
+	if x != nil {
+		return x.Port
+	}
+	return 0
+}
+
+func (x *P2PAnnouncement) GetKey() []byte {
+	if x != nil {
+		return x.Key
+	}
+	return nil
+}
+
+func (x *P2PAnnouncement) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *P2PAnnouncement) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+type P2PRouteEntry struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id     string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Metric uint32 `protobuf:"varint,2,opt,name=metric,proto3" json:"metric,omitempty"`
+	Hops   uint32 `protobuf:"varint,3,opt,name=hops,proto3" json:"hops,omitempty"`
+}
+
+func (x *P2PRouteEntry) Reset() {
+	*x = P2PRouteEntry{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_model_p2p_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *P2PRouteEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*P2PRouteEntry) ProtoMessage() {}
+
+func (x *P2PRouteEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_model_p2p_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use P2PRouteEntry.ProtoReflect.Descriptor instead.
+func (*P2PRouteEntry) Descriptor() ([]byte, []int) {
+	return file_model_p2p_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *P2PRouteEntry) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *P2PRouteEntry) GetMetric() uint32 {
+	if x != nil {
+		return x.Metric
+	}
+	return 0
+}
+
+func (x *P2PRouteEntry) GetHops() uint32 {
+	if x != nil {
+		return x.Hops
+	}
+	return 0
+}
+
+type P2PRouteFrame struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type      uint32           `protobuf:"varint,1,opt,name=type,proto3" json:"type,omitempty"`
+	Routes    []*P2PRouteEntry `protobuf:"bytes,2,rep,name=routes,proto3" json:"routes,omitempty"`
+	Timestamp int64            `protobuf:"varint,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Source    string           `protobuf:"bytes,4,opt,name=source,proto3" json:"source,omitempty"`
+	Target    string           `protobuf:"bytes,5,opt,name=target,proto3" json:"target,omitempty"`
+	Ttl       uint32           `protobuf:"varint,6,opt,name=ttl,proto3" json:"ttl,omitempty"`
+	Payload   []byte           `protobuf:"bytes,7,opt,name=payload,proto3" json:"payload,omitempty"`
+}
+
+func (x *P2PRouteFrame) Reset() {
+	*x = P2PRouteFrame{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_model_p2p_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *P2PRouteFrame) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*P2PRouteFrame) ProtoMessage() {}
+
+func (x *P2PRouteFrame) ProtoReflect() protoreflect.Message {
+	mi := &file_model_p2p_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use P2PRouteFrame.ProtoReflect.Descriptor instead.
+func (*P2PRouteFrame) Descriptor() ([]byte, []int) {
+	return file_model_p2p_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *P2PRouteFrame) GetType() uint32 {
+	if x != nil {
+		return x.Type
+	}
+	return 0
+}
+
+func (x *P2PRouteFrame) GetRoutes() []*P2PRouteEntry {
+	if x != nil {
+		return x.Routes
+	}
+	return nil
+}
+
+func (x *P2PRouteFrame) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *P2PRouteFrame) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *P2PRouteFrame) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+func (x *P2PRouteFrame) GetTtl() uint32 {
+	if x != nil {
+		return x.Ttl
+	}
+	return 0
+}
+
+func (x *P2PRouteFrame) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
 var File_model_p2p_proto protoreflect.FileDescriptor
 
 var file_model_p2p_proto_rawDesc = []byte{
@@ -749,86 +1089,138 @@ var file_model_p2p_proto_rawDesc = []byte{
 	0x61, 0x63, 0x74, 0x69, 0x76, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x61, 0x63,
 	0x74, 0x69, 0x76, 0x65, 0x22, 0x1d, 0x0a, 0x07, 0x50, 0x32, 0x50, 0x44, 0x61, 0x74, 0x61, 0x12,
 	0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64,
-	0x61, 0x74, 0x61, 0x22, 0x55, 0x0a, 0x0b, 0x50, 0x32, 0x50, 0x50, 0x65, 0x65, 0x72, 0x44, 0x61,
+	0x61, 0x74, 0x61, 0x22, 0xc0, 0x01, 0x0a, 0x0b, 0x50, 0x32, 0x50, 0x50, 0x65, 0x65, 0x72, 0x44,
+	0x61, 0x74, 0x61, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x02, 0x69, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x12, 0x1c, 0x0a,
+	0x09, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x65, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09,
+	0x52, 0x09, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x65, 0x73, 0x12, 0x30, 0x0a, 0x04, 0x74,
+	0x61, 0x67, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x6d, 0x6f, 0x64, 0x65,
+	0x6c, 0x2e, 0x50, 0x32, 0x50, 0x50, 0x65, 0x65, 0x72, 0x44, 0x61, 0x74, 0x61, 0x2e, 0x54, 0x61,
+	0x67, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x04, 0x74, 0x61, 0x67, 0x73, 0x1a, 0x37, 0x0a,
+	0x09, 0x54, 0x61, 0x67, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65,
+	0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0xae, 0x02, 0x0a, 0x14, 0x50, 0x32, 0x50, 0x43, 0x6f,
+	0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
+	0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12,
+	0x12, 0x0a, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6d,
+	0x6f, 0x64, 0x65, 0x12, 0x22, 0x0a, 0x0c, 0x72, 0x65, 0x6c, 0x61, 0x79, 0x41, 0x64, 0x64, 0x72,
+	0x65, 0x73, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x72, 0x65, 0x6c, 0x61, 0x79,
+	0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x12, 0x26, 0x0a, 0x04, 0x70, 0x65, 0x65, 0x72, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x2e, 0x50, 0x32,
+	0x50, 0x50, 0x65, 0x65, 0x72, 0x44, 0x61, 0x74, 0x61, 0x52, 0x04, 0x70, 0x65, 0x65, 0x72, 0x12,
+	0x22, 0x0a, 0x04, 0x62, 0x6f, 0x6e, 0x64, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0e, 0x2e,
+	0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x2e, 0x50, 0x32, 0x50, 0x42, 0x6f, 0x6e, 0x64, 0x52, 0x04, 0x62,
+	0x6f, 0x6e, 0x64, 0x12, 0x45, 0x0a, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x18,
+	0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x29, 0x2e, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x2e, 0x50, 0x32,
+	0x50, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x2e, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79,
+	0x52, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x1a, 0x3b, 0x0a, 0x0d, 0x4d, 0x65,
+	0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b,
+	0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a,
+	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x73, 0x0a, 0x07, 0x50, 0x32, 0x50, 0x42, 0x6f,
+	0x6e, 0x64, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02,
+	0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x12, 0x22, 0x0a, 0x0c, 0x64, 0x69, 0x72, 0x65, 0x63, 0x74,
+	0x57, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0c, 0x64, 0x69,
+	0x72, 0x65, 0x63, 0x74, 0x57, 0x65, 0x69, 0x67, 0x68, 0x74, 0x12, 0x20, 0x0a, 0x0b, 0x72, 0x65,
+	0x6c, 0x61, 0x79, 0x57, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52,
+	0x0b, 0x72, 0x65, 0x6c, 0x61, 0x79, 0x57, 0x65, 0x69, 0x67, 0x68, 0x74, 0x22, 0xe7, 0x01, 0x0a,
+	0x11, 0x50, 0x32, 0x50, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x44, 0x61,
 	0x74, 0x61, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02,
-	0x69, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x02, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x12, 0x1c, 0x0a, 0x09,
-	0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x65, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52,
-	0x09, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x65, 0x73, 0x22, 0x86, 0x01, 0x0a, 0x14, 0x50,
-	0x32, 0x50, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75,
-	0x65, 0x73, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x12, 0x22, 0x0a, 0x0c, 0x72, 0x65, 0x6c, 0x61, 0x79,
-	0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x72,
-	0x65, 0x6c, 0x61, 0x79, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x12, 0x26, 0x0a, 0x04, 0x70,
-	0x65, 0x65, 0x72, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x6d, 0x6f, 0x64, 0x65,
-	0x6c, 0x2e, 0x50, 0x32, 0x50, 0x50, 0x65, 0x65, 0x72, 0x44, 0x61, 0x74, 0x61, 0x52, 0x04, 0x70,
-	0x65, 0x65, 0x72, 0x22, 0xcb, 0x01, 0x0a, 0x11, 0x50, 0x32, 0x50, 0x43, 0x6f, 0x6e, 0x6e, 0x65,
-	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x44, 0x61, 0x74, 0x61, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6d, 0x6f, 0x64,
-	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x12, 0x16, 0x0a,
-	0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x73,
-	0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x72, 0x65, 0x74, 0x72, 0x79, 0x18, 0x04,
-	0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x72, 0x65, 0x74, 0x72, 0x79, 0x12, 0x18, 0x0a, 0x07, 0x6d,
-	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65,
-	0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x26, 0x0a, 0x04, 0x70, 0x65, 0x65, 0x72, 0x18, 0x06, 0x20,
-	0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x2e, 0x50, 0x32, 0x50, 0x50,
-	0x65, 0x65, 0x72, 0x44, 0x61, 0x74, 0x61, 0x52, 0x04, 0x70, 0x65, 0x65, 0x72, 0x12, 0x22, 0x0a,
-	0x0c, 0x72, 0x65, 0x6c, 0x61, 0x79, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x07, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x0c, 0x72, 0x65, 0x6c, 0x61, 0x79, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73,
-	0x73, 0x22, 0x57, 0x0a, 0x13, 0x50, 0x32, 0x50, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69,
-	0x6f, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74,
-	0x75, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73,
-	0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x2b, 0x0a, 0x0f, 0x50, 0x32,
-	0x50, 0x52, 0x65, 0x6c, 0x61, 0x79, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x73, 0x12, 0x18, 0x0a,
-	0x07, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x07,
-	0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x73, 0x22, 0xfe, 0x01, 0x0a, 0x16, 0x50, 0x32, 0x50, 0x52,
-	0x65, 0x6c, 0x61, 0x79, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x44, 0x61,
-	0x74, 0x61, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x08, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65,
-	0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73,
-	0x73, 0x61, 0x67, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x18, 0x04, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x12, 0x26, 0x0a, 0x04, 0x70, 0x65, 0x65, 0x72,
-	0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x2e, 0x50,
-	0x32, 0x50, 0x50, 0x65, 0x65, 0x72, 0x44, 0x61, 0x74, 0x61, 0x52, 0x04, 0x70, 0x65, 0x65, 0x72,
-	0x12, 0x32, 0x0a, 0x0a, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x50, 0x65, 0x65, 0x72, 0x18, 0x06,
-	0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x2e, 0x50, 0x32, 0x50,
-	0x50, 0x65, 0x65, 0x72, 0x44, 0x61, 0x74, 0x61, 0x52, 0x0a, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65,
-	0x50, 0x65, 0x65, 0x72, 0x12, 0x32, 0x0a, 0x0a, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x50, 0x65,
-	0x65, 0x72, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x6d, 0x6f, 0x64, 0x65, 0x6c,
-	0x2e, 0x50, 0x32, 0x50, 0x50, 0x65, 0x65, 0x72, 0x44, 0x61, 0x74, 0x61, 0x52, 0x0a, 0x74, 0x61,
-	0x72, 0x67, 0x65, 0x74, 0x50, 0x65, 0x65, 0x72, 0x22, 0x47, 0x0a, 0x13, 0x50, 0x32, 0x50, 0x52,
-	0x65, 0x6c, 0x61, 0x79, 0x50, 0x65, 0x65, 0x72, 0x73, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12,
-	0x16, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52,
-	0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61,
-	0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67,
-	0x65, 0x22, 0x88, 0x02, 0x0a, 0x12, 0x50, 0x32, 0x50, 0x52, 0x65, 0x6c, 0x61, 0x79, 0x4f, 0x70,
-	0x65, 0x6e, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x43, 0x0a, 0x08, 0x6d, 0x65, 0x74, 0x61,
-	0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x27, 0x2e, 0x6d, 0x6f, 0x64,
-	0x65, 0x6c, 0x2e, 0x50, 0x32, 0x50, 0x52, 0x65, 0x6c, 0x61, 0x79, 0x4f, 0x70, 0x65, 0x6e, 0x53,
-	0x74, 0x72, 0x65, 0x61, 0x6d, 0x2e, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x45, 0x6e,
-	0x74, 0x72, 0x79, 0x52, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x12, 0x37, 0x0a,
-	0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x23, 0x2e, 0x6d, 0x6f,
-	0x64, 0x65, 0x6c, 0x2e, 0x50, 0x32, 0x50, 0x52, 0x65, 0x6c, 0x61, 0x79, 0x4f, 0x70, 0x65, 0x6e,
-	0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79,
-	0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x1a, 0x3b, 0x0a, 0x0d, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61,
-	0x74, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c,
-	0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a,
-	0x02, 0x38, 0x01, 0x1a, 0x37, 0x0a, 0x09, 0x44, 0x61, 0x74, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79,
-	0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b,
-	0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x56, 0x0a, 0x12,
-	0x50, 0x32, 0x50, 0x52, 0x65, 0x6c, 0x61, 0x79, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x49, 0x6e,
-	0x66, 0x6f, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x08, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65,
-	0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73,
-	0x73, 0x61, 0x67, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x02, 0x69, 0x64, 0x42, 0x08, 0x5a, 0x06, 0x2f, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x62, 0x06,
-	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x14,
+	0x0a, 0x05, 0x72, 0x65, 0x74, 0x72, 0x79, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x72,
+	0x65, 0x74, 0x72, 0x79, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18,
+	0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x26,
+	0x0a, 0x04, 0x70, 0x65, 0x65, 0x72, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x6d,
+	0x6f, 0x64, 0x65, 0x6c, 0x2e, 0x50, 0x32, 0x50, 0x50, 0x65, 0x65, 0x72, 0x44, 0x61, 0x74, 0x61,
+	0x52, 0x04, 0x70, 0x65, 0x65, 0x72, 0x12, 0x22, 0x0a, 0x0c, 0x72, 0x65, 0x6c, 0x61, 0x79, 0x41,
+	0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x72, 0x65,
+	0x6c, 0x61, 0x79, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x72, 0x65,
+	0x6a, 0x65, 0x63, 0x74, 0x65, 0x64, 0x18, 0x08, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x72, 0x65,
+	0x6a, 0x65, 0x63, 0x74, 0x65, 0x64, 0x22, 0x57, 0x0a, 0x13, 0x50, 0x32, 0x50, 0x43, 0x6f, 0x6e,
+	0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x0e, 0x0a,
+	0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x16, 0x0a,
+	0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x73,
+	0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22,
+	0x2b, 0x0a, 0x0f, 0x50, 0x32, 0x50, 0x52, 0x65, 0x6c, 0x61, 0x79, 0x53, 0x65, 0x72, 0x76, 0x65,
+	0x72, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x73, 0x18, 0x01, 0x20,
+	0x03, 0x28, 0x09, 0x52, 0x07, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x73, 0x22, 0xfe, 0x01, 0x0a,
+	0x16, 0x50, 0x32, 0x50, 0x52, 0x65, 0x6c, 0x61, 0x79, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74,
+	0x69, 0x6f, 0x6e, 0x44, 0x61, 0x74, 0x61, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75,
+	0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12,
+	0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6d, 0x6f, 0x64,
+	0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x12, 0x26, 0x0a,
+	0x04, 0x70, 0x65, 0x65, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x6d, 0x6f,
+	0x64, 0x65, 0x6c, 0x2e, 0x50, 0x32, 0x50, 0x50, 0x65, 0x65, 0x72, 0x44, 0x61, 0x74, 0x61, 0x52,
+	0x04, 0x70, 0x65, 0x65, 0x72, 0x12, 0x32, 0x0a, 0x0a, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x50,
+	0x65, 0x65, 0x72, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x6d, 0x6f, 0x64, 0x65,
+	0x6c, 0x2e, 0x50, 0x32, 0x50, 0x50, 0x65, 0x65, 0x72, 0x44, 0x61, 0x74, 0x61, 0x52, 0x0a, 0x73,
+	0x6f, 0x75, 0x72, 0x63, 0x65, 0x50, 0x65, 0x65, 0x72, 0x12, 0x32, 0x0a, 0x0a, 0x74, 0x61, 0x72,
+	0x67, 0x65, 0x74, 0x50, 0x65, 0x65, 0x72, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e,
+	0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x2e, 0x50, 0x32, 0x50, 0x50, 0x65, 0x65, 0x72, 0x44, 0x61, 0x74,
+	0x61, 0x52, 0x0a, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x50, 0x65, 0x65, 0x72, 0x22, 0x47, 0x0a,
+	0x13, 0x50, 0x32, 0x50, 0x52, 0x65, 0x6c, 0x61, 0x79, 0x50, 0x65, 0x65, 0x72, 0x73, 0x53, 0x74,
+	0x61, 0x74, 0x75, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x18, 0x0a, 0x07,
+	0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x88, 0x02, 0x0a, 0x12, 0x50, 0x32, 0x50, 0x52, 0x65,
+	0x6c, 0x61, 0x79, 0x4f, 0x70, 0x65, 0x6e, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x43, 0x0a,
+	0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x27, 0x2e, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x2e, 0x50, 0x32, 0x50, 0x52, 0x65, 0x6c, 0x61, 0x79,
+	0x4f, 0x70, 0x65, 0x6e, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x2e, 0x4d, 0x65, 0x74, 0x61, 0x64,
+	0x61, 0x74, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61,
+	0x74, 0x61, 0x12, 0x37, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x23, 0x2e, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x2e, 0x50, 0x32, 0x50, 0x52, 0x65, 0x6c, 0x61,
+	0x79, 0x4f, 0x70, 0x65, 0x6e, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x2e, 0x44, 0x61, 0x74, 0x61,
+	0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x1a, 0x3b, 0x0a, 0x0d, 0x4d,
+	0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03,
+	0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14,
+	0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x1a, 0x37, 0x0a, 0x09, 0x44, 0x61, 0x74, 0x61,
+	0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38,
+	0x01, 0x22, 0x56, 0x0a, 0x12, 0x50, 0x32, 0x50, 0x52, 0x65, 0x6c, 0x61, 0x79, 0x53, 0x74, 0x72,
+	0x65, 0x61, 0x6d, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75,
+	0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12,
+	0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x22, 0x83, 0x01, 0x0a, 0x0f, 0x50, 0x32,
+	0x50, 0x41, 0x6e, 0x6e, 0x6f, 0x75, 0x6e, 0x63, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x0e, 0x0a,
+	0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a,
+	0x04, 0x70, 0x6f, 0x72, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x70, 0x6f, 0x72,
+	0x74, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03,
+	0x6b, 0x65, 0x79, 0x12, 0x1c, 0x0a, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d,
+	0x70, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x18, 0x05,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x22,
+	0x4b, 0x0a, 0x0d, 0x50, 0x32, 0x50, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x45, 0x6e, 0x74, 0x72, 0x79,
+	0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64,
+	0x12, 0x16, 0x0a, 0x06, 0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x06, 0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x6f, 0x70, 0x73,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x68, 0x6f, 0x70, 0x73, 0x22, 0xcb, 0x01, 0x0a,
+	0x0d, 0x50, 0x32, 0x50, 0x52, 0x6f, 0x75, 0x74, 0x65, 0x46, 0x72, 0x61, 0x6d, 0x65, 0x12, 0x12,
+	0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x74, 0x79,
+	0x70, 0x65, 0x12, 0x2c, 0x0a, 0x06, 0x72, 0x6f, 0x75, 0x74, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x14, 0x2e, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x2e, 0x50, 0x32, 0x50, 0x52, 0x6f,
+	0x75, 0x74, 0x65, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x06, 0x72, 0x6f, 0x75, 0x74, 0x65, 0x73,
+	0x12, 0x1c, 0x0a, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x12, 0x16,
+	0x0a, 0x06, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06,
+	0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x12, 0x10,
+	0x0a, 0x03, 0x74, 0x74, 0x6c, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x03, 0x74, 0x74, 0x6c,
+	0x12, 0x18, 0x0a, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x18, 0x07, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x42, 0x08, 0x5a, 0x06, 0x2f, 0x6d,
+	0x6f, 0x64, 0x65, 0x6c, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -843,35 +1235,45 @@ func file_model_p2p_proto_rawDescGZIP() []byte {
 	return file_model_p2p_proto_rawDescData
 }
 
-var file_model_p2p_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_model_p2p_proto_msgTypes = make([]protoimpl.MessageInfo, 19)
 var file_model_p2p_proto_goTypes = []interface{}{
 	(*P2PClientContext)(nil),       // 0: model.P2PClientContext
 	(*P2PData)(nil),                // 1: model.P2PData
 	(*P2PPeerData)(nil),            // 2: model.P2PPeerData
 	(*P2PConnectionRequest)(nil),   // 3: model.P2PConnectionRequest
-	(*P2PConnectionData)(nil),      // 4: model.P2PConnectionData
-	(*P2PConnectionStatus)(nil),    // 5: model.P2PConnectionStatus
-	(*P2PRelayServers)(nil),        // 6: model.P2PRelayServers
-	(*P2PRelayConnectionData)(nil), // 7: model.P2PRelayConnectionData
-	(*P2PRelayPeersStatus)(nil),    // 8: model.P2PRelayPeersStatus
-	(*P2PRelayOpenStream)(nil),     // 9: model.P2PRelayOpenStream
-	(*P2PRelayStreamInfo)(nil),     // 10: model.P2PRelayStreamInfo
-	nil,                            // 11: model.P2PRelayOpenStream.MetadataEntry
-	nil,                            // 12: model.P2PRelayOpenStream.DataEntry
+	(*P2PBond)(nil),                // 4: model.P2PBond
+	(*P2PConnectionData)(nil),      // 5: model.P2PConnectionData
+	(*P2PConnectionStatus)(nil),    // 6: model.P2PConnectionStatus
+	(*P2PRelayServers)(nil),        // 7: model.P2PRelayServers
+	(*P2PRelayConnectionData)(nil), // 8: model.P2PRelayConnectionData
+	(*P2PRelayPeersStatus)(nil),    // 9: model.P2PRelayPeersStatus
+	(*P2PRelayOpenStream)(nil),     // 10: model.P2PRelayOpenStream
+	(*P2PRelayStreamInfo)(nil),     // 11: model.P2PRelayStreamInfo
+	(*P2PAnnouncement)(nil),        // 12: model.P2PAnnouncement
+	(*P2PRouteEntry)(nil),          // 13: model.P2PRouteEntry
+	(*P2PRouteFrame)(nil),          // 14: model.P2PRouteFrame
+	nil,                            // 15: model.P2PPeerData.TagsEntry
+	nil,                            // 16: model.P2PConnectionRequest.MetadataEntry
+	nil,                            // 17: model.P2PRelayOpenStream.MetadataEntry
+	nil,                            // 18: model.P2PRelayOpenStream.DataEntry
 }
 var file_model_p2p_proto_depIdxs = []int32{
-	2,  // 0: model.P2PConnectionRequest.peer:type_name -> model.P2PPeerData
-	2,  // 1: model.P2PConnectionData.peer:type_name -> model.P2PPeerData
-	2,  // 2: model.P2PRelayConnectionData.peer:type_name -> model.P2PPeerData
-	2,  // 3: model.P2PRelayConnectionData.sourcePeer:type_name -> model.P2PPeerData
-	2,  // 4: model.P2PRelayConnectionData.targetPeer:type_name -> model.P2PPeerData
-	11, // 5: model.P2PRelayOpenStream.metadata:type_name -> model.P2PRelayOpenStream.MetadataEntry
-	12, // 6: model.P2PRelayOpenStream.data:type_name -> model.P2PRelayOpenStream.DataEntry
-	7,  // [7:7] is the sub-list for method output_type
-	7,  // [7:7] is the sub-list for method input_type
-	7,  // [7:7] is the sub-list for extension type_name
-	7,  // [7:7] is the sub-list for extension extendee
-	0,  // [0:7] is the sub-list for field type_name
+	15, // 0: model.P2PPeerData.tags:type_name -> model.P2PPeerData.TagsEntry
+	2,  // 1: model.P2PConnectionRequest.peer:type_name -> model.P2PPeerData
+	4,  // 2: model.P2PConnectionRequest.bond:type_name -> model.P2PBond
+	16, // 3: model.P2PConnectionRequest.metadata:type_name -> model.P2PConnectionRequest.MetadataEntry
+	2,  // 4: model.P2PConnectionData.peer:type_name -> model.P2PPeerData
+	2,  // 5: model.P2PRelayConnectionData.peer:type_name -> model.P2PPeerData
+	2,  // 6: model.P2PRelayConnectionData.sourcePeer:type_name -> model.P2PPeerData
+	2,  // 7: model.P2PRelayConnectionData.targetPeer:type_name -> model.P2PPeerData
+	17, // 8: model.P2PRelayOpenStream.metadata:type_name -> model.P2PRelayOpenStream.MetadataEntry
+	18, // 9: model.P2PRelayOpenStream.data:type_name -> model.P2PRelayOpenStream.DataEntry
+	13, // 10: model.P2PRouteFrame.routes:type_name -> model.P2PRouteEntry
+	11, // [11:11] is the sub-list for method output_type
+	11, // [11:11] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
 }
 
 func init() { file_model_p2p_proto_init() }
@@ -929,7 +1331,7 @@ func file_model_p2p_proto_init() {
 			}
 		}
 		file_model_p2p_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*P2PConnectionData); i {
+			switch v := v.(*P2PBond); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -941,7 +1343,7 @@ func file_model_p2p_proto_init() {
 			}
 		}
 		file_model_p2p_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*P2PConnectionStatus); i {
+			switch v := v.(*P2PConnectionData); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -953,7 +1355,7 @@ func file_model_p2p_proto_init() {
 			}
 		}
 		file_model_p2p_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*P2PRelayServers); i {
+			switch v := v.(*P2PConnectionStatus); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -965,7 +1367,7 @@ func file_model_p2p_proto_init() {
 			}
 		}
 		file_model_p2p_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*P2PRelayConnectionData); i {
+			switch v := v.(*P2PRelayServers); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -977,7 +1379,7 @@ func file_model_p2p_proto_init() {
 			}
 		}
 		file_model_p2p_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*P2PRelayPeersStatus); i {
+			switch v := v.(*P2PRelayConnectionData); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -989,7 +1391,7 @@ func file_model_p2p_proto_init() {
 			}
 		}
 		file_model_p2p_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*P2PRelayOpenStream); i {
+			switch v := v.(*P2PRelayPeersStatus); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1001,6 +1403,18 @@ func file_model_p2p_proto_init() {
 			}
 		}
 		file_model_p2p_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*P2PRelayOpenStream); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_model_p2p_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*P2PRelayStreamInfo); i {
 			case 0:
 				return &v.state
@@ -1012,6 +1426,42 @@ func file_model_p2p_proto_init() {
 				return nil
 			}
 		}
+		file_model_p2p_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*P2PAnnouncement); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_model_p2p_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*P2PRouteEntry); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_model_p2p_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*P2PRouteFrame); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -1019,7 +1469,7 @@ func file_model_p2p_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_model_p2p_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   13,
+			NumMessages:   19,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"io"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/supergiant-hq/xnet/network/model"
@@ -20,7 +21,7 @@ type ChannelUnmarshaler func(mtype MessageType) (msg proto.Message, err error)
 type Channel struct {
 	unmarshalers []ChannelUnmarshaler
 
-	stream quic.Stream
+	stream *countingStream
 	rmutex sync.Mutex
 	wmutex sync.Mutex
 
@@ -34,17 +35,28 @@ type Channel struct {
 func NewChannel(log *logrus.Logger, stream quic.Stream, unmarshalers []ChannelUnmarshaler) *Channel {
 	return &Channel{
 		unmarshalers: unmarshalers,
-		stream:       stream,
+		stream:       newCountingStream(stream),
 		acks:         make(map[string]chan *Message),
 		log:          log.WithField("prefix", "CHANNEL"),
 	}
 }
 
 // Returns the channel's stream
+// Bytes read from and written to it directly are counted as well
 func (c *Channel) Stream() quic.Stream {
 	return c.stream
 }
 
+// Number of bytes read from the channel stream
+func (c *Channel) BytesRead() uint64 {
+	return atomic.LoadUint64(&c.stream.read)
+}
+
+// Number of bytes written to the channel stream
+func (c *Channel) BytesWritten() uint64 {
+	return atomic.LoadUint64(&c.stream.written)
+}
+
 func (c *Channel) unmarshalData(mtype MessageType, bytes []byte) (data proto.Message, err error) {
 	for _, unmarshaler := range c.unmarshalers {
 		if data, err = unmarshaler(mtype); err == nil {
@@ -10,7 +10,6 @@ import (
 	"time"
 
 	"github.com/lucas-clemente/quic-go"
-	"github.com/lucas-clemente/quic-go/logging"
 )
 
 const (
@@ -40,7 +39,7 @@ func GenerateQuicConfig(c Config) *quic.Config {
 		MaxIncomingStreams:    int64(c.MaxStreams()),
 		MaxIncomingUniStreams: int64(c.MaxStreams()),
 		EnableDatagrams:       c.UseDatagram(),
-		Tracer:                logging.NewMultiplexedTracer(),
+		Tracer:                sessionPacketSizes,
 	}
 }
 
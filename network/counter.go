@@ -0,0 +1,32 @@
+package network
+
+import (
+	"sync/atomic"
+
+	"github.com/lucas-clemente/quic-go"
+)
+
+// Stream counting the bytes read from and written to it
+type countingStream struct {
+	// Accessed atomically, kept first for alignment on 32-bit platforms
+	read    uint64
+	written uint64
+
+	quic.Stream
+}
+
+func newCountingStream(stream quic.Stream) *countingStream {
+	return &countingStream{Stream: stream}
+}
+
+func (s *countingStream) Read(b []byte) (n int, err error) {
+	n, err = s.Stream.Read(b)
+	atomic.AddUint64(&s.read, uint64(n))
+	return
+}
+
+func (s *countingStream) Write(b []byte) (n int, err error) {
+	n, err = s.Stream.Write(b)
+	atomic.AddUint64(&s.written, uint64(n))
+	return
+}
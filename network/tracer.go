@@ -0,0 +1,175 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/logging"
+)
+
+// QUIC Tracer of all sessions, sessions sharing a UDP connection have to use the same Tracer
+var sessionPacketSizes = newPacketSizes()
+
+// Largest QUIC packet acknowledged on the session between the addresses, 0 if unknown
+// Once the handshake is confirmed the path MTU discovery of QUIC raises the packet size
+// with padded PING probes, the largest acknowledged packet is the largest UDP payload reaching the peer
+// The channel is signalled when it grows
+func AcknowledgedPacketSize(local, remote *net.UDPAddr) (size int, grown chan bool) {
+	return sessionPacketSizes.get(packetSizesKey(local, remote))
+}
+
+// Sessions are keyed by the local port, the local IP of sessions accepted on wildcard addresses differs
+func packetSizesKey(local, remote net.Addr) string {
+	port := 0
+	if addr, ok := local.(*net.UDPAddr); ok {
+		port = addr.Port
+	}
+	return fmt.Sprintf("%d-%s", port, remote.String())
+}
+
+// Sizes of the QUIC packets acknowledged on the sessions
+type packetSizes struct {
+	tracers map[string]*packetTracer
+	mutex   sync.Mutex
+}
+
+func newPacketSizes() *packetSizes {
+	return &packetSizes{
+		tracers: make(map[string]*packetTracer),
+	}
+}
+
+// Largest packet acknowledged on the session, 0 if unknown
+func (s *packetSizes) get(key string) (size int, grown chan bool) {
+	s.mutex.Lock()
+	t, ok := s.tracers[key]
+	s.mutex.Unlock()
+
+	if !ok {
+		return
+	}
+	return int(atomic.LoadInt32(&t.largest)), t.grown
+}
+
+func (s *packetSizes) TracerForConnection(ctx context.Context, p logging.Perspective, odcid logging.ConnectionID) logging.ConnectionTracer {
+	return &packetTracer{
+		sizes: s,
+		sent:  make(map[logging.PacketNumber]logging.ByteCount),
+		grown: make(chan bool, 1),
+	}
+}
+
+func (s *packetSizes) SentPacket(net.Addr, *logging.Header, logging.ByteCount, []logging.Frame) {}
+
+func (s *packetSizes) DroppedPacket(net.Addr, logging.PacketType, logging.ByteCount, logging.PacketDropReason) {
+}
+
+// Records the sizes of the 1-RTT packets of a session until they are acknowledged or lost
+// Only packets larger than the largest acknowledged one are recorded
+type packetTracer struct {
+	nullTracer
+
+	// Accessed atomically, kept first for alignment
+	largest int32
+
+	sizes *packetSizes
+	key   string
+	sent  map[logging.PacketNumber]logging.ByteCount
+	grown chan bool
+	mutex sync.Mutex
+}
+
+func (t *packetTracer) StartedConnection(local, remote net.Addr, srcConnID, destConnID logging.ConnectionID) {
+	t.key = packetSizesKey(local, remote)
+
+	t.sizes.mutex.Lock()
+	t.sizes.tracers[t.key] = t
+	t.sizes.mutex.Unlock()
+}
+
+func (t *packetTracer) SentPacket(hdr *logging.ExtendedHeader, size logging.ByteCount, ack *logging.AckFrame, frames []logging.Frame) {
+	if hdr.IsLongHeader || int32(size) <= atomic.LoadInt32(&t.largest) {
+		return
+	}
+
+	t.mutex.Lock()
+	t.sent[hdr.PacketNumber] = size
+	t.mutex.Unlock()
+}
+
+func (t *packetTracer) AcknowledgedPacket(level logging.EncryptionLevel, pn logging.PacketNumber) {
+	if level != logging.Encryption1RTT {
+		return
+	}
+
+	t.mutex.Lock()
+	size, ok := t.sent[pn]
+	delete(t.sent, pn)
+	t.mutex.Unlock()
+
+	if ok && int32(size) > atomic.LoadInt32(&t.largest) {
+		atomic.StoreInt32(&t.largest, int32(size))
+		select {
+		case t.grown <- true:
+		default:
+		}
+	}
+}
+
+func (t *packetTracer) LostPacket(level logging.EncryptionLevel, pn logging.PacketNumber, reason logging.PacketLossReason) {
+	if level != logging.Encryption1RTT {
+		return
+	}
+
+	t.mutex.Lock()
+	delete(t.sent, pn)
+	t.mutex.Unlock()
+}
+
+func (t *packetTracer) Close() {
+	t.sizes.mutex.Lock()
+	if t.sizes.tracers[t.key] == t {
+		delete(t.sizes.tracers, t.key)
+	}
+	t.sizes.mutex.Unlock()
+}
+
+// ConnectionTracer ignoring all events
+type nullTracer struct{}
+
+func (nullTracer) StartedConnection(local, remote net.Addr, srcConnID, destConnID logging.ConnectionID) {
+}
+func (nullTracer) NegotiatedVersion(chosen logging.VersionNumber, clientVersions, serverVersions []logging.VersionNumber) {
+}
+func (nullTracer) ClosedConnection(error)                                              {}
+func (nullTracer) SentTransportParameters(*logging.TransportParameters)                {}
+func (nullTracer) ReceivedTransportParameters(*logging.TransportParameters)            {}
+func (nullTracer) RestoredTransportParameters(parameters *logging.TransportParameters) {}
+func (nullTracer) SentPacket(hdr *logging.ExtendedHeader, size logging.ByteCount, ack *logging.AckFrame, frames []logging.Frame) {
+}
+func (nullTracer) ReceivedVersionNegotiationPacket(*logging.Header, []logging.VersionNumber) {}
+func (nullTracer) ReceivedRetry(*logging.Header)                                             {}
+func (nullTracer) ReceivedPacket(hdr *logging.ExtendedHeader, size logging.ByteCount, frames []logging.Frame) {
+}
+func (nullTracer) BufferedPacket(logging.PacketType)                                             {}
+func (nullTracer) DroppedPacket(logging.PacketType, logging.ByteCount, logging.PacketDropReason) {}
+func (nullTracer) UpdatedMetrics(rttStats *logging.RTTStats, cwnd, bytesInFlight logging.ByteCount, packetsInFlight int) {
+}
+func (nullTracer) AcknowledgedPacket(logging.EncryptionLevel, logging.PacketNumber) {}
+func (nullTracer) LostPacket(logging.EncryptionLevel, logging.PacketNumber, logging.PacketLossReason) {
+}
+func (nullTracer) UpdatedCongestionState(logging.CongestionState)                     {}
+func (nullTracer) UpdatedPTOCount(value uint32)                                       {}
+func (nullTracer) UpdatedKeyFromTLS(logging.EncryptionLevel, logging.Perspective)     {}
+func (nullTracer) UpdatedKey(generation logging.KeyPhase, remote bool)                {}
+func (nullTracer) DroppedEncryptionLevel(logging.EncryptionLevel)                     {}
+func (nullTracer) DroppedKey(generation logging.KeyPhase)                             {}
+func (nullTracer) SetLossTimer(logging.TimerType, logging.EncryptionLevel, time.Time) {}
+func (nullTracer) LossTimerExpired(logging.TimerType, logging.EncryptionLevel)        {}
+func (nullTracer) LossTimerCanceled()                                                 {}
+func (nullTracer) Close()                                                             {}
+func (nullTracer) Debug(name, msg string)                                             {}
@@ -0,0 +1,105 @@
+package network
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/lucas-clemente/quic-go/logging"
+)
+
+func TestPacketTracer(t *testing.T) {
+	type event struct {
+		sent  logging.ByteCount
+		pn    logging.PacketNumber
+		acked bool
+		lost  bool
+	}
+
+	tests := []struct {
+		name   string
+		events []event
+		want   int
+	}{
+		{
+			name: "none acknowledged",
+			events: []event{
+				{sent: 1252, pn: 1},
+			},
+			want: 0,
+		},
+		{
+			name: "probe acknowledged",
+			events: []event{
+				{sent: 1252, pn: 1},
+				{pn: 1, acked: true},
+				{sent: 1352, pn: 2},
+				{pn: 2, acked: true},
+			},
+			want: 1352,
+		},
+		{
+			name: "probe lost",
+			events: []event{
+				{sent: 1252, pn: 1},
+				{sent: 1452, pn: 2},
+				{pn: 1, acked: true},
+				{pn: 2, lost: true},
+				{pn: 2, acked: true},
+			},
+			want: 1252,
+		},
+		{
+			name: "smaller packet acknowledged later",
+			events: []event{
+				{sent: 1402, pn: 1},
+				{sent: 1252, pn: 2},
+				{pn: 1, acked: true},
+				{pn: 2, acked: true},
+			},
+			want: 1402,
+		},
+	}
+
+	local := &net.UDPAddr{IP: net.IPv4zero, Port: 5000}
+	remote := &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 4000}
+	key := packetSizesKey(&net.UDPAddr{IP: net.ParseIP("192.0.2.2"), Port: 5000}, remote)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sizes := newPacketSizes()
+			tracer := sizes.TracerForConnection(context.Background(), logging.PerspectiveClient, nil)
+			tracer.StartedConnection(local, remote, nil, nil)
+
+			for _, e := range tt.events {
+				switch {
+				case e.acked:
+					tracer.AcknowledgedPacket(logging.Encryption1RTT, e.pn)
+				case e.lost:
+					tracer.LostPacket(logging.Encryption1RTT, e.pn, logging.PacketLossTimeThreshold)
+				default:
+					tracer.SentPacket(&logging.ExtendedHeader{PacketNumber: e.pn}, e.sent, nil, nil)
+				}
+			}
+
+			size, grown := sizes.get(key)
+			if size != tt.want {
+				t.Fatalf("size = %d, want %d", size, tt.want)
+			}
+			select {
+			case <-grown:
+				if tt.want == 0 {
+					t.Fatal("grown signalled without acknowledged packets")
+				}
+			default:
+				if tt.want != 0 {
+					t.Fatal("grown not signalled")
+				}
+			}
+
+			tracer.Close()
+			if size, _ := sizes.get(key); size != 0 {
+				t.Fatalf("size = %d after close", size)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package overlay
+
+import (
+	"sync"
+	"time"
+
+	"github.com/supergiant-hq/xnet/model"
+)
+
+const (
+	// Idle time after which a learned MAC address is forgotten
+	macTimeout = 5 * time.Minute
+	// Maximum no. of learned MAC addresses, frames to other addresses are flooded
+	maxMACEntries = 4096
+)
+
+// MAC addresses learned from frames received from peers
+// The IPv4 addresses of the peers' hosts are learned from ARP messages to answer ARP requests locally
+type macTable struct {
+	// MAC -> *macEntry
+	entries map[[6]byte]*macEntry
+	// IPv4 -> MAC
+	arp   map[[4]byte][6]byte
+	mutex sync.Mutex
+}
+
+type macEntry struct {
+	peerId   string
+	lastSeen time.Time
+}
+
+func newMACTable() *macTable {
+	return &macTable{
+		entries: make(map[[6]byte]*macEntry),
+		arp:     make(map[[4]byte][6]byte),
+	}
+}
+
+// Learn the peer a MAC address is reachable through
+func (mt *macTable) learn(mac [6]byte, peerId string) {
+	mt.mutex.Lock()
+	defer mt.mutex.Unlock()
+
+	if entry, ok := mt.entries[mac]; ok {
+		entry.peerId, entry.lastSeen = peerId, time.Now()
+		return
+	}
+
+	if len(mt.entries) >= maxMACEntries {
+		mt.expireLocked()
+		if len(mt.entries) >= maxMACEntries {
+			return
+		}
+	}
+	mt.entries[mac] = &macEntry{peerId: peerId, lastSeen: time.Now()}
+}
+
+func (mt *macTable) learnARP(ip [4]byte, mac [6]byte) {
+	mt.mutex.Lock()
+	defer mt.mutex.Unlock()
+
+	if _, ok := mt.entries[mac]; ok {
+		mt.arp[ip] = mac
+	}
+}
+
+// Find the peer of a MAC address
+func (mt *macTable) lookup(mac [6]byte) (peerId string, ok bool) {
+	mt.mutex.Lock()
+	defer mt.mutex.Unlock()
+
+	entry, ok := mt.entries[mac]
+	if !ok {
+		return
+	}
+	if time.Since(entry.lastSeen) > macTimeout {
+		mt.deleteLocked(mac)
+		return "", false
+	}
+	return entry.peerId, true
+}
+
+// Find the MAC address of an IPv4 address behind a peer
+func (mt *macTable) lookupARP(ip [4]byte) (mac [6]byte, ok bool) {
+	mt.mutex.Lock()
+	mac, ok = mt.arp[ip]
+	mt.mutex.Unlock()
+	if !ok {
+		return
+	}
+
+	_, ok = mt.lookup(mac)
+	return
+}
+
+// Forget the MAC addresses of a peer
+func (mt *macTable) remove(peerId string) {
+	mt.mutex.Lock()
+	defer mt.mutex.Unlock()
+
+	for mac, entry := range mt.entries {
+		if entry.peerId == peerId {
+			mt.deleteLocked(mac)
+		}
+	}
+}
+
+func (mt *macTable) expireLocked() {
+	for mac, entry := range mt.entries {
+		if time.Since(entry.lastSeen) > macTimeout {
+			mt.deleteLocked(mac)
+		}
+	}
+}
+
+func (mt *macTable) deleteLocked(mac [6]byte) {
+	delete(mt.entries, mac)
+	for ip, amac := range mt.arp {
+		if amac == mac {
+			delete(mt.arp, ip)
+		}
+	}
+}
+
+// If a peer takes part in the bridge
+func (o *Overlay) isBridgeMember(record *model.ClientRecord) bool {
+	if len(o.config.BridgeTag) == 0 {
+		return true
+	}
+	_, ok := record.Tags[o.config.BridgeTag]
+	return ok
+}
+
+func (o *Overlay) setBridgeMember(peerId string, member bool) {
+	o.lmutex.Lock()
+	defer o.lmutex.Unlock()
+
+	if member {
+		o.members[peerId] = true
+	} else {
+		delete(o.members, peerId)
+	}
+}
+
+// Send a frame from the device to its peer
+// Broadcast, multicast and unknown unicast frames are flooded to all members of the bridge
+func (o *Overlay) forwardFrame(frame []byte) {
+	h, ok := parseEthernetHeader(frame)
+	if !ok {
+		return
+	}
+
+	if h.etherType == etherTypeARP && o.answerARP(h) {
+		return
+	}
+
+	if !isGroupMAC(h.dst) {
+		if peerId, ok := o.macs.lookup(h.dst); ok {
+			o.send(peerId, frame)
+			return
+		}
+	}
+
+	o.lmutex.Lock()
+	members := make([]string, 0, len(o.members))
+	for peerId := range o.members {
+		members = append(members, peerId)
+	}
+	o.lmutex.Unlock()
+
+	for _, peerId := range members {
+		o.send(peerId, frame)
+	}
+}
+
+// Reply to an ARP request for a host behind a peer using the learned addresses
+// Returns true if the request was answered
+func (o *Overlay) answerARP(h ethernetHeader) bool {
+	request, ok := parseARP(h.payload)
+	if !ok || request.op != arpRequest {
+		return false
+	}
+
+	mac, ok := o.macs.lookupARP(request.targetIP)
+	if !ok {
+		return false
+	}
+
+	o.dmutex.Lock()
+	_, err := o.device.Write(buildARPReply(request, mac))
+	o.dmutex.Unlock()
+	if err != nil {
+		o.log.Errorln("Error writing ARP reply to device:", err.Error())
+	}
+	return true
+}
+
+// Learn the addresses of a frame received from a peer
+// Returns false if the frame is invalid
+func (o *Overlay) receiveFrame(l *link, frame []byte) bool {
+	h, ok := parseEthernetHeader(frame)
+	if !ok || isGroupMAC(h.src) {
+		return false
+	}
+
+	o.macs.learn(h.src, l.peerId)
+	if h.etherType == etherTypeARP {
+		if m, ok := parseARP(h.payload); ok && m.senderMAC == h.src {
+			o.macs.learnARP(m.senderIP, m.senderMAC)
+		}
+	}
+	return true
+}
@@ -0,0 +1,257 @@
+package overlay
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/supergiant-hq/xnet/tun"
+)
+
+// Capture Config
+type CaptureConfig struct {
+	// Client IDs of the peers to capture packets of, all peers if empty
+	Peers []string
+	// File to write the capture to
+	Path string
+	// Maximum size of a capture file in bytes, unlimited if 0
+	// When exceeded the file is rotated to Path.1, Path.2, ...
+	MaxFileSize int64
+	// Maximum no. of rotated files to keep along with the current file
+	// Older files are removed so the capture acts as a ring buffer
+	MaxFiles int
+	// Stream the capture to a Writer (a local socket for example) instead of a file
+	// The capture stops when writing fails, a slow Writer slows down packet forwarding
+	Writer io.Writer
+}
+
+// Packet capture of the packets exchanged with peers
+// Packets are written in the pcapng format and annotated with their direction and peer
+type Capture struct {
+	// Accessed atomically, kept first for alignment
+	packets uint64
+	bytes   uint64
+
+	overlay *Overlay
+	config  CaptureConfig
+	peers   map[string]bool
+	writer  *tun.PcapngWriter
+	file    *os.File
+	size    int64
+
+	// Closed Status
+	Closed bool
+	mutex  sync.Mutex
+}
+
+// Capture counters
+type CaptureStats struct {
+	// Packets written
+	Packets uint64
+	// Bytes of captured packets
+	Bytes uint64
+}
+
+// Start capturing packets exchanged with peers
+// Multiple captures can run at the same time
+func (o *Overlay) StartCapture(config CaptureConfig) (c *Capture, err error) {
+	if config.Writer == nil && len(config.Path) == 0 {
+		err = fmt.Errorf("overlay: capture path or writer required")
+		return
+	}
+
+	c = &Capture{
+		overlay: o,
+		config:  config,
+		peers:   make(map[string]bool),
+	}
+	for _, peerId := range config.Peers {
+		c.peers[peerId] = true
+	}
+
+	if err = c.open(); err != nil {
+		return nil, err
+	}
+
+	o.captures.Store(c, true)
+	atomic.AddInt32(&o.capturing, 1)
+
+	o.log.Infof("Capture started: %s", c.String())
+	return
+}
+
+// Stream captures to the connections accepted by a listener (a unix socket for example)
+// Each connection receives its own capture using the config, until the connection is closed
+// The stream can be read by tools like Wireshark: "socat UNIX-CONNECT:<path> - | wireshark -k -i -"
+// Returns when the listener is closed
+func (o *Overlay) ServeCapture(l net.Listener, config CaptureConfig) (err error) {
+	for {
+		var conn net.Conn
+		if conn, err = l.Accept(); err != nil {
+			return
+		}
+
+		cconfig := config
+		cconfig.Path = ""
+		cconfig.Writer = conn
+		c, err := o.StartCapture(cconfig)
+		if err != nil {
+			o.log.Errorln("Error starting capture:", err.Error())
+			conn.Close()
+			continue
+		}
+		go func() {
+			// Nothing is expected from the reader, a read returns when the connection is closed
+			io.Copy(io.Discard, conn)
+			c.Stop()
+			conn.Close()
+		}()
+	}
+}
+
+// Running captures
+func (o *Overlay) Captures() (captures []*Capture) {
+	o.captures.Range(func(key, value interface{}) bool {
+		captures = append(captures, key.(*Capture))
+		return true
+	})
+	return
+}
+
+// Capture a packet exchanged with a peer
+func (o *Overlay) capture(peerId string, pkt []byte, direction tun.PacketDirection) {
+	if atomic.LoadInt32(&o.capturing) == 0 {
+		return
+	}
+
+	ts := time.Now()
+	o.captures.Range(func(key, value interface{}) bool {
+		key.(*Capture).write(ts, peerId, pkt, direction)
+		return true
+	})
+}
+
+func (c *Capture) linkType() uint32 {
+	if c.overlay.config.Layer2 {
+		return tun.LinkTypeEthernet
+	}
+	return tun.LinkTypeRaw
+}
+
+// Open the capture file or stream
+// Must be called with the mutex held
+func (c *Capture) open() (err error) {
+	var w io.Writer = c.config.Writer
+	if w == nil {
+		if c.file, err = os.Create(c.config.Path); err != nil {
+			return
+		}
+		w = c.file
+	}
+
+	c.size = 0
+	c.writer, err = tun.NewPcapngWriter(&countingWriter{w: w, n: &c.size}, c.linkType(), c.overlay.device.Name())
+	return
+}
+
+// Rotate the capture file
+// Must be called with the mutex held
+func (c *Capture) rotate() (err error) {
+	c.file.Close()
+
+	if c.config.MaxFiles <= 0 {
+		os.Remove(c.config.Path)
+	} else {
+		os.Remove(fmt.Sprintf("%s.%d", c.config.Path, c.config.MaxFiles))
+		for i := c.config.MaxFiles - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", c.config.Path, i), fmt.Sprintf("%s.%d", c.config.Path, i+1))
+		}
+		os.Rename(c.config.Path, c.config.Path+".1")
+	}
+
+	return c.open()
+}
+
+func (c *Capture) write(ts time.Time, peerId string, pkt []byte, direction tun.PacketDirection) {
+	if len(c.peers) > 0 && !c.peers[peerId] {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.Closed {
+		return
+	}
+
+	if c.file != nil && c.config.MaxFileSize > 0 && c.size >= c.config.MaxFileSize {
+		if err := c.rotate(); err != nil {
+			c.overlay.log.Errorln("Error rotating capture file:", err.Error())
+			go c.Stop()
+			return
+		}
+	}
+
+	if _, err := c.writer.WritePacket(ts, pkt, direction, fmt.Sprintf("peer(%s)", peerId)); err != nil {
+		c.overlay.log.Errorln("Error writing capture:", err.Error())
+		go c.Stop()
+		return
+	}
+
+	atomic.AddUint64(&c.packets, 1)
+	atomic.AddUint64(&c.bytes, uint64(len(pkt)))
+}
+
+// Capture counters
+func (c *Capture) Stats() CaptureStats {
+	return CaptureStats{
+		Packets: atomic.LoadUint64(&c.packets),
+		Bytes:   atomic.LoadUint64(&c.bytes),
+	}
+}
+
+// Stop the capture
+// The capture file is closed, a Writer is left open
+func (c *Capture) Stop() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.Closed {
+		return
+	}
+	c.Closed = true
+
+	if _, ok := c.overlay.captures.LoadAndDelete(c); ok {
+		atomic.AddInt32(&c.overlay.capturing, -1)
+	}
+	if c.file != nil {
+		c.file.Close()
+	}
+
+	c.overlay.log.Infof("Capture stopped: %s packets(%d)", c.String(), atomic.LoadUint64(&c.packets))
+}
+
+// Stringify
+func (c *Capture) String() string {
+	target := c.config.Path
+	if c.config.Writer != nil {
+		target = "stream"
+	}
+	return fmt.Sprintf("target(%s) peers(%v)", target, c.config.Peers)
+}
+
+// Writer counting the bytes written
+type countingWriter struct {
+	w io.Writer
+	n *int64
+}
+
+func (cw *countingWriter) Write(b []byte) (n int, err error) {
+	n, err = cw.w.Write(b)
+	*cw.n += int64(n)
+	return
+}
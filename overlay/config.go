@@ -0,0 +1,81 @@
+package overlay
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/supergiant-hq/xnet/p2p"
+)
+
+const (
+	DefaultMTU = 1280
+	// Idle time after which a tracked flow is removed from the packet filter
+	DefaultFilterFlowTimeout = 5 * time.Minute
+	// Flows tracked by the packet filter, packets of further flows are evaluated against the rules
+	DefaultFilterMaxFlows = 1 << 16
+	// Maximum size of an IP packet
+	maxPacketSize = 1<<16 - 1
+	// Size of the buffer of packets received from a peer which are written to the device in a batch
+	linkBatchBuffer = 2 * maxPacketSize
+	// Room for the Ethernet header of frames in buffers sized using the MTU
+	deviceHeadroom = 64
+)
+
+// Overlay Config
+type Config struct {
+	// Connection mode used to reach peers
+	Mode p2p.ConnectionMode
+	// Maximum transmission unit of the packet device
+	// Overlay.SafeMTU derives it from the path MTUs to the peers
+	MTU int
+	// Reply to packets from the device exceeding the MTU derived from the path to their peer
+	// with ICMP Fragmentation Needed (IPv4 packets with Don't Fragment set) or Packet Too Big (IPv6),
+	// instead of splitting them across QUIC packets
+	EnforcePathMTU bool
+	// Client ID of the peer to use as exit node
+	// Packets which are not routed to other peers are sent to it if it offers exit service
+	ExitNode string
+	// Idle time after which a tracked flow is removed from the packet filter
+	FilterFlowTimeout time.Duration
+	// Flows tracked by the packet filter, DefaultFilterMaxFlows if 0
+	FilterMaxFlows int
+	// Bridge Ethernet frames between peers instead of routing IP packets
+	// The device has to be a TAP Device, the packet filter and exit node are not used
+	Layer2 bool
+	// Peers having the Tag take part in the bridge, all peers if empty
+	BridgeTag string
+}
+
+// Create Overlay Config
+func NewConfig(mode p2p.ConnectionMode, mtu int) Config {
+	return Config{
+		Mode: mode,
+		MTU:  mtu,
+	}
+}
+
+func (c *Config) init() (err error) {
+	switch c.Mode {
+	case "":
+		c.Mode = p2p.ConnectionModeP2P
+	case p2p.ConnectionModeP2P, p2p.ConnectionModeRelay, p2p.ConnectionModeRouted:
+	default:
+		return fmt.Errorf("overlay: invalid connection mode: %v", c.Mode)
+	}
+
+	if c.MTU == 0 {
+		c.MTU = DefaultMTU
+	}
+	if c.MTU < 576 || c.MTU > maxPacketSize {
+		return fmt.Errorf("overlay: invalid mtu(%d)", c.MTU)
+	}
+
+	if c.FilterFlowTimeout == 0 {
+		c.FilterFlowTimeout = DefaultFilterFlowTimeout
+	}
+	if c.FilterMaxFlows <= 0 {
+		c.FilterMaxFlows = DefaultFilterMaxFlows
+	}
+
+	return
+}
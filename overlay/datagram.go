@@ -0,0 +1,170 @@
+package overlay
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+
+	p2pc "github.com/supergiant-hq/xnet/p2p/client"
+)
+
+const (
+	// Overlay Datagram carrying whole packets, each prefixed by its length
+	datagramPackets = 0x01
+	// Overlay Datagram carrying a fragment of a packet larger than a Datagram
+	datagramFragment = 0x02
+
+	// Kind, packet ID, fragment index and no. of fragments
+	fragmentHeader = 1 + 4 + 1 + 1
+	// Data carried by a fragment
+	fragmentSize = p2pc.MaxDatagramSize - fragmentHeader
+	// Largest packet which fits into a single overlay Datagram
+	maxDatagramPacket = p2pc.MaxDatagramSize - 1 - 2
+
+	// No. of packets reassembled at the same time, the oldest is dropped beyond it
+	maxFragmentedPackets = 64
+	// Time to receive the remaining fragments of a packet
+	fragmentTimeout = time.Second
+)
+
+// Pack packets into overlay Datagrams
+// Packets share a Datagram up to MaxDatagramSize, larger packets are split into fragments
+func packDatagrams(nextId func() uint32, pkts ...[]byte) (datagrams [][]byte) {
+	var d []byte
+	for _, pkt := range pkts {
+		// Packets are kept in order
+		if d != nil && (len(pkt) > maxDatagramPacket || len(d)+2+len(pkt) > p2pc.MaxDatagramSize) {
+			datagrams = append(datagrams, d)
+			d = nil
+		}
+		if len(pkt) > maxDatagramPacket {
+			datagrams = append(datagrams, fragmentPacket(nextId(), pkt)...)
+			continue
+		}
+		if d == nil {
+			d = make([]byte, 1, p2pc.MaxDatagramSize)
+			d[0] = datagramPackets
+		}
+		d = appendFrame(d, pkt)
+	}
+	if d != nil {
+		datagrams = append(datagrams, d)
+	}
+	return
+}
+
+// Split a packet into fragments
+func fragmentPacket(id uint32, pkt []byte) (fragments [][]byte) {
+	count := (len(pkt) + fragmentSize - 1) / fragmentSize
+	for i := 0; i < count; i++ {
+		data := pkt[i*fragmentSize:]
+		if len(data) > fragmentSize {
+			data = data[:fragmentSize]
+		}
+
+		f := make([]byte, fragmentHeader+len(data))
+		f[0] = datagramFragment
+		binary.BigEndian.PutUint32(f[1:5], id)
+		f[5], f[6] = byte(i), byte(count)
+		copy(f[fragmentHeader:], data)
+		fragments = append(fragments, f)
+	}
+	return
+}
+
+// Packet being reassembled from its fragments
+type fragmentedPacket struct {
+	fragments [][]byte
+	received  int
+	created   time.Time
+}
+
+// Reassembles the packets fragmented by a peer
+type reassembler struct {
+	packets map[uint32]*fragmentedPacket
+	mutex   sync.Mutex
+}
+
+func newReassembler() *reassembler {
+	return &reassembler{
+		packets: make(map[uint32]*fragmentedPacket),
+	}
+}
+
+// Packets carried by an overlay Datagram
+// A fragment yields the packet it completes, malformed Datagrams yield nothing
+func (r *reassembler) unpack(b []byte) (pkts [][]byte) {
+	if len(b) == 0 {
+		return
+	}
+
+	switch b[0] {
+	case datagramPackets:
+		for b = b[1:]; len(b) >= 2; {
+			size := int(binary.BigEndian.Uint16(b[:2]))
+			if 2+size > len(b) {
+				return nil
+			}
+			pkts = append(pkts, b[2:2+size])
+			b = b[2+size:]
+		}
+	case datagramFragment:
+		if len(b) <= fragmentHeader {
+			return
+		}
+		if pkt := r.add(binary.BigEndian.Uint32(b[1:5]), int(b[5]), int(b[6]), b[fragmentHeader:]); pkt != nil {
+			pkts = append(pkts, pkt)
+		}
+	}
+	return
+}
+
+// Add a fragment, returns the packet once all of its fragments were received
+func (r *reassembler) add(id uint32, index, count int, data []byte) (pkt []byte) {
+	if index >= count {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	p, ok := r.packets[id]
+	if !ok {
+		r.expire()
+		p = &fragmentedPacket{
+			fragments: make([][]byte, count),
+			created:   time.Now(),
+		}
+		r.packets[id] = p
+	}
+	if len(p.fragments) != count || p.fragments[index] != nil {
+		return
+	}
+	p.fragments[index] = data
+	if p.received++; p.received < count {
+		return
+	}
+
+	delete(r.packets, id)
+	for _, f := range p.fragments {
+		pkt = append(pkt, f...)
+	}
+	return
+}
+
+// Drop packets whose fragments did not arrive in time, and the oldest one if too many are left
+// Called under the mutex
+func (r *reassembler) expire() {
+	var oldestId uint32
+	var oldest *fragmentedPacket
+	for id, p := range r.packets {
+		if time.Since(p.created) > fragmentTimeout {
+			delete(r.packets, id)
+		} else if oldest == nil || p.created.Before(oldest.created) {
+			oldestId, oldest = id, p
+		}
+	}
+	if oldest != nil && len(r.packets) >= maxFragmentedPackets {
+		delete(r.packets, oldestId)
+	}
+}
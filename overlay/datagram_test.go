@@ -0,0 +1,129 @@
+package overlay
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	p2pc "github.com/supergiant-hq/xnet/p2p/client"
+)
+
+func testPacket(size int, fill byte) []byte {
+	return bytes.Repeat([]byte{fill}, size)
+}
+
+func TestPackDatagrams(t *testing.T) {
+	tests := []struct {
+		name      string
+		sizes     []int
+		datagrams int
+	}{
+		{"single", []int{100}, 1},
+		{"shared", []int{100, 200, 300}, 1},
+		{"full", []int{maxDatagramPacket}, 1},
+		{"split", []int{600, 600}, 2},
+		{"fragmented", []int{maxDatagramPacket + 1}, 2},
+		{"largest", []int{maxPacketSize}, (maxPacketSize + fragmentSize - 1) / fragmentSize},
+		{"mixed", []int{100, 3000, 100}, 1 + 3 + 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pkts := [][]byte{}
+			for i, size := range test.sizes {
+				pkts = append(pkts, testPacket(size, byte(i+1)))
+			}
+
+			id := uint32(0)
+			datagrams := packDatagrams(func() uint32 { id++; return id }, pkts...)
+			if len(datagrams) != test.datagrams {
+				t.Fatalf("datagrams %d, want %d", len(datagrams), test.datagrams)
+			}
+
+			r := newReassembler()
+			received := [][]byte{}
+			for _, d := range datagrams {
+				if len(d) > p2pc.MaxDatagramSize {
+					t.Fatalf("datagram size %d above %d", len(d), p2pc.MaxDatagramSize)
+				}
+				received = append(received, r.unpack(d)...)
+			}
+			if len(received) != len(pkts) {
+				t.Fatalf("packets %d, want %d", len(received), len(pkts))
+			}
+			for i := range pkts {
+				if !bytes.Equal(received[i], pkts[i]) {
+					t.Fatalf("packet %d differs", i)
+				}
+			}
+		})
+	}
+}
+
+func TestReassembler(t *testing.T) {
+	pkt := testPacket(3*fragmentSize, 1)
+	fragments := fragmentPacket(1, pkt)
+
+	tests := []struct {
+		name  string
+		order []int
+		want  bool
+	}{
+		{"in order", []int{0, 1, 2}, true},
+		{"reordered", []int{2, 0, 1}, true},
+		{"duplicate", []int{0, 0, 1}, false},
+		{"missing", []int{0, 2}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newReassembler()
+			var received [][]byte
+			for _, i := range test.order {
+				received = append(received, r.unpack(fragments[i])...)
+			}
+			if got := len(received) == 1 && bytes.Equal(received[0], pkt); got != test.want {
+				t.Fatalf("reassembled %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReassemblerExpiry(t *testing.T) {
+	r := newReassembler()
+	for id := uint32(1); id <= 2*maxFragmentedPackets; id++ {
+		r.unpack(fragmentPacket(id, testPacket(2*fragmentSize, 1))[0])
+	}
+	if len(r.packets) > maxFragmentedPackets {
+		t.Fatalf("packets %d above %d", len(r.packets), maxFragmentedPackets)
+	}
+
+	for _, p := range r.packets {
+		p.created = time.Now().Add(-2 * fragmentTimeout)
+	}
+	r.unpack(fragmentPacket(0, testPacket(2*fragmentSize, 1))[0])
+	if len(r.packets) != 1 {
+		t.Fatalf("packets %d, want expired packets dropped", len(r.packets))
+	}
+}
+
+func TestUnpackMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		b    []byte
+	}{
+		{"empty", nil},
+		{"unknown kind", []byte{0xff, 0, 1, 0}},
+		{"truncated packet", []byte{datagramPackets, 0, 10, 1, 2}},
+		{"fragment without data", []byte{datagramFragment, 0, 0, 0, 1, 0, 2}},
+		{"fragment index above count", []byte{datagramFragment, 0, 0, 0, 1, 2, 2, 1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if pkts := newReassembler().unpack(test.b); len(pkts) != 0 {
+				t.Fatalf("packets %d, want none", len(pkts))
+			}
+		})
+	}
+}
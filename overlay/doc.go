@@ -0,0 +1,10 @@
+// Package overlay provides functionality to forward IP packets between overlay peers
+//
+// Packets are read from a packet device such as a TUN Device or a userspace netstack.Stack,
+// routed to a peer using the overlay addresses published by the Broker Server
+// and sent over an overlay stream of a P2P connection
+//
+// Peers can act as subnet routers by advertising networks behind them using brokerc.Client.AdvertiseRoutes.
+// Packets for those networks are sent to the peer which writes them to its device,
+// the host has to forward them (e.g. net.ipv4.ip_forward on Linux)
+package overlay
@@ -0,0 +1,119 @@
+package overlay
+
+import (
+	"net"
+
+	"github.com/supergiant-hq/xnet/tun"
+)
+
+// Called to authorise a peer to send traffic through the exit device
+type ExitAccessHandler func(peerId string) bool
+
+// Default routes via an exit node
+func defaultRoutes(peerId string) []Route {
+	return []Route{
+		{
+			Network: &net.IPNet{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 32)},
+			PeerId:  peerId,
+		},
+		{
+			Network: &net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)},
+			PeerId:  peerId,
+		},
+	}
+}
+
+// Use the Client as an exit node
+// Packets from peers which are not addressed to the Client or its subnets are written to the exit device,
+// which is usually a netstack.Stack with Forward enabled acting as a source NAT
+// Packets read from the exit device are routed back to the peers
+// Exit service has to be advertised to the Broker Server using brokerc.Client.SetExitNode
+func (o *Overlay) EnableExit(device tun.Device) {
+	o.lmutex.Lock()
+	o.exitDevice = device
+	o.lmutex.Unlock()
+
+	go o.exitLoop(device)
+	o.log.Infoln("Exit enabled")
+}
+
+// Set Exit Access Handler
+// All peers can use the exit device if not set
+func (o *Overlay) SetExitAccessHandler(handler ExitAccessHandler) {
+	o.exitAccessHandler = handler
+}
+
+func (o *Overlay) setLocal(routes []Route) {
+	local := []*net.IPNet{}
+	for _, route := range routes {
+		local = append(local, route.Network)
+	}
+
+	o.lmutex.Lock()
+	o.local = local
+	o.lmutex.Unlock()
+}
+
+// If the IP is an overlay address or subnet of the Client
+func (o *Overlay) isLocal(ip net.IP) bool {
+	o.lmutex.Lock()
+	defer o.lmutex.Unlock()
+
+	for _, network := range o.local {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Write a packet from a peer to the exit device if it is not addressed to the Client
+// Returns true if the packet was consumed
+func (o *Overlay) exitPacket(l *link, pkt []byte) bool {
+	o.lmutex.Lock()
+	device := o.exitDevice
+	o.lmutex.Unlock()
+	if device == nil {
+		return false
+	}
+
+	dst, ok := packetDestination(pkt)
+	if !ok || o.isLocal(dst) {
+		return false
+	}
+
+	// The source has to be an address of the sending peer
+	src, ok := packetSource(pkt)
+	if !ok {
+		return true
+	}
+	if route, ok := o.routes.lookup(src); !ok || route.PeerId != l.peerId || route.Network.IP.IsUnspecified() {
+		o.log.Debugf("Dropping exit packet from peer(%s): source(%s) not owned", l.peerId, src.String())
+		return true
+	}
+	if o.exitAccessHandler != nil && !o.exitAccessHandler(l.peerId) {
+		o.log.Debugf("Dropping exit packet from peer(%s): not authorised", l.peerId)
+		return true
+	}
+
+	if _, err := device.Write(pkt); err != nil {
+		o.log.Errorln("Error writing to exit device:", err.Error())
+	}
+	return true
+}
+
+// Route packets read from the exit device back to the peers
+func (o *Overlay) exitLoop(device tun.Device) {
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, err := device.Read(buf)
+		if err != nil {
+			if !o.closed() {
+				o.log.Errorln("Error reading from exit device:", err.Error())
+			}
+			return
+		}
+
+		o.forward(buf[:n])
+	}
+}
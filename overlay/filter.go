@@ -0,0 +1,410 @@
+package overlay
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/supergiant-hq/xnet/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Packet filter counters
+type FilterStats struct {
+	// Packets accepted from peers
+	Allowed uint64
+	// Packets dropped by the policy
+	Denied uint64
+	// Packets dropped as their source is not an address or a route of the sending peer
+	Spoofed uint64
+	// Tracked flows
+	Flows int
+}
+
+// Stateful packet filter for packets received from peers
+// Packets are dropped unless their source is an overlay address or a route of the sending peer,
+// the rules are evaluated on the remaining packets
+// Flows initiated by the Client and flows accepted by the policy are tracked up to maxFlows,
+// so that the following packets of a flow are accepted without evaluating the rules
+// Fragments other than the first one carry no ports and only match rules without ports
+type filter struct {
+	// Accessed atomically, kept first for alignment
+	allowed uint64
+	denied  uint64
+	spoofed uint64
+
+	// nil if filtering is disabled
+	policy   *filterPolicy
+	flows    map[flowKey]*flow
+	maxFlows int
+	timeout  time.Duration
+	mutex    sync.Mutex
+	ticker   *time.Ticker
+	exit     chan bool
+
+	// Client ID -> Tags
+	peerTags func(peerId string) map[string]string
+	// If an IP is an overlay address or in a route of a peer
+	peerSource func(peerId string, ip net.IP) bool
+
+	log *logrus.Entry
+}
+
+type filterPolicy struct {
+	rules        []*filterRule
+	defaultAllow bool
+	logDenied    bool
+}
+
+type filterRule struct {
+	allow        bool
+	peers        map[string]bool
+	tags         []string
+	sources      []*net.IPNet
+	destinations []*net.IPNet
+	// 0 matches all protocols
+	protocol uint8
+	ports    []*model.PortRange
+	log      bool
+}
+
+// Flow as seen from the Client
+type flowKey struct {
+	proto      uint8
+	local      [16]byte
+	localPort  uint16
+	remote     [16]byte
+	remotePort uint16
+}
+
+type flow struct {
+	// Peer at the other end of the flow
+	peerId   string
+	lastSeen time.Time
+	// Accepted by the policy rather than initiated by the Client
+	inbound bool
+}
+
+func newFilter(
+	log *logrus.Entry,
+	timeout time.Duration,
+	maxFlows int,
+	peerTags func(peerId string) map[string]string,
+	peerSource func(peerId string, ip net.IP) bool,
+) *filter {
+	f := &filter{
+		flows:      make(map[flowKey]*flow),
+		maxFlows:   maxFlows,
+		timeout:    timeout,
+		ticker:     time.NewTicker(timeout / 2),
+		exit:       make(chan bool),
+		peerTags:   peerTags,
+		peerSource: peerSource,
+		log:        log,
+	}
+	go f.reapLoop()
+	return f
+}
+
+// Replace the policy
+// Flows accepted by the previous policy are evaluated again
+func (f *filter) setPolicy(mpolicy *model.FilterPolicy) {
+	var policy *filterPolicy
+	if mpolicy.Enabled {
+		policy = &filterPolicy{
+			defaultAllow: mpolicy.DefaultAllow,
+			logDenied:    mpolicy.LogDenied,
+		}
+		for i, mrule := range mpolicy.Rules {
+			rule, err := newFilterRule(mrule)
+			if err != nil {
+				f.log.Errorf("Ignoring filter rule(%d): %s", i, err.Error())
+				continue
+			}
+			policy.rules = append(policy.rules, rule)
+		}
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.policy = policy
+	for key, fl := range f.flows {
+		if fl.inbound {
+			delete(f.flows, key)
+		}
+	}
+
+	if policy == nil {
+		f.log.Infoln("Packet filter disabled")
+	} else {
+		f.log.Infof("Packet filter enabled: rules(%d) default-allow(%v)", len(policy.rules), policy.defaultAllow)
+	}
+}
+
+func newFilterRule(mrule *model.FilterRule) (rule *filterRule, err error) {
+	rule = &filterRule{
+		allow: mrule.Allow,
+		peers: make(map[string]bool),
+		tags:  mrule.Tags,
+		ports: mrule.Ports,
+		log:   mrule.Log,
+	}
+
+	for _, peer := range mrule.Peers {
+		rule.peers[peer] = true
+	}
+	if rule.sources, err = parseNetworks(mrule.Sources); err != nil {
+		return
+	}
+	if rule.destinations, err = parseNetworks(mrule.Destinations); err != nil {
+		return
+	}
+
+	switch strings.ToLower(mrule.Protocol) {
+	case "", "any":
+	case "tcp":
+		rule.protocol = protoTCP
+	case "udp":
+		rule.protocol = protoUDP
+	case "icmp":
+		rule.protocol = protoICMP
+	default:
+		err = fmt.Errorf("invalid protocol: %s", mrule.Protocol)
+		return
+	}
+
+	for _, port := range mrule.Ports {
+		if port.Start > port.End || port.End > 65535 {
+			err = fmt.Errorf("invalid port range: %d-%d", port.Start, port.End)
+			return
+		}
+	}
+
+	return
+}
+
+func parseNetworks(cidrs []string) (networks []*net.IPNet, err error) {
+	for _, cidr := range cidrs {
+		var network *net.IPNet
+		if _, network, err = net.ParseCIDR(cidr); err != nil {
+			if ip := net.ParseIP(cidr); ip != nil {
+				bits := len(ip) * 8
+				if ip4 := ip.To4(); ip4 != nil {
+					ip, bits = ip4, net.IPv4len*8
+				}
+				network, err = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+			} else {
+				err = fmt.Errorf("invalid network: %s", cidr)
+				return
+			}
+		}
+		networks = append(networks, network)
+	}
+	return
+}
+
+// If the rule matches a packet received from a peer
+func (r *filterRule) match(peerId string, tags map[string]string, p *packetInfo) bool {
+	if len(r.peers) > 0 || len(r.tags) > 0 {
+		matched := r.peers[peerId]
+		for _, tag := range r.tags {
+			if matched {
+				break
+			}
+			_, matched = tags[tag]
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if len(r.sources) > 0 && !containsIP(r.sources, p.src) {
+		return false
+	}
+	if len(r.destinations) > 0 && !containsIP(r.destinations, p.dst) {
+		return false
+	}
+
+	switch r.protocol {
+	case 0:
+	case protoICMP:
+		if p.proto != protoICMP && p.proto != protoICMPv6 {
+			return false
+		}
+	default:
+		if p.proto != r.protocol {
+			return false
+		}
+	}
+
+	if len(r.ports) > 0 {
+		if p.proto != protoTCP && p.proto != protoUDP {
+			return false
+		}
+		matched := false
+		for _, port := range r.ports {
+			if uint32(p.dstPort) >= port.Start && uint32(p.dstPort) <= port.End {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Track a packet sent by the Client to a peer
+func (f *filter) track(peerId string, pkt []byte) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.policy == nil {
+		return
+	}
+
+	p, ok := parsePacketInfo(pkt)
+	if !ok {
+		return
+	}
+
+	key := newFlowKey(p.proto, p.src, p.srcPort, p.dst, p.dstPort)
+	if fl, ok := f.flows[key]; ok && fl.peerId == peerId {
+		fl.lastSeen = time.Now()
+		return
+	}
+	f.addFlow(key, &flow{peerId: peerId, lastSeen: time.Now()})
+}
+
+// Track a flow unless maxFlows are tracked, called under the mutex
+func (f *filter) addFlow(key flowKey, fl *flow) {
+	if _, ok := f.flows[key]; !ok && len(f.flows) >= f.maxFlows {
+		return
+	}
+	f.flows[key] = fl
+}
+
+// Check a packet received from a peer
+func (f *filter) allow(peerId string, pkt []byte) bool {
+	p, ok := parsePacketInfo(pkt)
+	if !ok {
+		atomic.AddUint64(&f.denied, 1)
+		return false
+	}
+	if f.peerSource != nil && !f.peerSource(peerId, p.src) {
+		atomic.AddUint64(&f.spoofed, 1)
+		f.log.Debugf("Dropping packet from peer(%s): source(%s) not owned", peerId, p.src.String())
+		return false
+	}
+
+	f.mutex.Lock()
+	policy := f.policy
+	f.mutex.Unlock()
+
+	if policy == nil {
+		atomic.AddUint64(&f.allowed, 1)
+		return true
+	}
+
+	key := newFlowKey(p.proto, p.dst, p.dstPort, p.src, p.srcPort)
+	f.mutex.Lock()
+	if fl, ok := f.flows[key]; ok && fl.peerId == peerId {
+		fl.lastSeen = time.Now()
+		f.mutex.Unlock()
+		atomic.AddUint64(&f.allowed, 1)
+		return true
+	}
+	f.mutex.Unlock()
+
+	allow, log := policy.defaultAllow, policy.logDenied
+	var tags map[string]string
+	if f.peerTags != nil {
+		tags = f.peerTags(peerId)
+	}
+	for _, rule := range policy.rules {
+		if rule.match(peerId, tags, &p) {
+			allow, log = rule.allow, rule.log
+			break
+		}
+	}
+
+	if !allow {
+		atomic.AddUint64(&f.denied, 1)
+		if log {
+			f.log.Infof("Denied packet from peer(%s): %s", peerId, p.String())
+		}
+		return false
+	}
+
+	f.mutex.Lock()
+	// The policy may have changed during evaluation
+	if f.policy == policy {
+		f.addFlow(key, &flow{peerId: peerId, lastSeen: time.Now(), inbound: true})
+	}
+	f.mutex.Unlock()
+
+	atomic.AddUint64(&f.allowed, 1)
+	return true
+}
+
+func newFlowKey(proto uint8, local net.IP, localPort uint16, remote net.IP, remotePort uint16) (key flowKey) {
+	key.proto = proto
+	copy(key.local[:], local.To16())
+	key.localPort = localPort
+	copy(key.remote[:], remote.To16())
+	key.remotePort = remotePort
+	return
+}
+
+func (f *filter) stats() FilterStats {
+	f.mutex.Lock()
+	flows := len(f.flows)
+	f.mutex.Unlock()
+
+	return FilterStats{
+		Allowed: atomic.LoadUint64(&f.allowed),
+		Denied:  atomic.LoadUint64(&f.denied),
+		Spoofed: atomic.LoadUint64(&f.spoofed),
+		Flows:   flows,
+	}
+}
+
+// Remove idle flows
+func (f *filter) reapLoop() {
+	for {
+		select {
+		case <-f.ticker.C:
+		case <-f.exit:
+			return
+		}
+
+		f.mutex.Lock()
+		for key, fl := range f.flows {
+			if time.Since(fl.lastSeen) > f.timeout {
+				delete(f.flows, key)
+			}
+		}
+		f.mutex.Unlock()
+	}
+}
+
+func (f *filter) close() {
+	f.ticker.Stop()
+	close(f.exit)
+}
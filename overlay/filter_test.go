@@ -0,0 +1,106 @@
+package overlay
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/supergiant-hq/xnet/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// IPv4 UDP packet between two addresses and ports
+func testUDPPacket(src, dst string, srcPort, dstPort uint16) []byte {
+	b := make([]byte, 20+8)
+	b[0] = 0x45
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(b)))
+	b[8], b[9] = 64, protoUDP
+	copy(b[12:16], net.ParseIP(src).To4())
+	copy(b[16:20], net.ParseIP(dst).To4())
+	binary.BigEndian.PutUint16(b[20:22], srcPort)
+	binary.BigEndian.PutUint16(b[22:24], dstPort)
+	binary.BigEndian.PutUint16(b[24:26], 8)
+	return b
+}
+
+func newTestFilter(maxFlows int) *filter {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	rt := newRoutes()
+	rt.set("a", recordRoutes(&model.ClientRecord{
+		Id:               "a",
+		OverlayAddresses: []*model.OverlayAddress{{Ip: "100.64.0.1"}},
+		Routes:           []*model.SubnetRoute{{Network: "192.168.1.0/24"}},
+	}))
+	rt.set("b", recordRoutes(&model.ClientRecord{
+		Id:               "b",
+		OverlayAddresses: []*model.OverlayAddress{{Ip: "100.64.0.2"}},
+	}))
+	return newFilter(log.WithField("prefix", "OVERLAY"), time.Minute, maxFlows, nil, rt.advertised)
+}
+
+func TestFilterAllow(t *testing.T) {
+	policy := &model.FilterPolicy{
+		Enabled: true,
+		Rules: []*model.FilterRule{
+			{Allow: true, Peers: []string{"a"}, Protocol: "udp", Ports: []*model.PortRange{{Start: 53, End: 53}}},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		policy  *model.FilterPolicy
+		peerId  string
+		pkt     []byte
+		allow   bool
+		spoofed uint64
+	}{
+		{"no policy", &model.FilterPolicy{}, "a", testUDPPacket("100.64.0.1", "100.64.0.9", 1000, 80), true, 0},
+		{"no policy from route", &model.FilterPolicy{}, "a", testUDPPacket("192.168.1.7", "100.64.0.9", 1000, 80), true, 0},
+		{"no policy spoofed", &model.FilterPolicy{}, "a", testUDPPacket("100.64.0.2", "100.64.0.9", 1000, 80), false, 1},
+		{"no policy unknown source", &model.FilterPolicy{}, "b", testUDPPacket("10.0.0.1", "100.64.0.9", 1000, 80), false, 1},
+		{"rule allows", policy, "a", testUDPPacket("100.64.0.1", "100.64.0.9", 1000, 53), true, 0},
+		{"rule does not match", policy, "a", testUDPPacket("100.64.0.1", "100.64.0.9", 1000, 80), false, 0},
+		{"rule allows spoofed", policy, "b", testUDPPacket("100.64.0.1", "100.64.0.9", 1000, 53), false, 1},
+		{"malformed", &model.FilterPolicy{}, "a", []byte{0x45, 0}, false, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newTestFilter(DefaultFilterMaxFlows)
+			defer f.close()
+			f.setPolicy(test.policy)
+
+			if allow := f.allow(test.peerId, test.pkt); allow != test.allow {
+				t.Fatalf("allow %v, want %v", allow, test.allow)
+			}
+			if spoofed := f.stats().Spoofed; spoofed != test.spoofed {
+				t.Fatalf("spoofed %d, want %d", spoofed, test.spoofed)
+			}
+		})
+	}
+}
+
+// Replies to flows of the Client are accepted, new flows are not tracked beyond maxFlows
+func TestFilterMaxFlows(t *testing.T) {
+	f := newTestFilter(2)
+	defer f.close()
+	f.setPolicy(&model.FilterPolicy{Enabled: true})
+
+	for port := uint16(1); port <= 3; port++ {
+		f.track("a", testUDPPacket("100.64.0.9", "100.64.0.1", 1000+port, 53))
+	}
+	if flows := f.stats().Flows; flows != 2 {
+		t.Fatalf("flows %d, want 2", flows)
+	}
+
+	for port, allow := range map[uint16]bool{1: true, 2: true, 3: false} {
+		if got := f.allow("a", testUDPPacket("100.64.0.1", "100.64.0.9", 53, 1000+port)); got != allow {
+			t.Fatalf("reply to flow(%d) allowed %v, want %v", port, got, allow)
+		}
+	}
+}
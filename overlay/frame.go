@@ -0,0 +1,84 @@
+package overlay
+
+import "encoding/binary"
+
+const (
+	ethernetHeaderSize = 14
+	etherTypeARP       = 0x0806
+	etherTypeIPv4      = 0x0800
+	arpSize            = 28
+)
+
+// Ethernet frame header
+type ethernetHeader struct {
+	dst       [6]byte
+	src       [6]byte
+	etherType uint16
+	// Payload after the header, VLAN tagged frames are not unwrapped
+	payload []byte
+}
+
+func parseEthernetHeader(b []byte) (h ethernetHeader, ok bool) {
+	if len(b) < ethernetHeaderSize {
+		return
+	}
+	copy(h.dst[:], b[0:6])
+	copy(h.src[:], b[6:12])
+	h.etherType = binary.BigEndian.Uint16(b[12:14])
+	h.payload = b[ethernetHeaderSize:]
+	return h, true
+}
+
+// Broadcast and multicast addresses have the group bit set
+func isGroupMAC(mac [6]byte) bool {
+	return mac[0]&0x01 != 0
+}
+
+// ARP message for IPv4 over Ethernet
+type arpMessage struct {
+	op        uint16
+	senderMAC [6]byte
+	senderIP  [4]byte
+	targetMAC [6]byte
+	targetIP  [4]byte
+}
+
+const (
+	arpRequest = 1
+	arpReply   = 2
+)
+
+func parseARP(b []byte) (m arpMessage, ok bool) {
+	if len(b) < arpSize {
+		return
+	}
+	// Ethernet hardware and IPv4 protocol addresses only
+	if binary.BigEndian.Uint16(b[0:2]) != 1 || binary.BigEndian.Uint16(b[2:4]) != etherTypeIPv4 || b[4] != 6 || b[5] != 4 {
+		return
+	}
+	m.op = binary.BigEndian.Uint16(b[6:8])
+	copy(m.senderMAC[:], b[8:14])
+	copy(m.senderIP[:], b[14:18])
+	copy(m.targetMAC[:], b[18:24])
+	copy(m.targetIP[:], b[24:28])
+	return m, true
+}
+
+// Ethernet frame carrying an ARP reply to a request
+func buildARPReply(request arpMessage, mac [6]byte) []byte {
+	b := make([]byte, ethernetHeaderSize+arpSize)
+	copy(b[0:6], request.senderMAC[:])
+	copy(b[6:12], mac[:])
+	binary.BigEndian.PutUint16(b[12:14], etherTypeARP)
+
+	arp := b[ethernetHeaderSize:]
+	binary.BigEndian.PutUint16(arp[0:2], 1)
+	binary.BigEndian.PutUint16(arp[2:4], etherTypeIPv4)
+	arp[4], arp[5] = 6, 4
+	binary.BigEndian.PutUint16(arp[6:8], arpReply)
+	copy(arp[8:14], mac[:])
+	copy(arp[14:18], request.targetIP[:])
+	copy(arp[18:24], request.senderMAC[:])
+	copy(arp[24:28], request.senderIP[:])
+	return b
+}
@@ -0,0 +1,128 @@
+package overlay
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	p2pc "github.com/supergiant-hq/xnet/p2p/client"
+	"github.com/supergiant-hq/xnet/udp"
+)
+
+// Overlay stream to a peer
+// Packets are carried by overlay Datagrams, the stream carries them if the Connection does not support Datagrams
+type link struct {
+	// Accessed atomically, kept first for alignment
+	nextFragmentId uint32
+	streamOnly     int32
+
+	peerId string
+	conn   *p2pc.Connection
+	stream *udp.Stream
+	// Connection was created by the Overlay
+	owned  bool
+	reader *bufio.Reader
+	wmutex sync.Mutex
+	// Reassembles the packets fragmented across overlay Datagrams
+	fragments    *reassembler
+	sendDatagram func(b []byte) error
+}
+
+func newLink(conn *p2pc.Connection, stream *udp.Stream, owned bool) *link {
+	return &link{
+		peerId:       conn.PeerId(),
+		conn:         conn,
+		stream:       stream,
+		owned:        owned,
+		reader:       bufio.NewReaderSize(stream.Stream(), maxPacketSize+1),
+		fragments:    newReassembler(),
+		sendDatagram: conn.SendOverlayDatagram,
+	}
+}
+
+// Send packets to the peer
+// Packets are packed into overlay Datagrams which are lost if the connection fails,
+// the stream is used once the Connection reports that it does not support Datagrams
+func (l *link) write(pkts ...[]byte) (err error) {
+	if atomic.LoadInt32(&l.streamOnly) == 0 {
+		if err = l.writeDatagrams(pkts); err != udp.ErrorDatagramsUnsupported {
+			return
+		}
+		atomic.StoreInt32(&l.streamOnly, 1)
+	}
+	return l.writeStream(pkts)
+}
+
+// Send packets in overlay Datagrams
+// Only the lack of Datagram support is returned as an error, other errors drop the packets
+func (l *link) writeDatagrams(pkts [][]byte) (err error) {
+	for _, d := range packDatagrams(l.fragmentId, pkts...) {
+		if err = l.sendDatagram(d); err == udp.ErrorDatagramsUnsupported {
+			return
+		}
+	}
+	return nil
+}
+
+func (l *link) fragmentId() uint32 {
+	return atomic.AddUint32(&l.nextFragmentId, 1)
+}
+
+// Send packets over the stream
+// The packets are framed into a single write so they share QUIC packets
+func (l *link) writeStream(pkts [][]byte) (err error) {
+	var b []byte
+	if len(pkts) == 1 {
+		b = framePacket(pkts[0])
+	} else {
+		for _, pkt := range pkts {
+			b = appendFrame(b, pkt)
+		}
+	}
+
+	l.wmutex.Lock()
+	defer l.wmutex.Unlock()
+
+	_, err = l.stream.Stream().Write(b)
+	return
+}
+
+// Receive a packet from the peer
+func (l *link) read(buf []byte) (pkt []byte, err error) {
+	if _, err = io.ReadFull(l.reader, buf[:2]); err != nil {
+		return
+	}
+	size := int(binary.BigEndian.Uint16(buf[:2]))
+	if size > len(buf) {
+		err = fmt.Errorf("packet too large: %d", size)
+		return
+	}
+	if _, err = io.ReadFull(l.reader, buf[:size]); err != nil {
+		return
+	}
+	return buf[:size], nil
+}
+
+// If a complete packet was received and can be read without blocking
+func (l *link) buffered() bool {
+	if l.reader.Buffered() < 2 {
+		return false
+	}
+	header, err := l.reader.Peek(2)
+	if err != nil {
+		return false
+	}
+	return l.reader.Buffered() >= 2+int(binary.BigEndian.Uint16(header))
+}
+
+func (l *link) close() {
+	l.stream.Close()
+}
+
+// Stringify
+func (l *link) String() string {
+	return fmt.Sprintf("peer(%s) stream(%s)", l.peerId, l.stream.Id)
+}
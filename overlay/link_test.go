@@ -0,0 +1,202 @@
+package overlay
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/supergiant-hq/xnet/model"
+	"github.com/supergiant-hq/xnet/udp"
+	udpc "github.com/supergiant-hq/xnet/udp/client"
+	udps "github.com/supergiant-hq/xnet/udp/server"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Link from a UDP Client to a UDP Server on loopback
+// The peer reads the packets sent by the link from the stream or from overlay Datagrams
+type testLinkPair struct {
+	link *link
+	// Stream and Datagrams of the peer
+	peer     *link
+	datagram func() ([]byte, error)
+}
+
+func newTestLinkPair(tb testing.TB) *testLinkPair {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	server, err := udps.New(
+		log,
+		udps.Config{
+			Addr:      &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)},
+			Datagrams: true,
+		},
+		func(addr *net.UDPAddr, data *model.ClientValidateData) (*model.ClientData, error) {
+			return &model.ClientData{Id: data.Token}, nil
+		},
+	)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	streams := make(chan *udp.Stream, 1)
+	clients := make(chan *udps.Client, 1)
+	server.SetClientConnectedHandler(func(c *udps.Client) { clients <- c })
+	server.SetStreamHandler(func(c udp.Client, s *udp.Stream) { streams <- s })
+	if err = server.Listen(); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { server.Close(0, "done") })
+
+	client, err := udpc.New(log, udpc.Config{
+		ServerAddr:   server.UDPConn.LocalAddr().(*net.UDPAddr),
+		ConnectTries: 1,
+		Datagrams:    true,
+		Token:        "a",
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if err = client.Connect(); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { client.Close(0, "done") })
+
+	stream, err := client.OpenStream(nil, nil)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	pair := &testLinkPair{
+		link: &link{
+			peerId:       "b",
+			stream:       stream,
+			reader:       bufio.NewReaderSize(stream.Stream(), maxPacketSize+1),
+			sendDatagram: client.SendDatagram,
+		},
+	}
+	select {
+	case s := <-streams:
+		pair.peer = &link{
+			peerId:    "a",
+			stream:    s,
+			reader:    bufio.NewReaderSize(s.Stream(), maxPacketSize+1),
+			fragments: newReassembler(),
+		}
+	case <-time.After(5 * time.Second):
+		tb.Fatal("stream not accepted")
+	}
+	c := <-clients
+	pair.datagram = c.ReceiveDatagram
+	return pair
+}
+
+// Packets are carried by overlay Datagrams and by the stream once Datagrams are unsupported
+func TestLinkWrite(t *testing.T) {
+	tests := []struct {
+		name        string
+		unsupported bool
+		size        int
+	}{
+		{"datagram", false, 1000},
+		{"fragmented datagrams", false, 4000},
+		{"stream fallback", true, 1000},
+		{"stream fallback large", true, 4000},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pair := newTestLinkPair(t)
+			if test.unsupported {
+				pair.link.sendDatagram = func(b []byte) error { return udp.ErrorDatagramsUnsupported }
+			}
+
+			pkt := testPacket(test.size, 7)
+			if err := pair.link.write(pkt, pkt); err != nil {
+				t.Fatal(err)
+			}
+			if streamOnly := atomic.LoadInt32(&pair.link.streamOnly) == 1; streamOnly != test.unsupported {
+				t.Fatalf("stream only %v, want %v", streamOnly, test.unsupported)
+			}
+
+			received := 0
+			buf := make([]byte, maxPacketSize)
+			for received < 2 {
+				var pkts [][]byte
+				if test.unsupported {
+					p, err := pair.peer.read(buf)
+					if err != nil {
+						t.Fatal(err)
+					}
+					pkts = [][]byte{p}
+				} else {
+					d, err := pair.datagram()
+					if err != nil {
+						t.Fatal(err)
+					}
+					pkts = pair.peer.fragments.unpack(d)
+				}
+				for _, p := range pkts {
+					if string(p) != string(pkt) {
+						t.Fatalf("packet of size %d differs", len(p))
+					}
+					received++
+				}
+			}
+		})
+	}
+}
+
+func benchmarkLink(b *testing.B, datagrams bool) {
+	pair := newTestLinkPair(b)
+	if !datagrams {
+		pair.link.streamOnly = 1
+	}
+
+	const size = 1200
+	var received int64
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		buf := make([]byte, maxPacketSize)
+		for {
+			if datagrams {
+				d, err := pair.datagram()
+				if err != nil {
+					return
+				}
+				atomic.AddInt64(&received, int64(len(pair.peer.fragments.unpack(d))))
+			} else {
+				if _, err := pair.peer.read(buf); err != nil {
+					return
+				}
+				atomic.AddInt64(&received, 1)
+			}
+		}
+	}()
+
+	pkts := [][]byte{testPacket(size, 1), testPacket(size, 2), testPacket(size, 3), testPacket(size, 4)}
+	b.SetBytes(int64(size * len(pkts)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := pair.link.write(pkts...); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+
+	// Datagrams may be dropped under load, wait for the stragglers
+	time.Sleep(100 * time.Millisecond)
+	b.ReportMetric(float64(atomic.LoadInt64(&received))*100/float64(b.N*len(pkts)), "%delivered")
+}
+
+func BenchmarkLinkStream(b *testing.B) {
+	benchmarkLink(b, false)
+}
+
+func BenchmarkLinkDatagram(b *testing.B) {
+	benchmarkLink(b, true)
+}
@@ -0,0 +1,189 @@
+package overlay
+
+import (
+	"encoding/binary"
+	"net"
+
+	p2pc "github.com/supergiant-hq/xnet/p2p/client"
+)
+
+const (
+	// Minimum MTU of IPv6, the device MTU is never lowered below it
+	minIPv6MTU = 1280
+	// Overhead of a packet in the overlay stream (STREAM frame header and packet length)
+	streamOverhead = 1 + 8 + 8 + 2 + 2
+
+	icmpv4DestinationUnreachable = 3
+	icmpv4FragmentationNeeded    = 4
+	icmpv6PacketTooBig           = 2
+	// ICMP errors quote the original packet up to these sizes
+	icmpv4MaxSize = 576
+	icmpv6MaxSize = minIPv6MTU
+)
+
+// Device MTU which lets packets to a peer fit into single QUIC packets on a path MTU
+// Larger packets are fragmented across overlay Datagrams or split across QUIC packets by the overlay stream,
+// so the result is never lower than the minimum MTU of IPv6
+func DeviceMTU(pathMTU int) int {
+	mtu := pathMTU - p2pc.UDPv6Overhead - p2pc.QuicPacketOverhead - streamOverhead
+	if mtu < minIPv6MTU {
+		return minIPv6MTU
+	}
+	return mtu
+}
+
+// Path MTU to a peer, MinPathMTU if it is not connected
+func (o *Overlay) PathMTU(peerId string) int {
+	o.lmutex.Lock()
+	l, ok := o.links[peerId]
+	o.lmutex.Unlock()
+
+	if !ok {
+		return p2pc.MinPathMTU
+	}
+	return l.conn.PathMTU()
+}
+
+// Device MTU which is safe for the paths to all connected peers
+// It can be applied to a TUN Device using TunDevice.SetMTU
+func (o *Overlay) SafeMTU() int {
+	o.lmutex.Lock()
+	links := make([]*link, 0, len(o.links))
+	for _, l := range o.links {
+		links = append(links, l)
+	}
+	o.lmutex.Unlock()
+
+	mtu := 0
+	for _, l := range links {
+		if lmtu := DeviceMTU(l.conn.PathMTU()); mtu == 0 || lmtu < mtu {
+			mtu = lmtu
+		}
+	}
+	if mtu == 0 {
+		return DeviceMTU(p2pc.MinPathMTU)
+	}
+	return mtu
+}
+
+// Reply to a packet exceeding the MTU of the path to its peer with an ICMP error
+// Returns true if the packet is too big and was dropped
+func (o *Overlay) checkPathMTU(peerId string, pkt []byte) bool {
+	// Packets up to the minimum MTU fit on every path
+	if !o.config.EnforcePathMTU || len(pkt) <= minIPv6MTU {
+		return false
+	}
+
+	mtu := DeviceMTU(o.PathMTU(peerId))
+	if len(pkt) <= mtu {
+		return false
+	}
+
+	// IPv4 packets without the Don't Fragment flag are sent anyway
+	if pkt[0]>>4 == 4 && (len(pkt) < 20 || pkt[6]&0x40 == 0) {
+		return false
+	}
+
+	reply := buildPacketTooBig(pkt, mtu)
+	if reply == nil {
+		return false
+	}
+
+	o.dmutex.Lock()
+	_, err := o.device.Write(reply)
+	o.dmutex.Unlock()
+	if err != nil {
+		o.log.Errorln("Error writing ICMP packet too big to device:", err.Error())
+	}
+	return true
+}
+
+// ICMP Fragmentation Needed (IPv4) or Packet Too Big (IPv6) error quoting a packet
+// The error is sent from the packet's destination, a local source address would be dropped as martian
+func buildPacketTooBig(pkt []byte, mtu int) []byte {
+	src, ok := packetSource(pkt)
+	if !ok {
+		return nil
+	}
+	from, ok := packetDestination(pkt)
+	if !ok {
+		return nil
+	}
+
+	if ip4 := src.To4(); ip4 != nil {
+		quote := pkt
+		if len(quote) > icmpv4MaxSize-20-8 {
+			quote = quote[:icmpv4MaxSize-20-8]
+		}
+		icmp := make([]byte, 8+len(quote))
+		icmp[0] = icmpv4DestinationUnreachable
+		icmp[1] = icmpv4FragmentationNeeded
+		binary.BigEndian.PutUint16(icmp[6:8], uint16(mtu))
+		copy(icmp[8:], quote)
+		binary.BigEndian.PutUint16(icmp[2:4], checksum(icmp, 0))
+		return buildIPv4Packet(from.To4(), ip4, protoICMP, icmp)
+	}
+
+	quote := pkt
+	if len(quote) > icmpv6MaxSize-40-8 {
+		quote = quote[:icmpv6MaxSize-40-8]
+	}
+	icmp := make([]byte, 8+len(quote))
+	icmp[0] = icmpv6PacketTooBig
+	binary.BigEndian.PutUint32(icmp[4:8], uint32(mtu))
+	copy(icmp[8:], quote)
+	binary.BigEndian.PutUint16(icmp[2:4], checksum(icmp, pseudoHeaderSum(from.To16(), src.To16(), protoICMPv6, len(icmp))))
+	return buildIPv6Packet(from.To16(), src.To16(), protoICMPv6, icmp)
+}
+
+func buildIPv4Packet(src, dst net.IP, proto uint8, payload []byte) []byte {
+	b := make([]byte, 20+len(payload))
+	b[0] = 0x45
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(b)))
+	b[8] = 64
+	b[9] = proto
+	copy(b[12:16], src)
+	copy(b[16:20], dst)
+	binary.BigEndian.PutUint16(b[10:12], checksum(b[:20], 0))
+	copy(b[20:], payload)
+	return b
+}
+
+func buildIPv6Packet(src, dst net.IP, proto uint8, payload []byte) []byte {
+	b := make([]byte, 40+len(payload))
+	b[0] = 0x60
+	binary.BigEndian.PutUint16(b[4:6], uint16(len(payload)))
+	b[6] = proto
+	b[7] = 64
+	copy(b[8:24], src)
+	copy(b[24:40], dst)
+	copy(b[40:], payload)
+	return b
+}
+
+// Internet checksum of b added to a partial sum
+func checksum(b []byte, initial uint32) uint16 {
+	sum := initial
+	for len(b) >= 2 {
+		sum += uint32(b[0])<<8 | uint32(b[1])
+		b = b[2:]
+	}
+	if len(b) == 1 {
+		sum += uint32(b[0]) << 8
+	}
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return ^uint16(sum)
+}
+
+// Sum of the IPv6 pseudo header
+func pseudoHeaderSum(src, dst net.IP, proto uint8, length int) (sum uint32) {
+	for i := 0; i < len(src); i += 2 {
+		sum += uint32(src[i])<<8 | uint32(src[i+1])
+		sum += uint32(dst[i])<<8 | uint32(dst[i+1])
+	}
+	sum += uint32(length)
+	sum += uint32(proto)
+	return
+}
@@ -0,0 +1,518 @@
+package overlay
+
+import (
+	"hash/fnv"
+	"net"
+	"sync"
+
+	"github.com/supergiant-hq/xnet/model"
+	"github.com/supergiant-hq/xnet/p2p"
+	brokerc "github.com/supergiant-hq/xnet/p2p/broker/client"
+	p2pc "github.com/supergiant-hq/xnet/p2p/client"
+	"github.com/supergiant-hq/xnet/tun"
+	"github.com/supergiant-hq/xnet/udp"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Overlay forwards IP packets between a packet device and overlay peers
+type Overlay struct {
+	config Config
+	client *brokerc.Client
+	device tun.Device
+	routes *routes
+	filter *filter
+	// Overlay addresses and subnets of the Client
+	local []*net.IPNet
+
+	exitDevice        tun.Device
+	exitAccessHandler ExitAccessHandler
+
+	// MAC addresses of hosts behind peers in Layer2 mode
+	macs *macTable
+	// Client IDs of the peers taking part in the bridge
+	members map[string]bool
+
+	// *Capture -> true
+	captures  *sync.Map
+	capturing int32
+
+	links      map[string]*link
+	connecting map[string]bool
+	lmutex     sync.Mutex
+	dmutex     sync.Mutex
+
+	// Exit Channel
+	Exit chan bool
+	// Closed Status
+	Closed bool
+	mutex  sync.Mutex
+	log    *logrus.Entry
+}
+
+// Create an Overlay
+// The device can be a TUN Device (TunDevice.PacketDevice), a userspace netstack.Stack or a tun.Pipe
+// A TAP Device is used in Layer2 mode
+func New(
+	log *logrus.Logger,
+	config Config,
+	client *brokerc.Client,
+	device tun.Device,
+) (o *Overlay, err error) {
+	if err = config.init(); err != nil {
+		return
+	}
+
+	o = &Overlay{
+		config: config,
+		client: client,
+		device: device,
+		routes: newRoutes(),
+		macs:   newMACTable(),
+
+		captures:   new(sync.Map),
+		members:    make(map[string]bool),
+		links:      make(map[string]*link),
+		connecting: make(map[string]bool),
+
+		Exit: make(chan bool, 1),
+		log:  log.WithField("prefix", "OVERLAY"),
+	}
+
+	o.filter = newFilter(o.log, config.FilterFlowTimeout, config.FilterMaxFlows, o.peerTags, o.routes.advertised)
+
+	client.Manager().SetOverlayStreamHandler(o.overlayStreamHandler)
+	client.Manager().SetOverlayDatagramHandler(o.overlayDatagramHandler)
+	client.Registry().AddRecordsChangedHandler(o.recordsChangedHandler)
+	o.recordsChangedHandler(client.Registry().Records(), nil)
+	client.AddFilterPolicyHandler(o.filter.setPolicy)
+
+	return
+}
+
+// Start forwarding packets read from the device
+// The queues of a tun.MultiQueueDevice are read in parallel
+func (o *Overlay) Start() {
+	if mq, ok := o.device.(tun.MultiQueueDevice); ok {
+		for _, queue := range mq.Queues() {
+			go o.deviceLoop(queue)
+		}
+	} else {
+		go o.deviceLoop(o.device)
+	}
+	o.log.Infof("Overlay started: mode(%v) mtu(%d) layer2(%v)", o.config.Mode, o.config.MTU, o.config.Layer2)
+}
+
+// Current Routes
+func (o *Overlay) Routes() []Route {
+	return o.routes.list()
+}
+
+// Packet filter counters
+// Packets received from peers have to come from an address or a route of the peer,
+// they are filtered using the policy pushed by the Broker Server
+func (o *Overlay) FilterStats() FilterStats {
+	return o.filter.stats()
+}
+
+func (o *Overlay) peerTags(peerId string) map[string]string {
+	if record, ok := o.client.Registry().Record(peerId); ok {
+		return record.Tags
+	}
+	return nil
+}
+
+func (o *Overlay) recordsChangedHandler(updated []*model.ClientRecord, removed []string) {
+	for _, record := range updated {
+		routes := recordRoutes(record)
+		if record.Id == o.client.Id() {
+			o.setLocal(routes)
+			continue
+		}
+		if record.Id == o.config.ExitNode && record.Exit {
+			routes = append(routes, defaultRoutes(record.Id)...)
+		}
+		o.routes.set(record.Id, routes)
+		if o.config.Layer2 {
+			o.setBridgeMember(record.Id, o.isBridgeMember(record))
+		}
+	}
+
+	for _, id := range removed {
+		o.routes.remove(id)
+		o.setBridgeMember(id, false)
+		o.macs.remove(id)
+
+		o.lmutex.Lock()
+		l, ok := o.links[id]
+		delete(o.links, id)
+		o.lmutex.Unlock()
+		if ok {
+			o.closeLink(l, "Peer removed")
+		}
+	}
+}
+
+func (o *Overlay) deviceLoop(device tun.Device) {
+	if bd, ok := device.(tun.BatchDevice); ok {
+		o.deviceBatchLoop(bd)
+		return
+	}
+
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, err := device.Read(buf)
+		if err != nil {
+			o.deviceError(err)
+			return
+		}
+
+		if o.config.Layer2 {
+			o.forwardFrame(buf[:n])
+		} else {
+			o.forward(buf[:n])
+		}
+	}
+}
+
+// Read batches of packets and send them to each peer using a single write
+func (o *Overlay) deviceBatchLoop(device tun.BatchDevice) {
+	bufs := make([][]byte, device.BatchSize())
+	for i := range bufs {
+		bufs[i] = make([]byte, device.MTU()+deviceHeadroom)
+	}
+	sizes := make([]int, len(bufs))
+
+	for {
+		n, err := device.ReadBatch(bufs, sizes)
+		if err != nil {
+			o.deviceError(err)
+			return
+		}
+
+		if o.config.Layer2 {
+			for i := 0; i < n; i++ {
+				o.forwardFrame(bufs[i][:sizes[i]])
+			}
+			continue
+		}
+
+		if n == 1 {
+			o.forward(bufs[0][:sizes[0]])
+			continue
+		}
+
+		// Peers in the order of their first packet
+		peers := []string{}
+		batches := map[string][][]byte{}
+		for i := 0; i < n; i++ {
+			pkt := bufs[i][:sizes[i]]
+			peerId, ok := o.route(pkt)
+			if !ok {
+				continue
+			}
+			if _, ok := batches[peerId]; !ok {
+				peers = append(peers, peerId)
+			}
+			batches[peerId] = append(batches[peerId], pkt)
+		}
+		for _, peerId := range peers {
+			o.send(peerId, batches[peerId]...)
+		}
+	}
+}
+
+func (o *Overlay) deviceError(err error) {
+	if !o.closed() {
+		o.log.Errorln("Error reading from device:", err.Error())
+		o.Close()
+	}
+}
+
+// Send a packet from the device to its peer
+func (o *Overlay) forward(pkt []byte) {
+	if peerId, ok := o.route(pkt); ok {
+		o.send(peerId, pkt)
+	}
+}
+
+// Find the peer of a packet from the device
+func (o *Overlay) route(pkt []byte) (peerId string, ok bool) {
+	dst, ok := packetDestination(pkt)
+	if !ok {
+		return
+	}
+
+	route, ok := o.routes.lookup(dst)
+	if !ok {
+		o.log.Debugf("Dropping packet to (%s): no route", dst.String())
+		return
+	}
+	if o.checkPathMTU(route.PeerId, pkt) {
+		return "", false
+	}
+
+	o.filter.track(route.PeerId, pkt)
+	return route.PeerId, true
+}
+
+// Send packets or frames to a peer
+func (o *Overlay) send(peerId string, pkts ...[]byte) {
+	l, ok := o.getLink(peerId)
+	if !ok {
+		o.log.Debugf("Dropping packets(%d): connecting to peer(%s)", len(pkts), peerId)
+		return
+	}
+
+	if err := l.write(pkts...); err != nil {
+		o.log.Errorf("Error sending packets to %s: %s", l.String(), err.Error())
+		o.removeLink(l)
+		o.closeLink(l, "Write error")
+		return
+	}
+	for _, pkt := range pkts {
+		o.capture(peerId, pkt, tun.PacketDirectionOutbound)
+	}
+}
+
+// Get the link to a peer
+// A connection is initiated in the background if there is none
+func (o *Overlay) getLink(peerId string) (l *link, ok bool) {
+	o.lmutex.Lock()
+	defer o.lmutex.Unlock()
+
+	if l, ok = o.links[peerId]; ok {
+		return
+	}
+
+	if !o.connecting[peerId] && !o.Closed {
+		o.connecting[peerId] = true
+		go o.connect(peerId)
+	}
+	return
+}
+
+func (o *Overlay) connect(peerId string) {
+	defer func() {
+		o.lmutex.Lock()
+		delete(o.connecting, peerId)
+		o.lmutex.Unlock()
+	}()
+
+	conn, err := o.client.Manager().ConnectById(peerId, o.config.Mode)
+	if err != nil {
+		o.log.Errorf("Error connecting to peer(%s): %s", peerId, err.Error())
+		return
+	}
+
+	var stream *udp.Stream
+	if o.config.Layer2 {
+		stream, err = conn.OpenBridgeStream()
+	} else {
+		stream, err = conn.OpenOverlayStream()
+	}
+	if err != nil {
+		o.log.Errorf("Error opening overlay stream to peer(%s): %s", peerId, err.Error())
+		o.client.Manager().CloseConnection(conn.Id(), "Overlay stream error")
+		return
+	}
+
+	o.addLink(newLink(conn, stream, true))
+}
+
+func (o *Overlay) overlayStreamHandler(conn *p2pc.Connection, stream *udp.Stream) {
+	if _, bridge := stream.Metadata[p2p.KEY_STREAM_BRIDGE]; bridge != o.config.Layer2 {
+		o.log.Errorf("Rejecting overlay stream from peer(%s): layer2(%v) mismatch", conn.PeerId(), bridge)
+		stream.Close()
+		return
+	}
+
+	o.addLink(newLink(conn, stream, false))
+}
+
+func (o *Overlay) addLink(l *link) {
+	o.lmutex.Lock()
+	if o.Closed {
+		o.lmutex.Unlock()
+		l.close()
+		return
+	}
+	// The newest link is used to send packets
+	// Previous links are still read from until they are closed
+	o.links[l.peerId] = l
+	o.lmutex.Unlock()
+
+	o.log.Infoln("Link added:", l.String())
+	go o.linkLoop(l)
+}
+
+func (o *Overlay) removeLink(l *link) {
+	o.lmutex.Lock()
+	defer o.lmutex.Unlock()
+
+	if o.links[l.peerId] == l {
+		delete(o.links, l.peerId)
+	}
+}
+
+// Connections accepted from peers are left open as they may carry other streams
+func (o *Overlay) closeLink(l *link, reason string) {
+	l.close()
+	if l.owned {
+		o.client.Manager().CloseConnection(l.conn.Id(), reason)
+	}
+}
+
+// Write packets received from a peer to the device
+func (o *Overlay) linkLoop(l *link) {
+	defer func() {
+		o.removeLink(l)
+		o.closeLink(l, "Link closed")
+		o.log.Warnln("Link removed:", l.String())
+	}()
+
+	device := o.linkDevice(l)
+	bd, batch := device.(tun.BatchDevice)
+
+	// Packets buffered by the link are read into the slab and written to the device in a batch
+	slab := make([]byte, linkBatchBuffer)
+	pkts := [][]byte{}
+	for {
+		pkts = pkts[:0]
+		offset := 0
+		for {
+			pkt, err := l.read(slab[offset:])
+			if err != nil {
+				return
+			}
+			offset += len(pkt)
+
+			if o.receive(l, pkt) {
+				pkts = append(pkts, pkt)
+			}
+			if !batch || len(slab)-offset < maxPacketSize || len(pkts) >= bd.BatchSize() || !l.buffered() {
+				break
+			}
+		}
+		if len(pkts) == 0 {
+			continue
+		}
+
+		if err := o.writeDevice(device, pkts); err != nil {
+			o.log.Errorln("Error writing to device:", err.Error())
+			return
+		}
+	}
+}
+
+// Write packets received in an overlay Datagram to the device
+// Datagrams from peers without a link are dropped
+func (o *Overlay) overlayDatagramHandler(conn *p2pc.Connection, b []byte) {
+	o.lmutex.Lock()
+	l, ok := o.links[conn.PeerId()]
+	o.lmutex.Unlock()
+	if !ok {
+		return
+	}
+
+	pkts := l.fragments.unpack(b)
+	accepted := pkts[:0]
+	for _, pkt := range pkts {
+		if o.receive(l, pkt) {
+			accepted = append(accepted, pkt)
+		}
+	}
+	if len(accepted) == 0 {
+		return
+	}
+
+	if err := o.writeDevice(o.linkDevice(l), accepted); err != nil {
+		o.log.Errorln("Error writing to device:", err.Error())
+	}
+}
+
+// Write packets received from a peer to the device
+func (o *Overlay) writeDevice(device tun.Device, pkts [][]byte) (err error) {
+	if bd, ok := device.(tun.BatchDevice); ok {
+		_, err = bd.WriteBatch(pkts)
+		return
+	}
+
+	o.dmutex.Lock()
+	defer o.dmutex.Unlock()
+
+	for _, pkt := range pkts {
+		if _, err = device.Write(pkt); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Process a packet received from a peer
+// Returns true if the packet has to be written to the device
+func (o *Overlay) receive(l *link, pkt []byte) bool {
+	o.capture(l.peerId, pkt, tun.PacketDirectionInbound)
+
+	if o.config.Layer2 {
+		return o.receiveFrame(l, pkt)
+	}
+	if !o.filter.allow(l.peerId, pkt) {
+		return false
+	}
+	return !o.exitPacket(l, pkt)
+}
+
+// Device to write the packets of a link to
+// Links are spread over the queues of a tun.MultiQueueDevice
+func (o *Overlay) linkDevice(l *link) tun.Device {
+	mq, ok := o.device.(tun.MultiQueueDevice)
+	if !ok || len(mq.Queues()) == 0 {
+		return o.device
+	}
+
+	queues := mq.Queues()
+	h := fnv.New32a()
+	h.Write([]byte(l.peerId))
+	return queues[h.Sum32()%uint32(len(queues))]
+}
+
+// If the Overlay was closed, it is set under both mutexes
+func (o *Overlay) closed() bool {
+	o.lmutex.Lock()
+	defer o.lmutex.Unlock()
+
+	return o.Closed
+}
+
+// Close the Overlay along with its links
+// The device is not closed
+func (o *Overlay) Close() {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.Closed {
+		return
+	}
+
+	o.lmutex.Lock()
+	o.Closed = true
+	links := o.links
+	o.links = make(map[string]*link)
+	o.lmutex.Unlock()
+
+	for _, l := range links {
+		o.closeLink(l, "Overlay closed")
+	}
+	o.filter.close()
+	for _, c := range o.Captures() {
+		c.Stop()
+	}
+
+	select {
+	case o.Exit <- true:
+	default:
+	}
+
+	o.log.Warnln("Overlay closed")
+}
@@ -0,0 +1,292 @@
+package overlay
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/supergiant-hq/xnet/model"
+	"github.com/supergiant-hq/xnet/p2p"
+	"github.com/supergiant-hq/xnet/tun"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	testRecordA = &model.ClientRecord{
+		Id:               "a",
+		OverlayAddresses: []*model.OverlayAddress{{Ip: "100.64.0.1"}},
+		Routes:           []*model.SubnetRoute{{Network: "192.168.1.0/24"}},
+	}
+	testRecordB = &model.ClientRecord{
+		Id:               "b",
+		OverlayAddresses: []*model.OverlayAddress{{Ip: "100.64.0.2"}},
+		Routes:           []*model.SubnetRoute{{Network: "10.1.0.0/16"}},
+		Exit:             true,
+	}
+)
+
+// Overlay of a Client without the Broker, its routes are set from the records
+func newTestOverlay(device tun.Device, local *model.ClientRecord, routes map[string][]Route) *Overlay {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	o := &Overlay{
+		config: Config{Mode: p2p.ConnectionModeP2P, MTU: DefaultMTU},
+		device: device,
+		routes: newRoutes(),
+		macs:   newMACTable(),
+
+		captures:   new(sync.Map),
+		members:    make(map[string]bool),
+		links:      make(map[string]*link),
+		connecting: make(map[string]bool),
+
+		Exit: make(chan bool, 1),
+		log:  log.WithField("prefix", "OVERLAY"),
+	}
+	o.filter = newFilter(o.log, DefaultFilterFlowTimeout, DefaultFilterMaxFlows, func(string) map[string]string { return nil }, o.routes.advertised)
+
+	o.setLocal(recordRoutes(local))
+	for peerId, r := range routes {
+		o.routes.set(peerId, r)
+	}
+	return o
+}
+
+// Overlays of the Clients "a" and "b" linked by an overlay stream
+// Packets are written to and read from the other ends of their devices
+type testOverlayPair struct {
+	a, b       *Overlay
+	devA, devB *tun.Pipe
+}
+
+// Link two Overlays, "b" is the exit node of "a" if exit is set
+func newTestOverlayPair(t *testing.T, exit bool) *testOverlayPair {
+	deviceA, devA := tun.NewPipe(DefaultMTU)
+	deviceB, devB := tun.NewPipe(DefaultMTU)
+
+	routesB := recordRoutes(testRecordB)
+	if exit {
+		routesB = append(routesB, defaultRoutes("b")...)
+	}
+	p := &testOverlayPair{
+		a:    newTestOverlay(deviceA, testRecordA, map[string][]Route{"b": routesB}),
+		b:    newTestOverlay(deviceB, testRecordB, map[string][]Route{"a": recordRoutes(testRecordA)}),
+		devA: devA,
+		devB: devB,
+	}
+
+	// The Datagrams of the link pair are not delivered to the Overlays
+	links := newTestLinkPair(t)
+	links.link.streamOnly, links.peer.streamOnly = 1, 1
+	p.a.addLink(links.link)
+	p.b.addLink(links.peer)
+	p.a.Start()
+	p.b.Start()
+
+	t.Cleanup(func() {
+		p.a.Close()
+		p.b.Close()
+		devA.Close()
+		devB.Close()
+	})
+	return p
+}
+
+// Next packet written to the other end of a Pipe
+func readPipe(t *testing.T, p *tun.Pipe) []byte {
+	t.Helper()
+
+	pkts := make(chan []byte, 1)
+	go func() {
+		buf := make([]byte, maxPacketSize)
+		n, err := p.Read(buf)
+		if err != nil {
+			n = 0
+		}
+		pkts <- buf[:n]
+	}()
+
+	select {
+	case pkt := <-pkts:
+		return pkt
+	case <-time.After(5 * time.Second):
+		t.Fatal("no packet received")
+	}
+	return nil
+}
+
+// Write a packet followed by a marker to a device, the peer device reads the packet if it was delivered,
+// else the marker, as the link keeps packets in order
+func expectDelivery(t *testing.T, from, to *tun.Pipe, pkt, marker []byte, delivered bool) {
+	t.Helper()
+
+	if _, err := from.Write(pkt); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := from.Write(marker); err != nil {
+		t.Fatal(err)
+	}
+
+	want := marker
+	if delivered {
+		want = pkt
+	}
+	if got := readPipe(t, to); !bytes.Equal(got, want) {
+		t.Fatalf("received %x, want %x", got, want)
+	}
+	if delivered {
+		if got := readPipe(t, to); !bytes.Equal(got, marker) {
+			t.Fatalf("received %x, want marker", got)
+		}
+	}
+}
+
+func TestOverlayRouting(t *testing.T) {
+	p := newTestOverlayPair(t, false)
+
+	tests := []struct {
+		name      string
+		fromA     bool
+		pkt       []byte
+		delivered bool
+	}{
+		{"to address of peer", true, testUDPPacket("100.64.0.1", "100.64.0.2", 1000, 80), true},
+		{"to subnet of peer", true, testUDPPacket("100.64.0.1", "10.1.2.3", 1000, 80), true},
+		{"from subnet to peer", true, testUDPPacket("192.168.1.7", "100.64.0.2", 1000, 80), true},
+		{"no route", true, testUDPPacket("100.64.0.1", "10.2.0.1", 1000, 80), false},
+		{"to own address", true, testUDPPacket("100.64.0.1", "100.64.0.1", 1000, 80), false},
+		{"reply to address of peer", false, testUDPPacket("100.64.0.2", "100.64.0.1", 80, 1000), true},
+		{"reply to subnet of peer", false, testUDPPacket("10.1.2.3", "192.168.1.7", 80, 1000), true},
+		{"malformed", true, []byte{0x45, 0}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			from, to, marker := p.devA, p.devB, testUDPPacket("100.64.0.1", "100.64.0.2", 9, 9)
+			if !test.fromA {
+				from, to, marker = p.devB, p.devA, testUDPPacket("100.64.0.2", "100.64.0.1", 9, 9)
+			}
+			expectDelivery(t, from, to, test.pkt, marker, test.delivered)
+		})
+	}
+}
+
+// The peer "b" accepts DNS from "a" and replies to its own flows only
+func TestOverlayFilter(t *testing.T) {
+	p := newTestOverlayPair(t, false)
+	p.b.filter.setPolicy(&model.FilterPolicy{
+		Enabled: true,
+		Rules: []*model.FilterRule{
+			{Allow: true, Peers: []string{"a"}, Protocol: "udp", Ports: []*model.PortRange{{Start: 53, End: 53}}},
+		},
+	})
+	marker := testUDPPacket("100.64.0.1", "100.64.0.2", 9, 53)
+
+	// Flow of "b" tracked when its packet is routed to "a"
+	flow := testUDPPacket("100.64.0.2", "100.64.0.1", 5000, 8080)
+	expectDelivery(t, p.devB, p.devA, flow, testUDPPacket("100.64.0.2", "100.64.0.1", 9, 9), true)
+
+	tests := []struct {
+		name      string
+		pkt       []byte
+		delivered bool
+	}{
+		{"rule allows", testUDPPacket("100.64.0.1", "100.64.0.2", 1000, 53), true},
+		{"rule allows from subnet", testUDPPacket("192.168.1.7", "100.64.0.2", 1000, 53), true},
+		{"no rule", testUDPPacket("100.64.0.1", "100.64.0.2", 1000, 80), false},
+		{"reply to flow", testUDPPacket("100.64.0.1", "100.64.0.2", 8080, 5000), true},
+		{"reply to other port", testUDPPacket("100.64.0.1", "100.64.0.2", 8081, 5000), false},
+		{"spoofed", testUDPPacket("100.64.0.9", "100.64.0.2", 1000, 53), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectDelivery(t, p.devA, p.devB, test.pkt, marker, test.delivered)
+		})
+	}
+
+	if stats := p.b.FilterStats(); stats.Spoofed != 1 {
+		t.Fatalf("spoofed %d, want 1", stats.Spoofed)
+	}
+}
+
+// Packets of "a" to other networks leave through the exit device of "b", its other end stands in for the source NAT
+func TestOverlayExit(t *testing.T) {
+	p := newTestOverlayPair(t, true)
+	exitDevice, nat := tun.NewPipe(DefaultMTU)
+	defer nat.Close()
+
+	// The first packet of "a" is refused
+	var checked int32
+	p.b.SetExitAccessHandler(func(peerId string) bool {
+		return peerId == "a" && atomic.AddInt32(&checked, 1) > 1
+	})
+	p.b.EnableExit(exitDevice)
+
+	marker := testUDPPacket("100.64.0.1", "198.51.100.7", 9, 9)
+	expectDelivery(t, p.devA, nat, testUDPPacket("100.64.0.1", "198.51.100.7", 4000, 53), marker, false)
+
+	tests := []struct {
+		name string
+		pkt  []byte
+		// Device the packet is written to, the exit device if nil
+		to *tun.Pipe
+		// Reply of the translated destination
+		reply []byte
+	}{
+		{
+			name:  "to internet",
+			pkt:   testUDPPacket("100.64.0.1", "198.51.100.7", 4000, 53),
+			reply: testUDPPacket("198.51.100.7", "100.64.0.1", 53, 4000),
+		},
+		{
+			name:  "from subnet to internet",
+			pkt:   testUDPPacket("192.168.1.7", "203.0.113.1", 4001, 443),
+			reply: testUDPPacket("203.0.113.1", "192.168.1.7", 443, 4001),
+		},
+		{
+			name: "to exit node",
+			pkt:  testUDPPacket("100.64.0.1", "100.64.0.2", 4002, 80),
+			to:   p.devB,
+		},
+		{
+			name: "to subnet of exit node",
+			pkt:  testUDPPacket("100.64.0.1", "10.1.0.1", 4003, 80),
+			to:   p.devB,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := p.devA.Write(test.pkt); err != nil {
+				t.Fatal(err)
+			}
+			to := test.to
+			if to == nil {
+				to = nat
+			}
+			if got := readPipe(t, to); !bytes.Equal(got, test.pkt) {
+				t.Fatalf("received %x, want %x", got, test.pkt)
+			}
+			if test.reply == nil {
+				return
+			}
+
+			// Replies are routed back to the peer
+			if _, err := nat.Write(test.reply); err != nil {
+				t.Fatal(err)
+			}
+			if got := readPipe(t, p.devA); !bytes.Equal(got, test.reply) {
+				t.Fatalf("reply %x, want %x", got, test.reply)
+			}
+		})
+	}
+
+	// Sources of other peers are not sent through the exit device
+	expectDelivery(t, p.devA, nat, testUDPPacket("100.64.0.9", "198.51.100.7", 4004, 53), marker, false)
+}
@@ -0,0 +1,166 @@
+package overlay
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+const (
+	protoICMP   = 1
+	protoTCP    = 6
+	protoUDP    = 17
+	protoICMPv6 = 58
+)
+
+// Addresses, protocol and ports of an IP packet
+// ICMP echo messages use the identifier as both ports
+type packetInfo struct {
+	src     net.IP
+	dst     net.IP
+	proto   uint8
+	srcPort uint16
+	dstPort uint16
+}
+
+// Stringify
+func (p *packetInfo) String() string {
+	return fmt.Sprintf("proto(%d) %s -> %s",
+		p.proto,
+		net.JoinHostPort(p.src.String(), fmt.Sprint(p.srcPort)),
+		net.JoinHostPort(p.dst.String(), fmt.Sprint(p.dstPort)),
+	)
+}
+
+func parsePacketInfo(b []byte) (p packetInfo, ok bool) {
+	if len(b) == 0 {
+		return
+	}
+
+	var payload []byte
+	switch b[0] >> 4 {
+	case 4:
+		ihl := int(b[0]&0x0f) * 4
+		if len(b) < 20 || ihl < 20 || len(b) < ihl {
+			return
+		}
+		p.src, p.dst, p.proto = net.IP(b[12:16]), net.IP(b[16:20]), b[9]
+		// Only the first fragment carries the transport header
+		if binary.BigEndian.Uint16(b[6:8])&0x1fff == 0 {
+			payload = b[ihl:]
+		}
+	case 6:
+		if len(b) < 40 {
+			return
+		}
+		p.src, p.dst, p.proto = net.IP(b[8:24]), net.IP(b[24:40]), b[6]
+		if p.proto, payload, ok = skipExtensionHeaders(p.proto, b[40:]); !ok {
+			return
+		}
+	default:
+		return
+	}
+
+	switch p.proto {
+	case protoTCP, protoUDP:
+		if len(payload) >= 4 {
+			p.srcPort = binary.BigEndian.Uint16(payload[0:2])
+			p.dstPort = binary.BigEndian.Uint16(payload[2:4])
+		}
+	case protoICMP, protoICMPv6:
+		if len(payload) >= 6 && isEcho(p.proto, payload[0]) {
+			p.srcPort = binary.BigEndian.Uint16(payload[4:6])
+			p.dstPort = p.srcPort
+		}
+	}
+
+	return p, true
+}
+
+// Skip IPv6 extension headers to reach the transport header
+// The payload is nil for fragments other than the first one
+func skipExtensionHeaders(proto uint8, b []byte) (next uint8, payload []byte, ok bool) {
+	for {
+		switch proto {
+		case 0, 43, 60:
+			if len(b) < 8 || len(b) < 8*(int(b[1])+1) {
+				return
+			}
+			proto, b = b[0], b[8*(int(b[1])+1):]
+		case 44:
+			if len(b) < 8 {
+				return
+			}
+			if binary.BigEndian.Uint16(b[2:4])&0xfff8 != 0 {
+				return b[0], nil, true
+			}
+			proto, b = b[0], b[8:]
+		default:
+			return proto, b, true
+		}
+	}
+}
+
+// If an ICMP type is an echo request or reply
+func isEcho(proto, typ uint8) bool {
+	if proto == protoICMP {
+		return typ == 0 || typ == 8
+	}
+	return typ == 128 || typ == 129
+}
+
+// Destination address of an IP packet
+func packetDestination(b []byte) (ip net.IP, ok bool) {
+	if len(b) == 0 {
+		return
+	}
+
+	switch b[0] >> 4 {
+	case 4:
+		if len(b) < 20 {
+			return
+		}
+		return net.IP(b[16:20]), true
+	case 6:
+		if len(b) < 40 {
+			return
+		}
+		return net.IP(b[24:40]), true
+	}
+	return
+}
+
+// Source address of an IP packet
+func packetSource(b []byte) (ip net.IP, ok bool) {
+	if len(b) == 0 {
+		return
+	}
+
+	switch b[0] >> 4 {
+	case 4:
+		if len(b) < 20 {
+			return
+		}
+		return net.IP(b[12:16]), true
+	case 6:
+		if len(b) < 40 {
+			return
+		}
+		return net.IP(b[8:24]), true
+	}
+	return
+}
+
+// Packets are framed with a 2 byte length prefix on overlay streams
+func framePacket(b []byte) []byte {
+	frame := make([]byte, 2+len(b))
+	binary.BigEndian.PutUint16(frame, uint16(len(b)))
+	copy(frame[2:], b)
+	return frame
+}
+
+// Append a framed packet
+func appendFrame(frames []byte, b []byte) []byte {
+	frames = append(frames, byte(len(b)>>8), byte(len(b)))
+	return append(frames, b...)
+}
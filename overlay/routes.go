@@ -0,0 +1,136 @@
+package overlay
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/supergiant-hq/xnet/model"
+)
+
+// Route to a peer
+type Route struct {
+	// Destination network
+	Network *net.IPNet
+	// Client ID of the peer
+	PeerId string
+	// Preference among routes to the same network, higher is preferred
+	Priority uint32
+}
+
+// Stringify
+func (r *Route) String() string {
+	return fmt.Sprintf("%s via peer(%s) priority(%d)", r.Network.String(), r.PeerId, r.Priority)
+}
+
+// Routing table with longest prefix matching
+// Routes to the same network are ordered by priority
+type routes struct {
+	entries []Route
+	mutex   sync.RWMutex
+}
+
+func newRoutes() *routes {
+	return &routes{}
+}
+
+// Find the route for an IP
+func (rt *routes) lookup(ip net.IP) (route Route, ok bool) {
+	rt.mutex.RLock()
+	defer rt.mutex.RUnlock()
+
+	best := -1
+	for _, entry := range rt.entries {
+		if !entry.Network.Contains(ip) {
+			continue
+		}
+		ones, _ := entry.Network.Mask.Size()
+		if ones > best || (ones == best && entry.Priority > route.Priority) {
+			best = ones
+			route, ok = entry, true
+		}
+	}
+	return
+}
+
+// Replace all routes of a peer
+func (rt *routes) set(peerId string, routes []Route) {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+
+	rt.removeLocked(peerId)
+	rt.entries = append(rt.entries, routes...)
+}
+
+// Remove all routes of a peer
+func (rt *routes) remove(peerId string) {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+
+	rt.removeLocked(peerId)
+}
+
+func (rt *routes) removeLocked(peerId string) {
+	entries := rt.entries[:0]
+	for _, entry := range rt.entries {
+		if entry.PeerId != peerId {
+			entries = append(entries, entry)
+		}
+	}
+	rt.entries = entries
+}
+
+// If a network routed to a peer contains an IP
+func (rt *routes) advertised(peerId string, ip net.IP) bool {
+	rt.mutex.RLock()
+	defer rt.mutex.RUnlock()
+
+	for _, entry := range rt.entries {
+		if entry.PeerId == peerId && entry.Network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rt *routes) list() (routes []Route) {
+	rt.mutex.RLock()
+	defer rt.mutex.RUnlock()
+
+	return append(routes, rt.entries...)
+}
+
+// Host routes for the overlay addresses of a Client along with its subnet routes
+func recordRoutes(record *model.ClientRecord) (routes []Route) {
+	for _, addr := range record.OverlayAddresses {
+		ip := net.ParseIP(addr.Ip)
+		if ip == nil {
+			continue
+		}
+		bits := net.IPv6len * 8
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+			bits = net.IPv4len * 8
+		}
+		routes = append(routes, Route{
+			Network: &net.IPNet{
+				IP:   ip,
+				Mask: net.CIDRMask(bits, bits),
+			},
+			PeerId: record.Id,
+		})
+	}
+
+	for _, sroute := range record.Routes {
+		_, network, err := net.ParseCIDR(sroute.Network)
+		if err != nil {
+			continue
+		}
+		routes = append(routes, Route{
+			Network:  network,
+			PeerId:   record.Id,
+			Priority: sroute.Priority,
+		})
+	}
+	return
+}
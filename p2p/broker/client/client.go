@@ -1,6 +1,11 @@
 package brokerc
 
 import (
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/supergiant-hq/xnet/model"
 	"github.com/supergiant-hq/xnet/p2p"
 	p2pc "github.com/supergiant-hq/xnet/p2p/client"
 	"github.com/supergiant-hq/xnet/udp"
@@ -15,6 +20,17 @@ type Client struct {
 	config     Config
 	udpClient  *udpc.Client
 	p2pManager *p2pc.Manager
+	registry   *Registry
+
+	connectedHandler udpc.ConnectedHandler
+
+	routes []*model.SubnetRoute
+	exit   bool
+	rmutex sync.Mutex
+
+	filterPolicy   *model.FilterPolicy
+	filterHandlers []FilterPolicyHandler
+	fmutex         sync.Mutex
 
 	// Connect to peer by ID
 	ConnectPeerById func(peerId string, mode p2p.ConnectionMode) (conn *p2pc.Connection, err error)
@@ -51,6 +67,11 @@ func New(
 	}
 	c.p2pManager.SetStreamHandler(streamHandler)
 
+	c.registry = newRegistry(c.log, c.udpClient)
+	c.udpClient.RegisterHandler(model.MessageTypeClientRecords, c.registry.recordsHandler)
+	c.udpClient.RegisterHandler(model.MessageTypeFilterPolicy, c.filterPolicyHandler)
+	c.udpClient.SetConnectedHandler(c.handleConnected)
+
 	c.ConnectPeerById = c.p2pManager.ConnectById
 	c.ConnectPeerByTag = c.p2pManager.ConnectByTag
 
@@ -64,7 +85,27 @@ func (c *Client) SetCanConnectHandler(handler udpc.CanConnect) {
 
 // Set Connected Handler
 func (c *Client) SetConnectedHandler(handler udpc.ConnectedHandler) {
-	c.udpClient.SetConnectedHandler(handler)
+	c.connectedHandler = handler
+}
+
+func (c *Client) handleConnected(reconnect bool) {
+	if err := c.registry.subscribe(); err != nil {
+		c.log.Errorln("Error subscribing to records:", err.Error())
+	}
+
+	if err := c.queryFilterPolicy(); err != nil {
+		c.log.Errorln("Error querying filter policy:", err.Error())
+	}
+
+	if routes, exit := c.Routes(), c.IsExitNode(); len(routes) > 0 || exit {
+		if _, err := c.advertiseRoutes(routes, exit); err != nil {
+			c.log.Errorln("Error advertising routes:", err.Error())
+		}
+	}
+
+	if c.connectedHandler != nil {
+		c.connectedHandler(reconnect)
+	}
 }
 
 // Set CanReconnect Handler
@@ -92,6 +133,49 @@ func (c *Client) SetMessageStreamHandler(handler p2pc.MessageStreamHandler) {
 	c.p2pManager.SetMessageStreamHandler(handler)
 }
 
+// Client ID
+func (c *Client) Id() string {
+	return c.udpClient.Id
+}
+
+// P2P Connection Manager
+func (c *Client) Manager() *p2pc.Manager {
+	return c.p2pManager
+}
+
+// Registry of Clients connected to the Broker Server
+func (c *Client) Registry() *Registry {
+	return c.registry
+}
+
+// Overlay addresses assigned by the Broker Server
+// The addresses can be passed to tun.NewTunConfig using IPNet.String()
+func (c *Client) OverlayAddresses() (networks []*net.IPNet, err error) {
+	if c.udpClient.Data == nil {
+		err = fmt.Errorf("client not initialized")
+		return
+	}
+
+	for _, addr := range c.udpClient.Data.OverlayAddresses {
+		ip := net.ParseIP(addr.Ip)
+		if ip == nil {
+			err = fmt.Errorf("invalid overlay address: %s", addr.Ip)
+			return
+		}
+		bits := net.IPv6len * 8
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = net.IPv4len * 8
+		}
+		networks = append(networks, &net.IPNet{
+			IP:   ip,
+			Mask: net.CIDRMask(int(addr.Prefix), bits),
+		})
+	}
+
+	return
+}
+
 // Connect to Broker Server
 func (c *Client) Connect() (err error) {
 	if err = c.udpClient.Connect(); err != nil {
@@ -0,0 +1,71 @@
+package brokerc
+
+import (
+	"fmt"
+
+	"github.com/supergiant-hq/xnet/model"
+	"github.com/supergiant-hq/xnet/network"
+	udpc "github.com/supergiant-hq/xnet/udp/client"
+)
+
+// Called when the Broker Server pushes a packet filter policy
+type FilterPolicyHandler func(policy *model.FilterPolicy)
+
+// Add a Filter Policy Handler
+// The handler is called with the current policy if there is one
+func (c *Client) AddFilterPolicyHandler(handler FilterPolicyHandler) {
+	c.fmutex.Lock()
+	c.filterHandlers = append(c.filterHandlers, handler)
+	policy := c.filterPolicy
+	c.fmutex.Unlock()
+
+	if policy != nil {
+		go handler(policy)
+	}
+}
+
+// Packet filter policy of the Client
+// Returns nil if no policy was received yet
+func (c *Client) FilterPolicy() *model.FilterPolicy {
+	c.fmutex.Lock()
+	defer c.fmutex.Unlock()
+
+	return c.filterPolicy
+}
+
+func (c *Client) queryFilterPolicy() (err error) {
+	msg := network.NewMessageWithAck(
+		model.MessageTypeFilterPolicyQuery,
+		&model.FilterPolicyQuery{},
+		network.RequestTimeout,
+	)
+	rmsg, err := c.udpClient.Send(msg)
+	if err != nil {
+		return
+	}
+
+	policy := rmsg.Body.(*model.FilterPolicy)
+	if !policy.Status {
+		err = fmt.Errorf(policy.Message)
+		return
+	}
+	c.applyFilterPolicy(policy)
+
+	return
+}
+
+func (c *Client) filterPolicyHandler(uc *udpc.Client, msg *network.Message) {
+	c.applyFilterPolicy(msg.Body.(*model.FilterPolicy))
+}
+
+func (c *Client) applyFilterPolicy(policy *model.FilterPolicy) {
+	c.fmutex.Lock()
+	c.filterPolicy = policy
+	handlers := c.filterHandlers
+	c.fmutex.Unlock()
+
+	c.log.Infof("Filter policy received: enabled(%v) rules(%d)", policy.Enabled, len(policy.Rules))
+	for _, handler := range handlers {
+		go handler(policy)
+	}
+}
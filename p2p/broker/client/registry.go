@@ -0,0 +1,146 @@
+package brokerc
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/supergiant-hq/xnet/model"
+	"github.com/supergiant-hq/xnet/network"
+	udpc "github.com/supergiant-hq/xnet/udp/client"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Called when the Records in the Registry change
+type RecordsChangedHandler func(updated []*model.ClientRecord, removed []string)
+
+// Registry holds the Records of Clients connected to the Broker Server
+// It is kept up to date by the Broker Server as Clients join or leave
+type Registry struct {
+	client          *udpc.Client
+	records         *sync.Map
+	changedHandlers []RecordsChangedHandler
+	hmutex          sync.RWMutex
+	log             *logrus.Entry
+}
+
+func newRegistry(log *logrus.Logger, client *udpc.Client) *Registry {
+	return &Registry{
+		client:  client,
+		records: new(sync.Map),
+		log:     log.WithField("prefix", "REGISTRY"),
+	}
+}
+
+// Add a Records Changed Handler
+func (r *Registry) AddRecordsChangedHandler(handler RecordsChangedHandler) {
+	r.hmutex.Lock()
+	defer r.hmutex.Unlock()
+
+	r.changedHandlers = append(r.changedHandlers, handler)
+}
+
+func (r *Registry) subscribe() (err error) {
+	msg := network.NewMessageWithAck(
+		model.MessageTypeClientRecordsQuery,
+		&model.ClientRecordsQuery{
+			Subscribe: true,
+		},
+		network.RequestTimeout,
+	)
+	rmsg, err := r.client.Send(msg)
+	if err != nil {
+		return
+	}
+
+	records := rmsg.Body.(*model.ClientRecords)
+	if !records.Status {
+		err = fmt.Errorf(records.Message)
+		return
+	}
+	r.apply(records)
+
+	r.log.Infof("Subscribed to records: %d", len(records.Records))
+	return
+}
+
+func (r *Registry) recordsHandler(c *udpc.Client, msg *network.Message) {
+	r.apply(msg.Body.(*model.ClientRecords))
+}
+
+func (r *Registry) apply(records *model.ClientRecords) {
+	removed := records.Removed
+
+	if records.Full {
+		current := map[string]bool{}
+		for _, record := range records.Records {
+			current[record.Id] = true
+		}
+		r.records.Range(func(key, value interface{}) bool {
+			if !current[key.(string)] {
+				r.records.Delete(key)
+				removed = append(removed, key.(string))
+			}
+			return true
+		})
+	}
+
+	for _, record := range records.Records {
+		r.records.Store(record.Id, record)
+	}
+	for _, id := range records.Removed {
+		r.records.Delete(id)
+	}
+
+	if len(records.Records) == 0 && len(removed) == 0 {
+		return
+	}
+
+	r.hmutex.RLock()
+	defer r.hmutex.RUnlock()
+	for _, handler := range r.changedHandlers {
+		go handler(records.Records, removed)
+	}
+}
+
+// Get the Record of a Client
+// The ID is matched case-insensitively as DNS names are not case sensitive
+func (r *Registry) Record(id string) (record *model.ClientRecord, ok bool) {
+	if rrecord, found := r.records.Load(id); found {
+		return rrecord.(*model.ClientRecord), true
+	}
+
+	r.records.Range(func(key, value interface{}) bool {
+		if strings.EqualFold(key.(string), id) {
+			record, ok = value.(*model.ClientRecord), true
+			return false
+		}
+		return true
+	})
+	return
+}
+
+// Get the Records of Clients having a Tag
+func (r *Registry) RecordsWithTag(tag string) (records []*model.ClientRecord) {
+	r.records.Range(func(key, value interface{}) bool {
+		record := value.(*model.ClientRecord)
+		for rtag := range record.Tags {
+			if strings.EqualFold(rtag, tag) {
+				records = append(records, record)
+				break
+			}
+		}
+		return true
+	})
+	return
+}
+
+// Get all Records
+func (r *Registry) Records() (records []*model.ClientRecord) {
+	r.records.Range(func(key, value interface{}) bool {
+		records = append(records, value.(*model.ClientRecord))
+		return true
+	})
+	return
+}
@@ -0,0 +1,76 @@
+package brokerc
+
+import (
+	"fmt"
+
+	"github.com/supergiant-hq/xnet/model"
+	"github.com/supergiant-hq/xnet/network"
+)
+
+// Advertise subnet routes to the Broker Server
+// The routes replace the previously advertised routes, an empty list withdraws them
+// When the same network is advertised by multiple peers, the route with the highest priority is preferred
+// Routes which conflict with the overlay network or another peer's route of the same priority are rejected
+// The routes are advertised again when the Client reconnects
+func (c *Client) AdvertiseRoutes(routes []*model.SubnetRoute) (status *model.ClientRoutesStatus, err error) {
+	c.rmutex.Lock()
+	c.routes = routes
+	exit := c.exit
+	c.rmutex.Unlock()
+
+	return c.advertiseRoutes(routes, exit)
+}
+
+// Offer or stop offering exit service to other peers
+// Peers using the exit node send their traffic for the internet to it,
+// which has to be forwarded by an overlay with an exit device (see overlay.Overlay.EnableExit)
+func (c *Client) SetExitNode(enabled bool) (status *model.ClientRoutesStatus, err error) {
+	c.rmutex.Lock()
+	c.exit = enabled
+	routes := c.routes
+	c.rmutex.Unlock()
+
+	return c.advertiseRoutes(routes, enabled)
+}
+
+// If the Client offers exit service
+func (c *Client) IsExitNode() bool {
+	c.rmutex.Lock()
+	defer c.rmutex.Unlock()
+
+	return c.exit
+}
+
+func (c *Client) advertiseRoutes(routes []*model.SubnetRoute, exit bool) (status *model.ClientRoutesStatus, err error) {
+	msg := network.NewMessageWithAck(
+		model.MessageTypeClientRoutes,
+		&model.ClientRoutes{
+			Routes: routes,
+			Exit:   exit,
+		},
+		network.RequestTimeout,
+	)
+	rmsg, err := c.udpClient.Send(msg)
+	if err != nil {
+		return
+	}
+
+	status = rmsg.Body.(*model.ClientRoutesStatus)
+	if !status.Status {
+		err = fmt.Errorf(status.Message)
+		return
+	}
+	for _, conflict := range status.Conflicts {
+		c.log.Warnf("Route(%s) rejected: %s", conflict.Network, conflict.Reason)
+	}
+
+	return
+}
+
+// Advertised subnet routes
+func (c *Client) Routes() []*model.SubnetRoute {
+	c.rmutex.Lock()
+	defer c.rmutex.Unlock()
+
+	return c.routes
+}
@@ -1,6 +1,9 @@
 package brokers
 
-import udps "github.com/supergiant-hq/xnet/udp/server"
+import (
+	"github.com/supergiant-hq/xnet/ipam"
+	udps "github.com/supergiant-hq/xnet/udp/server"
+)
 
 // Broker Server Config
 type Config struct {
@@ -8,4 +11,7 @@ type Config struct {
 	Debug bool
 	// UDP Server Config
 	UdpsConfig udps.Config
+	// Overlay Address Management Config
+	// Overlay addresses are not assigned if nil
+	IPAM *ipam.Config
 }
@@ -0,0 +1,61 @@
+package brokers
+
+import (
+	"sort"
+
+	udps "github.com/supergiant-hq/xnet/udp/server"
+)
+
+// Called to authorise a Client to offer exit service
+type ExitAdvertiseHandler func(c *udps.Client) bool
+
+// Called to authorise a Client to use an exit node
+type ExitAccessHandler func(c *udps.Client, exitId string) bool
+
+// If a Client offers exit service
+func (rt *routeTable) isExit(id string) bool {
+	rt.mutex.RLock()
+	defer rt.mutex.RUnlock()
+
+	return rt.exits[id]
+}
+
+// Set Exit Advertise Handler
+// All Clients can offer exit service if not set
+func (s *Server) SetExitAdvertiseHandler(handler ExitAdvertiseHandler) {
+	s.exitAdvertiseHandler = handler
+}
+
+// Set Exit Access Handler
+// All Clients can use all exit nodes if not set
+func (s *Server) SetExitAccessHandler(handler ExitAccessHandler) {
+	s.exitAccessHandler = handler
+}
+
+// IDs of the Clients offering exit service
+func (s *Server) ExitNodes() (ids []string) {
+	s.routes.mutex.RLock()
+	defer s.routes.mutex.RUnlock()
+
+	for id := range s.routes.exits {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return
+}
+
+// If a Client may use an exit node
+func (s *Server) CanUseExitNode(id string, exitId string) bool {
+	if !s.routes.isExit(exitId) {
+		return false
+	}
+	if s.exitAccessHandler == nil {
+		return true
+	}
+
+	c, err := s.udpServer.GetClient(id)
+	if err != nil {
+		return false
+	}
+	return s.exitAccessHandler(c, exitId)
+}
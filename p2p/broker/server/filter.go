@@ -0,0 +1,58 @@
+package brokers
+
+import (
+	"github.com/supergiant-hq/xnet/model"
+	"github.com/supergiant-hq/xnet/network"
+	udps "github.com/supergiant-hq/xnet/udp/server"
+)
+
+// Called to get the packet filter policy of a Client
+// The rules are evaluated by the Client on packets received from its peers
+// Returning nil disables filtering on the Client
+type FilterPolicyHandler func(c *udps.Client) *model.FilterPolicy
+
+// Set Filter Policy Handler
+// Use PublishFilterPolicies to push changed policies to connected Clients
+func (s *Server) SetFilterPolicyHandler(handler FilterPolicyHandler) {
+	s.filterPolicyHandler = handler
+}
+
+// Push the current filter policies to all connected Clients
+func (s *Server) PublishFilterPolicies() {
+	for _, c := range s.udpServer.GetClients() {
+		if !isOverlayClient(c) || c.Closed {
+			continue
+		}
+		go c.Send(network.NewMessage(model.MessageTypeFilterPolicy, s.filterPolicy(c)))
+	}
+}
+
+func (s *Server) filterPolicy(c *udps.Client) *model.FilterPolicy {
+	var policy *model.FilterPolicy
+	if s.filterPolicyHandler != nil {
+		policy = s.filterPolicyHandler(c)
+	}
+	if policy == nil {
+		return &model.FilterPolicy{
+			Status:  true,
+			Message: "Ok",
+		}
+	}
+
+	return &model.FilterPolicy{
+		Status:       true,
+		Message:      "Ok",
+		Enabled:      true,
+		Rules:        policy.Rules,
+		DefaultAllow: policy.DefaultAllow,
+		LogDenied:    policy.LogDenied,
+	}
+}
+
+func (s *Server) filterPolicyQueryHandler(c *udps.Client, msg *network.Message) {
+	rmsg, err := msg.GenReply(model.MessageTypeFilterPolicy, s.filterPolicy(c))
+	if err != nil {
+		return
+	}
+	c.Send(rmsg)
+}
@@ -0,0 +1,165 @@
+package brokers
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/supergiant-hq/xnet/model"
+	"github.com/supergiant-hq/xnet/network"
+	"github.com/supergiant-hq/xnet/p2p"
+	udps "github.com/supergiant-hq/xnet/udp/server"
+)
+
+// Records of connected clients which are pushed to subscribed clients
+type registry struct {
+	// Client ID -> *udps.Client
+	subscribers *sync.Map
+}
+
+func newRegistry() *registry {
+	return &registry{
+		subscribers: new(sync.Map),
+	}
+}
+
+func (s *Server) newClientRecord(c *udps.Client) *model.ClientRecord {
+	record := &model.ClientRecord{
+		Id:       c.Id,
+		Address:  c.Addr.String(),
+		Tags:     c.Tags,
+		Services: map[string]uint32{},
+	}
+
+	if c.Meta != nil {
+		record.OverlayAddresses = c.Meta.OverlayAddresses
+		for key, value := range c.Meta.Data {
+			if !strings.HasPrefix(key, p2p.KEY_SERVICE_PREFIX) {
+				continue
+			}
+			port, err := strconv.ParseUint(value, 10, 16)
+			if err != nil {
+				continue
+			}
+			record.Services[strings.TrimPrefix(key, p2p.KEY_SERVICE_PREFIX)] = uint32(port)
+		}
+	}
+
+	record.Exit = s.routes.isExit(c.Id)
+	for _, route := range s.routes.get(c.Id) {
+		record.Routes = append(record.Routes, &model.SubnetRoute{
+			Network:  route.network.String(),
+			Priority: route.priority,
+		})
+	}
+
+	return record
+}
+
+func isOverlayClient(c *udps.Client) bool {
+	_, relay := c.Tags[p2p.TAG_RELAY]
+	return !relay
+}
+
+// Get the Record of a connected Client
+// The ID is matched case-insensitively as DNS names are not case sensitive
+func (s *Server) Record(id string) (record *model.ClientRecord, ok bool) {
+	if c, err := s.udpServer.GetClient(id); err == nil && isOverlayClient(c) {
+		return s.newClientRecord(c), true
+	}
+
+	for _, c := range s.udpServer.GetClients() {
+		if strings.EqualFold(c.Id, id) && isOverlayClient(c) {
+			return s.newClientRecord(c), true
+		}
+	}
+	return
+}
+
+// Get the Records of connected Clients having a Tag
+func (s *Server) RecordsWithTag(tag string) (records []*model.ClientRecord) {
+	for _, c := range s.udpServer.GetClients() {
+		if !isOverlayClient(c) {
+			continue
+		}
+		for ctag := range c.Tags {
+			if strings.EqualFold(ctag, tag) {
+				records = append(records, s.newClientRecord(c))
+				break
+			}
+		}
+	}
+	return
+}
+
+// Get the Records of all connected Clients
+func (s *Server) Records() (records []*model.ClientRecord) {
+	for _, c := range s.udpServer.GetClients() {
+		if isOverlayClient(c) {
+			records = append(records, s.newClientRecord(c))
+		}
+	}
+	return
+}
+
+func (s *Server) recordsQueryHandler(c *udps.Client, msg *network.Message) {
+	query := msg.Body.(*model.ClientRecordsQuery)
+
+	if query.Subscribe {
+		s.registry.subscribers.Store(c.Id, c)
+	}
+
+	rmsg, err := msg.GenReply(model.MessageTypeClientRecords, s.filterRecords(c, &model.ClientRecords{
+		Status:  true,
+		Message: "Ok",
+		Full:    true,
+		Records: s.Records(),
+	}))
+	if err != nil {
+		return
+	}
+	c.Send(rmsg)
+}
+
+func (s *Server) clientConnectedHandler(c *udps.Client) {
+	if !isOverlayClient(c) {
+		return
+	}
+
+	s.publishRecords(&model.ClientRecords{
+		Status:  true,
+		Message: "Joined",
+		Records: []*model.ClientRecord{s.newClientRecord(c)},
+	})
+}
+
+func (s *Server) publishRecords(records *model.ClientRecords) {
+	s.registry.subscribers.Range(func(key, value interface{}) bool {
+		subscriber := value.(*udps.Client)
+		if subscriber.Closed {
+			s.registry.subscribers.Delete(key)
+			return true
+		}
+
+		go subscriber.Send(network.NewMessage(model.MessageTypeClientRecords, s.filterRecords(subscriber, records)))
+		return true
+	})
+}
+
+// Records as seen by a subscriber
+func (s *Server) filterRecords(subscriber *udps.Client, records *model.ClientRecords) *model.ClientRecords {
+	if s.routeAccessHandler == nil && s.exitAccessHandler == nil {
+		return records
+	}
+
+	filtered := &model.ClientRecords{
+		Status:  records.Status,
+		Message: records.Message,
+		Full:    records.Full,
+		Removed: records.Removed,
+	}
+	for _, record := range records.Records {
+		filtered.Records = append(filtered.Records, s.filterRecord(subscriber, record))
+	}
+	return filtered
+}
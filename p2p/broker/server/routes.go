@@ -0,0 +1,289 @@
+package brokers
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+
+	"github.com/supergiant-hq/xnet/model"
+	"github.com/supergiant-hq/xnet/network"
+	udps "github.com/supergiant-hq/xnet/udp/server"
+)
+
+// Called to authorise a Client to advertise a subnet route
+type RouteAdvertiseHandler func(c *udps.Client, network *net.IPNet) bool
+
+// Called to authorise a Client to use a subnet route advertised by a peer
+type RouteAccessHandler func(c *udps.Client, owner string, network *net.IPNet) bool
+
+// Subnet routes advertised by Clients
+type routeTable struct {
+	// Client ID -> []*subnetRoute
+	routes map[string][]*subnetRoute
+	// Client IDs offering exit service
+	exits map[string]bool
+	mutex sync.RWMutex
+}
+
+type subnetRoute struct {
+	network  *net.IPNet
+	priority uint32
+}
+
+// Route advertised by a Client, nil with the reason if rejected
+type routeClaim struct {
+	sroute *model.SubnetRoute
+	route  *subnetRoute
+	reason string
+}
+
+func newRouteTable() *routeTable {
+	return &routeTable{
+		routes: make(map[string][]*subnetRoute),
+		exits:  make(map[string]bool),
+	}
+}
+
+// Routes advertised by a Client
+func (rt *routeTable) get(id string) []*subnetRoute {
+	rt.mutex.RLock()
+	defer rt.mutex.RUnlock()
+
+	return rt.routes[id]
+}
+
+// Check a route against the routes of other Clients
+// The same network can be advertised by multiple Clients using different priorities
+// Must be called with the mutex held
+func (rt *routeTable) conflict(id string, route *subnetRoute) (owner string, ok bool) {
+	for rid, routes := range rt.routes {
+		if rid == id {
+			continue
+		}
+		for _, r := range routes {
+			if r.network.String() == route.network.String() && r.priority == route.priority {
+				return rid, true
+			}
+		}
+	}
+	return
+}
+
+// Reject the claimed routes conflicting with the routes of other Clients or duplicated
+// Returns the routes left
+// Must be called with the mutex held
+func (rt *routeTable) claim(id string, claims []*routeClaim) (routes []*subnetRoute) {
+	seen := map[string]bool{}
+	for _, claim := range claims {
+		if claim.route == nil {
+			continue
+		}
+		if seen[claim.route.network.String()] {
+			claim.route, claim.reason = nil, "duplicate network"
+			continue
+		}
+		if owner, ok := rt.conflict(id, claim.route); ok {
+			claim.route, claim.reason = nil, fmt.Sprintf("advertised by %s with the same priority", owner)
+			continue
+		}
+
+		seen[claim.route.network.String()] = true
+		routes = append(routes, claim.route)
+	}
+	return
+}
+
+// Replace the routes of a Client
+// Returns the IDs of other Clients advertising the same networks as before or now
+// Must be called with the mutex held
+func (rt *routeTable) replace(id string, routes []*subnetRoute) (affected []string) {
+	networks := map[string]bool{}
+	for _, r := range rt.routes[id] {
+		networks[r.network.String()] = true
+	}
+	for _, r := range routes {
+		networks[r.network.String()] = true
+	}
+
+	if len(routes) == 0 {
+		delete(rt.routes, id)
+	} else {
+		rt.routes[id] = routes
+	}
+
+	for rid, rroutes := range rt.routes {
+		if rid == id {
+			continue
+		}
+		for _, r := range rroutes {
+			if networks[r.network.String()] {
+				affected = append(affected, rid)
+				break
+			}
+		}
+	}
+	sort.Strings(affected)
+	return
+}
+
+// Withdraw the routes and exit service of a Client
+func (rt *routeTable) remove(id string) {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+
+	delete(rt.routes, id)
+	delete(rt.exits, id)
+}
+
+// Set Route Advertise Handler
+// All Clients can advertise routes if not set
+func (s *Server) SetRouteAdvertiseHandler(handler RouteAdvertiseHandler) {
+	s.routeAdvertiseHandler = handler
+}
+
+// Set Route Access Handler
+// All Clients can use all routes if not set
+func (s *Server) SetRouteAccessHandler(handler RouteAccessHandler) {
+	s.routeAccessHandler = handler
+}
+
+// Validate a route advertised by a Client
+// Conflicts with the routes of other Clients are checked by routeTable.claim
+func (s *Server) validateRoute(c *udps.Client, sroute *model.SubnetRoute) (route *subnetRoute, reason string) {
+	_, network, err := net.ParseCIDR(sroute.Network)
+	if err != nil {
+		return nil, "invalid network"
+	}
+	route = &subnetRoute{
+		network:  network,
+		priority: sroute.Priority,
+	}
+
+	if s.routeAdvertiseHandler != nil && !s.routeAdvertiseHandler(c, network) {
+		return nil, "not authorised"
+	}
+
+	if s.ipPool != nil {
+		for _, overlay := range []*net.IPNet{s.config.IPAM.IPv4, s.config.IPAM.IPv6} {
+			if overlay != nil && (overlay.Contains(network.IP) || network.Contains(overlay.IP)) {
+				return nil, fmt.Sprintf("overlaps overlay network %s", overlay.String())
+			}
+		}
+	}
+
+	return
+}
+
+func (s *Server) routesHandler(c *udps.Client, msg *network.Message) {
+	sroutes := msg.Body.(*model.ClientRoutes)
+
+	status := &model.ClientRoutesStatus{
+		Status:    true,
+		Message:   "Ok",
+		Accepted:  []*model.SubnetRoute{},
+		Conflicts: []*model.RouteConflict{},
+	}
+
+	var affected []string
+	if !isOverlayClient(c) {
+		status.Status = false
+		status.Message = "Client is not part of the overlay"
+	} else {
+		// The authorisation handlers run before locking the route table, they may query it
+		claims := []*routeClaim{}
+		for _, sroute := range sroutes.Routes {
+			route, reason := s.validateRoute(c, sroute)
+			claims = append(claims, &routeClaim{sroute: sroute, route: route, reason: reason})
+		}
+		if sroutes.Exit && s.exitAdvertiseHandler != nil && !s.exitAdvertiseHandler(c) {
+			status.Conflicts = append(status.Conflicts, &model.RouteConflict{
+				Network: "exit",
+				Reason:  "not authorised",
+			})
+		} else {
+			status.Exit = sroutes.Exit
+		}
+
+		// Conflict checks and update are serialized so that two Clients cannot claim the same route at once
+		s.routes.mutex.Lock()
+		affected = s.routes.replace(c.Id, s.routes.claim(c.Id, claims))
+		for _, claim := range claims {
+			if claim.route == nil {
+				status.Conflicts = append(status.Conflicts, &model.RouteConflict{
+					Network: claim.sroute.Network,
+					Reason:  claim.reason,
+				})
+				continue
+			}
+			status.Accepted = append(status.Accepted, &model.SubnetRoute{
+				Network:  claim.route.network.String(),
+				Priority: claim.route.priority,
+			})
+		}
+		if status.Exit {
+			s.routes.exits[c.Id] = true
+		} else {
+			delete(s.routes.exits, c.Id)
+		}
+		s.routes.mutex.Unlock()
+
+		s.log.Infof("Client(%s) advertised routes: accepted(%d) conflicts(%d) exit(%v)", c.Id, len(status.Accepted), len(status.Conflicts), status.Exit)
+	}
+
+	rmsg, err := msg.GenReply(model.MessageTypeClientRoutesStatus, status)
+	if err != nil {
+		return
+	}
+	c.Send(rmsg)
+
+	if status.Status {
+		records := []*model.ClientRecord{s.newClientRecord(c)}
+		for _, id := range affected {
+			if record, ok := s.Record(id); ok {
+				records = append(records, record)
+			}
+		}
+		s.publishRecords(&model.ClientRecords{
+			Status:  true,
+			Message: "Routes",
+			Records: records,
+		})
+	}
+}
+
+// Record of a Client as seen by a subscriber
+func (s *Server) filterRecord(subscriber *udps.Client, record *model.ClientRecord) *model.ClientRecord {
+	if (s.routeAccessHandler == nil || len(record.Routes) == 0) && (s.exitAccessHandler == nil || !record.Exit) {
+		return record
+	}
+
+	filtered := &model.ClientRecord{
+		Id:               record.Id,
+		Address:          record.Address,
+		Tags:             record.Tags,
+		OverlayAddresses: record.OverlayAddresses,
+		Services:         record.Services,
+		Routes:           record.Routes,
+		Exit:             record.Exit,
+	}
+
+	if s.routeAccessHandler != nil {
+		filtered.Routes = nil
+		for _, route := range record.Routes {
+			_, network, err := net.ParseCIDR(route.Network)
+			if err != nil {
+				continue
+			}
+			if s.routeAccessHandler(subscriber, record.Id, network) {
+				filtered.Routes = append(filtered.Routes, route)
+			}
+		}
+	}
+
+	if s.exitAccessHandler != nil && record.Exit {
+		filtered.Exit = s.exitAccessHandler(subscriber, record.Id)
+	}
+
+	return filtered
+}
@@ -0,0 +1,242 @@
+package brokers
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/supergiant-hq/xnet/ipam"
+	"github.com/supergiant-hq/xnet/model"
+	"github.com/supergiant-hq/xnet/network"
+	udps "github.com/supergiant-hq/xnet/udp/server"
+)
+
+func testRoute(t *testing.T, cidr string, priority uint32) *subnetRoute {
+	t.Helper()
+
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &subnetRoute{network: network, priority: priority}
+}
+
+func TestRouteTableConflict(t *testing.T) {
+	rt := newRouteTable()
+	rt.replace("a", []*subnetRoute{testRoute(t, "10.1.0.0/16", 0)})
+	rt.replace("b", []*subnetRoute{testRoute(t, "10.2.0.0/16", 1)})
+
+	tests := []struct {
+		name      string
+		id        string
+		route     *subnetRoute
+		wantOwner string
+	}{
+		{"same network and priority", "c", testRoute(t, "10.1.0.0/16", 0), "a"},
+		{"host bits set", "c", testRoute(t, "10.1.2.3/16", 0), "a"},
+		{"other priority", "c", testRoute(t, "10.1.0.0/16", 1), ""},
+		{"other network", "c", testRoute(t, "10.1.0.0/24", 0), ""},
+		{"own route", "a", testRoute(t, "10.1.0.0/16", 0), ""},
+		{"second client", "c", testRoute(t, "10.2.0.0/16", 1), "b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner, ok := rt.conflict(tt.id, tt.route)
+			if ok != (tt.wantOwner != "") || owner != tt.wantOwner {
+				t.Fatalf("conflict = %q, %v, want %q", owner, ok, tt.wantOwner)
+			}
+		})
+	}
+}
+
+func TestRouteTableClaim(t *testing.T) {
+	rt := newRouteTable()
+	rt.replace("b", []*subnetRoute{testRoute(t, "172.16.0.0/12", 1)})
+
+	claims := []*routeClaim{
+		{route: testRoute(t, "172.16.0.0/12", 1)},
+		{route: testRoute(t, "172.16.0.0/12", 2)},
+		{route: testRoute(t, "192.168.1.0/24", 0)},
+		{route: testRoute(t, "192.168.1.0/24", 1)},
+		{reason: "not authorised"},
+	}
+	wantReasons := []string{
+		"advertised by b with the same priority",
+		"",
+		"",
+		"duplicate network",
+		"not authorised",
+	}
+
+	routes := rt.claim("a", claims)
+	if len(routes) != 2 {
+		t.Fatalf("%d routes claimed, want 2", len(routes))
+	}
+	for i, claim := range claims {
+		if claim.reason != wantReasons[i] || (claim.route == nil) != (wantReasons[i] != "") {
+			t.Fatalf("claim %d: route %v reason %q, want %q", i, claim.route, claim.reason, wantReasons[i])
+		}
+	}
+}
+
+func TestRoutesHandlerCallbacks(t *testing.T) {
+	s := newTestServer(t)
+	s.routes.exits["b"] = true
+
+	// Handlers querying the exit nodes do not deadlock
+	s.SetRouteAdvertiseHandler(func(c *udps.Client, network *net.IPNet) bool {
+		return len(s.ExitNodes()) > 0
+	})
+	s.SetExitAdvertiseHandler(func(c *udps.Client) bool {
+		return s.CanUseExitNode(c.Id, "b")
+	})
+
+	done := make(chan bool)
+	go func() {
+		s.routesHandler(&udps.Client{Id: "a"}, network.NewMessage(model.MessageTypeClientRoutes, &model.ClientRoutes{
+			Routes: []*model.SubnetRoute{{Network: "192.168.1.0/24"}},
+			Exit:   true,
+		}))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("routes handler deadlocked")
+	}
+	if len(s.routes.get("a")) != 1 || !s.routes.isExit("a") {
+		t.Fatal("routes not accepted")
+	}
+}
+
+func TestRouteTableReplace(t *testing.T) {
+	tests := []struct {
+		name         string
+		routes       []*subnetRoute
+		wantAffected []string
+	}{
+		{
+			name:   "moved network",
+			routes: []*subnetRoute{testRoute(t, "10.3.0.0/16", 1)},
+			// b advertised the previous network of a, c advertises the new one
+			wantAffected: []string{"b", "c"},
+		},
+		{
+			name:         "withdrawn network",
+			routes:       nil,
+			wantAffected: []string{"b"},
+		},
+		{
+			name: "kept and new network",
+			routes: []*subnetRoute{
+				testRoute(t, "10.1.0.0/16", 0),
+				testRoute(t, "10.3.0.0/16", 1),
+			},
+			wantAffected: []string{"b", "c"},
+		},
+		{
+			name:   "unshared network",
+			routes: []*subnetRoute{testRoute(t, "10.9.0.0/16", 0)},
+			// b advertised the previous network of a
+			wantAffected: []string{"b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := newRouteTable()
+			rt.replace("a", []*subnetRoute{testRoute(t, "10.1.0.0/16", 0)})
+			rt.replace("b", []*subnetRoute{testRoute(t, "10.1.0.0/16", 1)})
+			rt.replace("c", []*subnetRoute{testRoute(t, "10.3.0.0/16", 0)})
+
+			affected := rt.replace("a", tt.routes)
+			if strings.Join(affected, ",") != strings.Join(tt.wantAffected, ",") {
+				t.Fatalf("affected %v, want %v", affected, tt.wantAffected)
+			}
+			if got := rt.get("a"); len(got) != len(tt.routes) {
+				t.Fatalf("%d routes, want %d", len(got), len(tt.routes))
+			}
+			if _, ok := rt.routes["a"]; ok != (len(tt.routes) > 0) {
+				t.Fatalf("routes of client kept = %v", ok)
+			}
+		})
+	}
+}
+
+func TestRouteTableRemove(t *testing.T) {
+	rt := newRouteTable()
+	rt.replace("a", []*subnetRoute{testRoute(t, "10.1.0.0/16", 0)})
+	rt.exits["a"] = true
+
+	rt.remove("a")
+	if len(rt.get("a")) != 0 || rt.isExit("a") {
+		t.Fatal("routes of removed client kept")
+	}
+
+	// The network can be claimed again
+	if owner, ok := rt.conflict("b", testRoute(t, "10.1.0.0/16", 0)); ok {
+		t.Fatalf("conflict with removed client %s", owner)
+	}
+}
+
+func TestValidateRoute(t *testing.T) {
+	config, err := ipam.NewConfig("10.100.0.0/16", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		advertise  RouteAdvertiseHandler
+		route      *model.SubnetRoute
+		wantReason string
+	}{
+		{
+			name:  "valid",
+			route: &model.SubnetRoute{Network: "192.168.1.0/24"},
+		},
+		{
+			name:       "invalid network",
+			route:      &model.SubnetRoute{Network: "192.168.1.0"},
+			wantReason: "invalid network",
+		},
+		{
+			name:       "not authorised",
+			advertise:  func(c *udps.Client, network *net.IPNet) bool { return false },
+			route:      &model.SubnetRoute{Network: "192.168.1.0/24"},
+			wantReason: "not authorised",
+		},
+		{
+			name:       "inside overlay",
+			route:      &model.SubnetRoute{Network: "10.100.1.0/24"},
+			wantReason: "overlaps overlay network 10.100.0.0/16",
+		},
+		{
+			name:       "containing overlay",
+			route:      &model.SubnetRoute{Network: "10.0.0.0/8"},
+			wantReason: "overlaps overlay network 10.100.0.0/16",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				config:                Config{IPAM: &config},
+				ipPool:                &ipam.Pool{},
+				routes:                newRouteTable(),
+				routeAdvertiseHandler: tt.advertise,
+			}
+
+			route, reason := s.validateRoute(&udps.Client{Id: "a"}, tt.route)
+			if reason != tt.wantReason {
+				t.Fatalf("reason = %q, want %q", reason, tt.wantReason)
+			}
+			if (route != nil) != (tt.wantReason == "") {
+				t.Fatalf("route = %v", route)
+			}
+		})
+	}
+}
@@ -1,6 +1,12 @@
 package brokers
 
 import (
+	"fmt"
+	"sync"
+
+	"github.com/supergiant-hq/xnet/ipam"
+	"github.com/supergiant-hq/xnet/model"
+	"github.com/supergiant-hq/xnet/p2p"
 	p2ps "github.com/supergiant-hq/xnet/p2p/server"
 	udps "github.com/supergiant-hq/xnet/udp/server"
 	"github.com/supergiant-hq/xnet/util"
@@ -13,6 +19,19 @@ type Server struct {
 	config     Config
 	udpServer  *udps.Server
 	p2pManager *p2ps.Manager
+	cvh        udps.ClientValidateHandler
+	ipPool     *ipam.Pool
+	registry   *registry
+	routes     *routeTable
+	// Current session of each Client ID, the lease and routes of a Client are released by it only
+	sessions map[string]*udps.Client
+	smutex   sync.Mutex
+
+	routeAdvertiseHandler RouteAdvertiseHandler
+	routeAccessHandler    RouteAccessHandler
+	exitAdvertiseHandler  ExitAdvertiseHandler
+	exitAccessHandler     ExitAccessHandler
+	filterPolicyHandler   FilterPolicyHandler
 
 	// Server Open
 	Open bool
@@ -31,15 +50,31 @@ func New(config Config, cvh udps.ClientValidateHandler) (s *Server, err error) {
 	}
 
 	s = &Server{
-		config: config,
-		Exit:   make(chan bool, 1),
-		log:    util.NewLogger(logLevel),
+		config:   config,
+		cvh:      cvh,
+		registry: newRegistry(),
+		routes:   newRouteTable(),
+		sessions: make(map[string]*udps.Client),
+		Exit:     make(chan bool, 1),
+		log:      util.NewLogger(logLevel),
+	}
+
+	if config.IPAM != nil {
+		if s.ipPool, err = ipam.New(s.log, *config.IPAM); err != nil {
+			return
+		}
 	}
 
 	s.udpServer, err = udps.New(s.log, config.UdpsConfig, cvh)
 	if err != nil {
 		return
 	}
+	s.udpServer.SetClientSessionValidateHandler(s.clientValidateHandler)
+	s.udpServer.SetClientConnectedHandler(s.clientConnectedHandler)
+	s.udpServer.SetClientDisconnectedHandler(s.clientDisconnectedHandler)
+	s.udpServer.RegisterHandler(model.MessageTypeClientRecordsQuery, s.recordsQueryHandler)
+	s.udpServer.RegisterHandler(model.MessageTypeClientRoutes, s.routesHandler)
+	s.udpServer.RegisterHandler(model.MessageTypeFilterPolicyQuery, s.filterPolicyQueryHandler)
 
 	s.p2pManager, err = p2ps.New(s.log, s.udpServer)
 	if err != nil {
@@ -49,6 +84,84 @@ func New(config Config, cvh udps.ClientValidateHandler) (s *Server, err error) {
 	return
 }
 
+// Returns the overlay address Pool
+// Returns nil if IPAM is not configured
+func (s *Server) IPPool() *ipam.Pool {
+	return s.ipPool
+}
+
+func (s *Server) clientValidateHandler(c *udps.Client, data *model.ClientValidateData) (cdata *model.ClientData, err error) {
+	if cdata, err = s.cvh(c.Addr, data); err != nil {
+		return
+	}
+
+	// Serialized with the release of a previous session of the Client
+	s.smutex.Lock()
+	defer s.smutex.Unlock()
+	defer func() {
+		if err == nil {
+			s.sessions[cdata.Id] = c
+		}
+	}()
+
+	if s.ipPool == nil {
+		return
+	}
+
+	// Relays do not take part in the overlay
+	if _, ok := cdata.Tags[p2p.TAG_RELAY]; ok {
+		return
+	}
+
+	lease, err := s.ipPool.Acquire(cdata.Id)
+	if err != nil {
+		return nil, fmt.Errorf("error assigning overlay address: %v", err)
+	}
+
+	cdata.OverlayAddresses = []*model.OverlayAddress{}
+	for _, network := range s.ipPool.Networks(lease) {
+		prefix, _ := network.Mask.Size()
+		cdata.OverlayAddresses = append(cdata.OverlayAddresses, &model.OverlayAddress{
+			Ip:     network.IP.String(),
+			Prefix: uint32(prefix),
+		})
+	}
+
+	return
+}
+
+func (s *Server) clientDisconnectedHandler(c *udps.Client) {
+	if len(c.Id) == 0 {
+		return
+	}
+
+	s.smutex.Lock()
+	defer s.smutex.Unlock()
+
+	// The client reconnected using a new session
+	if s.sessions[c.Id] != c {
+		return
+	}
+	delete(s.sessions, c.Id)
+
+	if rc, ok := s.registry.subscribers.Load(c.Id); ok && rc.(*udps.Client) == c {
+		s.registry.subscribers.Delete(c.Id)
+	}
+	// Routes are withdrawn along with the record
+	s.routes.remove(c.Id)
+	if isOverlayClient(c) {
+		s.publishRecords(&model.ClientRecords{
+			Status:  true,
+			Message: "Left",
+			Removed: []string{c.Id},
+		})
+	}
+
+	if s.ipPool != nil {
+		s.ipPool.Release(c.Id)
+	}
+}
+
 // Listen for connections
 func (s *Server) Listen() (err error) {
 	if s.Open {
@@ -77,6 +190,9 @@ func (s *Server) Close() {
 
 	s.p2pManager.CloseAllConnections()
 	s.udpServer.Close(0, "Broker Server Shutdown")
+	if s.ipPool != nil {
+		s.ipPool.Close()
+	}
 
 	select {
 	case s.Exit <- true:
@@ -0,0 +1,84 @@
+package brokers
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/supergiant-hq/xnet/ipam"
+	"github.com/supergiant-hq/xnet/model"
+	udps "github.com/supergiant-hq/xnet/udp/server"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	config, err := ipam.NewConfig("10.100.0.0/16", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, err := ipam.New(log, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	return &Server{
+		config:   Config{IPAM: &config},
+		ipPool:   pool,
+		registry: newRegistry(),
+		routes:   newRouteTable(),
+		sessions: make(map[string]*udps.Client),
+		cvh: func(addr *net.UDPAddr, data *model.ClientValidateData) (*model.ClientData, error) {
+			return &model.ClientData{Id: data.Token}, nil
+		},
+		log: log,
+	}
+}
+
+// Validates a session of a Client as the udps Server does
+func connectTestClient(t *testing.T, s *Server, id string) *udps.Client {
+	t.Helper()
+
+	c := &udps.Client{Addr: &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 4000}}
+	if _, err := s.clientValidateHandler(c, &model.ClientValidateData{Token: id}); err != nil {
+		t.Fatal(err)
+	}
+	c.Id = id
+	return c
+}
+
+func TestClientReconnect(t *testing.T) {
+	s := newTestServer(t)
+
+	old := connectTestClient(t, s, "a")
+	s.routes.replace("a", []*subnetRoute{testRoute(t, "192.168.1.0/24", 0)})
+
+	// The new session is validated before the disconnect handler of the old one runs
+	c := connectTestClient(t, s, "a")
+	s.clientDisconnectedHandler(old)
+
+	l, err := s.ipPool.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.Active {
+		t.Fatal("lease of the new session released")
+	}
+	if len(s.routes.get("a")) != 1 {
+		t.Fatal("routes withdrawn by the old session")
+	}
+
+	s.clientDisconnectedHandler(c)
+	if l, err = s.ipPool.Get("a"); err != nil || l.Active {
+		t.Fatalf("lease %v, %v, want released", l, err)
+	}
+	if len(s.routes.get("a")) != 0 {
+		t.Fatal("routes kept after disconnect")
+	}
+}
@@ -0,0 +1,146 @@
+package p2pc
+
+import (
+	"net"
+	"path"
+	"strconv"
+
+	"github.com/supergiant-hq/xnet/tun"
+	"github.com/supergiant-hq/xnet/util"
+)
+
+// Host candidate policy
+// Host candidates are the addresses of the local interfaces advertised to peers for direct connections
+// Interfaces of TunDevices opened by this process and addresses in the overlay networks
+// assigned by the Broker are always excluded
+type CandidatePolicy struct {
+	// Interface name patterns to include (path.Match syntax, "eth*" for example), all interfaces if empty
+	Interfaces []string
+	// Interface name patterns to exclude ("docker*", "br-*", "veth*" for example)
+	ExcludeInterfaces []string
+	// Networks to include, all addresses if empty
+	Networks []*net.IPNet
+	// Networks to exclude
+	ExcludeNetworks []*net.IPNet
+	// Interface types to exclude, types can be combined (tun.InterfaceTypeVirtual | tun.InterfaceTypeTunnel)
+	ExcludeTypes tun.InterfaceType
+}
+
+// If the policy allows the addresses of an interface
+func (cp *CandidatePolicy) allowInterface(i tun.Interface) bool {
+	if i.Own || i.Type&cp.ExcludeTypes != 0 {
+		return false
+	}
+	if len(cp.Interfaces) > 0 && !matchName(cp.Interfaces, i.Name) {
+		return false
+	}
+	return !matchName(cp.ExcludeInterfaces, i.Name)
+}
+
+// If the policy allows an address
+func (cp *CandidatePolicy) allowIP(ip net.IP) bool {
+	if len(cp.Networks) > 0 && !containsIP(cp.Networks, ip) {
+		return false
+	}
+	return !containsIP(cp.ExcludeNetworks, ip)
+}
+
+func matchName(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Interfaces allowed by the candidate policy along with their allowed addresses
+func (m *Manager) candidateInterfaces() (interfaces []tun.Interface, err error) {
+	all, err := tun.GetInterfaces()
+	if err != nil {
+		return
+	}
+	overlay := m.overlayNetworks()
+
+	for _, i := range all {
+		if !m.config.Candidates.allowInterface(i) {
+			continue
+		}
+
+		addrs := []net.IPAddr{}
+		for _, addr := range i.Addrs {
+			if m.config.Candidates.allowIP(addr.IP) && !containsIP(overlay, addr.IP) {
+				addrs = append(addrs, addr)
+			}
+		}
+		i.Addrs = addrs
+		interfaces = append(interfaces, i)
+	}
+	return
+}
+
+// Overlay networks assigned to the Client by the Broker
+func (m *Manager) overlayNetworks() (networks []*net.IPNet) {
+	if m.client.Data == nil {
+		return
+	}
+
+	for _, addr := range m.client.Data.OverlayAddresses {
+		ip := net.ParseIP(addr.Ip)
+		if ip == nil {
+			continue
+		}
+		bits := net.IPv6len * 8
+		if ip.To4() != nil {
+			bits = net.IPv4len * 8
+		}
+		mask := net.CIDRMask(int(addr.Prefix), bits)
+		networks = append(networks, &net.IPNet{IP: ip.Mask(mask), Mask: mask})
+	}
+	return
+}
+
+// Host candidates (IPv4 and IPv6) on a port allowed by the candidate policy
+// IPv6 link-local addresses are included along with the zone of their interface
+func (m *Manager) candidates(port int) (candidates []string, err error) {
+	interfaces, err := m.candidateInterfaces()
+	if err != nil {
+		return
+	}
+
+	for _, i := range interfaces {
+		for _, addr := range i.Addrs {
+			candidates = append(candidates, net.JoinHostPort(addr.String(), strconv.Itoa(port)))
+		}
+	}
+	return util.RemoveDuplicatesFromSlice(candidates), nil
+}
+
+// Scope an IPv6 link-local candidate of the peer with the local interfaces
+// The zone sent by the peer names its own interface, so the candidate is tried on
+// every local interface allowed by the policy having a link-local address
+func (m *Manager) scopeCandidate(addr *net.UDPAddr) (addrs []*net.UDPAddr) {
+	interfaces, err := m.candidateInterfaces()
+	if err != nil {
+		return
+	}
+
+	for _, i := range interfaces {
+		for _, laddr := range i.Addrs {
+			if len(laddr.Zone) > 0 {
+				addrs = append(addrs, &net.UDPAddr{IP: addr.IP, Port: addr.Port, Zone: laddr.Zone})
+				break
+			}
+		}
+	}
+	return
+}
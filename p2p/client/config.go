@@ -1,7 +1,9 @@
 package p2pc
 
 import (
+	"crypto/ed25519"
 	"net"
+	"time"
 )
 
 // P2P Client Manager
@@ -10,4 +12,33 @@ type Config struct {
 	// Providing an address (non-nil) will ensure that all relay connections from this client
 	// will use the server with this address
 	RelayAddr *net.UDPAddr
+	// Policy selecting the local addresses advertised to peers as host candidates
+	// All addresses except those of the overlay are advertised by default
+	Candidates CandidatePolicy
+
+	// Maximum no. of connections to a peer, unlimited if 0
+	// The least recently used connections are closed when a new connection exceeds it
+	MaxConnectionsPerPeer int
+	// Connections without open streams are closed after being idle for this duration, never if 0
+	IdleTimeout time.Duration
+	// Selects the peer ConnectByTag connects to among the clients having the tag, SelectRandom if nil
+	PeerSelector PeerSelector
+	// Monitors the direct path of initiated P2P connections and fails over to a Relay when it degrades, disabled if nil
+	PathMonitor *PathMonitorConfig
+
+	// Client ID used for direct (Broker-less) connections and LAN discovery
+	// Defaults to the ID assigned by the Broker, set it when the Broker may be unreachable
+	ClientId string
+	// Key identifying this client to peers on direct connections
+	PrivateKey ed25519.PrivateKey
+	// Trusted public keys of peers by Client ID
+	PeerKeys map[string]ed25519.PublicKey
+	// Called to verify the key of a peer which is not in PeerKeys
+	// Direct connections are rejected if the key of the peer is not trusted
+	VerifyPeerKey func(peerId string, key ed25519.PublicKey) bool
+	// Secret shared by the peers of a site-to-site network, it authenticates direct connections without keys
+	// Any holder of the secret can claim any Client ID
+	PreSharedKey []byte
+	// Tags published in the DHT record of the Client in addition to those assigned by the Broker
+	Tags map[string]string
 }
@@ -3,13 +3,12 @@ package p2pc
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/supergiant-hq/xnet/model"
 	"github.com/supergiant-hq/xnet/network"
 	"github.com/supergiant-hq/xnet/p2p"
-	"github.com/supergiant-hq/xnet/tun"
 	"github.com/supergiant-hq/xnet/udp"
-	"github.com/supergiant-hq/xnet/util"
 
 	"github.com/sirupsen/logrus"
 )
@@ -21,32 +20,103 @@ type Connection struct {
 	ClientId string
 
 	initiator bool
+	direct    bool
 	id        string
 	mode      p2p.ConnectionMode
 	peer      *peer
+	// Application data the connection was requested with
+	metadata map[string]string
 
 	p2pConn   *p2pConn
 	relayAddr string
 	relayConn *relayConn
 
+	routedConn *routedConn
+
+	// Path monitor of P2P connections and the relayed path bonded to them
+	// Without a multipath config the relayed path is a failover path used while the direct path is degraded
+	monitor      *pathMonitor
+	relayPath    *relayConn
+	multipath    *MultipathConfig
+	directCredit int64
+	relayCredit  int64
+	fmutex       sync.Mutex
+
+	datagrams datagramQueue
+	// Sequence numbers of the overlay Datagrams, they are passed to the Overlay Datagram Handler
+	overlayDatagrams datagramQueue
+
+	// Streams of the connection and the time one was last opened, used to expire idle connections
+	streams  []*udp.Stream
+	lastUsed time.Time
+	// Usage of the connection reported by Stats
+	connectedAt time.Time
+	streamsIn   int
+	streamsOut  int
+	closedIn    uint64
+	closedOut   uint64
+	smutex      sync.Mutex
+
+	// State and the changes not yet delivered to the ConnectionStateHandler
+	state       p2p.ConnectionState
+	events      []p2p.ConnectionState
+	dispatching bool
+	emutex      sync.Mutex
+
+	reconnectPolicy *ReconnectPolicy
+
 	// Exit Channel
 	Exit chan bool
 	// Closed Status
 	Closed bool
-	mutex  sync.Mutex
+	// Held while connecting and closing
+	mutex sync.Mutex
+	// Guards the identity, the mode, the paths and the status for readers not holding the mutex, writers hold both
+	pmutex sync.RWMutex
 	log    *logrus.Entry
 }
 
-func createConnection(log *logrus.Logger, mgr *Manager, peerId string, mode p2p.ConnectionMode) (c *Connection, err error) {
-	interfaceAddrs, err := tun.GetInterfaceAddresses()
+func createConnection(log *logrus.Logger, mgr *Manager, peerId string, mode p2p.ConnectionMode, metadata map[string]string) (c *Connection, err error) {
+	connData, relayAddress, err := requestConnection(mgr, peerId, mode, metadata, nil)
+	if err != nil {
+		return
+	}
+
+	peer, err := newPeer(mgr, connData.Peer)
 	if err != nil {
 		return
 	}
-	interfaceIPs := []string{}
-	for _, addr := range interfaceAddrs {
-		interfaceIPs = append(interfaceIPs, fmt.Sprintf("%s:%d", addr.String(), mgr.client.Addr.Port))
+	c = &Connection{
+		mgr:      mgr,
+		ClientId: mgr.client.Id,
+
+		initiator: true,
+		id:        connData.Id,
+		mode:      mode,
+		peer:      peer,
+		relayAddr: relayAddress,
+		metadata:  metadata,
+
+		Exit: make(chan bool, 1),
+		log:  log.WithField("prefix", fmt.Sprintf("P2P-CONN-%s", connData.Id)),
+	}
+	mgr.log.Infof("Peer accepted connection request: %s", c.String())
+
+	if err = c.connect(); err != nil {
+		c.Close(err.Error())
+		return
+	}
+
+	return
+}
+
+// Request a connection with the peer through the Broker
+// A relay connection with a bond is an additional path of an existing P2P connection
+func requestConnection(mgr *Manager, peerId string, mode p2p.ConnectionMode, metadata map[string]string, bond *model.P2PBond) (connData *model.P2PConnectionData, relayAddress string, err error) {
+	interfaceIPs, err := mgr.candidates(mgr.localPort())
+	if err != nil {
+		return
 	}
-	relayAddress := ""
 
 	if mode == p2p.ConnectionModeRelay {
 		relayAddress, err = mgr.getNearestRelay()
@@ -63,8 +133,10 @@ func createConnection(log *logrus.Logger, mgr *Manager, peerId string, mode p2p.
 			Peer: &model.P2PPeerData{
 				Id:        peerId,
 				Address:   mgr.client.Addr.String(),
-				Addresses: util.RemoveDuplicatesFromSlice(interfaceIPs),
+				Addresses: interfaceIPs,
 			},
+			Bond:     bond,
+			Metadata: metadata,
 		},
 		p2p.ConnectionTimeout,
 	)
@@ -73,33 +145,12 @@ func createConnection(log *logrus.Logger, mgr *Manager, peerId string, mode p2p.
 		return
 	}
 
-	connData := mres.Body.(*model.P2PConnectionData)
-	if !connData.Status {
-		err = fmt.Errorf(connData.Message)
-		return
-	}
-
-	peer, err := newPeer(connData.Peer)
-	if err != nil {
+	connData = mres.Body.(*model.P2PConnectionData)
+	if connData.Rejected {
+		err = &ConnectionRejectedError{PeerId: peerId, Reason: connData.Message}
 		return
-	}
-	c = &Connection{
-		mgr:      mgr,
-		ClientId: mgr.client.Id,
-
-		initiator: true,
-		id:        connData.Id,
-		mode:      mode,
-		peer:      peer,
-		relayAddr: relayAddress,
-
-		Exit: make(chan bool, 1),
-		log:  log.WithField("prefix", fmt.Sprintf("P2P-CONN-%s", connData.Id)),
-	}
-	mgr.log.Infof("Peer accepted connection request: %s", c.String())
-
-	if err = c.connect(); err != nil {
-		c.Close(err.Error())
+	} else if !connData.Status {
+		err = fmt.Errorf(connData.Message)
 		return
 	}
 
@@ -107,7 +158,7 @@ func createConnection(log *logrus.Logger, mgr *Manager, peerId string, mode p2p.
 }
 
 func acceptConnection(log *logrus.Logger, mgr *Manager, connData *model.P2PConnectionRequest) (c *Connection, err error) {
-	peer, err := newPeer(connData.Peer)
+	peer, err := newPeer(mgr, connData.Peer)
 	if err != nil {
 		return
 	}
@@ -121,6 +172,7 @@ func acceptConnection(log *logrus.Logger, mgr *Manager, connData *model.P2PConne
 		mode:      p2p.ConnectionMode(connData.Mode),
 		peer:      peer,
 		relayAddr: connData.RelayAddress,
+		metadata:  connData.Metadata,
 
 		Exit: make(chan bool, 1),
 		log:  log.WithField("prefix", fmt.Sprintf("P2P-CONN-%s", connData.Id)),
@@ -147,35 +199,53 @@ func (c *Connection) connect() (err error) {
 		}
 	}()
 
+	c.setState(p2p.ConnectionStateConnecting)
+
 	switch c.mode {
 	case p2p.ConnectionModeP2P:
-		c.p2pConn = c.newP2PConn()
+		if c.p2pConn == nil {
+			conn := c.newP2PConn()
+			c.update(func() { c.p2pConn = conn })
+		}
 		if err = c.p2pConn.connect(); err != nil {
 			c.p2pConn.close()
-			c.p2pConn = nil
+			c.update(func() { c.p2pConn = nil })
 			return
 		}
 
-		go func() {
-			<-c.p2pConn.exit
-			c.Close("Exited")
-		}()
+		go c.awaitExit(c.p2pConn.exit)
+		c.p2pConn.startProbing()
+		c.startPathMonitor(c.p2pConn)
 
 	case p2p.ConnectionModeRelay:
-		c.relayConn, err = c.newRelayConn()
-		if err != nil {
+		var conn *relayConn
+		if conn, err = c.newRelayConn(c.id, c.relayAddr); err != nil {
 			return
 		}
+		c.update(func() { c.relayConn = conn })
 		if err = c.relayConn.connect(); err != nil {
 			c.relayConn.close()
-			c.relayConn = nil
+			c.update(func() { c.relayConn = nil })
 			return
 		}
 
-		go func() {
-			<-c.relayConn.exit
-			c.Close("Exited")
-		}()
+		go c.awaitExit(c.relayConn.exit)
+
+	case p2p.ConnectionModeRouted:
+		if c.routedConn == nil {
+			var conn *routedConn
+			if conn, err = c.newRoutedConn(); err != nil {
+				return
+			}
+			c.update(func() { c.routedConn = conn })
+		}
+		if err = c.routedConn.connect(); err != nil {
+			c.routedConn.close()
+			c.update(func() { c.routedConn = nil })
+			return
+		}
+
+		go c.awaitExit(c.routedConn.exit)
 
 	default:
 		err = fmt.Errorf("invalid connection mode: %v", c.mode)
@@ -188,7 +258,23 @@ func (c *Connection) connect() (err error) {
 }
 
 func (c *Connection) notifyNewConnection() {
-	if c.mgr.connectionHandler != nil {
+	c.smutex.Lock()
+	reconnected := !c.connectedAt.IsZero()
+	c.connectedAt = time.Now()
+	c.smutex.Unlock()
+	c.datagrams.reset()
+	c.overlayDatagrams.reset()
+	c.touch()
+	c.setState(p2p.ConnectionStateConnected)
+	go c.mgr.limitConnections(c.peer.id)
+
+	// Peers connected to directly exchange routes, the initiator opens the route stream
+	if r, err := c.mgr.getRouter(); err == nil && c.initiator && c.mode != p2p.ConnectionModeRouted {
+		go r.connectNeighbour(c)
+	}
+
+	// Re-established connections are reported by the ConnectionStateHandler only
+	if c.mgr.connectionHandler != nil && !reconnected {
 		go c.mgr.connectionHandler(c)
 	}
 }
@@ -214,23 +300,127 @@ func (c *Connection) OpenMessageStream() (ms *MessageStream, err error) {
 	return
 }
 
+// Open a Stream to exchange IP packets with the Peer
+func (c *Connection) OpenOverlayStream() (stream *udp.Stream, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.Closed {
+		err = fmt.Errorf("connection closed")
+		return
+	}
+
+	return c.openStream(map[string]string{
+		p2p.KEY_STREAM_OVERLAY: "true",
+	}, nil)
+}
+
+// Open a Stream to exchange Ethernet frames with the Peer
+// It is delivered to the Overlay Stream Handler of the Peer with KEY_STREAM_BRIDGE set in its metadata
+func (c *Connection) OpenBridgeStream() (stream *udp.Stream, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.Closed {
+		err = fmt.Errorf("connection closed")
+		return
+	}
+
+	return c.openStream(map[string]string{
+		p2p.KEY_STREAM_OVERLAY: "true",
+		p2p.KEY_STREAM_BRIDGE:  "true",
+	}, nil)
+}
+
+// Open a Stream to exchange routes and forwarded packets with the Peer
+func (c *Connection) openRouteStream() (stream *udp.Stream, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.Closed {
+		err = fmt.Errorf("connection closed")
+		return
+	}
+
+	return c.openStream(map[string]string{
+		p2p.KEY_STREAM_ROUTE: "true",
+	}, nil)
+}
+
 func (c *Connection) openStream(metadata map[string]string, data map[string]string) (stream *udp.Stream, err error) {
 	if metadata == nil {
 		metadata = map[string]string{}
 	}
-	metadata[p2p.KEY_CONNECTION_ID] = c.id
+	metadata[p2p.KEY_CONNECTION_ID] = c.Id()
 	if data == nil {
 		data = map[string]string{}
 	}
 
-	switch c.mode {
-	case p2p.ConnectionModeP2P:
-		return c.p2pConn.openStream(metadata, data)
-	case p2p.ConnectionModeRelay:
-		return c.relayConn.openStream(metadata, data)
+	// Bonded connections fall back to the other path
+	paths, _ := c.schedule()
+	err = udp.ErrorNotConnected
+	for _, path := range paths {
+		if stream, err = path.openStream(metadata, data); err == nil {
+			c.track(path.counters(), stream, false)
+			return
+		}
 	}
 	return
+}
+
+// Record the use of the connection
+func (c *Connection) touch() {
+	c.smutex.Lock()
+	defer c.smutex.Unlock()
+
+	c.lastUsed = time.Now()
+}
+
+// Record a stream opened by either peer over a path
+func (c *Connection) track(path *pathCounters, stream *udp.Stream, incoming bool) {
+	if path != nil {
+		path.track(stream, incoming)
+	}
+
+	c.smutex.Lock()
+	defer c.smutex.Unlock()
+
+	if incoming {
+		c.streamsIn++
+	} else {
+		c.streamsOut++
+	}
+	c.lastUsed = time.Now()
+	c.streams = append(c.streams, stream)
+}
 
+// Time the connection was last used, now if it has open streams
+func (c *Connection) lastActive() time.Time {
+	c.smutex.Lock()
+	defer c.smutex.Unlock()
+
+	c.prune()
+	if len(c.streams) > 0 {
+		return time.Now()
+	}
+	return c.lastUsed
+}
+
+// Forget closed streams, keeping the bytes they transferred
+func (c *Connection) prune() {
+	streams := c.streams[:0]
+	for _, stream := range c.streams {
+		if !stream.Closed {
+			streams = append(streams, stream)
+			continue
+		}
+		c.closedIn += stream.BytesRead()
+		c.closedOut += stream.BytesWritten()
+	}
+	for i := len(streams); i < len(c.streams); i++ {
+		c.streams[i] = nil
+	}
+	c.streams = streams
 }
 
 // Open Stream to Peer
@@ -238,16 +428,53 @@ func (c *Connection) OpenStream(data map[string]string) (stream *udp.Stream, err
 	return c.openStream(nil, data)
 }
 
+// Connection ID, it changes when the connection is re-established
+func (c *Connection) Id() string {
+	c.pmutex.RLock()
+	defer c.pmutex.RUnlock()
+
+	return c.id
+}
+
+// Peer ID
+func (c *Connection) PeerId() string {
+	c.pmutex.RLock()
+	defer c.pmutex.RUnlock()
+
+	return c.peer.id
+}
+
+// Logger of the connection, its prefix carries the current connection ID
+func (c *Connection) logger() *logrus.Entry {
+	c.pmutex.RLock()
+	defer c.pmutex.RUnlock()
+
+	return c.log
+}
+
+// Path MTU to the Peer, the largest IP packet carrying QUIC packets which reaches the Peer
+// It is found by the path MTU discovery of QUIC on P2P connections,
+// Relay connections and routed connections report MinPathMTU
+func (c *Connection) PathMTU() int {
+	s := c.snapshot()
+	if s.mode != p2p.ConnectionModeP2P || s.p2p == nil {
+		return MinPathMTU
+	}
+	return s.p2p.pathMTU()
+}
+
+// Search the path MTU to the Peer now
+func (c *Connection) ProbePathMTU() (mtu int, err error) {
+	s := c.snapshot()
+	if s.mode != p2p.ConnectionModeP2P || s.p2p == nil {
+		return MinPathMTU, fmt.Errorf("path mtu probing requires a p2p connection")
+	}
+	return s.p2p.probePathMTU()
+}
+
 // If Connection is active
 func (c *Connection) IsConnected() bool {
-	switch c.mode {
-	case p2p.ConnectionModeP2P:
-		return c.p2pConn != nil && c.p2pConn.connected
-	case p2p.ConnectionModeRelay:
-		return c.relayConn != nil && c.relayConn.connected
-	default:
-		return false
-	}
+	return c.snapshot().connected()
 }
 
 // Close Connection
@@ -259,26 +486,110 @@ func (c *Connection) Close(reason string) {
 		return
 	}
 
-	if c.p2pConn != nil {
-		c.p2pConn.close()
-		c.p2pConn = nil
-	}
-
-	if c.relayConn != nil {
-		c.relayConn.close()
-		c.relayConn = nil
-	}
+	c.closeConns()
 
 	select {
 	case c.Exit <- true:
 	default:
 	}
-	c.Closed = true
+	c.update(func() { c.Closed = true })
+	c.datagrams.close()
+	c.overlayDatagrams.close()
+	c.setState(p2p.ConnectionStateDisconnected)
 
 	c.log.Warnf("Connection closed: %s", reason)
 }
 
+// Close the connection with the peer in the current mode
+func (c *Connection) closeConns() {
+	c.fmutex.Lock()
+	c.monitor = nil
+	c.fmutex.Unlock()
+	c.closeRelayPath()
+
+	p2pConn, relayConn, routedConn := c.p2pConn, c.relayConn, c.routedConn
+	c.update(func() { c.p2pConn, c.relayConn, c.routedConn = nil, nil, nil })
+
+	if p2pConn != nil {
+		p2pConn.close()
+	}
+	if relayConn != nil {
+		relayConn.close()
+	}
+	if routedConn != nil {
+		routedConn.close()
+	}
+}
+
+// Change the identity, the mode, the paths or the status, called under the mutex
+func (c *Connection) update(f func()) {
+	c.pmutex.Lock()
+	defer c.pmutex.Unlock()
+
+	f()
+}
+
+// Identity, mode, paths and status of a Connection at one point in time
+type connSnapshot struct {
+	id        string
+	mode      p2p.ConnectionMode
+	peer      *peer
+	relayAddr string
+	direct    bool
+	p2p       *p2pConn
+	relay     *relayConn
+	routed    *routedConn
+	closed    bool
+}
+
+// Snapshot of the identity, the mode, the paths and the status, they change while the connection is re-established
+func (c *Connection) snapshot() connSnapshot {
+	c.pmutex.RLock()
+	defer c.pmutex.RUnlock()
+
+	return connSnapshot{
+		id:        c.id,
+		mode:      c.mode,
+		peer:      c.peer,
+		relayAddr: c.relayAddr,
+		direct:    c.direct,
+		p2p:       c.p2pConn,
+		relay:     c.relayConn,
+		routed:    c.routedConn,
+		closed:    c.Closed,
+	}
+}
+
+// If the path of the mode is connected
+func (s connSnapshot) connected() bool {
+	switch s.mode {
+	case p2p.ConnectionModeP2P:
+		return s.p2p != nil && s.p2p.isConnected()
+	case p2p.ConnectionModeRelay:
+		return s.relay != nil && s.relay.isConnected()
+	case p2p.ConnectionModeRouted:
+		return s.routed != nil && s.routed.isConnected()
+	default:
+		return false
+	}
+}
+
+// If exit belongs to the connection with the peer in the current mode
+func (c *Connection) current(exit chan bool) bool {
+	switch c.mode {
+	case p2p.ConnectionModeP2P:
+		return c.p2pConn != nil && c.p2pConn.exit == exit
+	case p2p.ConnectionModeRelay:
+		return c.relayConn != nil && c.relayConn.exit == exit
+	case p2p.ConnectionModeRouted:
+		return c.routedConn != nil && c.routedConn.exit == exit
+	default:
+		return false
+	}
+}
+
 // Stringify
 func (c *Connection) String() string {
-	return fmt.Sprintf("id(%s) with mode(%v) peer(%v) closed(%v)", c.id, c.mode, c.peer.id, c.Closed)
+	s := c.snapshot()
+	return fmt.Sprintf("id(%s) with mode(%v) peer(%v) closed(%v)", s.id, s.mode, s.peer.id, s.closed)
 }
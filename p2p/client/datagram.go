@@ -0,0 +1,217 @@
+package p2pc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/supergiant-hq/xnet/p2p"
+	"github.com/supergiant-hq/xnet/udp"
+)
+
+const (
+	// Largest Datagram sent by Connection.SendDatagram, it fits into the smallest packet QUIC sends
+	MaxDatagramSize = quicPacketSizeIPv6 - QuicPacketOverhead - datagramFrameOverhead - datagramHeader
+	// No. of received Datagrams buffered for ReceiveDatagram, further Datagrams are dropped
+	DatagramQueueSize = 256
+
+	// Data Datagrams carry a sequence number, duplicates received over another path are dropped
+	datagramHeader = 1 + 4
+	// No. of sequence numbers below the highest received which are still accepted
+	datagramWindow = 64
+)
+
+// Datagrams received from the peer over the paths of a Connection
+type datagramQueue struct {
+	// Accessed atomically, kept first for alignment
+	nextSeq uint32
+
+	highest uint32
+	seen    uint64
+	queue   chan []byte
+	done    chan bool
+	closed  bool
+	mutex   sync.Mutex
+}
+
+// Create the channels, called under the mutex
+func (q *datagramQueue) init() {
+	if q.queue == nil {
+		q.queue = make(chan []byte, DatagramQueueSize)
+		q.done = make(chan bool)
+	}
+}
+
+// Sequence number of the next Datagram sent
+func (q *datagramQueue) next() uint32 {
+	return atomic.AddUint32(&q.nextSeq, 1)
+}
+
+// Forget the received sequence numbers, the peer numbers its Datagrams anew on new connections
+func (q *datagramQueue) reset() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.seen = 0
+}
+
+// If a sequence number was not received yet, marking it received
+func (q *datagramQueue) accept(seq uint32) bool {
+	if q.seen == 0 {
+		q.highest, q.seen = seq, 1
+		return true
+	}
+
+	diff := int32(seq - q.highest)
+	switch {
+	case diff > 0:
+		if diff >= datagramWindow {
+			q.seen = 1
+		} else {
+			q.seen = q.seen<<uint(diff) | 1
+		}
+		q.highest = seq
+		return true
+	case -diff >= datagramWindow:
+		return false
+	default:
+		bit := uint64(1) << uint(-diff)
+		if q.seen&bit != 0 {
+			return false
+		}
+		q.seen |= bit
+		return true
+	}
+}
+
+// If a sequence number was not received yet, for Datagrams which are not queued
+func (q *datagramQueue) fresh(seq uint32) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return !q.closed && q.accept(seq)
+}
+
+func (q *datagramQueue) deliver(seq uint32, b []byte) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.closed || !q.accept(seq) {
+		return
+	}
+	q.init()
+
+	select {
+	case q.queue <- b:
+	default:
+	}
+}
+
+func (q *datagramQueue) receive() (b []byte, err error) {
+	q.mutex.Lock()
+	q.init()
+	queue, done := q.queue, q.done
+	q.mutex.Unlock()
+
+	select {
+	case b = <-queue:
+	case <-done:
+		err = fmt.Errorf("connection closed")
+	}
+	return
+}
+
+func (q *datagramQueue) close() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.closed {
+		return
+	}
+	q.init()
+	close(q.done)
+	q.closed = true
+}
+
+// Send a Datagram to the Peer, it arrives at most once and may be lost or reordered
+// It is sent over the paths of a bonded connection according to the multipath mode
+// Routed connections do not support Datagrams, their size is limited to MaxDatagramSize
+func (c *Connection) SendDatagram(b []byte) (err error) {
+	return c.sendDatagram(datagramData, &c.datagrams, b)
+}
+
+// Send a Datagram carrying overlay packets to the Peer
+// It is delivered to the Overlay Datagram Handler of the Peer, the size is limited to MaxDatagramSize
+func (c *Connection) SendOverlayDatagram(b []byte) (err error) {
+	return c.sendDatagram(datagramOverlay, &c.overlayDatagrams, b)
+}
+
+func (c *Connection) sendDatagram(kind byte, q *datagramQueue, b []byte) (err error) {
+	if len(b) > MaxDatagramSize {
+		err = fmt.Errorf("datagram too large: %d > %d", len(b), MaxDatagramSize)
+		return
+	}
+	if s := c.snapshot(); s.closed {
+		err = fmt.Errorf("connection closed")
+		return
+	} else if s.mode == p2p.ConnectionModeRouted {
+		err = udp.ErrorDatagramsUnsupported
+		return
+	}
+
+	d := make([]byte, datagramHeader+len(b))
+	d[0] = kind
+	binary.BigEndian.PutUint32(d[1:datagramHeader], q.next())
+	copy(d[datagramHeader:], b)
+
+	paths, redundant := c.schedule()
+	sent := false
+	err = udp.ErrorNotConnected
+	for _, path := range paths {
+		client, _ := path.path()
+		if client == nil {
+			continue
+		} else if !client.DatagramsSupported() {
+			err = udp.ErrorDatagramsUnsupported
+			continue
+		}
+
+		if err = client.SendDatagram(d); err != nil {
+			continue
+		}
+		path.counters().countDatagram(false)
+		sent = true
+		if !redundant {
+			break
+		}
+	}
+	if sent {
+		err = nil
+	}
+	return
+}
+
+// Receive a Datagram sent by the Peer with SendDatagram
+func (c *Connection) ReceiveDatagram() (b []byte, err error) {
+	return c.datagrams.receive()
+}
+
+// Deliver a data or overlay Datagram received over a path
+func (c *Connection) receiveDatagram(path *pathCounters, b []byte) {
+	if len(b) < datagramHeader {
+		return
+	}
+
+	seq := binary.BigEndian.Uint32(b[1:datagramHeader])
+	switch b[0] {
+	case datagramData:
+		path.countDatagram(true)
+		c.datagrams.deliver(seq, b[datagramHeader:])
+	case datagramOverlay:
+		path.countDatagram(true)
+		if handler := c.mgr.overlayDatagramHandler; handler != nil && c.overlayDatagrams.fresh(seq) {
+			handler(c, b[datagramHeader:])
+		}
+	}
+}
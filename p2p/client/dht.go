@@ -0,0 +1,291 @@
+package p2pc
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/supergiant-hq/xnet/model"
+	"github.com/supergiant-hq/xnet/network"
+	"github.com/supergiant-hq/xnet/p2p/dht"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// Size of the nonce of connection requests sent over the DHT
+	dhtNonceSize = 16
+
+	dhtConnect = "dht connect"
+)
+
+// Start the DHT Node of the Manager and publish its peer record
+// The record holds the Client ID, public key, host candidates and tags of the Client and is
+// published again periodically. Requires Config.PrivateKey to sign the record
+func (m *Manager) StartDHT(config dht.Config) (node *dht.Node, err error) {
+	if m.config.PrivateKey == nil {
+		err = fmt.Errorf("dht requires a private key")
+		return
+	}
+	if config.PrivateKey == nil {
+		config.PrivateKey = m.config.PrivateKey
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.dhtNode != nil {
+		err = fmt.Errorf("dht already running")
+		return
+	}
+
+	if node, err = dht.New(m.log.Logger, config); err != nil {
+		return
+	}
+	node.SetRequestHandler(m.dhtRequestHandler)
+	if err = node.Listen(); err != nil {
+		return
+	}
+
+	m.dhtNode = node
+	go m.publishLoop(node)
+
+	return
+}
+
+func (m *Manager) publishLoop(node *dht.Node) {
+	for {
+		record, err := m.dhtRecord(node, node.RecordTTL())
+		if err == nil {
+			var stored int
+			stored, err = node.Publish(record)
+			m.log.Debugf("Published DHT record to (%d) nodes", stored)
+		}
+		if err != nil {
+			m.log.Warnln("Error publishing DHT record:", err.Error())
+		}
+
+		select {
+		case <-time.After(dht.RepublishInterval):
+		case <-node.Exit:
+			return
+		}
+	}
+}
+
+// Peer record of the Manager
+// The tags are those assigned by the Broker along with Config.Tags
+func (m *Manager) dhtRecord(node *dht.Node, ttl time.Duration) (record *model.DHTRecord, err error) {
+	addrs, err := m.candidates(m.localPort())
+	if err != nil {
+		return
+	}
+	tags := map[string]string{}
+	if m.client.Data != nil {
+		if len(m.client.Data.Address) > 0 {
+			addrs = append(addrs, m.client.Data.Address)
+		}
+		for k, v := range m.client.Data.Tags {
+			tags[k] = v
+		}
+	}
+	for k, v := range m.config.Tags {
+		tags[k] = v
+	}
+
+	return dht.NewRecord(m.config.PrivateKey, m.localId(), addrs, tags, node.ID, ttl), nil
+}
+
+func (m *Manager) getDHTNode() (node *dht.Node, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.dhtNode == nil {
+		return nil, fmt.Errorf("dht not running")
+	}
+	select {
+	case <-m.dhtNode.Exit:
+		return nil, fmt.Errorf("dht not running")
+	default:
+	}
+	return m.dhtNode, nil
+}
+
+// Trusted records of a peer, newest first
+func (m *Manager) trustedRecords(records []*model.DHTRecord) (trusted []*model.DHTRecord) {
+	for _, record := range records {
+		if record.Id != m.localId() && m.trustedKey(record.Id, record.Key, nil) {
+			trusted = append(trusted, record)
+		}
+	}
+	sort.Slice(trusted, func(i, j int) bool {
+		return trusted[i].Timestamp > trusted[j].Timestamp
+	})
+	return
+}
+
+// Client IDs of the trusted peers having a tag, found in the DHT
+func (m *Manager) FindByTagDHT(tag string) (peerIds []string, err error) {
+	node, err := m.getDHTNode()
+	if err != nil {
+		return
+	}
+
+	found := map[string]bool{}
+	for _, record := range m.trustedRecords(node.FindTag(tag)) {
+		if !found[record.Id] {
+			found[record.Id] = true
+			peerIds = append(peerIds, record.Id)
+		}
+	}
+	if len(peerIds) == 0 {
+		err = fmt.Errorf("no peers with tag (%s) found", tag)
+	}
+	return
+}
+
+// Connect to a peer found in the DHT, without the Broker
+// A connection request is sent to the DHT Node of the peer, which then punches towards this Client,
+// and the connection is authenticated by the key in the trusted record of the peer
+func (m *Manager) ConnectDHT(peerId string) (conn *Connection, err error) {
+	return m.connectDHT(peerId, nil)
+}
+
+func (m *Manager) connectDHT(peerId string, metadata map[string]string) (conn *Connection, err error) {
+	node, err := m.getDHTNode()
+	if err != nil {
+		return
+	}
+
+	records := m.trustedRecords(node.FindPeer(peerId))
+	if len(records) == 0 {
+		err = fmt.Errorf("no trusted record of peer (%s) found", peerId)
+		return
+	}
+	record := records[0]
+
+	addrs := []*net.UDPAddr{}
+	for _, raddr := range record.Addresses {
+		addr, err := net.ResolveUDPAddr("udp", raddr)
+		if err != nil {
+			continue
+		}
+		if addr.IP.IsLinkLocalUnicast() && addr.IP.To4() == nil {
+			addrs = append(addrs, m.scopeCandidate(addr)...)
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+
+	// The peer is reachable without punching if the request does not arrive
+	if naddr, err := net.ResolveUDPAddr("udp", record.NodeAddress); err == nil {
+		if err := m.sendConnectionRequest(node, naddr, peerId); err != nil {
+			m.log.Warnf("Connection request to peer (%s) failed: %s", peerId, err.Error())
+		}
+	}
+
+	m.log.Infof("Connecting to DHT peer id(%s) at (%v)...", peerId, addrs)
+	return m.connectDirect(peerId, record.Key, addrs, metadata)
+}
+
+// Send a connection request to the DHT Node of a peer
+// The request carries the record of this Client and a nonce signed for the peer
+func (m *Manager) sendConnectionRequest(node *dht.Node, addr *net.UDPAddr, peerId string) (err error) {
+	record, err := m.dhtRecord(node, network.ConnectionTimeout)
+	if err != nil {
+		return
+	}
+	req, err := m.dhtConnectionRequest(record, peerId)
+	if err != nil {
+		return
+	}
+	payload, err := proto.Marshal(req)
+	if err != nil {
+		return
+	}
+	_, err = node.SendRequest(addr, payload)
+	return
+}
+
+// Connection request to a peer carrying a record of this Client
+func (m *Manager) dhtConnectionRequest(record *model.DHTRecord, peerId string) (req *model.DHTConnectionRequest, err error) {
+	req = &model.DHTConnectionRequest{
+		Record:    record,
+		Target:    peerId,
+		Nonce:     make([]byte, dhtNonceSize),
+		Timestamp: time.Now().Unix(),
+	}
+	if _, err = rand.Read(req.Nonce); err != nil {
+		return
+	}
+	req.Signature = ed25519.Sign(m.config.PrivateKey, dhtRequestPayload(req))
+	return
+}
+
+// Payload signed by the sender of a connection request
+func dhtRequestPayload(req *model.DHTConnectionRequest) []byte {
+	return signedPayload(dhtConnect, req.Record.Id, req.Target, base64.StdEncoding.EncodeToString(req.Nonce), strconv.FormatInt(req.Timestamp, 10))
+}
+
+// Connection request of a peer delivered by the DHT
+// A request of a trusted peer for this Client is answered by punching towards its addresses,
+// each request is accepted once within the allowed clock skew
+func (m *Manager) dhtRequestHandler(addr *net.UDPAddr, payload []byte) (reply []byte, err error) {
+	req := &model.DHTConnectionRequest{}
+	if err = proto.Unmarshal(payload, req); err != nil {
+		return
+	}
+	record := req.Record
+	if record == nil {
+		err = fmt.Errorf("connection request without record")
+		return
+	}
+	if err = dht.VerifyRecord(record); err != nil {
+		return
+	}
+	if !m.trustedKey(record.Id, record.Key, nil) {
+		err = fmt.Errorf("peer (%s) is not trusted", record.Id)
+		return
+	}
+	if req.Target != m.localId() {
+		err = fmt.Errorf("connection request for another client")
+		return
+	}
+	if skew := time.Since(time.Unix(req.Timestamp, 0)); skew > directClockSkew || skew < -directClockSkew {
+		err = fmt.Errorf("connection request expired")
+		return
+	}
+	if len(req.Nonce) != dhtNonceSize || !ed25519.Verify(record.Key, dhtRequestPayload(req), req.Signature) {
+		err = fmt.Errorf("invalid signature of connection request")
+		return
+	}
+	if m.replayed(base64.StdEncoding.EncodeToString(req.Nonce)) {
+		err = fmt.Errorf("connection request replayed")
+		return
+	}
+
+	addrs := []*net.UDPAddr{}
+	for _, raddr := range record.Addresses {
+		if addr, err := net.ResolveUDPAddr("udp", raddr); err == nil {
+			addrs = append(addrs, addr)
+		}
+	}
+	m.log.Infof("Connection request of peer (%s) from DHT, punching to (%v)", record.Id, addrs)
+	go m.punch(addrs, network.ConnectionTimeout)
+
+	return
+}
+
+// Punch towards the addresses of a peer to open the NAT mappings for its connection
+func (m *Manager) punch(addrs []*net.UDPAddr, duration time.Duration) {
+	for start := time.Now(); time.Since(start) < duration; time.Sleep(time.Second) {
+		for _, addr := range addrs {
+			m.client.UDPConn.WriteTo([]byte("punch!"), addr)
+		}
+	}
+}
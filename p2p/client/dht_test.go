@@ -0,0 +1,131 @@
+package p2pc
+
+import (
+	"crypto/ed25519"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/supergiant-hq/xnet/model"
+	"github.com/supergiant-hq/xnet/p2p/dht"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestDHTRequestHandler(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	apub, akey, _ := ed25519.GenerateKey(nil)
+	_, xkey, _ := ed25519.GenerateKey(nil)
+	a := newTestManager(Config{ClientId: "a", PrivateKey: akey})
+	x := newTestManager(Config{ClientId: "a", PrivateKey: xkey})
+
+	// Request of a peer, the record has no addresses to punch to
+	request := func(m *Manager, target string) *model.DHTConnectionRequest {
+		req, err := m.dhtConnectionRequest(dht.NewRecord(m.config.PrivateKey, "a", nil, nil, dht.ID{}, time.Minute), target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+
+	tests := []struct {
+		name    string
+		req     func() *model.DHTConnectionRequest
+		wantErr bool
+	}{
+		{
+			name: "signed",
+			req:  func() *model.DHTConnectionRequest { return request(a, "b") },
+		},
+		{
+			name:    "other target",
+			req:     func() *model.DHTConnectionRequest { return request(a, "c") },
+			wantErr: true,
+		},
+		{
+			name: "expired",
+			req: func() *model.DHTConnectionRequest {
+				req := request(a, "b")
+				req.Timestamp -= int64(2 * directClockSkew / time.Second)
+				req.Signature = ed25519.Sign(akey, dhtRequestPayload(req))
+				return req
+			},
+			wantErr: true,
+		},
+		{
+			name: "nonce changed",
+			req: func() *model.DHTConnectionRequest {
+				req := request(a, "b")
+				req.Nonce[0] ^= 1
+				return req
+			},
+			wantErr: true,
+		},
+		{
+			name: "without nonce",
+			req: func() *model.DHTConnectionRequest {
+				req := request(a, "b")
+				req.Nonce = nil
+				req.Signature = ed25519.Sign(akey, dhtRequestPayload(req))
+				return req
+			},
+			wantErr: true,
+		},
+		{
+			name: "signed with another key",
+			req: func() *model.DHTConnectionRequest {
+				req := request(a, "b")
+				req.Signature = ed25519.Sign(xkey, dhtRequestPayload(req))
+				return req
+			},
+			wantErr: true,
+		},
+		{
+			name:    "untrusted key",
+			req:     func() *model.DHTConnectionRequest { return request(x, "b") },
+			wantErr: true,
+		},
+		{
+			name: "without record",
+			req: func() *model.DHTConnectionRequest {
+				req := request(a, "b")
+				req.Record = nil
+				return req
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestManager(Config{
+				ClientId: "b",
+				PeerKeys: map[string]ed25519.PublicKey{"a": apub},
+			})
+			b.log = log.WithField("prefix", "P2P-MANAGER")
+
+			payload, err := proto.Marshal(tt.req())
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = b.dhtRequestHandler(nil, payload)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("request accepted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The request is accepted once
+			if _, err := b.dhtRequestHandler(nil, payload); err == nil {
+				t.Fatal("replayed request accepted")
+			}
+		})
+	}
+}
@@ -0,0 +1,362 @@
+package p2pc
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/supergiant-hq/xnet/model"
+	"github.com/supergiant-hq/xnet/p2p"
+
+	"github.com/google/uuid"
+	"github.com/lucas-clemente/quic-go"
+)
+
+const (
+	// Maximum difference between the clocks of peers on direct connections
+	directClockSkew = 30 * time.Second
+
+	directRequest = "request"
+	directAccept  = "accept"
+
+	// Label of the TLS keying material the credentials of direct connections are bound to
+	directBindingLabel = "xnet direct connection"
+)
+
+// Peer connected to directly at known addresses, without the Broker
+type DirectPeer struct {
+	// Client ID of the peer
+	Id string
+	// Addresses of the peer ("host:port"), the port is the listening port of its Client (udpc.Config.ListenAddr)
+	Addrs []string
+	// Public key of the peer
+	// If set, the peer must prove possession of this key, else it is authenticated by
+	// Config.PeerKeys, Config.VerifyPeerKey or Config.PreSharedKey
+	Key ed25519.PublicKey
+	// Application data passed to the ConnectionRequestHandler of the peer
+	Metadata map[string]string
+}
+
+// Client ID used on direct connections
+func (m *Manager) localId() string {
+	if len(m.config.ClientId) > 0 {
+		return m.config.ClientId
+	}
+	return m.client.Id
+}
+
+// If direct connections can be authenticated
+func (m *Manager) directEnabled() bool {
+	return m.config.PrivateKey != nil || len(m.config.PreSharedKey) > 0
+}
+
+// If the key of a peer is trusted
+// A pinned key (non-nil) is the only key trusted for the peer
+func (m *Manager) trustedKey(peerId string, key ed25519.PublicKey, pinned ed25519.PublicKey) bool {
+	if len(key) != ed25519.PublicKeySize {
+		return false
+	}
+	if pinned != nil {
+		return bytes.Equal(pinned, key)
+	}
+	if pkey, ok := m.config.PeerKeys[peerId]; ok {
+		return bytes.Equal(pkey, key)
+	}
+	return m.config.VerifyPeerKey != nil && m.config.VerifyPeerKey(peerId, key)
+}
+
+// Value binding credentials to a TLS session, exported from its keying material
+func sessionBinding(state quic.ConnectionState, label string) (binding string, err error) {
+	ekm, err := state.TLS.ExportKeyingMaterial(label, nil, 32)
+	if err != nil {
+		return
+	}
+	return base64.StdEncoding.EncodeToString(ekm), nil
+}
+
+// Value binding the credentials of the peers to the TLS session of a direct connection
+// The TLS certificates are not verified, a peer in the middle terminating the sessions of both peers cannot relay the credentials
+func directBinding(state quic.ConnectionState) (binding string, err error) {
+	return sessionBinding(state, directBindingLabel)
+}
+
+// Payload signed on direct connections, the fields are separated by zero bytes
+func signedPayload(fields ...string) []byte {
+	return []byte(strings.Join(fields, "\x00"))
+}
+
+func (m *Manager) mac(payload []byte) []byte {
+	h := hmac.New(sha256.New, m.config.PreSharedKey)
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+// Add the credentials of this client over a payload to the Client Data of a direct connection
+// The payload is signed with the private key and authenticated with the pre-shared key, if they are set
+func (m *Manager) authenticate(data map[string]string, fields ...string) map[string]string {
+	payload := signedPayload(fields...)
+
+	data[p2p.KEY_DIRECT_ID] = m.localId()
+	if m.config.PrivateKey != nil {
+		data[p2p.KEY_DIRECT_KEY] = base64.StdEncoding.EncodeToString(m.config.PrivateKey.Public().(ed25519.PublicKey))
+		data[p2p.KEY_DIRECT_SIGNATURE] = base64.StdEncoding.EncodeToString(ed25519.Sign(m.config.PrivateKey, payload))
+	}
+	if len(m.config.PreSharedKey) > 0 {
+		data[p2p.KEY_DIRECT_MAC] = base64.StdEncoding.EncodeToString(m.mac(payload))
+	}
+	return data
+}
+
+// Verify the credentials of a peer over a payload in its Client Data
+// A signature with a trusted key or an authentication code with the pre-shared key is accepted
+func (m *Manager) verifyPeer(peerId string, pinned ed25519.PublicKey, data map[string]string, fields ...string) (err error) {
+	payload := signedPayload(fields...)
+
+	if skey, ok := data[p2p.KEY_DIRECT_KEY]; ok {
+		key, kerr := base64.StdEncoding.DecodeString(skey)
+		signature, serr := base64.StdEncoding.DecodeString(data[p2p.KEY_DIRECT_SIGNATURE])
+		if kerr == nil && serr == nil && m.trustedKey(peerId, key, pinned) && ed25519.Verify(key, payload, signature) {
+			return
+		}
+	}
+
+	// The pre-shared key does not prove the identity of a peer with a pinned key
+	if smac, ok := data[p2p.KEY_DIRECT_MAC]; ok && len(m.config.PreSharedKey) > 0 && pinned == nil {
+		mac, merr := base64.StdEncoding.DecodeString(smac)
+		if merr == nil && hmac.Equal(mac, m.mac(payload)) {
+			return
+		}
+	}
+
+	return fmt.Errorf("peer (%s) is not trusted", peerId)
+}
+
+// Metadata of a connection request in its Client Data
+func requestMetadata(data map[string]string) (metadata map[string]string) {
+	for k, v := range data {
+		if strings.HasPrefix(k, p2p.KEY_DIRECT_METADATA_PREFIX) {
+			if metadata == nil {
+				metadata = map[string]string{}
+			}
+			metadata[strings.TrimPrefix(k, p2p.KEY_DIRECT_METADATA_PREFIX)] = v
+		}
+	}
+	return
+}
+
+// Signed fields of the metadata of a connection request, its keys and values sorted by key
+func metadataFields(metadata map[string]string) (fields []string) {
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		fields = append(fields, k, metadata[k])
+	}
+	return
+}
+
+// Client Data authenticating a direct connection request to a peer
+// The credentials also cover the binding values, if any, and the metadata
+func (m *Manager) directRequestData(peerId string, connId string, metadata map[string]string, binding ...string) (data map[string]string, err error) {
+	if !m.directEnabled() {
+		err = fmt.Errorf("direct connections require a private key or a pre-shared key")
+		return
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	data = map[string]string{
+		p2p.KEY_DIRECT_TARGET: peerId,
+		p2p.KEY_DIRECT_TIME:   timestamp,
+	}
+	for k, v := range metadata {
+		data[p2p.KEY_DIRECT_METADATA_PREFIX+k] = v
+	}
+
+	fields := append([]string{directRequest, m.localId(), peerId, connId, timestamp}, binding...)
+	data = m.authenticate(data, append(fields, metadataFields(metadata)...)...)
+	return
+}
+
+// Verify a direct connection request, returns the ID of the peer and the metadata of the request
+func (m *Manager) verifyDirectRequest(connId string, data map[string]string, binding ...string) (peerId string, metadata map[string]string, err error) {
+	if !m.directEnabled() {
+		err = fmt.Errorf("direct connections are disabled")
+		return
+	}
+
+	peerId = data[p2p.KEY_DIRECT_ID]
+	if data[p2p.KEY_DIRECT_TARGET] != m.localId() {
+		err = fmt.Errorf("direct connection request for another client")
+		return
+	}
+
+	timestamp, err := strconv.ParseInt(data[p2p.KEY_DIRECT_TIME], 10, 64)
+	if err != nil {
+		err = fmt.Errorf("invalid direct connection time")
+		return
+	}
+	if skew := time.Since(time.Unix(timestamp, 0)); skew > directClockSkew || skew < -directClockSkew {
+		err = fmt.Errorf("direct connection request expired")
+		return
+	}
+
+	metadata = requestMetadata(data)
+	fields := append([]string{directRequest, peerId, m.localId(), connId, data[p2p.KEY_DIRECT_TIME]}, binding...)
+	if err = m.verifyPeer(peerId, nil, data, append(fields, metadataFields(metadata)...)...); err != nil {
+		return
+	}
+
+	// The connection ID is the nonce of the request
+	if m.replayed(connId) {
+		err = fmt.Errorf("direct connection request replayed")
+		return
+	}
+	return
+}
+
+// If the nonce of a request was seen before, nonces are kept until their requests expire
+func (m *Manager) replayed(nonce string) bool {
+	m.nonces.Range(func(k, v interface{}) bool {
+		if time.Since(v.(time.Time)) > 2*directClockSkew {
+			m.nonces.Delete(k)
+		}
+		return true
+	})
+	_, loaded := m.nonces.LoadOrStore(nonce, time.Now())
+	return loaded
+}
+
+// Client Data accepting a direct connection request of a peer
+func (m *Manager) directAcceptData(peerId string, connId string, binding ...string) map[string]string {
+	return m.authenticate(map[string]string{}, append([]string{directAccept, m.localId(), peerId, connId}, binding...)...)
+}
+
+// Verify that the peer accepted a direct connection
+func (m *Manager) verifyDirectAccept(p *peer, connId string, data map[string]string, binding ...string) (err error) {
+	if data[p2p.KEY_DIRECT_ID] != p.id {
+		return fmt.Errorf("direct connection accepted by another client")
+	}
+	return m.verifyPeer(p.id, p.key, data, append([]string{directAccept, p.id, m.localId(), connId}, binding...)...)
+}
+
+// Accept a direct connection request of a peer validated by the peer server
+// The credentials of the peer have to be bound to the QUIC session it was received on
+func (m *Manager) acceptDirect(addr *net.UDPAddr, data *model.ClientValidateData, binding string) (cdata *model.ClientData, err error) {
+	peerId, metadata, err := m.verifyDirectRequest(data.Token, data.Data, binding)
+	if err != nil {
+		return
+	}
+	if cdata, err = m.checkDirectRequest(&ConnectionRequest{
+		Id:       data.Token,
+		Mode:     p2p.ConnectionModeP2P,
+		PeerId:   peerId,
+		Metadata: metadata,
+	}); err != nil {
+		return
+	}
+
+	conn := newDirectConnection(m, data.Token, false, &peer{
+		id:    peerId,
+		addr:  addr,
+		addrs: []*net.UDPAddr{addr},
+	}, metadata)
+	// The peer checks in right after validation, the P2P connection has to be ready for it
+	conn.p2pConn = conn.newP2PConn()
+	if _, loaded := m.conns.LoadOrStore(conn.id, conn); loaded {
+		err = fmt.Errorf("connection with id (%s) exists", conn.id)
+		return
+	}
+	m.log.Infof("Accepted direct connection request: %s", conn.String())
+
+	go func() {
+		if err := conn.connect(); err != nil {
+			m.log.Errorf("Error waiting for peer connection: %s", err.Error())
+			m.CloseConnection(conn.id, err.Error())
+		}
+	}()
+
+	cdata = &model.ClientData{
+		Id:      fmt.Sprintf("%s:%s", conn.id, addr.String()),
+		Address: addr.String(),
+		Data:    m.directAcceptData(peerId, conn.id, binding),
+		Ctx: &model.ClientData_P2PCtx{
+			P2PCtx: &model.P2PClientContext{
+				ConnId: conn.id,
+				PeerId: peerId,
+				Active: false,
+			},
+		},
+	}
+	return
+}
+
+// Connect to a peer directly at known addresses, without the Broker
+// Both peers authenticate each other with their keys (Config.PrivateKey) or the pre-shared key (Config.PreSharedKey)
+func (m *Manager) ConnectDirect(dp DirectPeer) (conn *Connection, err error) {
+	addrs := []*net.UDPAddr{}
+	for _, raddr := range dp.Addrs {
+		addr, err := net.ResolveUDPAddr("udp", raddr)
+		if err != nil {
+			return nil, err
+		}
+		if addr.IP.IsLinkLocalUnicast() && addr.IP.To4() == nil && len(addr.Zone) == 0 {
+			addrs = append(addrs, m.scopeCandidate(addr)...)
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+
+	m.log.Infof("Connecting directly to peer id(%s) at (%v)...", dp.Id, addrs)
+	return m.connectDirect(dp.Id, dp.Key, addrs, dp.Metadata)
+}
+
+func (m *Manager) connectDirect(peerId string, key ed25519.PublicKey, addrs []*net.UDPAddr, metadata map[string]string) (conn *Connection, err error) {
+	if len(addrs) == 0 {
+		err = fmt.Errorf("no addresses of peer (%s)", peerId)
+		return
+	}
+
+	conn = newDirectConnection(m, uuid.New().String(), true, &peer{
+		id:    peerId,
+		key:   key,
+		addr:  addrs[0],
+		addrs: addrs,
+	}, metadata)
+
+	if err = conn.connect(); err != nil {
+		conn.Close(err.Error())
+		return
+	}
+	m.conns.Store(conn.id, conn)
+
+	m.log.Infof("Created connection: %s", conn.String())
+	return
+}
+
+func newDirectConnection(m *Manager, id string, initiator bool, p *peer, metadata map[string]string) *Connection {
+	return &Connection{
+		mgr:      m,
+		ClientId: m.localId(),
+
+		initiator: initiator,
+		direct:    true,
+		id:        id,
+		mode:      p2p.ConnectionModeP2P,
+		peer:      p,
+		metadata:  metadata,
+
+		Exit: make(chan bool, 1),
+		log:  m.log.Logger.WithField("prefix", fmt.Sprintf("P2P-CONN-%s", id)),
+	}
+}
@@ -0,0 +1,354 @@
+package p2pc
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/supergiant-hq/xnet/model"
+	"github.com/supergiant-hq/xnet/p2p"
+	udpc "github.com/supergiant-hq/xnet/udp/client"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newTestManager(config Config) *Manager {
+	return &Manager{
+		config: config,
+		conns:  new(sync.Map),
+		rnd:    rand.New(rand.NewSource(1)),
+	}
+}
+
+// Client Data of a direct connection request made at a time
+func testRequestData(m *Manager, peerId, connId string, at time.Time, binding string) map[string]string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	return m.authenticate(map[string]string{
+		p2p.KEY_DIRECT_TARGET: peerId,
+		p2p.KEY_DIRECT_TIME:   timestamp,
+	}, directRequest, m.localId(), peerId, connId, timestamp, binding)
+}
+
+func TestVerifyDirectRequest(t *testing.T) {
+	apub, akey, _ := ed25519.GenerateKey(nil)
+	_, xkey, _ := ed25519.GenerateKey(nil)
+	psk := []byte("secret")
+
+	a := newTestManager(Config{ClientId: "a", PrivateKey: akey})
+	apsk := newTestManager(Config{ClientId: "a", PreSharedKey: psk})
+	x := newTestManager(Config{ClientId: "a", PrivateKey: xkey})
+	xpsk := newTestManager(Config{ClientId: "a", PreSharedKey: []byte("other")})
+
+	tests := []struct {
+		name    string
+		data    func(connId string) map[string]string
+		binding string
+		wantErr bool
+	}{
+		{
+			name: "signed",
+			data: func(connId string) map[string]string {
+				return testRequestData(a, "b", connId, time.Now(), "session")
+			},
+			binding: "session",
+		},
+		{
+			name: "pre-shared key",
+			data: func(connId string) map[string]string {
+				return testRequestData(apsk, "b", connId, time.Now(), "session")
+			},
+			binding: "session",
+		},
+		{
+			name: "expired",
+			data: func(connId string) map[string]string {
+				return testRequestData(a, "b", connId, time.Now().Add(-2*directClockSkew), "session")
+			},
+			binding: "session",
+			wantErr: true,
+		},
+		{
+			name: "from the future",
+			data: func(connId string) map[string]string {
+				return testRequestData(a, "b", connId, time.Now().Add(2*directClockSkew), "session")
+			},
+			binding: "session",
+			wantErr: true,
+		},
+		{
+			name: "time changed",
+			data: func(connId string) map[string]string {
+				data := testRequestData(a, "b", connId, time.Now(), "session")
+				data[p2p.KEY_DIRECT_TIME] = strconv.FormatInt(time.Now().Unix()+1, 10)
+				return data
+			},
+			binding: "session",
+			wantErr: true,
+		},
+		{
+			name: "other target",
+			data: func(connId string) map[string]string {
+				return testRequestData(a, "c", connId, time.Now(), "session")
+			},
+			binding: "session",
+			wantErr: true,
+		},
+		{
+			name: "relayed to another session",
+			data: func(connId string) map[string]string {
+				return testRequestData(a, "b", connId, time.Now(), "session")
+			},
+			binding: "other session",
+			wantErr: true,
+		},
+		{
+			name: "untrusted key",
+			data: func(connId string) map[string]string {
+				return testRequestData(x, "b", connId, time.Now(), "session")
+			},
+			binding: "session",
+			wantErr: true,
+		},
+		{
+			name: "wrong pre-shared key",
+			data: func(connId string) map[string]string {
+				return testRequestData(xpsk, "b", connId, time.Now(), "session")
+			},
+			binding: "session",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestManager(Config{
+				ClientId:     "b",
+				PeerKeys:     map[string]ed25519.PublicKey{"a": apub},
+				PreSharedKey: psk,
+			})
+
+			connId := tt.name
+			peerId, _, err := b.verifyDirectRequest(connId, tt.data(connId), tt.binding)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("request accepted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if peerId != "a" {
+				t.Fatalf("peer = %s, want a", peerId)
+			}
+
+			// The connection ID is a nonce
+			if _, _, err := b.verifyDirectRequest(connId, tt.data(connId), tt.binding); err == nil {
+				t.Fatal("replayed request accepted")
+			}
+		})
+	}
+}
+
+func TestVerifyDirectAccept(t *testing.T) {
+	bpub, bkey, _ := ed25519.GenerateKey(nil)
+	xpub, _, _ := ed25519.GenerateKey(nil)
+	psk := []byte("secret")
+
+	tests := []struct {
+		name    string
+		peer    *peer
+		config  Config
+		binding string
+		wantErr bool
+	}{
+		{
+			name:    "pinned key",
+			peer:    &peer{id: "b", key: bpub},
+			config:  Config{ClientId: "b", PrivateKey: bkey},
+			binding: "session",
+		},
+		{
+			name:    "pre-shared key",
+			peer:    &peer{id: "b"},
+			config:  Config{ClientId: "b", PreSharedKey: psk},
+			binding: "session",
+		},
+		{
+			name:    "other pinned key",
+			peer:    &peer{id: "b", key: xpub},
+			config:  Config{ClientId: "b", PrivateKey: bkey},
+			binding: "session",
+			wantErr: true,
+		},
+		{
+			name:    "pre-shared key for pinned key",
+			peer:    &peer{id: "b", key: bpub},
+			config:  Config{ClientId: "b", PreSharedKey: psk},
+			binding: "session",
+			wantErr: true,
+		},
+		{
+			name:    "other client",
+			peer:    &peer{id: "c"},
+			config:  Config{ClientId: "b", PreSharedKey: psk},
+			binding: "session",
+			wantErr: true,
+		},
+		{
+			name:    "relayed to another session",
+			peer:    &peer{id: "b", key: bpub},
+			config:  Config{ClientId: "b", PrivateKey: bkey},
+			binding: "other session",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestManager(Config{ClientId: "a", PreSharedKey: psk})
+			b := newTestManager(tt.config)
+
+			data := b.directAcceptData("a", "conn", "session")
+			err := a.verifyDirectAccept(tt.peer, "conn", data, tt.binding)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDirectRequestMetadata(t *testing.T) {
+	psk := []byte("secret")
+	a := newTestManager(Config{ClientId: "a", PreSharedKey: psk})
+
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		change   func(data map[string]string)
+		wantErr  bool
+	}{
+		{name: "none"},
+		{name: "metadata", metadata: map[string]string{"app": "1", "user": "x"}},
+		{
+			name:     "value changed",
+			metadata: map[string]string{"app": "1"},
+			change: func(data map[string]string) {
+				data[p2p.KEY_DIRECT_METADATA_PREFIX+"app"] = "2"
+			},
+			wantErr: true,
+		},
+		{
+			name:     "key added",
+			metadata: map[string]string{"app": "1"},
+			change: func(data map[string]string) {
+				data[p2p.KEY_DIRECT_METADATA_PREFIX+"user"] = "x"
+			},
+			wantErr: true,
+		},
+		{
+			name:     "key removed",
+			metadata: map[string]string{"app": "1"},
+			change: func(data map[string]string) {
+				delete(data, p2p.KEY_DIRECT_METADATA_PREFIX+"app")
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestManager(Config{ClientId: "b", PreSharedKey: psk})
+
+			data, err := a.directRequestData("b", tt.name, tt.metadata, "session")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.change != nil {
+				tt.change(data)
+			}
+
+			_, metadata, err := b.verifyDirectRequest(tt.name, data, "session")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("request accepted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(metadata, tt.metadata) {
+				t.Fatalf("metadata = %v, want %v", metadata, tt.metadata)
+			}
+		})
+	}
+}
+
+// A rejected direct request is returned to the peer as a *ConnectionRejectedError
+func TestDirectRejection(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	tests := []struct {
+		name    string
+		handler ConnectionRequestHandler
+		reason  string
+	}{
+		{name: "no handler"},
+		{
+			name:    "accepted",
+			handler: func(req *ConnectionRequest) error { return nil },
+		},
+		{
+			name: "rejected",
+			handler: func(req *ConnectionRequest) error {
+				return fmt.Errorf("%s not allowed", req.Metadata["app"])
+			},
+			reason: "x not allowed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(Config{ClientId: "b"})
+			m.log = log.WithField("prefix", "P2P-MANAGER")
+			m.SetConnectionRequestHandler(tt.handler)
+
+			cdata, err := m.checkDirectRequest(&ConnectionRequest{
+				Id:       "conn",
+				Mode:     p2p.ConnectionModeP2P,
+				PeerId:   "a",
+				Metadata: map[string]string{"app": "x"},
+			})
+			if (err != nil) != (len(tt.reason) > 0) {
+				t.Fatalf("err = %v, want rejection %q", err, tt.reason)
+			}
+
+			// The peer server replies with the Client Data and the error
+			client := &udpc.Client{Data: &model.ClientData{Status: err == nil}}
+			if cdata != nil {
+				client.Data.Data = cdata.Data
+			}
+
+			rerr := directRejection("b", client)
+			if len(tt.reason) == 0 {
+				if rerr != nil {
+					t.Fatalf("rejected: %v", rerr)
+				}
+				return
+			}
+			var rejected *ConnectionRejectedError
+			if !errors.As(rerr, &rejected) || rejected.PeerId != "b" || rejected.Reason != tt.reason {
+				t.Fatalf("rejection = %v, want reason %q", rerr, tt.reason)
+			}
+		})
+	}
+}
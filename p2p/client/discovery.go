@@ -0,0 +1,484 @@
+package p2pc
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/supergiant-hq/xnet/model"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// Default UDP port of LAN discovery
+	DiscoveryPort = 47077
+	// Default interval between announcements
+	DiscoveryInterval = 5 * time.Second
+
+	discoveryAnnounce = "announce"
+	// Keys recorded per Client ID, announcements of further keys are ignored
+	discoveryMaxKeys = 4
+)
+
+var (
+	// Multicast groups of LAN discovery
+	DiscoveryGroupIPv4 = net.IPv4(239, 255, 70, 77)
+	DiscoveryGroupIPv6 = net.ParseIP("ff02::4677")
+)
+
+// LAN Discovery Config
+type DiscoveryConfig struct {
+	// UDP port of the multicast groups, DiscoveryPort if 0
+	Port int
+	// Interval between announcements, DiscoveryInterval if 0
+	Interval time.Duration
+	// Peers which stop announcing are forgotten after this duration, 3 intervals if 0
+	Expiry time.Duration
+}
+
+// Peer found by LAN discovery
+type DiscoveredPeer struct {
+	// Client ID
+	Id string
+	// Public key of the peer, it proved possession of the key by signing its announcements
+	Key ed25519.PublicKey
+	// If the key is trusted (Config.PeerKeys or Config.VerifyPeerKey)
+	// Connections to untrusted peers are refused
+	Trusted bool
+	// Addresses of the peer
+	Addrs []*net.UDPAddr
+	// Time of the last announcement
+	LastSeen time.Time
+}
+
+// LAN Discovery
+// Announces the Client ID, listening port and public key of the Manager to the multicast groups
+// on the interfaces allowed by the candidate policy and collects the announcements of peers
+// Announcements only locate peers, connections to them are authenticated by their keys
+type Discovery struct {
+	mgr    *Manager
+	config DiscoveryConfig
+
+	conn4   *ipv4.PacketConn
+	conn6   *ipv6.PacketConn
+	joined4 map[int]*net.Interface
+	joined6 map[int]*net.Interface
+	// Client ID and key -> *DiscoveredPeer
+	peers sync.Map
+
+	// Exit Channel
+	Exit chan bool
+	// Closed Status
+	Closed bool
+	mutex  sync.Mutex
+	log    *logrus.Entry
+}
+
+// Start LAN discovery
+// Requires Config.PrivateKey to sign the announcements
+func (m *Manager) StartDiscovery(config DiscoveryConfig) (d *Discovery, err error) {
+	if m.config.PrivateKey == nil {
+		err = fmt.Errorf("discovery requires a private key")
+		return
+	}
+	if config.Port == 0 {
+		config.Port = DiscoveryPort
+	}
+	if config.Interval == 0 {
+		config.Interval = DiscoveryInterval
+	}
+	if config.Expiry == 0 {
+		config.Expiry = 3 * config.Interval
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.discovery != nil {
+		err = fmt.Errorf("discovery already running")
+		return
+	}
+
+	d = &Discovery{
+		mgr:     m,
+		config:  config,
+		joined4: map[int]*net.Interface{},
+		joined6: map[int]*net.Interface{},
+		Exit:    make(chan bool, 1),
+		log:     m.log.Logger.WithField("prefix", "P2P-DISCOVERY"),
+	}
+
+	lc := net.ListenConfig{Control: reusePort}
+	port := strconv.Itoa(config.Port)
+	pc4, err4 := lc.ListenPacket(context.Background(), "udp4", net.JoinHostPort("0.0.0.0", port))
+	if err4 == nil {
+		d.conn4 = ipv4.NewPacketConn(pc4)
+		d.conn4.SetMulticastLoopback(true)
+	} else {
+		d.log.Warnln("IPv4 discovery disabled:", err4.Error())
+	}
+	pc6, err6 := lc.ListenPacket(context.Background(), "udp6", net.JoinHostPort("::", port))
+	if err6 == nil {
+		d.conn6 = ipv6.NewPacketConn(pc6)
+		d.conn6.SetMulticastLoopback(true)
+	} else {
+		d.log.Warnln("IPv6 discovery disabled:", err6.Error())
+	}
+	if err4 != nil && err6 != nil {
+		err = err4
+		return
+	}
+
+	if d.conn4 != nil {
+		go d.receive(func(b []byte) (int, net.Addr, error) {
+			n, _, src, err := d.conn4.ReadFrom(b)
+			return n, src, err
+		})
+	}
+	if d.conn6 != nil {
+		go d.receive(func(b []byte) (int, net.Addr, error) {
+			n, _, src, err := d.conn6.ReadFrom(b)
+			return n, src, err
+		})
+	}
+	go d.announceLoop()
+
+	m.discovery = d
+	d.log.Infof("Discovery started on port (%d)", config.Port)
+	return
+}
+
+// Join the multicast groups on the interfaces allowed by the candidate policy
+// Interfaces which appeared since the last call are joined
+func (d *Discovery) join() {
+	interfaces, err := d.mgr.candidateInterfaces()
+	if err != nil {
+		d.log.Errorln("Error listing interfaces:", err.Error())
+		return
+	}
+
+	for _, i := range interfaces {
+		ifi, err := net.InterfaceByIndex(i.Index)
+		if err != nil || ifi.Flags&net.FlagMulticast == 0 || ifi.Flags&net.FlagUp == 0 {
+			continue
+		}
+
+		has4, has6 := false, false
+		for _, addr := range i.Addrs {
+			if addr.IP.To4() != nil {
+				has4 = true
+			} else {
+				has6 = true
+			}
+		}
+
+		if _, ok := d.joined4[ifi.Index]; !ok && has4 && d.conn4 != nil {
+			if err := d.conn4.JoinGroup(ifi, &net.UDPAddr{IP: DiscoveryGroupIPv4}); err == nil {
+				d.joined4[ifi.Index] = ifi
+			} else {
+				d.log.Debugf("Error joining group on (%s): %s", ifi.Name, err.Error())
+			}
+		}
+		if _, ok := d.joined6[ifi.Index]; !ok && has6 && d.conn6 != nil {
+			if err := d.conn6.JoinGroup(ifi, &net.UDPAddr{IP: DiscoveryGroupIPv6}); err == nil {
+				d.joined6[ifi.Index] = ifi
+			} else {
+				d.log.Debugf("Error joining group on (%s): %s", ifi.Name, err.Error())
+			}
+		}
+	}
+}
+
+func (d *Discovery) announceLoop() {
+	for {
+		d.mutex.Lock()
+		if d.Closed {
+			d.mutex.Unlock()
+			return
+		}
+		d.join()
+		d.announce()
+		d.mutex.Unlock()
+		d.expire()
+
+		select {
+		case <-time.After(d.config.Interval):
+		case <-d.Exit:
+			return
+		}
+	}
+}
+
+// Send an announcement on every joined interface
+func (d *Discovery) announce() {
+	m := d.mgr
+	ann := &model.P2PAnnouncement{
+		Id:        m.localId(),
+		Port:      int32(m.localPort()),
+		Key:       m.config.PrivateKey.Public().(ed25519.PublicKey),
+		Timestamp: time.Now().Unix(),
+	}
+	ann.Signature = ed25519.Sign(m.config.PrivateKey, announcementPayload(ann))
+
+	b, err := proto.Marshal(ann)
+	if err != nil {
+		d.log.Errorln("Error encoding announcement:", err.Error())
+		return
+	}
+
+	group4 := &net.UDPAddr{IP: DiscoveryGroupIPv4, Port: d.config.Port}
+	for _, ifi := range d.joined4 {
+		if err := d.conn4.SetMulticastInterface(ifi); err == nil {
+			d.conn4.WriteTo(b, nil, group4)
+		}
+	}
+	group6 := &net.UDPAddr{IP: DiscoveryGroupIPv6, Port: d.config.Port}
+	for _, ifi := range d.joined6 {
+		if err := d.conn6.SetMulticastInterface(ifi); err == nil {
+			d.conn6.WriteTo(b, nil, group6)
+		}
+	}
+}
+
+func announcementPayload(ann *model.P2PAnnouncement) []byte {
+	return signedPayload(
+		discoveryAnnounce,
+		ann.Id,
+		strconv.Itoa(int(ann.Port)),
+		string(ann.Key),
+		strconv.FormatInt(ann.Timestamp, 10),
+	)
+}
+
+func (d *Discovery) receive(read func(b []byte) (int, net.Addr, error)) {
+	b := make([]byte, 1500)
+	for {
+		n, src, err := read(b)
+		if err != nil {
+			return
+		}
+
+		ann := &model.P2PAnnouncement{}
+		if err := proto.Unmarshal(b[:n], ann); err != nil {
+			continue
+		}
+		if addr, ok := src.(*net.UDPAddr); ok {
+			d.handleAnnouncement(ann, addr)
+		}
+	}
+}
+
+// Record the peer of a valid announcement
+func (d *Discovery) handleAnnouncement(ann *model.P2PAnnouncement, src *net.UDPAddr) {
+	m := d.mgr
+	if len(ann.Id) == 0 || ann.Id == m.localId() || ann.Port <= 0 || ann.Port > 65535 {
+		return
+	}
+	if len(ann.Key) != ed25519.PublicKeySize || !ed25519.Verify(ann.Key, announcementPayload(ann), ann.Signature) {
+		d.log.Debugf("Invalid announcement of (%s) from (%s)", ann.Id, src.String())
+		return
+	}
+	if skew := time.Since(time.Unix(ann.Timestamp, 0)); skew > directClockSkew || skew < -directClockSkew {
+		return
+	}
+	if !m.config.Candidates.allowIP(src.IP) {
+		return
+	}
+
+	addr := &net.UDPAddr{IP: src.IP, Port: int(ann.Port), Zone: src.Zone}
+	key := ed25519.PublicKey(ann.Key)
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.Closed {
+		return
+	}
+
+	// Peers are recorded per key, an announcement with another key does not replace a trusted peer
+	rp, ok := d.peers.Load(discoveredKey(ann.Id, key))
+	if !ok {
+		p := &DiscoveredPeer{
+			Id:      ann.Id,
+			Key:     key,
+			Trusted: m.trustedKey(ann.Id, key, nil),
+		}
+		if !p.Trusted && len(d.entries(ann.Id)) >= discoveryMaxKeys {
+			return
+		}
+		d.peers.Store(discoveredKey(ann.Id, key), p)
+		rp = p
+		d.log.Infof("Discovered peer (%s) at (%s) trusted(%v)", p.Id, addr.String(), p.Trusted)
+	}
+
+	p := rp.(*DiscoveredPeer)
+	p.LastSeen = time.Now()
+	for _, paddr := range p.Addrs {
+		if paddr.String() == addr.String() {
+			return
+		}
+	}
+	p.Addrs = append(p.Addrs, addr)
+}
+
+func discoveredKey(id string, key ed25519.PublicKey) string {
+	return id + "\x00" + string(key)
+}
+
+// Peers of a Client ID which have not expired, a trusted peer first, then the most recently seen
+// Must be called with the mutex held
+func (d *Discovery) entries(peerId string) (peers []*DiscoveredPeer) {
+	d.peers.Range(func(k, v interface{}) bool {
+		if p := v.(*DiscoveredPeer); p.Id == peerId && time.Since(p.LastSeen) <= d.config.Expiry {
+			peers = append(peers, p)
+		}
+		return true
+	})
+	sort.Slice(peers, func(i, j int) bool {
+		if peers[i].Trusted != peers[j].Trusted {
+			return peers[i].Trusted
+		}
+		return peers[i].LastSeen.After(peers[j].LastSeen)
+	})
+	return
+}
+
+// Forget the peers which stopped announcing
+func (d *Discovery) expire() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.peers.Range(func(k, v interface{}) bool {
+		if p := v.(*DiscoveredPeer); time.Since(p.LastSeen) > d.config.Expiry {
+			d.peers.Delete(k)
+			d.log.Infof("Peer (%s) expired trusted(%v)", p.Id, p.Trusted)
+		}
+		return true
+	})
+}
+
+// Peer by Client ID
+// A peer with a trusted key is preferred over peers announcing the ID with other keys
+func (d *Discovery) Peer(peerId string) (p DiscoveredPeer, ok bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	peers := d.entries(peerId)
+	if len(peers) == 0 {
+		return p, false
+	}
+	p = *peers[0]
+	p.Addrs = append([]*net.UDPAddr{}, p.Addrs...)
+	return p, true
+}
+
+// Peers discovered on the LAN
+// A Client ID announced with multiple keys is listed once per key
+func (d *Discovery) Peers() (peers []DiscoveredPeer) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.peers.Range(func(k, v interface{}) bool {
+		p := *v.(*DiscoveredPeer)
+		if time.Since(p.LastSeen) <= d.config.Expiry {
+			p.Addrs = append([]*net.UDPAddr{}, p.Addrs...)
+			peers = append(peers, p)
+		}
+		return true
+	})
+	return
+}
+
+// Stop LAN discovery
+func (d *Discovery) Close() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.Closed {
+		return
+	}
+
+	if d.conn4 != nil {
+		d.conn4.Close()
+	}
+	if d.conn6 != nil {
+		d.conn6.Close()
+	}
+
+	select {
+	case d.Exit <- true:
+	default:
+	}
+	d.Closed = true
+
+	d.mgr.mutex.Lock()
+	if d.mgr.discovery == d {
+		d.mgr.discovery = nil
+	}
+	d.mgr.mutex.Unlock()
+
+	d.log.Warnln("Discovery stopped")
+}
+
+// Connect to a peer found by LAN discovery, without the Broker
+// The peer must have a trusted key and proves it during the connection handshake
+func (m *Manager) ConnectLAN(peerId string) (conn *Connection, err error) {
+	return m.connectLAN(peerId, nil)
+}
+
+func (m *Manager) connectLAN(peerId string, metadata map[string]string) (conn *Connection, err error) {
+	m.mutex.Lock()
+	d := m.discovery
+	m.mutex.Unlock()
+
+	if d == nil {
+		err = fmt.Errorf("discovery not running")
+		return
+	}
+
+	p, ok := d.Peer(peerId)
+	if !ok {
+		err = fmt.Errorf("peer (%s) not discovered", peerId)
+		return
+	} else if !p.Trusted {
+		err = fmt.Errorf("key of peer (%s) is not trusted", peerId)
+		return
+	}
+
+	m.log.Infof("Connecting to LAN peer id(%s) at (%v)...", peerId, p.Addrs)
+	return m.connectDirect(p.Id, p.Key, p.Addrs, metadata)
+}
+
+// Peers found by LAN discovery
+func (m *Manager) DiscoveredPeers() []DiscoveredPeer {
+	m.mutex.Lock()
+	d := m.discovery
+	m.mutex.Unlock()
+
+	if d == nil {
+		return nil
+	}
+	return d.Peers()
+}
+
+// If a peer with a trusted key was found by LAN discovery
+func (m *Manager) discovered(peerId string) bool {
+	m.mutex.Lock()
+	d := m.discovery
+	m.mutex.Unlock()
+
+	if d == nil {
+		return false
+	}
+	p, ok := d.Peer(peerId)
+	return ok && p.Trusted
+}
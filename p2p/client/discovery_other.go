@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd
+
+package p2pc
+
+import (
+	"syscall"
+)
+
+// The discovery port is not shared, only one client per host can run discovery
+func reusePort(network, address string, c syscall.RawConn) error {
+	return nil
+}
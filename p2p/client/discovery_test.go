@@ -0,0 +1,155 @@
+package p2pc
+
+import (
+	"crypto/ed25519"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/supergiant-hq/xnet/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newTestDiscovery(m *Manager) *Discovery {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	return &Discovery{
+		mgr:    m,
+		config: DiscoveryConfig{Interval: time.Second, Expiry: time.Minute},
+		log:    log.WithField("prefix", "P2P-DISCOVERY"),
+	}
+}
+
+func testAnnouncement(key ed25519.PrivateKey, id string, port int32) *model.P2PAnnouncement {
+	ann := &model.P2PAnnouncement{
+		Id:        id,
+		Port:      port,
+		Key:       key.Public().(ed25519.PublicKey),
+		Timestamp: time.Now().Unix(),
+	}
+	ann.Signature = ed25519.Sign(key, announcementPayload(ann))
+	return ann
+}
+
+func TestHandleAnnouncement(t *testing.T) {
+	bpub, bkey, _ := ed25519.GenerateKey(nil)
+	_, xkey, _ := ed25519.GenerateKey(nil)
+	src := &net.UDPAddr{IP: net.ParseIP("192.168.1.2"), Port: DiscoveryPort}
+	other := &net.UDPAddr{IP: net.ParseIP("192.168.1.9"), Port: DiscoveryPort}
+
+	tests := []struct {
+		name        string
+		announce    func(d *Discovery)
+		wantFound   bool
+		wantTrusted bool
+		wantPort    int
+	}{
+		{
+			name: "trusted",
+			announce: func(d *Discovery) {
+				d.handleAnnouncement(testAnnouncement(bkey, "b", 4000), src)
+			},
+			wantFound:   true,
+			wantTrusted: true,
+			wantPort:    4000,
+		},
+		{
+			name: "other key after trusted",
+			announce: func(d *Discovery) {
+				d.handleAnnouncement(testAnnouncement(bkey, "b", 4000), src)
+				d.handleAnnouncement(testAnnouncement(xkey, "b", 5000), other)
+			},
+			wantFound:   true,
+			wantTrusted: true,
+			wantPort:    4000,
+		},
+		{
+			name: "trusted after other key",
+			announce: func(d *Discovery) {
+				d.handleAnnouncement(testAnnouncement(xkey, "b", 5000), other)
+				d.handleAnnouncement(testAnnouncement(bkey, "b", 4000), src)
+			},
+			wantFound:   true,
+			wantTrusted: true,
+			wantPort:    4000,
+		},
+		{
+			name: "untrusted only",
+			announce: func(d *Discovery) {
+				d.handleAnnouncement(testAnnouncement(xkey, "b", 5000), other)
+			},
+			wantFound: true,
+			wantPort:  5000,
+		},
+		{
+			name: "invalid signature",
+			announce: func(d *Discovery) {
+				ann := testAnnouncement(bkey, "b", 4000)
+				ann.Port = 5000
+				d.handleAnnouncement(ann, other)
+			},
+		},
+		{
+			name: "expired",
+			announce: func(d *Discovery) {
+				ann := testAnnouncement(bkey, "b", 4000)
+				ann.Timestamp -= int64(2 * directClockSkew / time.Second)
+				ann.Signature = ed25519.Sign(bkey, announcementPayload(ann))
+				d.handleAnnouncement(ann, src)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(Config{
+				ClientId: "a",
+				PeerKeys: map[string]ed25519.PublicKey{"b": bpub},
+			})
+			d := newTestDiscovery(m)
+			m.discovery = d
+
+			tt.announce(d)
+			p, ok := d.Peer("b")
+			if ok != tt.wantFound {
+				t.Fatalf("found = %v, want %v", ok, tt.wantFound)
+			}
+			if !ok {
+				return
+			}
+			if p.Trusted != tt.wantTrusted || len(p.Addrs) != 1 || p.Addrs[0].Port != tt.wantPort {
+				t.Fatalf("peer trusted(%v) addrs(%v), want trusted(%v) port(%d)", p.Trusted, p.Addrs, tt.wantTrusted, tt.wantPort)
+			}
+			if m.discovered("b") != tt.wantTrusted {
+				t.Fatalf("discovered = %v, want %v", m.discovered("b"), tt.wantTrusted)
+			}
+		})
+	}
+}
+
+func TestHandleAnnouncementKeys(t *testing.T) {
+	bpub, bkey, _ := ed25519.GenerateKey(nil)
+	m := newTestManager(Config{
+		ClientId: "a",
+		PeerKeys: map[string]ed25519.PublicKey{"b": bpub},
+	})
+	d := newTestDiscovery(m)
+	src := &net.UDPAddr{IP: net.ParseIP("192.168.1.9"), Port: DiscoveryPort}
+
+	// Keys announced for an ID are limited, a trusted key is still recorded
+	for i := 0; i < 2*discoveryMaxKeys; i++ {
+		_, key, _ := ed25519.GenerateKey(nil)
+		d.handleAnnouncement(testAnnouncement(key, "b", 5000), src)
+	}
+	if n := len(d.Peers()); n != discoveryMaxKeys {
+		t.Fatalf("%d peers recorded, want %d", n, discoveryMaxKeys)
+	}
+
+	d.handleAnnouncement(testAnnouncement(bkey, "b", 4000), src)
+	if p, ok := d.Peer("b"); !ok || !p.Trusted {
+		t.Fatalf("trusted peer not preferred: %v %v", p, ok)
+	}
+}
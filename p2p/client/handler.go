@@ -2,12 +2,10 @@ package p2pc
 
 import (
 	"fmt"
-	"net"
 
 	"github.com/supergiant-hq/xnet/model"
 	"github.com/supergiant-hq/xnet/network"
 	"github.com/supergiant-hq/xnet/p2p"
-	"github.com/supergiant-hq/xnet/tun"
 	"github.com/supergiant-hq/xnet/udp"
 	udpc "github.com/supergiant-hq/xnet/udp/client"
 	udps "github.com/supergiant-hq/xnet/udp/server"
@@ -21,6 +19,12 @@ func (m *Manager) clientDisconnectedHandler(c *udps.Client) {
 	}
 
 	if ctx.Active {
+		// The Connection continues over a bonded relayed path
+		if conn, err := m.Connection(ctx.ConnId); err == nil && conn.adoptRelayPath(func(conn *p2pConn) bool {
+			return conn.remoteClient == c
+		}) {
+			return
+		}
 		m.CloseConnection(ctx.ConnId, "Exited")
 	}
 }
@@ -30,6 +34,7 @@ func (m *Manager) connectionRequestHandler(c *udpc.Client, msg *network.Message)
 	var err error
 	var conn *Connection
 	var interfaces []string
+	rejected := false
 
 	defer func() {
 		var resData model.P2PConnectionData
@@ -38,8 +43,9 @@ func (m *Manager) connectionRequestHandler(c *udpc.Client, msg *network.Message)
 			m.log.Errorln("Error accepting connection:", err.Error())
 
 			resData = model.P2PConnectionData{
-				Status:  false,
-				Message: err.Error(),
+				Status:   false,
+				Message:  err.Error(),
+				Rejected: rejected,
 			}
 		} else {
 			resData = model.P2PConnectionData{
@@ -69,12 +75,26 @@ func (m *Manager) connectionRequestHandler(c *udpc.Client, msg *network.Message)
 
 	m.log.Infof("Connection request from peer id(%s) ip(%s)", creq.Peer.Id, creq.Peer.Address)
 
-	interfaceIPs, err := tun.GetInterfaceAddresses()
-	if err != nil {
+	if interfaces, err = m.candidates(m.localPort()); err != nil {
 		return
 	}
-	for _, ip := range interfaceIPs {
-		interfaces = append(interfaces, fmt.Sprintf("%s:%d", ip.String(), c.Addr.Port))
+
+	if creq.Bond != nil {
+		err = m.acceptBond(creq)
+		return
+	}
+
+	if m.requestHandler != nil {
+		if err = m.requestHandler(&ConnectionRequest{
+			Id:       creq.Id,
+			Mode:     p2p.ConnectionMode(creq.Mode),
+			PeerId:   creq.Peer.Id,
+			PeerTags: creq.Peer.Tags,
+			Metadata: creq.Metadata,
+		}); err != nil {
+			rejected = true
+			return
+		}
 	}
 
 	if conn, err = acceptConnection(m.log.Logger, m, creq); err != nil {
@@ -82,7 +102,7 @@ func (m *Manager) connectionRequestHandler(c *udpc.Client, msg *network.Message)
 	}
 	m.conns.Store(conn.id, conn)
 
-	conn.log.Infof("Created connection: %s", conn.String())
+	conn.logger().Infof("Created connection: %s", conn.String())
 }
 
 func (m *Manager) connectionStatusHandler(c *udpc.Client, msg *network.Message) {
@@ -117,24 +137,35 @@ func (m *Manager) connectionStatusHandler(c *udpc.Client, msg *network.Message)
 	}
 }
 
-func (m *Manager) clientValidateHandler(addr *net.UDPAddr, data *model.ClientValidateData) (cdata *model.ClientData, err error) {
+func (m *Manager) clientValidateHandler(c *udps.Client, data *model.ClientValidateData) (cdata *model.ClientData, err error) {
+	addr := c.Addr
+
+	// Direct connections are authenticated by the keys of the peers instead of the Broker
+	if _, ok := data.Data[p2p.KEY_DIRECT_ID]; ok {
+		var binding string
+		if binding, err = directBinding(c.ConnectionState()); err != nil {
+			return
+		}
+		return m.acceptDirect(addr, data, binding)
+	}
+
 	rconn, ok := m.conns.Load(data.Token)
 	if !ok {
 		err = fmt.Errorf("connection with id (%s) not found", data.Token)
 		return
 	}
-	conn := rconn.(*Connection)
+	s := rconn.(*Connection).snapshot()
 
-	if conn.mode != p2p.ConnectionModeP2P {
+	if s.mode != p2p.ConnectionModeP2P {
 		err = fmt.Errorf("connection not in p2p mode")
 		return
-	} else if conn.p2pConn == nil {
+	} else if s.p2p == nil {
 		err = fmt.Errorf("peer not ready")
 		return
 	}
 
 	validIP := false
-	for _, paddr := range conn.peer.addrs {
+	for _, paddr := range s.peer.addrs {
 		if err != nil {
 			continue
 		} else if paddr.IP.String() == addr.IP.String() {
@@ -148,13 +179,13 @@ func (m *Manager) clientValidateHandler(addr *net.UDPAddr, data *model.ClientVal
 	}
 
 	cdata = &model.ClientData{
-		Id:      fmt.Sprintf("%s:%s", conn.id, addr.String()),
+		Id:      fmt.Sprintf("%s:%s", s.id, addr.String()),
 		Address: addr.String(),
 		Data:    data.Data,
 		Ctx: &model.ClientData_P2PCtx{
 			P2PCtx: &model.P2PClientContext{
-				ConnId: conn.id,
-				PeerId: conn.peer.id,
+				ConnId: s.id,
+				PeerId: s.peer.id,
 				Active: false,
 			},
 		},
@@ -189,12 +220,33 @@ func (m *Manager) clientInitHandler(c *udps.Client, msg *network.Message) {
 	}
 	conn := rconn.(*Connection)
 
-	if err = conn.p2pConn.checkinRemoteClient(c); err != nil {
+	switch s := conn.snapshot(); {
+	case s.mode == p2p.ConnectionModeRouted && s.routed != nil:
+		err = s.routed.checkinRemoteClient(c)
+	case s.mode == p2p.ConnectionModeP2P && s.p2p != nil:
+		err = s.p2p.checkinRemoteClient(c)
+	default:
+		err = fmt.Errorf("peer not ready")
+	}
+	if err != nil {
 		return
 	}
 	ctx.Active = true
 }
 
+// Connection a stream belongs to, streams opened over a bonded relayed path carry the ID of the path
+func (m *Manager) streamConnection(stream *udp.Stream) (conn *Connection, ok bool) {
+	id := stream.Metadata[p2p.KEY_CONNECTION_ID]
+	v, ok := m.conns.Load(id)
+	if !ok {
+		v, ok = m.paths.Load(id)
+	}
+	if ok {
+		conn = v.(*Connection)
+	}
+	return
+}
+
 func (m *Manager) incomingStreamHandler(client udp.Client, stream *udp.Stream) {
 	// Check if the stream should be ignored
 	// This key is present if the stream was opened using
@@ -205,6 +257,10 @@ func (m *Manager) incomingStreamHandler(client udp.Client, stream *udp.Stream) {
 		return
 	}
 
+	if conn, ok := m.streamConnection(stream); ok {
+		conn.track(conn.pathOf(client), stream, true)
+	}
+
 	// Stream is a message stream.
 	// It's opened to exchange structured messages (network.Message)
 	if _, ok := stream.Metadata[p2p.KEY_STREAM_MESSAGE]; ok {
@@ -214,14 +270,57 @@ func (m *Manager) incomingStreamHandler(client udp.Client, stream *udp.Stream) {
 			return
 		}
 
-		conn, ok := m.conns.Load(stream.Metadata[p2p.KEY_CONNECTION_ID])
+		conn, ok := m.streamConnection(stream)
 		if !ok {
 			m.log.Errorln("Incoming stream error: MessageStream connection not found")
 			stream.Close()
 			return
 		}
 
-		go m.messageStreamHandler(NewMessageStream(conn.(*Connection), stream))
+		go m.messageStreamHandler(NewMessageStream(conn, stream))
+
+		return
+	}
+
+	// Stream is an overlay stream.
+	// It's opened to exchange IP packets
+	if _, ok := stream.Metadata[p2p.KEY_STREAM_OVERLAY]; ok {
+		if m.overlayStreamHandler == nil {
+			m.log.Errorln("Incoming stream error: Overlay stream handler not found")
+			stream.Close()
+			return
+		}
+
+		conn, ok := m.streamConnection(stream)
+		if !ok {
+			m.log.Errorln("Incoming stream error: Overlay stream connection not found")
+			stream.Close()
+			return
+		}
+
+		go m.overlayStreamHandler(conn, stream)
+
+		return
+	}
+
+	// Stream is a route stream.
+	// It's opened to exchange routes and forwarded packets
+	if _, ok := stream.Metadata[p2p.KEY_STREAM_ROUTE]; ok {
+		r, err := m.getRouter()
+		if err != nil {
+			m.log.Errorln("Incoming stream error: Route stream rejected:", err.Error())
+			stream.Close()
+			return
+		}
+
+		conn, ok := m.streamConnection(stream)
+		if !ok {
+			m.log.Errorln("Incoming stream error: Route stream connection not found")
+			stream.Close()
+			return
+		}
+
+		go r.addNeighbour(conn, stream)
 
 		return
 	}
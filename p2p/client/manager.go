@@ -1,6 +1,7 @@
 package p2pc
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
 	"net"
@@ -10,6 +11,7 @@ import (
 	"github.com/supergiant-hq/xnet/model"
 	"github.com/supergiant-hq/xnet/network"
 	"github.com/supergiant-hq/xnet/p2p"
+	"github.com/supergiant-hq/xnet/p2p/dht"
 	"github.com/supergiant-hq/xnet/udp"
 	udpc "github.com/supergiant-hq/xnet/udp/client"
 	udps "github.com/supergiant-hq/xnet/udp/server"
@@ -20,19 +22,41 @@ import (
 // Called on new Connection
 type ConnectionHandler func(c *Connection)
 
+// Called when the state of a Connection changes
+type ConnectionStateHandler func(c *Connection, state p2p.ConnectionState)
+
 // Called on new MessageStream
 type MessageStreamHandler func(ms *MessageStream)
 
+// Called on new Overlay Stream
+type OverlayStreamHandler func(c *Connection, stream *udp.Stream)
+
+// Called on overlay Datagrams, on the goroutine receiving from the path
+type OverlayDatagramHandler func(c *Connection, b []byte)
+
 // P2P Client Manager
 type Manager struct {
 	config     Config
 	peerServer *udps.Server
 	client     *udpc.Client
 
-	conns                *sync.Map
-	connectionHandler    ConnectionHandler
-	streamHandler        udp.StreamHandler
-	messageStreamHandler MessageStreamHandler
+	conns                  *sync.Map
+	paths                  sync.Map
+	pending                sync.Map
+	connectionHandler      ConnectionHandler
+	connectionStateHandler ConnectionStateHandler
+	requestHandler         ConnectionRequestHandler
+	streamHandler          udp.StreamHandler
+	messageStreamHandler   MessageStreamHandler
+	overlayStreamHandler   OverlayStreamHandler
+	overlayDatagramHandler OverlayDatagramHandler
+
+	// Nonces of the connection requests received directly or over the DHT
+	nonces    sync.Map
+	discovery *Discovery
+	dhtNode   *dht.Node
+	router    *Router
+	mutex     sync.Mutex
 
 	rnd *rand.Rand
 	log *logrus.Entry
@@ -57,24 +81,32 @@ func New(
 		m.log.Logger,
 		udps.Config{
 			Tag:         "P2P",
+			Datagrams:   true,
 			TLS:         client.Cfg.TLS.Clone(),
 			Quic:        client.Cfg.Quic.Clone(),
 			Unmarshaler: client.Cfg.Unmarshaler,
 		},
 		client.UDPConn,
-		m.clientValidateHandler,
+		nil,
 	); err != nil {
 		return
 	}
+	// Direct connections are validated with the session their credentials are bound to
+	m.peerServer.SetClientSessionValidateHandler(m.clientValidateHandler)
 
 	if err = m.peerServer.Listen(); err != nil {
 		return
 	}
 
 	m.peerServer.SetStreamHandler(m.incomingStreamHandler)
+	client.SetLoadHandler(m.load)
 
 	m.registerHandlers()
 
+	if config.IdleTimeout > 0 {
+		go m.expireLoop()
+	}
+
 	m.log.Infoln("P2P Manager Registered")
 	return
 }
@@ -84,6 +116,11 @@ func (m *Manager) SetConnectionHandler(handler ConnectionHandler) {
 	m.connectionHandler = handler
 }
 
+// Set Connection State Handler
+func (m *Manager) SetConnectionStateHandler(handler ConnectionStateHandler) {
+	m.connectionStateHandler = handler
+}
+
 // Set New Stream Handler
 func (m *Manager) SetStreamHandler(handler udp.StreamHandler) {
 	m.streamHandler = handler
@@ -94,6 +131,16 @@ func (m *Manager) SetMessageStreamHandler(handler MessageStreamHandler) {
 	m.messageStreamHandler = handler
 }
 
+// Set Overlay Stream Handler
+func (m *Manager) SetOverlayStreamHandler(handler OverlayStreamHandler) {
+	m.overlayStreamHandler = handler
+}
+
+// Set Overlay Datagram Handler
+func (m *Manager) SetOverlayDatagramHandler(handler OverlayDatagramHandler) {
+	m.overlayDatagramHandler = handler
+}
+
 func (m *Manager) registerHandlers() {
 	m.peerServer.SetClientDisconnectedHandler(m.clientDisconnectedHandler)
 	m.peerServer.RegisterHandler(model.MessageTypeP2PClientInit, m.clientInitHandler)
@@ -144,11 +191,45 @@ func (m *Manager) getNearestRelay() (addr string, err error) {
 	return
 }
 
+// Port of the UDP connection shared by the Client and the peer server
+func (m *Manager) localPort() int {
+	if addr, ok := m.client.UDPConn.LocalAddr().(*net.UDPAddr); ok {
+		return addr.Port
+	}
+	return m.client.Addr.Port
+}
+
 // Connect to Client by ID
+// If the Broker is not connected, peers found by LAN discovery or in the DHT are connected to directly
+// Peers which cannot be connected to are connected to over a route, if routing is running
 func (m *Manager) ConnectById(peerId string, mode p2p.ConnectionMode) (conn *Connection, err error) {
+	return m.ConnectByIdWithMetadata(peerId, mode, nil)
+}
+
+// Connect to Client by ID with application data passed to the ConnectionRequestHandler of the Peer
+// A rejected request returns a *ConnectionRejectedError
+func (m *Manager) ConnectByIdWithMetadata(peerId string, mode p2p.ConnectionMode, metadata map[string]string) (conn *Connection, err error) {
+	if mode == p2p.ConnectionModeRouted {
+		return m.connectRouted(peerId, metadata)
+	}
+
+	if !m.client.Connected && mode == p2p.ConnectionModeP2P {
+		if m.discovered(peerId) {
+			return m.connectLAN(peerId, metadata)
+		}
+		if _, derr := m.getDHTNode(); derr == nil {
+			return m.connectDHT(peerId, metadata)
+		}
+	}
+
 	m.log.Infof("Connecting to peer id(%s) using mode(%v)...", peerId, mode)
 
-	if conn, err = createConnection(m.log.Logger, m, peerId, mode); err != nil {
+	if conn, err = createConnection(m.log.Logger, m, peerId, mode, metadata); err != nil {
+		var rejected *ConnectionRejectedError
+		if !errors.As(err, &rejected) && m.routable(peerId) {
+			m.log.Warnf("Connecting to peer id(%s) failed, connecting over route: %s", peerId, err.Error())
+			return m.connectRouted(peerId, metadata)
+		}
 		return
 	}
 	m.conns.Store(conn.id, conn)
@@ -158,24 +239,10 @@ func (m *Manager) ConnectById(peerId string, mode p2p.ConnectionMode) (conn *Con
 }
 
 // Connect to Client by Tag
+// The peer is chosen by Config.PeerSelector, at random if it is not set
+// If the Broker is not connected, the peers having the tag are looked up in the DHT
 func (m *Manager) ConnectByTag(tag string, mode p2p.ConnectionMode) (conn *Connection, err error) {
-	m.log.Infof("Connecting to peer tag(%s) using mode(%v)...", tag, mode)
-
-	clients, err := m.client.SearchClients(&model.ClientSearch{
-		Tag: tag,
-	})
-	if err != nil {
-		return
-	}
-	clientId := clients[m.rnd.Intn(len(clients))]
-
-	if conn, err = createConnection(m.log.Logger, m, clientId, mode); err != nil {
-		return
-	}
-	m.conns.Store(conn.id, conn)
-
-	m.log.Infof("Created connection: %s", conn.String())
-	return
+	return m.ConnectByTagWith(tag, mode, nil)
 }
 
 // Close Connection
@@ -183,7 +250,7 @@ func (m *Manager) CloseConnection(cid string, reason string) {
 	if c, ok := m.conns.LoadAndDelete(cid); ok {
 		conn := c.(*Connection)
 		conn.Close(reason)
-		m.log.Warnf("Connection closed (%s)", conn.id)
+		m.log.Warnf("Connection closed (%s)", cid)
 	}
 }
 
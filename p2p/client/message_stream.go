@@ -32,7 +32,7 @@ func NewMessageStream(conn *Connection, stream *udp.Stream) *MessageStream {
 		stream: stream,
 
 		Exit: make(chan bool, 1),
-		log:  conn.log.WithField("prefix", fmt.Sprintf("%s:MessageStream", conn.id)),
+		log:  conn.logger().WithField("prefix", fmt.Sprintf("%s:MessageStream", conn.Id())),
 	}
 }
 
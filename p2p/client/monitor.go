@@ -0,0 +1,250 @@
+package p2pc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/supergiant-hq/xnet/udp"
+)
+
+const (
+	// Default interval between path quality probes
+	PathProbeInterval = time.Second
+	// Default no. of probes the path quality is measured over
+	PathWindow = 10
+	// Default ratio of lost probes above which a path is degraded
+	PathMaxLoss = 0.3
+	// Default duration a degraded path has to be healthy again before failing back to it
+	PathRecoverAfter = 30 * time.Second
+)
+
+// Path quality monitoring of P2P connections
+// The initiator probes the direct path with QUIC Datagrams, fails over to a Relay when it degrades
+// and fails back when it recovers, streams opened meanwhile use the Relay
+type PathMonitorConfig struct {
+	// Interval between probes, a probe is lost if its reply does not arrive within it, PathProbeInterval if 0
+	Interval time.Duration
+	// No. of probes the quality is measured over, PathWindow if 0
+	Window int
+	// Average round-trip time above which the path is degraded, not considered if 0
+	MaxRTT time.Duration
+	// Ratio of lost probes above which the path is degraded, PathMaxLoss if 0
+	MaxLoss float64
+	// Duration the path has to be healthy again before failing back to it, PathRecoverAfter if 0
+	RecoverAfter time.Duration
+}
+
+func (c *PathMonitorConfig) init() {
+	if c.Interval <= 0 {
+		c.Interval = PathProbeInterval
+	}
+	if c.Window <= 0 {
+		c.Window = PathWindow
+	}
+	if c.MaxLoss <= 0 {
+		c.MaxLoss = PathMaxLoss
+	}
+	if c.RecoverAfter <= 0 {
+		c.RecoverAfter = PathRecoverAfter
+	}
+}
+
+// Quality of the direct path of a P2P connection
+type PathQuality struct {
+	// Average round-trip time of the answered probes
+	RTT time.Duration
+	// Ratio of lost probes
+	Loss float64
+	// No. of probes measured
+	Probes int
+	// If the quality is past the thresholds
+	Degraded bool
+	// If streams are opened over a Relay
+	FailedOver bool
+}
+
+type pathSample struct {
+	rtt  time.Duration
+	lost bool
+}
+
+// Monitors the direct path of a Connection
+type pathMonitor struct {
+	conn    *Connection
+	p2pConn *p2pConn
+	config  PathMonitorConfig
+
+	samples      []pathSample
+	quality      PathQuality
+	healthySince time.Time
+	mutex        sync.Mutex
+}
+
+func (c *Connection) startPathMonitor(conn *p2pConn) {
+	config := c.mgr.config.PathMonitor
+	if config == nil || !c.initiator {
+		return
+	}
+	cfg := *config
+	cfg.init()
+
+	monitor := &pathMonitor{
+		conn:    c,
+		p2pConn: conn,
+		config:  cfg,
+	}
+	c.fmutex.Lock()
+	c.monitor = monitor
+	c.fmutex.Unlock()
+	go monitor.loop()
+}
+
+func (p *pathMonitor) loop() {
+	p.p2pConn.mutex.Lock()
+	prober := p.p2pConn.prober
+	p.p2pConn.mutex.Unlock()
+
+	if prober == nil || !prober.client.DatagramsSupported() {
+		p.conn.logger().Warnln("Path monitoring disabled:", udp.ErrorDatagramsUnsupported.Error())
+		return
+	}
+
+	for {
+		started := time.Now()
+		rtt, ok, err := prober.ping(p.config.Interval)
+		if err != nil {
+			p.conn.logger().Debugln("Path probe error:", err.Error())
+		}
+		p.record(pathSample{rtt: rtt, lost: !ok})
+		p.evaluate()
+
+		select {
+		case <-time.After(p.config.Interval - time.Since(started)):
+		case <-p.p2pConn.probeExit:
+			return
+		}
+	}
+}
+
+// Add a probe to the window and measure the quality
+func (p *pathMonitor) record(sample pathSample) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.samples = append(p.samples, sample)
+	if len(p.samples) > p.config.Window {
+		p.samples = p.samples[len(p.samples)-p.config.Window:]
+	}
+
+	var total time.Duration
+	lost := 0
+	for _, s := range p.samples {
+		if s.lost {
+			lost++
+		} else {
+			total += s.rtt
+		}
+	}
+
+	p.quality.Probes = len(p.samples)
+	p.quality.Loss = float64(lost) / float64(len(p.samples))
+	p.quality.RTT = 0
+	if answered := len(p.samples) - lost; answered > 0 {
+		p.quality.RTT = total / time.Duration(answered)
+	}
+	p.quality.Degraded = p.quality.Loss > p.config.MaxLoss ||
+		(p.config.MaxRTT > 0 && p.quality.RTT > p.config.MaxRTT)
+}
+
+// Fail over when the window shows a degraded path, fail back after it recovered
+func (p *pathMonitor) evaluate() {
+	p.mutex.Lock()
+	quality := p.quality
+	full := len(p.samples) >= p.config.Window
+	if quality.Degraded {
+		p.healthySince = time.Time{}
+	} else if p.healthySince.IsZero() {
+		p.healthySince = time.Now()
+	}
+	recovered := !p.healthySince.IsZero() && time.Since(p.healthySince) >= p.config.RecoverAfter
+	p.mutex.Unlock()
+
+	// Bonded connections use the relayed path already
+	if _, bonded := p.conn.Multipath(); bonded {
+		return
+	}
+
+	failedOver := p.conn.failedOver()
+	switch {
+	case full && quality.Degraded && !failedOver:
+		p.conn.logger().Warnf("Path to peer degraded with rtt(%v) loss(%.2f), failing over to relay...", quality.RTT, quality.Loss)
+		if err := p.conn.failover(p); err != nil {
+			p.conn.logger().Errorln("Failover to relay failed:", err.Error())
+		}
+		// The next attempt is decided on a new window
+		p.mutex.Lock()
+		p.samples = p.samples[:0]
+		p.mutex.Unlock()
+	case recovered && failedOver:
+		p.conn.logger().Infof("Path to peer recovered with rtt(%v) loss(%.2f), failing back", quality.RTT, quality.Loss)
+		p.conn.failback()
+	}
+}
+
+func (p *pathMonitor) pathQuality() PathQuality {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	quality := p.quality
+	quality.FailedOver = p.conn.failedOver()
+	return quality
+}
+
+// Quality of the direct path, if it is monitored (Config.PathMonitor)
+func (c *Connection) PathQuality() (quality PathQuality, ok bool) {
+	c.fmutex.Lock()
+	monitor := c.monitor
+	c.fmutex.Unlock()
+
+	if monitor == nil {
+		return
+	}
+	return monitor.pathQuality(), true
+}
+
+// If streams are opened over the failover Relay
+func (c *Connection) failedOver() bool {
+	c.fmutex.Lock()
+	defer c.fmutex.Unlock()
+
+	return c.multipath == nil && c.relayPath != nil && c.relayPath.isConnected()
+}
+
+// Connect to the peer over a Relay, streams are opened over it until failing back
+// The peer attaches the relayed path to its Connection and opens its streams over it as well
+func (c *Connection) failover(monitor *pathMonitor) (err error) {
+	// The direct path may have been closed meanwhile
+	conn, err := c.bondRelayPath(nil, func() bool {
+		return c.monitor == monitor && c.multipath == nil
+	})
+	if err != nil {
+		return
+	}
+
+	c.logger().Infof("Failed over to relay(%s)", conn.addr.String())
+	return
+}
+
+// Open streams over the direct path again and close the failover Relay
+func (c *Connection) failback() {
+	c.fmutex.Lock()
+	var conn *relayConn
+	if c.multipath == nil {
+		conn, c.relayPath = c.relayPath, nil
+	}
+	c.fmutex.Unlock()
+
+	if conn != nil {
+		conn.close()
+	}
+}
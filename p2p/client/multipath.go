@@ -0,0 +1,433 @@
+package p2pc
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/supergiant-hq/xnet/model"
+	"github.com/supergiant-hq/xnet/p2p"
+	"github.com/supergiant-hq/xnet/udp"
+)
+
+// Scheduling of the traffic over the direct and the relayed path of a bonded P2P connection
+type MultipathMode string
+
+const (
+	// Datagrams are sent over both paths, streams are opened over the direct path while it is connected
+	MultipathModeRedundant MultipathMode = "redundant"
+	// Datagrams and streams are distributed over the paths in proportion to their weights
+	MultipathModeWeighted MultipathMode = "weighted"
+)
+
+const (
+	// Default weight of a path in weighted mode
+	MultipathWeight = 1
+	// Default interval between attempts to connect a lost relayed path again
+	MultipathRepairInterval = 5 * time.Second
+
+	// Bond of a relayed path the initiator fails over to while the direct path is degraded
+	bondModeFailover = "failover"
+)
+
+// Multipath bonding of a P2P connection
+// A relayed path is kept connected next to the direct path, the connection continues over
+// either path without reconnecting when the other is lost
+type MultipathConfig struct {
+	// Scheduling of the traffic, MultipathModeRedundant if empty
+	Mode MultipathMode
+	// Weights of the paths in weighted mode, MultipathWeight if 0
+	DirectWeight uint32
+	RelayWeight  uint32
+	// Interval between attempts to connect a lost relayed path again, MultipathRepairInterval if 0
+	RepairInterval time.Duration
+}
+
+func (c *MultipathConfig) init() {
+	if c.Mode == "" {
+		c.Mode = MultipathModeRedundant
+	}
+	if c.DirectWeight == 0 {
+		c.DirectWeight = MultipathWeight
+	}
+	if c.RelayWeight == 0 {
+		c.RelayWeight = MultipathWeight
+	}
+	if c.RepairInterval <= 0 {
+		c.RepairInterval = MultipathRepairInterval
+	}
+}
+
+// Path to the peer carrying the streams and Datagrams of a Connection
+type connPath interface {
+	openStream(metadata map[string]string, data map[string]string) (stream *udp.Stream, err error)
+	path() (client udp.Client, addr *net.UDPAddr)
+	counters() *pathCounters
+}
+
+// Connection status of a path, read without holding the mutex of the path
+type pathStatus struct {
+	// Accessed atomically
+	connected int32
+}
+
+func (s *pathStatus) setConnected(connected bool) {
+	v := int32(0)
+	if connected {
+		v = 1
+	}
+	atomic.StoreInt32(&s.connected, v)
+}
+
+func (s *pathStatus) isConnected() bool {
+	return atomic.LoadInt32(&s.connected) == 1
+}
+
+// Bond a relayed path to the P2P connection and schedule the traffic over both paths
+// It is enabled by the initiator, the Peer schedules its traffic in the same mode
+// A lost relayed path is connected again, a lost direct path is replaced by the relayed path
+func (c *Connection) EnableMultipath(config MultipathConfig) (err error) {
+	if !c.initiator {
+		err = fmt.Errorf("multipath is enabled by the initiator")
+		return
+	} else if c.snapshot().mode != p2p.ConnectionModeP2P {
+		err = fmt.Errorf("multipath requires a p2p connection")
+		return
+	}
+
+	config.init()
+	switch config.Mode {
+	case MultipathModeRedundant, MultipathModeWeighted:
+	default:
+		err = fmt.Errorf("invalid multipath mode: %v", config.Mode)
+		return
+	}
+	multipath := &config
+
+	// A failover path or the path of a previous config is replaced
+	c.fmutex.Lock()
+	c.multipath = multipath
+	previous := c.relayPath
+	c.relayPath = nil
+	c.fmutex.Unlock()
+	if previous != nil {
+		previous.close()
+	}
+
+	if _, err = c.bondRelayPath(multipath, func() bool { return c.multipath == multipath }); err != nil {
+		c.fmutex.Lock()
+		if c.multipath == multipath {
+			c.multipath = nil
+		}
+		c.fmutex.Unlock()
+		return
+	}
+	go c.maintainMultipath(multipath)
+
+	c.logger().Infof("Multipath enabled with mode(%v)", config.Mode)
+	return
+}
+
+// Close the relayed path and use the direct path only
+func (c *Connection) DisableMultipath() {
+	c.fmutex.Lock()
+	if c.multipath == nil {
+		c.fmutex.Unlock()
+		return
+	}
+	c.multipath = nil
+	conn := c.relayPath
+	c.relayPath = nil
+	c.fmutex.Unlock()
+
+	if conn != nil {
+		conn.close()
+	}
+	c.logger().Infoln("Multipath disabled")
+}
+
+// Multipath config of the connection, if it is bonded
+func (c *Connection) Multipath() (config MultipathConfig, ok bool) {
+	c.fmutex.Lock()
+	defer c.fmutex.Unlock()
+
+	if c.multipath == nil {
+		return
+	}
+	return *c.multipath, true
+}
+
+// Connect the relayed path again while multipath is enabled
+func (c *Connection) maintainMultipath(multipath *MultipathConfig) {
+	for {
+		<-time.After(multipath.RepairInterval)
+
+		c.fmutex.Lock()
+		enabled, attached := c.multipath == multipath, c.relayPath != nil
+		c.fmutex.Unlock()
+		s := c.snapshot()
+		if s.closed || !enabled {
+			return
+		}
+		if attached || s.mode != p2p.ConnectionModeP2P || !c.IsConnected() {
+			continue
+		}
+
+		c.logger().Warnln("Relayed path lost, connecting it again...")
+		if _, err := c.bondRelayPath(multipath, func() bool { return c.multipath == multipath }); err != nil {
+			c.logger().Errorln("Connecting relayed path failed:", err.Error())
+		}
+	}
+}
+
+// Connect a relayed path to the peer through the Broker and bond it to the P2P connection
+// The path is attached while valid holds, multipath is nil for failover paths
+func (c *Connection) bondRelayPath(multipath *MultipathConfig, valid func() bool) (conn *relayConn, err error) {
+	if !c.mgr.client.Connected {
+		err = fmt.Errorf("broker not connected")
+		return
+	}
+
+	s := c.snapshot()
+	bond := &model.P2PBond{
+		Id:   s.id,
+		Mode: bondModeFailover,
+	}
+	if multipath != nil {
+		bond.Mode = string(multipath.Mode)
+		bond.DirectWeight = multipath.DirectWeight
+		bond.RelayWeight = multipath.RelayWeight
+	}
+
+	connData, relayAddr, err := requestConnection(c.mgr, s.peer.id, p2p.ConnectionModeRelay, nil, bond)
+	if err != nil {
+		return
+	}
+	if conn, err = c.newRelayConn(connData.Id, relayAddr); err != nil {
+		return
+	}
+	if err = conn.connect(); err != nil {
+		conn.close()
+		return
+	}
+
+	err = c.attachRelayPath(conn, multipath, valid)
+	return
+}
+
+// Attach a connected relayed path to the P2P connection
+// The initiator keeps the path it attached first, the Peer replaces it with the path bonded last
+func (c *Connection) attachRelayPath(conn *relayConn, multipath *MultipathConfig, valid func() bool) (err error) {
+	c.mutex.Lock()
+	c.fmutex.Lock()
+	previous := c.relayPath
+	if c.Closed || c.mode != p2p.ConnectionModeP2P || (previous != nil && c.initiator) || !valid() {
+		c.fmutex.Unlock()
+		c.mutex.Unlock()
+		conn.close()
+		err = fmt.Errorf("path closed")
+		return
+	}
+	c.relayPath = conn
+	c.multipath = multipath
+	c.fmutex.Unlock()
+	c.mutex.Unlock()
+
+	if previous != nil {
+		previous.close()
+	}
+
+	// Streams opened by the peer over the path carry its ID
+	c.mgr.paths.Store(conn.id, c)
+	go func() {
+		<-conn.exit
+		c.mgr.paths.Delete(conn.id)
+
+		c.fmutex.Lock()
+		if c.relayPath == conn {
+			c.relayPath = nil
+		}
+		c.fmutex.Unlock()
+	}()
+
+	c.log.Infof("Bonded relayed path(%s) over relay(%s)", conn.id, conn.addr.String())
+	return
+}
+
+// Close the relayed path bonded to the P2P connection
+func (c *Connection) closeRelayPath() {
+	c.fmutex.Lock()
+	conn := c.relayPath
+	c.relayPath = nil
+	c.fmutex.Unlock()
+
+	if conn != nil {
+		conn.close()
+	}
+}
+
+// Continue the P2P connection in relay mode over the bonded relayed path when lost reports the direct path lost
+func (c *Connection) adoptRelayPath(lost func(conn *p2pConn) bool) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.Closed || c.mode != p2p.ConnectionModeP2P || c.p2pConn == nil || !lost(c.p2pConn) {
+		return false
+	}
+
+	c.fmutex.Lock()
+	conn := c.relayPath
+	if conn == nil || !conn.isConnected() {
+		c.fmutex.Unlock()
+		return false
+	}
+	c.relayPath = nil
+	c.monitor = nil
+	c.fmutex.Unlock()
+
+	c.p2pConn.close()
+	c.update(func() {
+		c.p2pConn = nil
+		c.mode = p2p.ConnectionModeRelay
+		c.relayConn = conn
+		c.relayAddr = conn.addr.String()
+	})
+	go c.awaitExit(conn.exit)
+
+	c.log.Warnf("Direct path lost, continuing over relay(%s)", c.relayAddr)
+	return true
+}
+
+// Path of the connection a client carries
+func (c *Connection) pathOf(client udp.Client) *pathCounters {
+	paths := []connPath{}
+	c.fmutex.Lock()
+	if c.relayPath != nil {
+		paths = append(paths, c.relayPath)
+	}
+	c.fmutex.Unlock()
+	s := c.snapshot()
+	if s.p2p != nil {
+		paths = append(paths, s.p2p)
+	}
+	if s.relay != nil {
+		paths = append(paths, s.relay)
+	}
+	if s.routed != nil {
+		paths = append(paths, s.routed)
+	}
+
+	for _, path := range paths {
+		if pc, _ := path.path(); pc != nil && pc == client {
+			return path.counters()
+		}
+	}
+	return nil
+}
+
+// Paths to open the next stream or send the next Datagram over, in order of preference
+// In redundant mode Datagrams are sent over all of them
+// The paths are taken from a snapshot as the connection may be re-established meanwhile
+func (c *Connection) schedule() (paths []connPath, redundant bool) {
+	s := c.snapshot()
+	switch s.mode {
+	case p2p.ConnectionModeP2P:
+		if s.p2p == nil {
+			return
+		}
+	case p2p.ConnectionModeRelay:
+		if s.relay != nil {
+			paths = append(paths, s.relay)
+		}
+		return
+	case p2p.ConnectionModeRouted:
+		if s.routed != nil {
+			paths = append(paths, s.routed)
+		}
+		return
+	default:
+		return
+	}
+
+	c.fmutex.Lock()
+	defer c.fmutex.Unlock()
+
+	direct, relay := s.p2p, c.relayPath
+	switch {
+	case relay == nil || !relay.isConnected():
+		paths = []connPath{direct}
+	case c.multipath == nil:
+		// The direct path is degraded
+		paths = []connPath{relay, direct}
+	case c.multipath.Mode == MultipathModeWeighted && c.nextRelayed():
+		paths = []connPath{relay, direct}
+	case c.multipath.Mode == MultipathModeWeighted:
+		paths = []connPath{direct, relay}
+	default:
+		paths, redundant = []connPath{direct, relay}, true
+	}
+	return
+}
+
+// If the next stream or Datagram is sent over the relayed path in weighted mode
+// The paths are chosen by smooth weighted round-robin, called under fmutex
+func (c *Connection) nextRelayed() bool {
+	total := int64(c.multipath.DirectWeight) + int64(c.multipath.RelayWeight)
+	c.directCredit += int64(c.multipath.DirectWeight)
+	c.relayCredit += int64(c.multipath.RelayWeight)
+
+	if c.relayCredit > c.directCredit {
+		c.relayCredit -= total
+		return true
+	}
+	c.directCredit -= total
+	return false
+}
+
+// Attach a relayed path the peer bonded to a P2P connection
+func (m *Manager) acceptBond(creq *model.P2PConnectionRequest) (err error) {
+	bond := creq.Bond
+	c, err := m.Connection(bond.Id)
+	if err != nil {
+		return
+	}
+	if s := c.snapshot(); c.initiator || s.peer.id != creq.Peer.Id || s.mode != p2p.ConnectionModeP2P {
+		err = fmt.Errorf("connection with id (%s) cannot be bonded", bond.Id)
+		return
+	}
+
+	var multipath *MultipathConfig
+	switch MultipathMode(bond.Mode) {
+	case bondModeFailover:
+	case MultipathModeRedundant, MultipathModeWeighted:
+		multipath = &MultipathConfig{
+			Mode:         MultipathMode(bond.Mode),
+			DirectWeight: bond.DirectWeight,
+			RelayWeight:  bond.RelayWeight,
+		}
+		multipath.init()
+	default:
+		err = fmt.Errorf("invalid bond mode: %v", bond.Mode)
+		return
+	}
+
+	conn, err := c.newRelayConn(creq.Id, creq.RelayAddress)
+	if err != nil {
+		return
+	}
+	c.logger().Infof("Peer bonded relayed path(%s) with mode(%s)", creq.Id, bond.Mode)
+
+	go func() {
+		if err := conn.connect(); err != nil {
+			c.logger().Errorln("Error connecting relayed path:", err.Error())
+			conn.close()
+			return
+		}
+		if err := c.attachRelayPath(conn, multipath, func() bool { return true }); err != nil {
+			c.logger().Errorln("Error attaching relayed path:", err.Error())
+		}
+	}()
+
+	return
+}
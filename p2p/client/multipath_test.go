@@ -0,0 +1,97 @@
+package p2pc
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/supergiant-hq/xnet/p2p"
+)
+
+// Status of a connected path
+var connectedPath = pathStatus{connected: 1}
+
+func TestSchedule(t *testing.T) {
+	direct := &p2pConn{pathStatus: connectedPath}
+	relay := &relayConn{pathStatus: connectedPath}
+	routed := &routedConn{pathStatus: connectedPath}
+	bonded := &relayConn{pathStatus: connectedPath}
+
+	tests := []struct {
+		name      string
+		conn      *Connection
+		paths     []connPath
+		redundant bool
+	}{
+		{"p2p", &Connection{mode: p2p.ConnectionModeP2P, p2pConn: direct}, []connPath{direct}, false},
+		{"p2p without path", &Connection{mode: p2p.ConnectionModeP2P}, nil, false},
+		{"relay", &Connection{mode: p2p.ConnectionModeRelay, relayConn: relay}, []connPath{relay}, false},
+		{"relay without path", &Connection{mode: p2p.ConnectionModeRelay}, nil, false},
+		{"routed", &Connection{mode: p2p.ConnectionModeRouted, routedConn: routed}, []connPath{routed}, false},
+		{"routed without path", &Connection{mode: p2p.ConnectionModeRouted}, nil, false},
+		{"failover", &Connection{mode: p2p.ConnectionModeP2P, p2pConn: direct, relayPath: bonded}, []connPath{bonded, direct}, false},
+		{
+			"redundant",
+			&Connection{mode: p2p.ConnectionModeP2P, p2pConn: direct, relayPath: bonded, multipath: &MultipathConfig{Mode: MultipathModeRedundant}},
+			[]connPath{direct, bonded},
+			true,
+		},
+		{
+			"bonded path disconnected",
+			&Connection{mode: p2p.ConnectionModeP2P, p2pConn: direct, relayPath: &relayConn{}, multipath: &MultipathConfig{Mode: MultipathModeRedundant}},
+			[]connPath{direct},
+			false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			paths, redundant := test.conn.schedule()
+			if redundant != test.redundant {
+				t.Fatalf("redundant %v, want %v", redundant, test.redundant)
+			}
+			if len(paths) != len(test.paths) {
+				t.Fatalf("paths %d, want %d", len(paths), len(test.paths))
+			}
+			for i := range paths {
+				if paths[i] != test.paths[i] {
+					t.Fatalf("path %d differs", i)
+				}
+			}
+		})
+	}
+}
+
+// Paths are scheduled while the direct path is replaced by a relayed path
+func TestScheduleWhileAdopting(t *testing.T) {
+	c := &Connection{mode: p2p.ConnectionModeP2P, p2pConn: &p2pConn{pathStatus: connectedPath}}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			relay := &relayConn{pathStatus: connectedPath}
+			c.update(func() { c.p2pConn, c.relayConn, c.mode = nil, relay, p2p.ConnectionModeRelay })
+			direct := &p2pConn{pathStatus: connectedPath}
+			c.update(func() { c.p2pConn, c.relayConn, c.mode = direct, nil, p2p.ConnectionModeP2P })
+		}
+	}()
+
+	for i := 0; i < 1000; i++ {
+		paths, _ := c.schedule()
+		for _, path := range paths {
+			switch p := path.(type) {
+			case *p2pConn:
+				if p == nil {
+					t.Fatal("nil direct path scheduled")
+				}
+			case *relayConn:
+				if p == nil {
+					t.Fatal("nil relayed path scheduled")
+				}
+			}
+		}
+		c.pathOf(nil)
+	}
+	wg.Wait()
+}
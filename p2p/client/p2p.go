@@ -13,6 +13,7 @@ import (
 	udpc "github.com/supergiant-hq/xnet/udp/client"
 	udps "github.com/supergiant-hq/xnet/udp/server"
 
+	"github.com/lucas-clemente/quic-go"
 	"github.com/sirupsen/logrus"
 )
 
@@ -25,6 +26,10 @@ type connectPeerContext struct {
 	resultChan chan *udpc.Client
 	mutex      sync.RWMutex
 	completed  bool
+	// Error authenticating the peer on direct connections
+	authErr error
+	// Rejection of a direct connection by the peer
+	rejectedChan chan error
 }
 
 // P2P Connection
@@ -35,11 +40,16 @@ type p2pConn struct {
 	remoteClientChan chan *udps.Client
 	remoteClient     *udps.Client
 
-	connected bool
-	exit      chan bool
-	closed    bool
-	mutex     sync.Mutex
-	log       *logrus.Entry
+	prober    *pmtuProber
+	probeExit chan bool
+
+	pathCounters
+	pathStatus
+
+	exit   chan bool
+	closed bool
+	mutex  sync.Mutex
+	log    *logrus.Entry
 }
 
 func (c *Connection) newP2PConn() *p2pConn {
@@ -47,8 +57,9 @@ func (c *Connection) newP2PConn() *p2pConn {
 		conn: c,
 
 		remoteClientChan: make(chan *udps.Client, 1),
+		probeExit:        make(chan bool),
 		exit:             make(chan bool, 1),
-		log:              c.log,
+		log:              c.logger(),
 	}
 }
 
@@ -60,7 +71,8 @@ func (c *p2pConn) connect() (err error) {
 
 	if c.conn.initiator {
 		connectContext := &connectPeerContext{
-			resultChan: make(chan *udpc.Client),
+			resultChan:   make(chan *udpc.Client),
+			rejectedChan: make(chan error, 1),
 		}
 		for _, serverAddr := range c.conn.peer.addrs {
 			go c.connectToPeer(serverAddr, connectContext)
@@ -86,9 +98,19 @@ func (c *p2pConn) connect() (err error) {
 				connectContext.completed = true
 				connectContext.mutex.Unlock()
 
+				break loop
+			case err = <-connectContext.rejectedChan:
+				connectContext.mutex.Lock()
+				connectContext.completed = true
+				connectContext.mutex.Unlock()
 				break loop
 			case <-time.After(network.ConnectionTimeout * P2P_CONNECT_TRIES):
 				err = fmt.Errorf("connecting to peer timeout")
+				connectContext.mutex.RLock()
+				if connectContext.authErr != nil {
+					err = connectContext.authErr
+				}
+				connectContext.mutex.RUnlock()
 				break loop
 			}
 		}
@@ -102,6 +124,11 @@ func (c *p2pConn) connect() (err error) {
 }
 
 func (c *p2pConn) connectToPeer(serverAddr *net.UDPAddr, connectCtx *connectPeerContext) (client *udpc.Client, err error) {
+	if c.conn.direct && !c.conn.mgr.directEnabled() {
+		err = fmt.Errorf("direct connections require a private key or a pre-shared key")
+		return
+	}
+
 	client, err = udpc.NewWithConnection(
 		c.log.Logger,
 		udpc.Config{
@@ -110,6 +137,8 @@ func (c *p2pConn) connectToPeer(serverAddr *net.UDPAddr, connectCtx *connectPeer
 			ConnectTries:   P2P_CONNECT_TRIES,
 			ReconnectTries: P2P_RECONNECT_TRIES,
 
+			Datagrams: true,
+
 			TLS:  c.conn.mgr.client.Cfg.TLS.Clone(),
 			Quic: c.conn.mgr.client.Cfg.Quic.Clone(),
 
@@ -128,11 +157,49 @@ func (c *p2pConn) connectToPeer(serverAddr *net.UDPAddr, connectCtx *connectPeer
 		defer connectCtx.mutex.RUnlock()
 		return !connectCtx.completed && tries < P2P_CONNECT_TRIES
 	})
+	if c.conn.direct {
+		// The peer closes its side of a direct connection with the session
+		client.SetCanReconnectHandler(func(tries int) bool {
+			return false
+		})
+		// The credentials are bound to the session
+		client.SetSessionDataHandler(func(state quic.ConnectionState) (data map[string]string, err error) {
+			binding, err := directBinding(state)
+			if err != nil {
+				return
+			}
+			return c.conn.mgr.directRequestData(c.conn.peer.id, c.conn.id, c.conn.metadata, binding)
+		})
+	}
 
 	if err = client.Connect(); err != nil {
+		if rerr := directRejection(c.conn.peer.id, client); c.conn.direct && rerr != nil {
+			select {
+			case connectCtx.rejectedChan <- rerr:
+			default:
+			}
+			return nil, rerr
+		}
+		// The peer rejected the credentials of a direct connection
+		if c.conn.direct && client.Data != nil && !client.Data.Status {
+			connectCtx.mutex.Lock()
+			connectCtx.authErr = err
+			connectCtx.mutex.Unlock()
+		}
 		return
 	}
 
+	if c.conn.direct {
+		if err = c.verifyDirectAccept(client); err != nil {
+			c.log.Errorln("Peer authentication failed:", err.Error())
+			client.Close(0, err.Error())
+			connectCtx.mutex.Lock()
+			connectCtx.authErr = err
+			connectCtx.mutex.Unlock()
+			return
+		}
+	}
+
 	connectCtx.mutex.RLock()
 	defer connectCtx.mutex.RUnlock()
 	select {
@@ -145,6 +212,19 @@ func (c *p2pConn) connectToPeer(serverAddr *net.UDPAddr, connectCtx *connectPeer
 	return
 }
 
+// Verify that the peer accepted the direct connection over this session
+func (c *p2pConn) verifyDirectAccept(client *udpc.Client) (err error) {
+	state, err := client.ConnectionState()
+	if err != nil {
+		return
+	}
+	binding, err := directBinding(state)
+	if err != nil {
+		return
+	}
+	return c.conn.mgr.verifyDirectAccept(c.conn.peer, c.conn.id, client.Data.Data, binding)
+}
+
 func (c *p2pConn) initClient(client *udpc.Client) (err error) {
 	msg := network.NewMessageWithAck(
 		model.MessageTypeP2PClientInit,
@@ -165,7 +245,7 @@ func (c *p2pConn) initClient(client *udpc.Client) (err error) {
 	client.SetStreamHandler(c.conn.mgr.incomingStreamHandler)
 
 	c.localClient = client
-	c.connected = true
+	c.setConnected(true)
 
 	return
 }
@@ -179,7 +259,7 @@ func (c *p2pConn) awaitRemoteConnection() (err error) {
 		}
 
 		c.remoteClient = remoteClient
-		c.connected = true
+		c.setConnected(true)
 
 	case <-time.After(time.Minute / 2):
 		err = fmt.Errorf("awaiting for peer timedout")
@@ -189,7 +269,7 @@ func (c *p2pConn) awaitRemoteConnection() (err error) {
 }
 
 func (c *p2pConn) checkinRemoteClient(client *udps.Client) (err error) {
-	if c.connected {
+	if c.isConnected() {
 		err = fmt.Errorf("connection already open")
 		return
 	} else if c.remoteClient != nil {
@@ -207,12 +287,12 @@ func (c *p2pConn) checkinRemoteClient(client *udps.Client) (err error) {
 
 func (c *p2pConn) punch(conn *net.UDPConn, addrs []*net.UDPAddr) {
 	for {
-		if c.connected || c.closed {
+		if c.isConnected() || c.closed {
 			return
 		}
 
 		if conn != nil {
-			c.log.Debugf("Punching to ips(%v) for conn(%s)", addrs, c.conn.id)
+			c.log.Debugf("Punching to ips(%v) for conn(%s)", addrs, c.conn.Id())
 
 			for _, addr := range addrs {
 				conn.WriteTo([]byte("punch!"), addr)
@@ -227,7 +307,7 @@ func (c *p2pConn) openStream(metadata map[string]string, data map[string]string)
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	if !c.connected {
+	if !c.isConnected() {
 		err = udp.ErrorNotConnected
 		return
 	}
@@ -257,10 +337,91 @@ func (c *p2pConn) close() {
 		c.remoteClient = nil
 	}
 
+	close(c.probeExit)
 	select {
 	case c.exit <- true:
 	default:
 	}
-	c.connected = false
+	c.setConnected(false)
 	c.closed = true
 }
+
+// Client of the session with the peer and the address of the peer
+func (c *p2pConn) path() (client udp.Client, addr *net.UDPAddr) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.localClient != nil {
+		return c.localClient, c.localClient.Cfg.ServerAddr
+	} else if c.remoteClient != nil {
+		return c.remoteClient, c.remoteClient.Addr
+	}
+	return
+}
+
+// Start searching the path MTU to the peer periodically
+func (c *p2pConn) startProbing() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	var client udp.Client
+	var addr *net.UDPAddr
+	if c.conn.initiator {
+		client, addr = c.localClient, c.localClient.Cfg.ServerAddr
+	} else {
+		client, addr = c.remoteClient, c.remoteClient.Addr
+	}
+	c.prober = newPMTUProber(client, c.conn.mgr.client.UDPConn.LocalAddr().(*net.UDPAddr), addr)
+	c.prober.deliver = func(b []byte) {
+		c.conn.receiveDatagram(&c.pathCounters, b)
+	}
+
+	go c.prober.receive()
+	go c.probeLoop(c.prober)
+}
+
+// Searches again when the path MTU discovery of QUIC raised the packet size
+func (c *p2pConn) probeLoop(prober *pmtuProber) {
+	for {
+		if mtu, err := prober.search(); err != nil {
+			c.log.Debugln("Path MTU search error:", err.Error())
+		} else {
+			c.log.Debugf("Path MTU to peer: %d, largest datagram: %d", mtu, prober.maxDatagram())
+		}
+
+		_, grown := prober.acknowledged()
+		select {
+		case <-grown:
+		case <-time.After(pmtuProbeInterval):
+		case <-c.probeExit:
+			return
+		}
+	}
+}
+
+// Path MTU to the peer
+func (c *p2pConn) pathMTU() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.prober == nil {
+		return MinPathMTU
+	}
+	return c.prober.mtu()
+}
+
+// Search the path MTU to the peer now
+func (c *p2pConn) probePathMTU() (mtu int, err error) {
+	c.mutex.Lock()
+	prober := c.prober
+	c.mutex.Unlock()
+
+	if prober == nil {
+		return MinPathMTU, udp.ErrorNotConnected
+	}
+	return prober.search()
+}
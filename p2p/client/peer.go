@@ -1,6 +1,7 @@
 package p2pc
 
 import (
+	"crypto/ed25519"
 	"fmt"
 	"net"
 
@@ -8,12 +9,14 @@ import (
 )
 
 type peer struct {
-	id    string
+	id string
+	// Key the peer must prove on direct connections, any trusted key if nil
+	key   ed25519.PublicKey
 	addr  *net.UDPAddr
 	addrs []*net.UDPAddr
 }
 
-func newPeer(data *model.P2PPeerData) (p *peer, err error) {
+func newPeer(m *Manager, data *model.P2PPeerData) (p *peer, err error) {
 	addr, err := net.ResolveUDPAddr("udp", data.Address)
 	if err != nil {
 		return
@@ -25,6 +28,10 @@ func newPeer(data *model.P2PPeerData) (p *peer, err error) {
 		if err != nil {
 			return nil, err
 		}
+		if addr.IP.IsLinkLocalUnicast() && addr.IP.To4() == nil {
+			addrs = append(addrs, m.scopeCandidate(addr)...)
+			continue
+		}
 		addrs = append(addrs, addr)
 	}
 
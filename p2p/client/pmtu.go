@@ -0,0 +1,227 @@
+package p2pc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/supergiant-hq/xnet/network"
+	"github.com/supergiant-hq/xnet/udp"
+)
+
+const (
+	// Minimum path MTU, the smallest MTU of IPv6 which QUIC requires to complete the handshake
+	MinPathMTU = 1280
+	// Overhead of a QUIC short header packet (flags, 16 byte connection ID, packet number and AEAD tag)
+	QuicPacketOverhead = 1 + 16 + 4 + 16
+	// Overhead of UDP over IPv4 and IPv6
+	UDPv4Overhead = 20 + 8
+	UDPv6Overhead = 40 + 8
+
+	// Overhead of a DATAGRAM frame (type and length)
+	datagramFrameOverhead = 1 + 2
+	// Largest DATAGRAM frame accepted by QUIC, whatever the path MTU
+	maxDatagramFrameSize = 1220
+	// Largest UDP payloads sent by QUIC before its own path MTU discovery raises them
+	// A Datagram which does not fit into the current packet size closes the session
+	quicPacketSizeIPv4 = 1252
+	quicPacketSizeIPv6 = 1232
+	// Interval between path MTU searches
+	pmtuProbeInterval = 10 * time.Minute
+	// Time to wait for the reply to a probe
+	pmtuProbeTimeout = time.Second
+	// Tries before a probe size is considered too large
+	pmtuProbeTries = 2
+	// The search stops when the bounds are closer than this
+	pmtuProbeAccuracy = 8
+
+	probeRequest = 0x01
+	probeReply   = 0x02
+	probeHeader  = 1 + 4
+	// Datagram sent by Connection.SendDatagram
+	datagramData = 0x03
+	// Datagram sent by Connection.SendOverlayDatagram
+	datagramOverlay = 0x04
+)
+
+// Path MTU probing over QUIC Datagrams
+// The path MTU discovery of QUIC raises the packet size with padded PING probes,
+// the largest packet acknowledged by the peer yields the path MTU and bounds the Datagrams
+// Probes are Datagrams padded to the probed size, the peer acknowledges each probe it receives
+// Data Datagrams received meanwhile are passed to deliver
+type pmtuProber struct {
+	// Accessed atomically, kept first for alignment
+	datagramSize int32
+	nextId       uint32
+
+	client     udp.Client
+	local      *net.UDPAddr
+	remote     *net.UDPAddr
+	udpHeader  int
+	packetSize int
+	probes     sync.Map
+	deliver    func(b []byte)
+	mutex      sync.Mutex
+}
+
+func newPMTUProber(client udp.Client, local, remote *net.UDPAddr) *pmtuProber {
+	udpHeader, packetSize := UDPv4Overhead, quicPacketSizeIPv4
+	if remote.IP.To4() == nil {
+		udpHeader, packetSize = UDPv6Overhead, quicPacketSizeIPv6
+	}
+
+	return &pmtuProber{
+		datagramSize: int32(MinPathMTU - udpHeader - QuicPacketOverhead - datagramFrameOverhead),
+		client:       client,
+		local:        local,
+		remote:       remote,
+		udpHeader:    udpHeader,
+		packetSize:   packetSize,
+	}
+}
+
+// Largest QUIC packet acknowledged by the peer, at least the initial packet size
+// The channel is signalled when it grows
+func (p *pmtuProber) acknowledged() (size int, grown chan bool) {
+	size, grown = network.AcknowledgedPacketSize(p.local, p.remote)
+	if size < p.packetSize {
+		size = p.packetSize
+	}
+	return
+}
+
+// Receive probes and replies until the session is closed
+func (p *pmtuProber) receive() {
+	for {
+		b, err := p.client.ReceiveDatagram()
+		if err != nil {
+			return
+		}
+		if len(b) < probeHeader {
+			continue
+		}
+
+		id := binary.BigEndian.Uint32(b[1:5])
+		switch b[0] {
+		case probeRequest:
+			reply := make([]byte, probeHeader)
+			reply[0] = probeReply
+			binary.BigEndian.PutUint32(reply[1:5], id)
+			p.client.SendDatagram(reply)
+		case probeReply:
+			if ch, ok := p.probes.Load(id); ok {
+				select {
+				case ch.(chan bool) <- true:
+				default:
+				}
+			}
+		case datagramData, datagramOverlay:
+			if p.deliver != nil {
+				p.deliver(b)
+			}
+		}
+	}
+}
+
+// Send a probe padded to size and wait for its reply
+func (p *pmtuProber) probe(size int) (ok bool, err error) {
+	b := make([]byte, size)
+	b[0] = probeRequest
+
+	for i := 0; i < pmtuProbeTries; i++ {
+		if ok, err = p.send(b, pmtuProbeTimeout); ok || err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Send a probe of the minimum size once and measure its round-trip time
+func (p *pmtuProber) ping(timeout time.Duration) (rtt time.Duration, ok bool, err error) {
+	b := make([]byte, probeHeader)
+	b[0] = probeRequest
+
+	sent := time.Now()
+	if ok, err = p.send(b, timeout); ok {
+		rtt = time.Since(sent)
+	}
+	return
+}
+
+// Send a probe once and wait for its reply
+func (p *pmtuProber) send(b []byte, timeout time.Duration) (ok bool, err error) {
+	id := atomic.AddUint32(&p.nextId, 1)
+	binary.BigEndian.PutUint32(b[1:5], id)
+
+	ch := make(chan bool, 1)
+	p.probes.Store(id, ch)
+	defer p.probes.Delete(id)
+
+	if err = p.client.SendDatagram(b); err != nil {
+		return
+	}
+	select {
+	case <-ch:
+		ok = true
+	case <-time.After(timeout):
+	}
+	return
+}
+
+// Search the largest Datagram reaching the peer, up to the largest which fits into the packet size
+// acknowledged by the peer and into a DATAGRAM frame
+// Returns the path MTU found by the path MTU discovery of QUIC
+func (p *pmtuProber) search() (mtu int, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	mtu = p.mtu()
+	if !p.client.DatagramsSupported() {
+		return mtu, udp.ErrorDatagramsUnsupported
+	}
+
+	overhead := p.udpHeader + QuicPacketOverhead + datagramFrameOverhead
+	low := MinPathMTU - overhead
+	if ok, err := p.probe(low); err != nil || !ok {
+		if err == nil {
+			err = fmt.Errorf("probe of minimum size lost")
+		}
+		return mtu, err
+	}
+
+	size, _ := p.acknowledged()
+	high := size - QuicPacketOverhead - datagramFrameOverhead
+	if high > maxDatagramFrameSize-datagramFrameOverhead {
+		high = maxDatagramFrameSize - datagramFrameOverhead
+	}
+	for high-low > pmtuProbeAccuracy {
+		size := (low + high + 1) / 2
+		if ok, err := p.probe(size); ok && err == nil {
+			low = size
+		} else {
+			high = size - 1
+		}
+	}
+	if high > low {
+		if ok, err := p.probe(high); ok && err == nil {
+			low = high
+		}
+	}
+
+	atomic.StoreInt32(&p.datagramSize, int32(low))
+	return
+}
+
+// Path MTU found by the path MTU discovery of QUIC
+func (p *pmtuProber) mtu() int {
+	size, _ := p.acknowledged()
+	return size + p.udpHeader
+}
+
+// Largest Datagram which reached the peer in the last search
+func (p *pmtuProber) maxDatagram() int {
+	return int(atomic.LoadInt32(&p.datagramSize))
+}
@@ -0,0 +1,84 @@
+package p2pc
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/supergiant-hq/xnet/model"
+	udpc "github.com/supergiant-hq/xnet/udp/client"
+	udps "github.com/supergiant-hq/xnet/udp/server"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The path MTU and the Datagram size grow beyond the initial packet size of QUIC on loopback
+func TestPMTUProber(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	server, err := udps.New(
+		log,
+		udps.Config{
+			Addr:      &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)},
+			Datagrams: true,
+		},
+		func(addr *net.UDPAddr, data *model.ClientValidateData) (*model.ClientData, error) {
+			return &model.ClientData{Id: data.Token}, nil
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetClientConnectedHandler(func(c *udps.Client) {
+		go newPMTUProber(c, server.UDPConn.LocalAddr().(*net.UDPAddr), c.Addr).receive()
+	})
+	if err = server.Listen(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close(0, "done")
+
+	addr := server.UDPConn.LocalAddr().(*net.UDPAddr)
+	client, err := udpc.New(log, udpc.Config{
+		ServerAddr:   addr,
+		ConnectTries: 1,
+		Datagrams:    true,
+		Token:        "a",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close(0, "done")
+
+	prober := newPMTUProber(client, client.UDPConn.LocalAddr().(*net.UDPAddr), addr)
+	go prober.receive()
+
+	deadline := time.After(10 * time.Second)
+	for {
+		size, grown := prober.acknowledged()
+		if size > quicPacketSizeIPv4 {
+			break
+		}
+		select {
+		case <-grown:
+		case <-time.After(100 * time.Millisecond):
+		case <-deadline:
+			t.Fatalf("packet size not raised by QUIC: %d", size)
+		}
+	}
+
+	mtu, err := prober.search()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mtu <= MinPathMTU {
+		t.Fatalf("path mtu %d, want above %d", mtu, MinPathMTU)
+	}
+	if size := prober.maxDatagram(); size <= quicPacketSizeIPv4-QuicPacketOverhead-datagramFrameOverhead {
+		t.Fatalf("largest datagram %d not raised", size)
+	}
+}
@@ -0,0 +1,126 @@
+package p2pc
+
+import (
+	"sort"
+	"time"
+
+	"github.com/supergiant-hq/xnet/p2p"
+)
+
+const (
+	// Minimum interval between checks for idle connections
+	minIdleCheckInterval = time.Second
+)
+
+// Connection attempt shared by concurrent calls of GetOrConnect for a peer
+type pendingConnection struct {
+	done chan bool
+	conn *Connection
+	err  error
+}
+
+// Get a connected Connection to a peer, or connect to it if there is none
+// Connections initiated by the peer are reused as well, those in the requested mode are preferred
+// Concurrent calls for the same peer share a single connection attempt
+func (m *Manager) GetOrConnect(peerId string, mode p2p.ConnectionMode) (conn *Connection, err error) {
+	if conn = m.reusableConnection(peerId, mode); conn != nil {
+		return
+	}
+
+	rpending, loaded := m.pending.LoadOrStore(peerId, &pendingConnection{done: make(chan bool)})
+	pending := rpending.(*pendingConnection)
+	if loaded {
+		<-pending.done
+		if pending.err == nil {
+			pending.conn.touch()
+		}
+		return pending.conn, pending.err
+	}
+
+	defer func() {
+		pending.conn, pending.err = conn, err
+		m.pending.Delete(peerId)
+		close(pending.done)
+	}()
+
+	// The peer may have connected meanwhile
+	if conn = m.reusableConnection(peerId, mode); conn != nil {
+		return
+	}
+	return m.ConnectById(peerId, mode)
+}
+
+// Connected Connections to a peer, most recently used first
+// Connections initiated by the peer are included
+func (m *Manager) PeerConnections(peerId string) (conns []*Connection) {
+	m.conns.Range(func(k, v interface{}) bool {
+		conn := v.(*Connection)
+		if s := conn.snapshot(); s.peer.id == peerId && !s.closed && s.connected() {
+			conns = append(conns, conn)
+		}
+		return true
+	})
+
+	active := map[*Connection]time.Time{}
+	for _, conn := range conns {
+		active[conn] = conn.lastActive()
+	}
+	sort.SliceStable(conns, func(i, j int) bool {
+		return active[conns[i]].After(active[conns[j]])
+	})
+	return
+}
+
+func (m *Manager) reusableConnection(peerId string, mode p2p.ConnectionMode) (conn *Connection) {
+	conns := m.PeerConnections(peerId)
+	for _, c := range conns {
+		if c.snapshot().mode == mode {
+			conn = c
+			break
+		}
+	}
+	if conn == nil && len(conns) > 0 {
+		conn = conns[0]
+	}
+
+	if conn != nil {
+		conn.touch()
+		m.log.Debugf("Reusing connection: %s", conn.String())
+	}
+	return
+}
+
+// Close the least recently used connections to a peer exceeding Config.MaxConnectionsPerPeer
+func (m *Manager) limitConnections(peerId string) {
+	if m.config.MaxConnectionsPerPeer <= 0 {
+		return
+	}
+
+	conns := m.PeerConnections(peerId)
+	for i := m.config.MaxConnectionsPerPeer; i < len(conns); i++ {
+		m.CloseConnection(conns[i].Id(), "Connection limit of peer exceeded")
+	}
+}
+
+// Close connections idle for longer than Config.IdleTimeout
+func (m *Manager) expireLoop() {
+	interval := m.config.IdleTimeout / 4
+	if interval < minIdleCheckInterval {
+		interval = minIdleCheckInterval
+	}
+
+	for {
+		<-time.After(interval)
+		if m.client.Closed {
+			return
+		}
+
+		m.conns.Range(func(k, v interface{}) bool {
+			conn := v.(*Connection)
+			if conn.IsConnected() && time.Since(conn.lastActive()) > m.config.IdleTimeout {
+				m.CloseConnection(conn.Id(), "Idle")
+			}
+			return true
+		})
+	}
+}
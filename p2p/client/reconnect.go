@@ -0,0 +1,243 @@
+package p2pc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/supergiant-hq/xnet/model"
+	"github.com/supergiant-hq/xnet/p2p"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Default delay before the first reconnect attempt
+	ReconnectMinBackoff = time.Second
+	// Default maximum delay between reconnect attempts
+	ReconnectMaxBackoff = 30 * time.Second
+)
+
+// Policy re-establishing a Connection whose connection with the peer dropped
+type ReconnectPolicy struct {
+	// Attempts before the Connection is closed, unlimited if 0
+	MaxAttempts int
+	// Delay before the first attempt, doubled after every failed attempt, ReconnectMinBackoff if 0
+	MinBackoff time.Duration
+	// Maximum delay between attempts, ReconnectMaxBackoff if 0
+	MaxBackoff time.Duration
+	// Modes tried in order on every attempt, the current mode of the Connection if empty
+	// Direct connections are re-established directly in p2p mode, other modes require the Broker except routed
+	Modes []p2p.ConnectionMode
+}
+
+func (p *ReconnectPolicy) init() {
+	if p.MinBackoff <= 0 {
+		p.MinBackoff = ReconnectMinBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = ReconnectMaxBackoff
+	}
+	if p.MaxBackoff < p.MinBackoff {
+		p.MaxBackoff = p.MinBackoff
+	}
+}
+
+// Re-establish the Connection with the policy when the connection with the peer drops, nil disables it
+// Only the initiator of a Connection re-establishes it, the Connection keeps its identity while the ID changes
+// The peer sees the re-established connection as a new Connection, streams have to be opened again
+func (c *Connection) SetReconnectPolicy(policy *ReconnectPolicy) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if policy == nil {
+		c.reconnectPolicy = nil
+		return
+	}
+	p := *policy
+	p.Modes = append([]p2p.ConnectionMode{}, policy.Modes...)
+	p.init()
+	c.reconnectPolicy = &p
+}
+
+// Connection State
+func (c *Connection) State() p2p.ConnectionState {
+	c.emutex.Lock()
+	defer c.emutex.Unlock()
+
+	return c.state
+}
+
+// Change the state and notify the ConnectionStateHandler
+// The handler is called in the order of the changes
+func (c *Connection) setState(state p2p.ConnectionState) {
+	c.emutex.Lock()
+	defer c.emutex.Unlock()
+
+	if c.state == state {
+		return
+	}
+	c.state = state
+
+	if c.mgr.connectionStateHandler == nil {
+		return
+	}
+	c.events = append(c.events, state)
+	if !c.dispatching {
+		c.dispatching = true
+		go c.dispatchStates()
+	}
+}
+
+func (c *Connection) dispatchStates() {
+	for {
+		c.emutex.Lock()
+		if len(c.events) == 0 {
+			c.dispatching = false
+			c.emutex.Unlock()
+			return
+		}
+		state := c.events[0]
+		c.events = c.events[1:]
+		c.emutex.Unlock()
+
+		c.mgr.connectionStateHandler(c, state)
+	}
+}
+
+// Wait for the connection with the peer to exit
+// A lost direct path is replaced by a bonded relayed path, otherwise
+// the Connection is re-established according to its ReconnectPolicy or closed
+func (c *Connection) awaitExit(exit chan bool) {
+	<-exit
+
+	if c.adoptRelayPath(func(conn *p2pConn) bool { return conn.exit == exit }) {
+		return
+	}
+
+	c.mutex.Lock()
+	// The connection was replaced meanwhile
+	if !c.Closed && !c.current(exit) {
+		c.mutex.Unlock()
+		return
+	}
+	if c.Closed || c.reconnectPolicy == nil || !c.initiator {
+		c.mutex.Unlock()
+		c.Close("Exited")
+		return
+	}
+	policy := *c.reconnectPolicy
+	if len(policy.Modes) == 0 {
+		policy.Modes = []p2p.ConnectionMode{c.mode}
+	}
+	c.closeConns()
+	c.mutex.Unlock()
+
+	c.logger().Warnln("Connection with peer dropped, reconnecting...")
+	c.setState(p2p.ConnectionStateConnecting)
+	go c.reconnect(policy)
+}
+
+// If the Connection was closed
+func (c *Connection) closed() bool {
+	return c.snapshot().closed
+}
+
+func (c *Connection) reconnect(policy ReconnectPolicy) {
+	backoff := policy.MinBackoff
+	for attempt := 1; policy.MaxAttempts <= 0 || attempt <= policy.MaxAttempts; attempt++ {
+		<-time.After(backoff)
+		if c.closed() {
+			return
+		}
+
+		for _, mode := range policy.Modes {
+			err := c.reestablish(mode)
+			if err == nil {
+				c.logger().Infof("Reconnected to peer in mode(%v) after attempt(%d)", mode, attempt)
+				return
+			}
+			c.logger().Warnf("Reconnect attempt(%d) in mode(%v) failed: %s", attempt, mode, err.Error())
+			if c.closed() {
+				return
+			}
+		}
+
+		if backoff *= 2; backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+
+	c.mgr.CloseConnection(c.Id(), "Reconnect attempts exhausted")
+}
+
+// Connect to the peer again in a mode, keeping the Connection
+func (c *Connection) reestablish(mode p2p.ConnectionMode) (err error) {
+	m := c.mgr
+	c.mutex.Lock()
+	current, wasDirect := c.peer, c.direct
+	c.mutex.Unlock()
+
+	var id, relayAddr string
+	var p *peer
+	direct := false
+
+	switch {
+	case mode == p2p.ConnectionModeRouted:
+		if !m.routable(current.id) {
+			err = fmt.Errorf("no route to peer (%s)", current.id)
+			return
+		}
+		id = uuid.New().String()
+		p = &peer{
+			id:    current.id,
+			key:   current.key,
+			addr:  routeAddr(current.id),
+			addrs: current.addrs,
+		}
+
+	case wasDirect && mode == p2p.ConnectionModeP2P:
+		id, p, direct = uuid.New().String(), current, true
+
+	default:
+		if !m.client.Connected {
+			err = fmt.Errorf("broker not connected")
+			return
+		}
+		var connData *model.P2PConnectionData
+		if connData, relayAddr, err = requestConnection(m, current.id, mode, c.metadata, nil); err != nil {
+			return
+		}
+		if p, err = newPeer(m, connData.Peer); err != nil {
+			return
+		}
+		id = connData.Id
+	}
+
+	previousId, err := c.replace(id, mode, p, relayAddr, direct)
+	if err != nil {
+		return
+	}
+	m.conns.Delete(previousId)
+	m.conns.Store(id, c)
+
+	return c.connect()
+}
+
+// Close the paths of the Connection and replace its identity and mode
+// Readers see either the previous or the new identity through the snapshot
+func (c *Connection) replace(id string, mode p2p.ConnectionMode, p *peer, relayAddr string, direct bool) (previousId string, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.Closed {
+		err = fmt.Errorf("connection closed")
+		return
+	}
+	c.closeConns()
+	previousId = c.id
+	c.update(func() {
+		c.id, c.mode, c.peer, c.relayAddr, c.direct = id, mode, p, relayAddr, direct
+		c.log = c.mgr.log.Logger.WithField("prefix", fmt.Sprintf("P2P-CONN-%s", id))
+	})
+	return
+}
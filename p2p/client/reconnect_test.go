@@ -0,0 +1,90 @@
+package p2pc
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/supergiant-hq/xnet/p2p"
+	"github.com/supergiant-hq/xnet/udp"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The Connection is re-established in another mode while streams are opened and its state is read
+// Run with -race to detect readers of the replaced identity and paths
+func TestReplaceWhileOpeningStreams(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	m := newTestManager(Config{})
+	m.log = log.WithField("prefix", "P2P-MANAGER")
+	c := &Connection{
+		mgr:       m,
+		initiator: true,
+		id:        "conn-0",
+		mode:      p2p.ConnectionModeP2P,
+		peer:      &peer{id: "b"},
+		Exit:      make(chan bool, 1),
+		log:       m.log,
+	}
+	c.p2pConn = c.newP2PConn()
+	m.conns.Store(c.id, c)
+
+	done := make(chan error)
+	go func() {
+		defer close(done)
+		for i := 1; i <= 2000; i++ {
+			id, mode := fmt.Sprintf("conn-%d", i), p2p.ConnectionModeP2P
+			if i%2 == 1 {
+				mode = p2p.ConnectionModeRelay
+			}
+			previousId, err := c.replace(id, mode, &peer{id: "b"}, "127.0.0.1:1", false)
+			if err != nil {
+				done <- err
+				return
+			}
+			m.conns.Delete(previousId)
+			m.conns.Store(id, c)
+
+			// The path of the mode is created while connecting
+			c.mutex.Lock()
+			if mode == p2p.ConnectionModeP2P {
+				conn := c.newP2PConn()
+				c.update(func() { c.p2pConn = conn })
+			} else if conn, err := c.newRelayConn(id, "127.0.0.1:1"); err == nil {
+				c.update(func() { c.relayConn = conn })
+			}
+			c.mutex.Unlock()
+		}
+	}()
+
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id := c.Id(); id != "conn-2000" {
+				t.Fatalf("id %s, want conn-2000", id)
+			}
+			return
+		default:
+		}
+
+		if _, err := c.OpenStream(nil); err != udp.ErrorNotConnected {
+			t.Fatalf("open stream error %v, want %v", err, udp.ErrorNotConnected)
+		}
+		if err := c.SendDatagram([]byte("a")); err == nil {
+			t.Fatal("datagram sent without a connected path")
+		}
+		if stats := c.Stats(); stats.PeerId != "b" || stats.Connected {
+			t.Fatalf("stats of peer(%s) connected(%v)", stats.PeerId, stats.Connected)
+		}
+		if c.PeerId() != "b" || c.IsConnected() || c.PathMTU() != MinPathMTU {
+			t.Fatal("connection state changed")
+		}
+		m.PeerConnections("b")
+		_ = c.String()
+	}
+}
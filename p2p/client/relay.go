@@ -23,28 +23,33 @@ const (
 
 // Relay Connection
 type relayConn struct {
-	conn   *Connection
+	conn *Connection
+	// ID of the relayed connection, the ID of the Connection unless it is a bonded path
+	id     string
 	addr   *net.UDPAddr
 	client *udpc.Client
 
-	connected bool
-	exit      chan bool
-	closed    bool
-	mutex     sync.Mutex
-	log       *logrus.Entry
+	pathCounters
+	pathStatus
+
+	exit   chan bool
+	closed bool
+	mutex  sync.Mutex
+	log    *logrus.Entry
 }
 
-func (c *Connection) newRelayConn() (conn *relayConn, err error) {
-	addr, err := net.ResolveUDPAddr("udp", c.relayAddr)
+func (c *Connection) newRelayConn(id string, relayAddr string) (conn *relayConn, err error) {
+	addr, err := net.ResolveUDPAddr("udp", relayAddr)
 	if err != nil {
 		return
 	}
 
 	conn = &relayConn{
 		conn: c,
+		id:   id,
 		addr: addr,
 		exit: make(chan bool, 1),
-		log:  c.log,
+		log:  c.logger(),
 	}
 	return
 }
@@ -67,21 +72,23 @@ func (c *relayConn) connect() (err error) {
 }
 
 func (c *relayConn) connectRelayServer() (err error) {
-	c.log.Infoln("Connecting to relay: ", c.conn.relayAddr)
+	c.log.Infoln("Connecting to relay: ", c.addr.String())
 	client, err := udpc.New(
 		c.log.Logger,
 		udpc.Config{
-			Tag:            fmt.Sprintf("RELAY-%s", c.conn.id),
+			Tag:            fmt.Sprintf("RELAY-%s", c.id),
 			ServerAddr:     c.addr,
 			ConnectTries:   RELAY_CONNECT_TRIES,
 			ReconnectTries: RELAY_RECONNECT_TRIES,
 
+			Datagrams: true,
+
 			TLS:  c.conn.mgr.client.Cfg.TLS.Clone(),
 			Quic: c.conn.mgr.client.Cfg.Quic.Clone(),
 
 			Token: c.conn.mgr.client.Cfg.Token,
 			Data: map[string]string{
-				p2p.KEY_CONNECTION_ID: c.conn.id,
+				p2p.KEY_CONNECTION_ID: c.id,
 			},
 			Unmarshaler: c.conn.mgr.client.Cfg.Unmarshaler,
 		},
@@ -115,7 +122,8 @@ func (c *relayConn) awaitPeer() (err error) {
 	}
 	c.log.Infoln("Peer connected to relay")
 
-	c.connected = true
+	c.setConnected(true)
+	go c.receiveDatagrams(c.client)
 
 	return
 }
@@ -124,7 +132,7 @@ func (c *relayConn) openStream(metadata map[string]string, data map[string]strin
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	if !c.connected {
+	if !c.isConnected() {
 		err = udp.ErrorNotConnected
 		return
 	}
@@ -151,6 +159,17 @@ func (c *relayConn) openStream(metadata map[string]string, data map[string]strin
 	return c.client.GetStream(streamInfo.Id)
 }
 
+// Client of the session with the relay and the address of the relay
+func (c *relayConn) path() (client udp.Client, addr *net.UDPAddr) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.client != nil {
+		return c.client, c.addr
+	}
+	return
+}
+
 func (c *relayConn) close() {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
@@ -164,10 +183,22 @@ func (c *relayConn) close() {
 		c.client = nil
 	}
 
-	select {
-	case c.exit <- true:
-	default:
-	}
-	c.connected = false
+	close(c.exit)
+	c.setConnected(false)
 	c.closed = true
 }
+
+// Receive Datagrams the relay forwards from the peer until the session is closed
+func (c *relayConn) receiveDatagrams(client *udpc.Client) {
+	if !client.DatagramsSupported() {
+		return
+	}
+
+	for {
+		b, err := client.ReceiveDatagram()
+		if err != nil {
+			return
+		}
+		c.conn.receiveDatagram(&c.pathCounters, b)
+	}
+}
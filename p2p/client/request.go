@@ -0,0 +1,78 @@
+package p2pc
+
+import (
+	"fmt"
+
+	"github.com/supergiant-hq/xnet/model"
+	"github.com/supergiant-hq/xnet/p2p"
+	udpc "github.com/supergiant-hq/xnet/udp/client"
+)
+
+// Incoming connection request, forwarded by the Broker or received directly from the Peer
+type ConnectionRequest struct {
+	// Connection ID
+	Id string
+	// Connection Mode
+	Mode p2p.ConnectionMode
+	// ID of the requesting Peer
+	PeerId string
+	// Tags the Broker assigned to the requesting Peer, empty on direct and routed connections
+	PeerTags map[string]string
+	// Application data passed to ConnectByIdWithMetadata by the Peer
+	Metadata map[string]string
+}
+
+// Called on incoming connection requests, a non-nil error rejects the request with its message as the reason
+// It is called while the Broker or the Peer awaits the reply (p2p.RequestTimeout) and should return quickly
+type ConnectionRequestHandler func(req *ConnectionRequest) error
+
+// Error returned when the Peer rejected a connection request
+type ConnectionRejectedError struct {
+	// ID of the Peer
+	PeerId string
+	// Reason returned by the ConnectionRequestHandler of the Peer
+	Reason string
+}
+
+func (e *ConnectionRejectedError) Error() string {
+	return fmt.Sprintf("connection rejected by peer (%s): %s", e.PeerId, e.Reason)
+}
+
+// Set Connection Request Handler
+// Requests are accepted automatically if it is not set
+// It is called on connections requested through the Broker, directly (LAN, DHT, ConnectDirect) and over routes,
+// bonded paths of accepted connections are not passed to it
+func (m *Manager) SetConnectionRequestHandler(handler ConnectionRequestHandler) {
+	m.requestHandler = handler
+}
+
+// Pass a verified direct or routed connection request to the ConnectionRequestHandler
+// A rejected request returns the Client Data telling the Peer the reason
+func (m *Manager) checkDirectRequest(req *ConnectionRequest) (cdata *model.ClientData, err error) {
+	if m.requestHandler == nil {
+		return
+	}
+	if err = m.requestHandler(req); err != nil {
+		m.log.Warnf("Rejected connection request from peer id(%s): %s", req.PeerId, err.Error())
+		cdata = &model.ClientData{
+			Data: map[string]string{p2p.KEY_DIRECT_REJECTED: err.Error()},
+		}
+	}
+	return
+}
+
+// Error of a direct or routed connection request the Peer rejected, nil if it was not rejected
+func directRejection(peerId string, client *udpc.Client) error {
+	if client.Data == nil || client.Data.Status {
+		return nil
+	}
+	if reason, ok := client.Data.Data[p2p.KEY_DIRECT_REJECTED]; ok {
+		return &ConnectionRejectedError{PeerId: peerId, Reason: reason}
+	}
+	return nil
+}
+
+// Application data the connection was requested with
+func (c *Connection) Metadata() map[string]string {
+	return c.metadata
+}
@@ -0,0 +1,364 @@
+package p2pc
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/supergiant-hq/xnet/model"
+	"github.com/supergiant-hq/xnet/network"
+	"github.com/supergiant-hq/xnet/p2p"
+	"github.com/supergiant-hq/xnet/udp"
+	udpc "github.com/supergiant-hq/xnet/udp/client"
+	udps "github.com/supergiant-hq/xnet/udp/server"
+
+	"github.com/google/uuid"
+	"github.com/lucas-clemente/quic-go"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// Label of the TLS keying material the credentials of routed connections are bound to
+	routedBindingLabel = "xnet routed connection"
+)
+
+// Routed Connection
+// A QUIC session with the peer whose packets are forwarded by the intermediate peers
+type routedConn struct {
+	conn   *Connection
+	router *Router
+
+	localClient      *udpc.Client
+	remoteClientChan chan *udps.Client
+	remoteClient     *udps.Client
+
+	pathCounters
+	pathStatus
+
+	exit   chan bool
+	closed bool
+	mutex  sync.Mutex
+	log    *logrus.Entry
+}
+
+func (c *Connection) newRoutedConn() (conn *routedConn, err error) {
+	router, err := c.mgr.getRouter()
+	if err != nil {
+		return
+	}
+
+	conn = &routedConn{
+		conn:   c,
+		router: router,
+
+		remoteClientChan: make(chan *udps.Client, 1),
+		exit:             make(chan bool, 1),
+		log:              c.logger(),
+	}
+	return
+}
+
+// Value binding the credentials of the peers to the TLS session
+// Intermediate peers relaying the handshake between two sessions of their own cannot reuse the credentials
+func routedBinding(state quic.ConnectionState) (binding string, err error) {
+	return sessionBinding(state, routedBindingLabel)
+}
+
+func (c *routedConn) connect() (err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.conn.initiator {
+		return c.awaitRemoteConnection()
+	}
+
+	mgr := c.conn.mgr
+	client, err := udpc.NewWithPacketConn(
+		c.log.Logger,
+		udpc.Config{
+			Tag:          fmt.Sprintf("ROUTED-%s", c.conn.id),
+			ServerAddr:   routeAddr(c.conn.peer.id),
+			ConnectTries: P2P_CONNECT_TRIES,
+
+			TLS:  mgr.client.Cfg.TLS.Clone(),
+			Quic: mgr.client.Cfg.Quic.Clone(),
+
+			Token:       c.conn.id,
+			Unmarshaler: mgr.client.Cfg.Unmarshaler,
+		},
+		routeAddr(mgr.localId()),
+		c.router.conn,
+	)
+	if err != nil {
+		return
+	}
+
+	// The session is not resumed, the credentials are bound to it
+	client.SetCanReconnectHandler(func(tries int) bool {
+		return false
+	})
+	client.SetSessionDataHandler(func(state quic.ConnectionState) (data map[string]string, err error) {
+		binding, err := routedBinding(state)
+		if err != nil {
+			return
+		}
+		return mgr.directRequestData(c.conn.peer.id, c.conn.id, c.conn.metadata, binding)
+	})
+
+	if err = client.Connect(); err != nil {
+		if rerr := directRejection(c.conn.peer.id, client); rerr != nil {
+			err = rerr
+		}
+		return
+	}
+	if err = c.verifyAccept(client); err != nil {
+		client.Close(0, err.Error())
+		return
+	}
+	if err = c.initClient(client); err != nil {
+		client.Close(0, err.Error())
+		return
+	}
+
+	go func() {
+		<-client.Exit
+		c.close()
+	}()
+
+	return
+}
+
+// Verify that the peer accepted the connection over this session
+func (c *routedConn) verifyAccept(client *udpc.Client) (err error) {
+	state, err := client.ConnectionState()
+	if err != nil {
+		return
+	}
+	binding, err := routedBinding(state)
+	if err != nil {
+		return
+	}
+	return c.conn.mgr.verifyDirectAccept(c.conn.peer, c.conn.id, client.Data.Data, binding)
+}
+
+func (c *routedConn) initClient(client *udpc.Client) (err error) {
+	msg := network.NewMessageWithAck(
+		model.MessageTypeP2PClientInit,
+		&model.NoDataMessage{},
+		p2p.RequestTimeout,
+	)
+	rmsg, err := client.Send(msg)
+	if err != nil {
+		return
+	}
+
+	connStatus := rmsg.Body.(*model.P2PConnectionStatus)
+	if !connStatus.Status {
+		err = fmt.Errorf(connStatus.Message)
+		return
+	}
+
+	client.SetStreamHandler(c.conn.mgr.incomingStreamHandler)
+
+	c.localClient = client
+	c.setConnected(true)
+
+	return
+}
+
+func (c *routedConn) awaitRemoteConnection() (err error) {
+	select {
+	case remoteClient := <-c.remoteClientChan:
+		c.remoteClient = remoteClient
+		c.setConnected(true)
+
+	case <-time.After(p2p.ConnectionTimeout):
+		err = fmt.Errorf("awaiting for peer timedout")
+	}
+
+	return
+}
+
+func (c *routedConn) checkinRemoteClient(client *udps.Client) (err error) {
+	if c.isConnected() {
+		err = fmt.Errorf("connection already open")
+		return
+	}
+
+	select {
+	case c.remoteClientChan <- client:
+	default:
+		err = fmt.Errorf("another client is already connected")
+	}
+
+	return
+}
+
+func (c *routedConn) openStream(metadata map[string]string, data map[string]string) (stream *udp.Stream, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.isConnected() {
+		err = udp.ErrorNotConnected
+		return
+	}
+
+	if c.conn.initiator {
+		return c.localClient.OpenStream(metadata, data)
+	}
+	return c.remoteClient.OpenStream(metadata, data)
+}
+
+// Client of the session with the peer and the route address of the peer
+func (c *routedConn) path() (client udp.Client, addr *net.UDPAddr) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.localClient != nil {
+		return c.localClient, c.localClient.Cfg.ServerAddr
+	} else if c.remoteClient != nil {
+		return c.remoteClient, c.remoteClient.Addr
+	}
+	return
+}
+
+func (c *routedConn) close() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	if c.localClient != nil {
+		c.localClient.Close(0, "Closed")
+		c.localClient = nil
+	}
+
+	if c.remoteClient != nil {
+		c.remoteClient.Close(0, "Closed")
+		c.remoteClient = nil
+	}
+
+	select {
+	case c.exit <- true:
+	default:
+	}
+	c.setConnected(false)
+	c.closed = true
+}
+
+// Connect to a peer over the route with the lowest latency
+// The connection is encrypted end-to-end and both peers authenticate each other with their keys
+// (Config.PrivateKey) or the pre-shared key (Config.PreSharedKey), as on direct connections
+func (m *Manager) ConnectRouted(peerId string) (conn *Connection, err error) {
+	return m.connectRouted(peerId, nil)
+}
+
+func (m *Manager) connectRouted(peerId string, metadata map[string]string) (conn *Connection, err error) {
+	if !m.routable(peerId) {
+		err = fmt.Errorf("no route to peer (%s)", peerId)
+		return
+	}
+
+	id := uuid.New().String()
+	conn = &Connection{
+		mgr:      m,
+		ClientId: m.localId(),
+
+		initiator: true,
+		id:        id,
+		mode:      p2p.ConnectionModeRouted,
+		peer: &peer{
+			id:   peerId,
+			addr: routeAddr(peerId),
+		},
+		metadata: metadata,
+
+		Exit: make(chan bool, 1),
+		log:  m.log.Logger.WithField("prefix", fmt.Sprintf("P2P-CONN-%s", id)),
+	}
+
+	m.log.Infof("Connecting to peer id(%s) over route...", peerId)
+	if err = conn.connect(); err != nil {
+		conn.Close(err.Error())
+		return
+	}
+	m.conns.Store(conn.id, conn)
+
+	m.log.Infof("Created connection: %s", conn.String())
+	return
+}
+
+// Validate a routed connection request of a peer
+// The credentials of the peer have to be bound to the QUIC session it was received on
+func (m *Manager) routedValidateHandler(c *udps.Client, data *model.ClientValidateData) (cdata *model.ClientData, err error) {
+	binding, err := routedBinding(c.ConnectionState())
+	if err != nil {
+		return
+	}
+	peerId, metadata, err := m.verifyDirectRequest(data.Token, data.Data, binding)
+	if err != nil {
+		return
+	}
+	if peerId != c.Addr.Zone {
+		err = fmt.Errorf("routed connection request of another client")
+		return
+	}
+	if cdata, err = m.checkDirectRequest(&ConnectionRequest{
+		Id:       data.Token,
+		Mode:     p2p.ConnectionModeRouted,
+		PeerId:   peerId,
+		Metadata: metadata,
+	}); err != nil {
+		return
+	}
+
+	conn := &Connection{
+		mgr:      m,
+		ClientId: m.localId(),
+
+		initiator: false,
+		id:        data.Token,
+		mode:      p2p.ConnectionModeRouted,
+		peer: &peer{
+			id:   peerId,
+			addr: c.Addr,
+		},
+		metadata: metadata,
+
+		Exit: make(chan bool, 1),
+		log:  m.log.Logger.WithField("prefix", fmt.Sprintf("P2P-CONN-%s", data.Token)),
+	}
+	// The peer checks in right after validation, the routed connection has to be ready for it
+	if conn.routedConn, err = conn.newRoutedConn(); err != nil {
+		return
+	}
+	if _, loaded := m.conns.LoadOrStore(conn.id, conn); loaded {
+		err = fmt.Errorf("connection with id (%s) exists", conn.id)
+		return
+	}
+	m.log.Infof("Accepted routed connection request: %s", conn.String())
+
+	go func() {
+		if err := conn.connect(); err != nil {
+			m.log.Errorf("Error waiting for peer connection: %s", err.Error())
+			m.CloseConnection(conn.id, err.Error())
+		}
+	}()
+
+	cdata = &model.ClientData{
+		Id:      fmt.Sprintf("%s:%s", conn.id, peerId),
+		Address: c.Addr.String(),
+		Data:    m.directAcceptData(peerId, conn.id, binding),
+		Ctx: &model.ClientData_P2PCtx{
+			P2PCtx: &model.P2PClientContext{
+				ConnId: conn.id,
+				PeerId: peerId,
+				Active: false,
+			},
+		},
+	}
+	return
+}
@@ -0,0 +1,675 @@
+package p2pc
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/supergiant-hq/xnet/model"
+	"github.com/supergiant-hq/xnet/p2p"
+	"github.com/supergiant-hq/xnet/udp"
+	udps "github.com/supergiant-hq/xnet/udp/server"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// Default interval between route updates and latency probes
+	RoutingInterval = 5 * time.Second
+	// Default maximum no. of links along a route
+	RoutingMaxHops = 8
+
+	// Metric of unreachable peers
+	routeMetricInfinity = math.MaxUint32
+	// Maximum size of a frame on a route stream
+	maxRouteFrameSize = 1<<16 - 1
+	// Maximum no. of routes sent in a single update
+	maxRouteUpdateSize = 512
+	// Packets received for routed connections waiting to be read
+	routePacketQueue = 1024
+)
+
+const (
+	routeFrameUpdate uint32 = iota + 1
+	routeFramePing
+	routeFramePong
+	routeFramePacket
+)
+
+var (
+	// Address prefix of the peers on routed connections (RFC 6666 discard prefix)
+	// The zone of the address holds the Client ID of the peer
+	routeIP = net.ParseIP("100::")
+)
+
+// Routing Config
+type RoutingConfig struct {
+	// Interval between route updates and latency probes, RoutingInterval if 0
+	Interval time.Duration
+	// Maximum no. of links along a route, RoutingMaxHops if 0
+	MaxHops int
+	// Routes which are not updated are removed after this duration, 3 intervals if 0
+	Expiry time.Duration
+}
+
+// Route to a peer
+type PeerRoute struct {
+	// Client ID of the peer
+	PeerId string
+	// Client ID of the neighbour forwarding the packets to the peer
+	NextHop string
+	// Sum of the round trip times of the links along the route
+	Latency time.Duration
+	// No. of links along the route
+	Hops int
+}
+
+type route struct {
+	nextHop string
+	// Latency in microseconds
+	metric  uint32
+	hops    uint32
+	updated time.Time
+}
+
+// Peer connected to the Manager exchanging routes
+type neighbour struct {
+	peerId string
+	conn   *Connection
+	stream *udp.Stream
+	reader *bufio.Reader
+	// Smoothed round trip time of the link, 0 until measured
+	rtt    time.Duration
+	wmutex sync.Mutex
+}
+
+// Multi-hop Router
+// Exchanges routes with the peers connected to the Manager (neighbours) using a distance-vector protocol
+// with the latency of the links as metric, and forwards the packets of routed connections along them
+// Routed connections are QUIC sessions between their peers, intermediate peers only forward encrypted packets
+type Router struct {
+	mgr    *Manager
+	config RoutingConfig
+	conn   *routeConn
+	server *udps.Server
+
+	neighbours map[string]*neighbour
+	routes     map[string]*route
+	rmutex     sync.RWMutex
+
+	// Exit Channel
+	Exit chan bool
+	// Closed Status
+	Closed bool
+	mutex  sync.Mutex
+	log    *logrus.Entry
+}
+
+// Start routing
+// Routes are exchanged over the connections opened by the Manager, both peers must have routing running
+// Requires Config.PrivateKey or Config.PreSharedKey to authenticate routed connections
+func (m *Manager) StartRouting(config RoutingConfig) (r *Router, err error) {
+	if !m.directEnabled() {
+		err = fmt.Errorf("routing requires a private key or a pre-shared key")
+		return
+	}
+	if config.Interval == 0 {
+		config.Interval = RoutingInterval
+	}
+	if config.MaxHops == 0 {
+		config.MaxHops = RoutingMaxHops
+	}
+	if config.Expiry == 0 {
+		config.Expiry = 3 * config.Interval
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.router != nil {
+		err = fmt.Errorf("routing already running")
+		return
+	}
+
+	r = &Router{
+		mgr:        m,
+		config:     config,
+		neighbours: map[string]*neighbour{},
+		routes:     map[string]*route{},
+		Exit:       make(chan bool, 1),
+		log:        m.log.Logger.WithField("prefix", "P2P-ROUTER"),
+	}
+	r.conn = newRouteConn(r)
+
+	if r.server, err = udps.NewWithPacketConn(
+		m.log.Logger,
+		udps.Config{
+			Tag:         "P2P-ROUTE",
+			Addr:        routeAddr(m.localId()),
+			TLS:         m.client.Cfg.TLS.Clone(),
+			Quic:        m.client.Cfg.Quic.Clone(),
+			Unmarshaler: m.client.Cfg.Unmarshaler,
+		},
+		r.conn,
+		nil,
+	); err != nil {
+		return
+	}
+	r.server.SetClientSessionValidateHandler(m.routedValidateHandler)
+	r.server.SetClientDisconnectedHandler(m.clientDisconnectedHandler)
+	r.server.RegisterHandler(model.MessageTypeP2PClientInit, m.clientInitHandler)
+	r.server.SetStreamHandler(m.incomingStreamHandler)
+	if err = r.server.Listen(); err != nil {
+		r.conn.Close()
+		return
+	}
+
+	m.router = r
+	go r.loop()
+
+	// Connections opened before routing started
+	m.conns.Range(func(k, v interface{}) bool {
+		if conn := v.(*Connection); conn.initiator && conn.snapshot().mode != p2p.ConnectionModeRouted && conn.IsConnected() {
+			go r.connectNeighbour(conn)
+		}
+		return true
+	})
+
+	r.log.Infof("Routing started: interval(%v) max-hops(%d)", config.Interval, config.MaxHops)
+	return
+}
+
+func (m *Manager) getRouter() (r *Router, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.router == nil {
+		return nil, fmt.Errorf("routing not running")
+	}
+	return m.router, nil
+}
+
+// If a route to a peer is known
+func (m *Manager) routable(peerId string) bool {
+	r, err := m.getRouter()
+	if err != nil {
+		return false
+	}
+	_, ok := r.Route(peerId)
+	return ok
+}
+
+// Current routes
+func (m *Manager) Routes() []PeerRoute {
+	r, err := m.getRouter()
+	if err != nil {
+		return nil
+	}
+	return r.Routes()
+}
+
+// Address of a peer on routed connections
+func routeAddr(peerId string) *net.UDPAddr {
+	return &net.UDPAddr{
+		IP:   routeIP,
+		Port: 1,
+		Zone: peerId,
+	}
+}
+
+func (r *Router) loop() {
+	for {
+		select {
+		case <-time.After(r.config.Interval):
+		case <-r.Exit:
+			return
+		}
+
+		r.expire()
+
+		r.rmutex.RLock()
+		neighbours := make([]*neighbour, 0, len(r.neighbours))
+		for _, n := range r.neighbours {
+			neighbours = append(neighbours, n)
+		}
+		r.rmutex.RUnlock()
+
+		for _, n := range neighbours {
+			r.probe(n)
+			r.advertise(n)
+		}
+	}
+}
+
+// Open the route stream of a connection initiated by the Manager
+func (r *Router) connectNeighbour(conn *Connection) {
+	stream, err := conn.openRouteStream()
+	if err != nil {
+		r.log.Errorf("Error opening route stream to peer(%s): %s", conn.PeerId(), err.Error())
+		return
+	}
+	r.addNeighbour(conn, stream)
+}
+
+func (r *Router) addNeighbour(conn *Connection, stream *udp.Stream) {
+	n := &neighbour{
+		peerId: conn.PeerId(),
+		conn:   conn,
+		stream: stream,
+		reader: bufio.NewReaderSize(stream.Stream(), maxRouteFrameSize+2),
+	}
+
+	r.rmutex.Lock()
+	if r.Closed {
+		r.rmutex.Unlock()
+		stream.Close()
+		return
+	}
+	// The newest stream is used to send frames
+	// Previous streams are still read from until they are closed
+	if current, ok := r.neighbours[n.peerId]; ok {
+		n.rtt = current.rtt
+	}
+	r.neighbours[n.peerId] = n
+	r.rmutex.Unlock()
+
+	r.log.Infof("Neighbour added: peer(%s) connection(%s)", n.peerId, conn.Id())
+	go r.receive(n)
+	r.probe(n)
+}
+
+func (r *Router) removeNeighbour(n *neighbour) {
+	n.stream.Close()
+
+	r.rmutex.Lock()
+	defer r.rmutex.Unlock()
+
+	if r.neighbours[n.peerId] != n {
+		return
+	}
+	delete(r.neighbours, n.peerId)
+	for id, rt := range r.routes {
+		if rt.nextHop == n.peerId {
+			delete(r.routes, id)
+		}
+	}
+	r.log.Warnf("Neighbour removed: peer(%s)", n.peerId)
+}
+
+func (r *Router) neighbour(peerId string) (n *neighbour, ok bool) {
+	r.rmutex.RLock()
+	defer r.rmutex.RUnlock()
+
+	n, ok = r.neighbours[peerId]
+	return
+}
+
+// Send a frame to a neighbour
+func (r *Router) write(n *neighbour, frame *model.P2PRouteFrame) (err error) {
+	b, err := proto.Marshal(frame)
+	if err != nil {
+		return
+	}
+	if len(b) > maxRouteFrameSize {
+		return fmt.Errorf("route frame too large: %d", len(b))
+	}
+	b = append(make([]byte, 2, 2+len(b)), b...)
+	binary.BigEndian.PutUint16(b, uint16(len(b)-2))
+
+	n.wmutex.Lock()
+	_, err = n.stream.Stream().Write(b)
+	n.wmutex.Unlock()
+
+	if err != nil {
+		r.log.Errorf("Error writing to neighbour(%s): %s", n.peerId, err.Error())
+		go r.removeNeighbour(n)
+	}
+	return
+}
+
+func (r *Router) receive(n *neighbour) {
+	defer r.removeNeighbour(n)
+
+	buf := make([]byte, maxRouteFrameSize)
+	for {
+		if _, err := io.ReadFull(n.reader, buf[:2]); err != nil {
+			return
+		}
+		size := int(binary.BigEndian.Uint16(buf[:2]))
+		if _, err := io.ReadFull(n.reader, buf[:size]); err != nil {
+			return
+		}
+
+		frame := &model.P2PRouteFrame{}
+		if err := proto.Unmarshal(buf[:size], frame); err != nil {
+			r.log.Errorf("Invalid frame from neighbour(%s): %s", n.peerId, err.Error())
+			return
+		}
+
+		switch frame.Type {
+		case routeFramePing:
+			r.write(n, &model.P2PRouteFrame{
+				Type:      routeFramePong,
+				Timestamp: frame.Timestamp,
+			})
+
+		case routeFramePong:
+			r.measure(n, time.Since(time.Unix(0, frame.Timestamp)))
+
+		case routeFrameUpdate:
+			for _, entry := range frame.Routes {
+				r.update(n, entry.Id, entry.Metric, entry.Hops)
+			}
+
+		case routeFramePacket:
+			r.forward(frame.Source, frame.Target, frame.Ttl, frame.Payload)
+		}
+	}
+}
+
+// Measure the round trip time of a link
+func (r *Router) probe(n *neighbour) {
+	r.write(n, &model.P2PRouteFrame{
+		Type:      routeFramePing,
+		Timestamp: time.Now().UnixNano(),
+	})
+}
+
+func (r *Router) measure(n *neighbour, sample time.Duration) {
+	if sample <= 0 {
+		return
+	}
+
+	r.rmutex.Lock()
+	if n.rtt == 0 {
+		n.rtt = sample
+	} else {
+		n.rtt = (7*n.rtt + sample) / 8
+	}
+	r.rmutex.Unlock()
+
+	// The neighbour itself is reachable over the link
+	r.update(n, n.peerId, 0, 0)
+}
+
+// Process a route advertised by a neighbour
+func (r *Router) update(n *neighbour, peerId string, metric uint32, hops uint32) {
+	if peerId == r.mgr.localId() {
+		return
+	}
+
+	r.rmutex.Lock()
+	defer r.rmutex.Unlock()
+
+	// Routes are not used until the latency of the link is known
+	if n.rtt == 0 || r.neighbours[n.peerId] != n {
+		return
+	}
+
+	cost := uint64(metric) + uint64(n.rtt/time.Microsecond)
+	hops++
+	if metric == routeMetricInfinity || cost >= routeMetricInfinity || int(hops) > r.config.MaxHops {
+		cost = routeMetricInfinity
+	}
+
+	current, ok := r.routes[peerId]
+	switch {
+	case ok && current.nextHop == n.peerId:
+		// The neighbour the route goes through is authoritative for it
+		if cost == routeMetricInfinity {
+			delete(r.routes, peerId)
+			return
+		}
+		current.metric, current.hops, current.updated = uint32(cost), hops, time.Now()
+
+	case cost != routeMetricInfinity && (!ok || uint32(cost) < current.metric):
+		if !ok {
+			r.log.Infof("Route added: peer(%s) via(%s) hops(%d)", peerId, n.peerId, hops)
+		}
+		r.routes[peerId] = &route{
+			nextHop: n.peerId,
+			metric:  uint32(cost),
+			hops:    hops,
+			updated: time.Now(),
+		}
+	}
+}
+
+// Send the routes to a neighbour
+// Routes through the neighbour are advertised as unreachable (split horizon with poisoned reverse)
+func (r *Router) advertise(n *neighbour) {
+	entries := []*model.P2PRouteEntry{{Id: r.mgr.localId()}}
+
+	r.rmutex.RLock()
+	for peerId, rt := range r.routes {
+		if peerId == n.peerId {
+			continue
+		}
+		entry := &model.P2PRouteEntry{Id: peerId, Metric: rt.metric, Hops: rt.hops}
+		if rt.nextHop == n.peerId {
+			entry.Metric = routeMetricInfinity
+		}
+		entries = append(entries, entry)
+	}
+	r.rmutex.RUnlock()
+
+	for len(entries) > 0 {
+		size := len(entries)
+		if size > maxRouteUpdateSize {
+			size = maxRouteUpdateSize
+		}
+		if err := r.write(n, &model.P2PRouteFrame{
+			Type:   routeFrameUpdate,
+			Routes: entries[:size],
+		}); err != nil {
+			return
+		}
+		entries = entries[size:]
+	}
+}
+
+func (r *Router) expire() {
+	r.rmutex.Lock()
+	defer r.rmutex.Unlock()
+
+	for peerId, rt := range r.routes {
+		if time.Since(rt.updated) > r.config.Expiry {
+			delete(r.routes, peerId)
+			r.log.Warnf("Route expired: peer(%s) via(%s)", peerId, rt.nextHop)
+		}
+	}
+}
+
+// Route to a peer
+func (r *Router) Route(peerId string) (pr PeerRoute, ok bool) {
+	r.rmutex.RLock()
+	defer r.rmutex.RUnlock()
+
+	rt, ok := r.routes[peerId]
+	if !ok {
+		return
+	}
+	return rt.peerRoute(peerId), true
+}
+
+// Current routes ordered by latency
+func (r *Router) Routes() (routes []PeerRoute) {
+	r.rmutex.RLock()
+	for peerId, rt := range r.routes {
+		routes = append(routes, rt.peerRoute(peerId))
+	}
+	r.rmutex.RUnlock()
+
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Latency < routes[j].Latency
+	})
+	return
+}
+
+func (rt *route) peerRoute(peerId string) PeerRoute {
+	return PeerRoute{
+		PeerId:  peerId,
+		NextHop: rt.nextHop,
+		Latency: time.Duration(rt.metric) * time.Microsecond,
+		Hops:    int(rt.hops),
+	}
+}
+
+// Forward a packet towards its target, packets for this Client are delivered to the routed connections
+func (r *Router) forward(source string, target string, ttl uint32, payload []byte) {
+	if target == r.mgr.localId() {
+		r.conn.deliver(source, payload)
+		return
+	}
+	if ttl == 0 {
+		r.log.Debugf("Dropping packet from (%s) to (%s): ttl exceeded", source, target)
+		return
+	}
+
+	rt, ok := r.Route(target)
+	if !ok {
+		r.log.Debugf("Dropping packet from (%s) to (%s): no route", source, target)
+		return
+	}
+	n, ok := r.neighbour(rt.NextHop)
+	if !ok {
+		return
+	}
+
+	r.write(n, &model.P2PRouteFrame{
+		Type:    routeFramePacket,
+		Source:  source,
+		Target:  target,
+		Ttl:     ttl - 1,
+		Payload: payload,
+	})
+}
+
+// Send a packet of a routed connection to a peer
+func (r *Router) send(peerId string, payload []byte) {
+	r.forward(r.mgr.localId(), peerId, uint32(r.config.MaxHops), payload)
+}
+
+// Stop routing
+// Routed connections time out once their packets are no longer forwarded
+func (r *Router) Close() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.Closed {
+		return
+	}
+
+	r.server.Close(0, "Routing stopped")
+	r.conn.Close()
+
+	r.rmutex.Lock()
+	r.Closed = true
+	neighbours := r.neighbours
+	r.neighbours = map[string]*neighbour{}
+	r.routes = map[string]*route{}
+	r.rmutex.Unlock()
+
+	for _, n := range neighbours {
+		n.stream.Close()
+	}
+
+	select {
+	case r.Exit <- true:
+	default:
+	}
+
+	r.mgr.mutex.Lock()
+	if r.mgr.router == r {
+		r.mgr.router = nil
+	}
+	r.mgr.mutex.Unlock()
+
+	r.log.Warnln("Routing stopped")
+}
+
+type routePacket struct {
+	source  string
+	payload []byte
+}
+
+// Packet connection of the QUIC sessions of routed connections
+// Packets are addressed to peers by their route address (routeAddr)
+type routeConn struct {
+	router  *Router
+	packets chan routePacket
+	exit    chan bool
+	once    sync.Once
+}
+
+func newRouteConn(r *Router) *routeConn {
+	return &routeConn{
+		router:  r,
+		packets: make(chan routePacket, routePacketQueue),
+		exit:    make(chan bool),
+	}
+}
+
+// Queue a packet received from a peer, it is dropped if the queue is full
+func (c *routeConn) deliver(source string, payload []byte) {
+	select {
+	case c.packets <- routePacket{source, payload}:
+	default:
+	}
+}
+
+func (c *routeConn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
+	select {
+	case p := <-c.packets:
+		return copy(b, p.payload), routeAddr(p.source), nil
+	case <-c.exit:
+		return 0, nil, net.ErrClosed
+	}
+}
+
+// Packets without a route are dropped, as they would be on a network
+func (c *routeConn) WriteTo(b []byte, addr net.Addr) (n int, err error) {
+	raddr, ok := addr.(*net.UDPAddr)
+	if !ok || !raddr.IP.Equal(routeIP) || len(raddr.Zone) == 0 {
+		return 0, fmt.Errorf("invalid route address: %v", addr)
+	}
+
+	select {
+	case <-c.exit:
+		return 0, net.ErrClosed
+	default:
+	}
+
+	c.router.send(raddr.Zone, b)
+	return len(b), nil
+}
+
+func (c *routeConn) Close() error {
+	c.once.Do(func() {
+		close(c.exit)
+	})
+	return nil
+}
+
+func (c *routeConn) LocalAddr() net.Addr {
+	return routeAddr(c.router.mgr.localId())
+}
+
+func (c *routeConn) SetDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *routeConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *routeConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
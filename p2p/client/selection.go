@@ -0,0 +1,288 @@
+package p2pc
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/supergiant-hq/xnet/model"
+	"github.com/supergiant-hq/xnet/network"
+	"github.com/supergiant-hq/xnet/p2p"
+)
+
+// Client having a Tag, a candidate for connections by Tag
+type PeerCandidate struct {
+	// Client ID
+	Id string
+	// Address of the Client seen by the Broker, empty if found in the DHT
+	Address string
+	// No. of connections the Client reported to the Broker, 0 if unknown
+	Load uint32
+}
+
+// Orders the candidates of a connection by Tag by preference, candidates left out are not connected to
+type PeerSelector func(m *Manager, candidates []PeerCandidate) []PeerCandidate
+
+// Candidates in random order
+func SelectRandom(m *Manager, candidates []PeerCandidate) []PeerCandidate {
+	selected := append([]PeerCandidate{}, candidates...)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.rnd.Shuffle(len(selected), func(i, j int) {
+		selected[i], selected[j] = selected[j], selected[i]
+	})
+	return selected
+}
+
+// Candidates with the lowest round-trip time first
+// It is taken from the P2P connections to a candidate, otherwise its address is pinged (ICMP)
+// Candidates which could not be measured are last
+func SelectLowestRTT(m *Manager, candidates []PeerCandidate) []PeerCandidate {
+	rtts := map[string]time.Duration{}
+	unmeasured := map[string][]string{}
+	for _, candidate := range candidates {
+		if rtt := m.peerRTT(candidate.Id); rtt > 0 {
+			rtts[candidate.Id] = rtt
+			continue
+		}
+		if addr, err := net.ResolveUDPAddr("udp", candidate.Address); err == nil {
+			ip := addr.IP.String()
+			unmeasured[ip] = append(unmeasured[ip], candidate.Id)
+		}
+	}
+
+	if len(unmeasured) > 0 {
+		ips := []string{}
+		for ip := range unmeasured {
+			ips = append(ips, ip)
+		}
+		for _, res := range network.PingAddrs(ips, true).Success {
+			for _, id := range unmeasured[res.Addr] {
+				rtts[id] = res.AvgTime
+			}
+		}
+	}
+
+	selected := append([]PeerCandidate{}, candidates...)
+	sort.SliceStable(selected, func(i, j int) bool {
+		ri, iok := rtts[selected[i].Id]
+		rj, jok := rtts[selected[j].Id]
+		if iok != jok {
+			return iok
+		}
+		return ri < rj
+	})
+	return selected
+}
+
+// Candidates with the fewest connections first, as reported to the Broker
+func SelectLeastConnections(m *Manager, candidates []PeerCandidate) []PeerCandidate {
+	selected := append([]PeerCandidate{}, candidates...)
+	sort.SliceStable(selected, func(i, j int) bool {
+		return selected[i].Load < selected[j].Load
+	})
+	return selected
+}
+
+// Candidates ordered by rendezvous hashing of the key
+// A key selects the same peer as long as it has the Tag, only the keys of a peer which leaves move
+func SelectConsistentHash(key string) PeerSelector {
+	return func(m *Manager, candidates []PeerCandidate) []PeerCandidate {
+		scores := map[string]uint64{}
+		for _, candidate := range candidates {
+			sum := sha256.Sum256([]byte(key + "\x00" + candidate.Id))
+			scores[candidate.Id] = binary.BigEndian.Uint64(sum[:8])
+		}
+
+		selected := append([]PeerCandidate{}, candidates...)
+		sort.SliceStable(selected, func(i, j int) bool {
+			return scores[selected[i].Id] > scores[selected[j].Id]
+		})
+		return selected
+	}
+}
+
+// Candidates this Client is connected to first, each group ordered by next (SelectRandom if nil)
+func SelectPreferConnected(next PeerSelector) PeerSelector {
+	if next == nil {
+		next = SelectRandom
+	}
+
+	return func(m *Manager, candidates []PeerCandidate) []PeerCandidate {
+		selected := next(m, candidates)
+		connected := map[string]bool{}
+		for _, candidate := range selected {
+			connected[candidate.Id] = len(m.PeerConnections(candidate.Id)) > 0
+		}
+
+		sort.SliceStable(selected, func(i, j int) bool {
+			return connected[selected[i].Id] && !connected[selected[j].Id]
+		})
+		return selected
+	}
+}
+
+// Lowest round-trip time of the P2P connections to a peer, 0 if unknown
+func (m *Manager) peerRTT(peerId string) (rtt time.Duration) {
+	for _, conn := range m.PeerConnections(peerId) {
+		s := conn.snapshot()
+		if s.mode != p2p.ConnectionModeP2P || s.p2p == nil {
+			continue
+		}
+		if client, _ := s.p2p.path(); client != nil {
+			if r := client.RTT(); r > 0 && (rtt == 0 || r < rtt) {
+				rtt = r
+			}
+		}
+	}
+	return
+}
+
+// No. of open connections, reported to the Broker as the load of the Client
+func (m *Manager) load() (n uint32) {
+	m.conns.Range(func(k, v interface{}) bool {
+		if !v.(*Connection).closed() {
+			n++
+		}
+		return true
+	})
+	return
+}
+
+// Clients having the Tag in the order of the selector, Config.PeerSelector or SelectRandom if nil
+// If the Broker is not connected, they are looked up in the DHT for connections in p2p mode as by ConnectById
+func (m *Manager) SelectByTag(tag string, mode p2p.ConnectionMode, selector PeerSelector) (candidates []PeerCandidate, err error) {
+	if selector == nil {
+		selector = m.config.PeerSelector
+	}
+	if selector == nil {
+		selector = SelectRandom
+	}
+
+	if !m.client.Connected {
+		if _, derr := m.getDHTNode(); derr != nil || mode != p2p.ConnectionModeP2P {
+			err = fmt.Errorf("broker not connected")
+			return
+		}
+		var peerIds []string
+		if peerIds, err = m.FindByTagDHT(tag); err != nil {
+			return
+		}
+		for _, id := range peerIds {
+			candidates = append(candidates, PeerCandidate{Id: id})
+		}
+	} else {
+		var matches []*model.ClientMatch
+		if matches, err = m.client.SearchClientMatches(&model.ClientSearch{
+			Tag: tag,
+		}); err != nil {
+			return
+		}
+		for _, match := range matches {
+			if match.Id == m.client.Id {
+				continue
+			}
+			candidates = append(candidates, PeerCandidate{
+				Id:      match.Id,
+				Address: match.Address,
+				Load:    match.Load,
+			})
+		}
+	}
+
+	if candidates = selector(m, candidates); len(candidates) == 0 {
+		err = fmt.Errorf("no peers with tag (%s) found", tag)
+	}
+	return
+}
+
+// Connect to a Client having the Tag chosen by the selector, Config.PeerSelector or SelectRandom if nil
+// The next candidate is tried if connecting fails
+func (m *Manager) ConnectByTagWith(tag string, mode p2p.ConnectionMode, selector PeerSelector) (conn *Connection, err error) {
+	candidates, err := m.SelectByTag(tag, mode, selector)
+	if err != nil {
+		return
+	}
+
+	m.log.Infof("Connecting to peer tag(%s) using mode(%v)...", tag, mode)
+
+	for _, candidate := range candidates {
+		if conn, err = m.ConnectById(candidate.Id, mode); err == nil {
+			return
+		}
+		m.log.Warnf("Connecting to peer id(%s) with tag(%s) failed: %s", candidate.Id, tag, err.Error())
+	}
+	return
+}
+
+// Get a connected Connection to a Client having the Tag chosen by the selector, or connect to it
+// Candidates are tried in order as by ConnectByTagWith, connections are reused as by GetOrConnect
+func (m *Manager) GetOrConnectByTag(tag string, mode p2p.ConnectionMode, selector PeerSelector) (conn *Connection, err error) {
+	candidates, err := m.SelectByTag(tag, mode, selector)
+	if err != nil {
+		return
+	}
+
+	for _, candidate := range candidates {
+		if conn, err = m.GetOrConnect(candidate.Id, mode); err == nil {
+			return
+		}
+		m.log.Warnf("Connecting to peer id(%s) with tag(%s) failed: %s", candidate.Id, tag, err.Error())
+	}
+	return
+}
+
+// Connect to count Clients having the Tag in the order of the selector, to all of them if count is 0
+// Connections to the peers are reused as by GetOrConnect, peers which fail to connect are replaced by the next candidates
+// An error is returned if no peer could be connected to
+func (m *Manager) ConnectAllByTag(tag string, mode p2p.ConnectionMode, selector PeerSelector, count int) (conns []*Connection, err error) {
+	candidates, err := m.SelectByTag(tag, mode, selector)
+	if err != nil {
+		return
+	}
+	if count <= 0 || count > len(candidates) {
+		count = len(candidates)
+	}
+
+	m.log.Infof("Connecting to %d peers with tag(%s) using mode(%v)...", count, tag, mode)
+
+	for len(conns) < count && len(candidates) > 0 {
+		batch := candidates
+		if n := count - len(conns); n < len(batch) {
+			batch = batch[:n]
+		}
+		candidates = candidates[len(batch):]
+
+		results := make([]*Connection, len(batch))
+		errs := make([]error, len(batch))
+		wg := new(sync.WaitGroup)
+		for i, candidate := range batch {
+			wg.Add(1)
+			go func(i int, peerId string) {
+				defer wg.Done()
+				results[i], errs[i] = m.GetOrConnect(peerId, mode)
+			}(i, candidate.Id)
+		}
+		wg.Wait()
+
+		for i, candidate := range batch {
+			if errs[i] != nil {
+				m.log.Warnf("Connecting to peer id(%s) with tag(%s) failed: %s", candidate.Id, tag, errs[i].Error())
+				err = errs[i]
+				continue
+			}
+			conns = append(conns, results[i])
+		}
+	}
+
+	if len(conns) > 0 {
+		err = nil
+	}
+	return
+}
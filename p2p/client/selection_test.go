@@ -0,0 +1,222 @@
+package p2pc
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/supergiant-hq/xnet/p2p"
+	"github.com/supergiant-hq/xnet/p2p/dht"
+	udpc "github.com/supergiant-hq/xnet/udp/client"
+
+	"github.com/sirupsen/logrus"
+)
+
+func testCandidates(ids ...string) (candidates []PeerCandidate) {
+	for _, id := range ids {
+		candidates = append(candidates, PeerCandidate{Id: id})
+	}
+	return
+}
+
+func candidateIds(candidates []PeerCandidate) (ids []string) {
+	for _, candidate := range candidates {
+		ids = append(ids, candidate.Id)
+	}
+	return
+}
+
+// Adds a connected routed Connection to a peer
+func addTestConnection(m *Manager, peerId string) {
+	m.conns.Store("conn-"+peerId, &Connection{
+		id:         "conn-" + peerId,
+		mode:       p2p.ConnectionModeRouted,
+		peer:       &peer{id: peerId},
+		routedConn: &routedConn{pathStatus: connectedPath},
+	})
+}
+
+func TestPeerSelectors(t *testing.T) {
+	tests := []struct {
+		name       string
+		selector   PeerSelector
+		setup      func(m *Manager)
+		candidates []PeerCandidate
+		want       []string
+	}{
+		{
+			name:     "least connections",
+			selector: SelectLeastConnections,
+			candidates: []PeerCandidate{
+				{Id: "a", Load: 3},
+				{Id: "b", Load: 1},
+				{Id: "c", Load: 3},
+				{Id: "d", Load: 0},
+			},
+			want: []string{"d", "b", "a", "c"},
+		},
+		{
+			name:     "lowest rtt keeps unmeasured order",
+			selector: SelectLowestRTT,
+			candidates: []PeerCandidate{
+				{Id: "a", Address: "invalid"},
+				{Id: "b", Address: "invalid"},
+			},
+			want: []string{"a", "b"},
+		},
+		{
+			name:     "prefer connected",
+			selector: SelectPreferConnected(SelectLeastConnections),
+			setup: func(m *Manager) {
+				addTestConnection(m, "c")
+			},
+			candidates: []PeerCandidate{
+				{Id: "a", Load: 1},
+				{Id: "b", Load: 2},
+				{Id: "c", Load: 3},
+			},
+			want: []string{"c", "a", "b"},
+		},
+		{
+			name:       "prefer connected without connections",
+			selector:   SelectPreferConnected(SelectLeastConnections),
+			candidates: []PeerCandidate{{Id: "b", Load: 2}, {Id: "a", Load: 1}},
+			want:       []string{"a", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(Config{})
+			if tt.setup != nil {
+				tt.setup(m)
+			}
+
+			got := candidateIds(tt.selector(m, tt.candidates))
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("selected %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectRandom(t *testing.T) {
+	m := newTestManager(Config{})
+	candidates := testCandidates("a", "b", "c", "d")
+
+	first := map[string]int{}
+	for i := 0; i < 200; i++ {
+		selected := SelectRandom(m, candidates)
+		if len(selected) != len(candidates) {
+			t.Fatalf("selected %d candidates, want %d", len(selected), len(candidates))
+		}
+		first[selected[0].Id]++
+	}
+	if len(first) != len(candidates) {
+		t.Fatalf("first candidates %v, want all of them", first)
+	}
+	if got := candidateIds(candidates); strings.Join(got, ",") != "a,b,c,d" {
+		t.Fatalf("candidates changed: %v", got)
+	}
+}
+
+func TestSelectConsistentHash(t *testing.T) {
+	m := newTestManager(Config{})
+	candidates := testCandidates("a", "b", "c", "d", "e")
+
+	moved, spread := 0, map[string]bool{}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		selected := SelectConsistentHash(key)(m, candidates)[0].Id
+		spread[selected] = true
+
+		// The order of the candidates does not matter
+		reversed := []PeerCandidate{}
+		for j := len(candidates) - 1; j >= 0; j-- {
+			reversed = append(reversed, candidates[j])
+		}
+		if got := SelectConsistentHash(key)(m, reversed)[0].Id; got != selected {
+			t.Fatalf("key(%s) selected %s and %s", key, selected, got)
+		}
+
+		// Only the keys of a peer which leaves move
+		remaining := []PeerCandidate{}
+		for _, candidate := range candidates {
+			if candidate.Id != "e" {
+				remaining = append(remaining, candidate)
+			}
+		}
+		if got := SelectConsistentHash(key)(m, remaining)[0].Id; got != selected {
+			if selected != "e" {
+				t.Fatalf("key(%s) moved from %s to %s", key, selected, got)
+			}
+			moved++
+		}
+	}
+	if len(spread) < 3 {
+		t.Fatalf("keys selected only %v", spread)
+	}
+	if moved == 0 {
+		t.Fatal("no key selected the peer which left")
+	}
+}
+
+func TestSelectByTagBrokerDisconnected(t *testing.T) {
+	bpub, bkey, _ := ed25519.GenerateKey(nil)
+	_, akey, _ := ed25519.GenerateKey(nil)
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	// The record of b is published to the DHT Node of a
+	node, err := dht.New(log, dht.Config{PrivateKey: akey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := dht.NewRecord(bkey, "b", nil, map[string]string{"db": "true"}, node.ID, time.Minute)
+	if _, err := node.Publish(record); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		mode    p2p.ConnectionMode
+		dht     bool
+		wantErr bool
+	}{
+		{name: "p2p from the dht", mode: p2p.ConnectionModeP2P, dht: true},
+		{name: "relay", mode: p2p.ConnectionModeRelay, dht: true, wantErr: true},
+		{name: "routed", mode: p2p.ConnectionModeRouted, dht: true, wantErr: true},
+		{name: "p2p without dht", mode: p2p.ConnectionModeP2P, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(Config{
+				ClientId: "a",
+				PeerKeys: map[string]ed25519.PublicKey{"b": bpub},
+			})
+			m.client = &udpc.Client{}
+			if tt.dht {
+				m.dhtNode = node
+			}
+
+			candidates, err := m.SelectByTag("db", tt.mode, nil)
+			if tt.wantErr {
+				if err == nil || err.Error() != "broker not connected" {
+					t.Fatalf("err = %v, want broker not connected", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := candidateIds(candidates); len(got) != 1 || got[0] != "b" {
+				t.Fatalf("candidates %v, want [b]", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+package p2pc
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/supergiant-hq/xnet/p2p"
+	"github.com/supergiant-hq/xnet/udp"
+)
+
+// Snapshot of the state and usage of a Connection
+type ConnectionStats struct {
+	// Connection ID
+	Id string
+	// Peer ID
+	PeerId string
+	// Connection Mode
+	Mode p2p.ConnectionMode
+	// If this Client initiated the connection
+	Initiator bool
+	// If the connection was established without the Broker
+	Direct bool
+	// Connected Status
+	Connected bool
+
+	// Address of the Relay on relay connections and P2P connections with a relayed path
+	RelayAddr string
+	// If streams of the P2P connection are opened over a Relay as the direct path is degraded
+	FailedOver bool
+	// Scheduling of the traffic over the paths of P2P connections with multipath enabled, empty otherwise
+	Multipath MultipathMode
+	// Address the connection sends its packets to
+	// The address of the Peer on P2P connections, of the Relay on relay connections
+	// and the route address of the Peer on routed connections
+	RemoteAddr string
+	// Addresses of the Peer tried when connecting
+	PeerAddrs []string
+	// Next hop to the Peer on routed connections
+	NextHop string
+
+	// Smoothed round-trip time of the session carrying the connection, 0 if not measured yet
+	// On relay connections it is the round-trip time to the Relay
+	RTT time.Duration
+	// Path MTU to the Peer
+	PathMTU int
+
+	// Bytes received on the streams of the connection
+	BytesIn uint64
+	// Bytes sent on the streams of the connection
+	BytesOut uint64
+	// Number of streams opened by the Peer
+	StreamsIn int
+	// Number of streams opened by this Client
+	StreamsOut int
+	// Number of open streams
+	OpenStreams int
+
+	// Time the connection was established
+	ConnectedAt time.Time
+	// Time since the connection was established, 0 if it is not connected
+	Uptime time.Duration
+	// Time the connection was last used
+	LastActive time.Time
+
+	// Paths carrying the connection
+	Paths []PathStats
+}
+
+// Kind of a path of a Connection
+type PathKind string
+
+const (
+	PathKindDirect PathKind = "direct"
+	PathKindRelay  PathKind = "relay"
+	PathKindRouted PathKind = "routed"
+)
+
+// Snapshot of the state and usage of a path of a Connection
+type PathStats struct {
+	// Kind of path
+	Kind PathKind
+	// Address the path sends its packets to
+	RemoteAddr string
+	// Connected Status
+	Connected bool
+	// Weight of the path in weighted multipath mode, 0 otherwise
+	Weight uint32
+	// Smoothed round-trip time of the session carrying the path, 0 if not measured yet
+	RTT time.Duration
+
+	// Bytes received and sent on the streams of the path
+	BytesIn  uint64
+	BytesOut uint64
+	// Number of streams opened by the Peer and this Client over the path
+	StreamsIn  int
+	StreamsOut int
+	// Number of streams currently open over the path
+	OpenStreams int
+	// Datagrams received and sent over the path, including duplicates in redundant mode
+	DatagramsIn  uint64
+	DatagramsOut uint64
+}
+
+// Usage of a path of a Connection
+type pathCounters struct {
+	streams      []*udp.Stream
+	streamsIn    int
+	streamsOut   int
+	closedIn     uint64
+	closedOut    uint64
+	datagramsIn  uint64
+	datagramsOut uint64
+	cmutex       sync.Mutex
+}
+
+func (p *pathCounters) counters() *pathCounters {
+	return p
+}
+
+// Record a stream opened over the path
+func (p *pathCounters) track(stream *udp.Stream, incoming bool) {
+	p.cmutex.Lock()
+	defer p.cmutex.Unlock()
+
+	if incoming {
+		p.streamsIn++
+	} else {
+		p.streamsOut++
+	}
+	p.streams = append(p.streams, stream)
+}
+
+// Record a Datagram received or sent over the path
+func (p *pathCounters) countDatagram(incoming bool) {
+	p.cmutex.Lock()
+	defer p.cmutex.Unlock()
+
+	if incoming {
+		p.datagramsIn++
+	} else {
+		p.datagramsOut++
+	}
+}
+
+// Add the usage of the path to stats, forgetting closed streams
+func (p *pathCounters) collect(stats *PathStats) {
+	p.cmutex.Lock()
+	defer p.cmutex.Unlock()
+
+	streams := p.streams[:0]
+	for _, stream := range p.streams {
+		if stream.Closed {
+			p.closedIn += stream.BytesRead()
+			p.closedOut += stream.BytesWritten()
+			continue
+		}
+		streams = append(streams, stream)
+		stats.BytesIn += stream.BytesRead()
+		stats.BytesOut += stream.BytesWritten()
+	}
+	for i := len(streams); i < len(p.streams); i++ {
+		p.streams[i] = nil
+	}
+	p.streams = streams
+
+	stats.BytesIn += p.closedIn
+	stats.BytesOut += p.closedOut
+	stats.StreamsIn = p.streamsIn
+	stats.StreamsOut = p.streamsOut
+	stats.OpenStreams = len(p.streams)
+	stats.DatagramsIn = p.datagramsIn
+	stats.DatagramsOut = p.datagramsOut
+}
+
+// Snapshot of the state and usage of the Connection
+func (c *Connection) Stats() (stats ConnectionStats) {
+	s := c.snapshot()
+	stats = ConnectionStats{
+		Id:        s.id,
+		PeerId:    s.peer.id,
+		Mode:      s.mode,
+		Initiator: c.initiator,
+		Direct:    s.direct,
+		Connected: !s.closed && s.connected(),
+
+		RelayAddr: s.relayAddr,
+		PeerAddrs: []string{},
+		PathMTU:   MinPathMTU,
+
+		LastActive: c.lastActive(),
+	}
+	if s.mode == p2p.ConnectionModeP2P && s.p2p != nil {
+		stats.PathMTU = s.p2p.pathMTU()
+	}
+	for _, addr := range s.peer.addrs {
+		stats.PeerAddrs = append(stats.PeerAddrs, addr.String())
+	}
+
+	c.fmutex.Lock()
+	if c.relayPath != nil && c.relayPath.isConnected() {
+		stats.FailedOver = c.multipath == nil
+		stats.RelayAddr = c.relayPath.addr.String()
+	}
+	if c.multipath != nil {
+		stats.Multipath = c.multipath.Mode
+	}
+	c.fmutex.Unlock()
+	stats.Paths = c.pathStats(s)
+
+	if stats.Connected {
+		var client udp.Client
+		var addr *net.UDPAddr
+		switch s.mode {
+		case p2p.ConnectionModeP2P:
+			if conn := s.p2p; conn != nil {
+				client, addr = conn.path()
+			}
+		case p2p.ConnectionModeRelay:
+			if conn := s.relay; conn != nil {
+				client, addr = conn.path()
+			}
+		case p2p.ConnectionModeRouted:
+			if conn := s.routed; conn != nil {
+				client, addr = conn.path()
+				if route, ok := conn.router.Route(s.peer.id); ok {
+					stats.NextHop = route.NextHop
+				}
+			}
+		}
+		if client != nil {
+			stats.RTT = client.RTT()
+			stats.RemoteAddr = addr.String()
+		}
+	}
+
+	c.smutex.Lock()
+	defer c.smutex.Unlock()
+
+	stats.BytesIn, stats.BytesOut = c.closedIn, c.closedOut
+	for _, stream := range c.streams {
+		stats.BytesIn += stream.BytesRead()
+		stats.BytesOut += stream.BytesWritten()
+	}
+	stats.StreamsIn = c.streamsIn
+	stats.StreamsOut = c.streamsOut
+	stats.OpenStreams = len(c.streams)
+
+	stats.ConnectedAt = c.connectedAt
+	if stats.Connected && !c.connectedAt.IsZero() {
+		stats.Uptime = time.Since(c.connectedAt)
+	}
+
+	return
+}
+
+// Snapshot of the paths carrying the Connection
+// Bonded P2P connections have a direct and a relayed path, other connections a single path
+func (c *Connection) PathStats() (paths []PathStats) {
+	return c.pathStats(c.snapshot())
+}
+
+func (c *Connection) pathStats(s connSnapshot) (paths []PathStats) {
+	var directWeight, relayWeight uint32
+	c.fmutex.Lock()
+	relayPath := c.relayPath
+	if c.multipath != nil && c.multipath.Mode == MultipathModeWeighted {
+		directWeight, relayWeight = c.multipath.DirectWeight, c.multipath.RelayWeight
+	}
+	c.fmutex.Unlock()
+
+	add := func(kind PathKind, path connPath, connected bool, weight uint32) {
+		stats := PathStats{
+			Kind:      kind,
+			Connected: connected,
+			Weight:    weight,
+		}
+		if client, addr := path.path(); client != nil {
+			stats.RTT = client.RTT()
+			stats.RemoteAddr = addr.String()
+		}
+		path.counters().collect(&stats)
+		paths = append(paths, stats)
+	}
+
+	switch s.mode {
+	case p2p.ConnectionModeP2P:
+		if conn := s.p2p; conn != nil {
+			add(PathKindDirect, conn, conn.isConnected(), directWeight)
+		}
+		if relayPath != nil {
+			add(PathKindRelay, relayPath, relayPath.isConnected(), relayWeight)
+		}
+	case p2p.ConnectionModeRelay:
+		if conn := s.relay; conn != nil {
+			add(PathKindRelay, conn, conn.isConnected(), 0)
+		}
+	case p2p.ConnectionModeRouted:
+		if conn := s.routed; conn != nil {
+			add(PathKindRouted, conn, conn.isConnected(), 0)
+		}
+	}
+	return
+}
+
+// All Connections of the Manager, including those still connecting
+func (m *Manager) Connections() (conns []*Connection) {
+	m.conns.Range(func(k, v interface{}) bool {
+		conns = append(conns, v.(*Connection))
+		return true
+	})
+	return
+}
+
+// Connection with the ID
+func (m *Manager) Connection(id string) (conn *Connection, err error) {
+	v, ok := m.conns.Load(id)
+	if !ok {
+		err = fmt.Errorf("connection with id (%s) not found", id)
+		return
+	}
+	return v.(*Connection), nil
+}
@@ -15,11 +15,37 @@ const (
 )
 
 const (
-	KEY_PORT          = "PORT"
+	KEY_PORT = "PORT"
+	// Comma separated IP addresses a Relay can be reached at in addition to the address seen by the Broker
+	KEY_ADDRESSES     = "ADDRESSES"
 	KEY_CONNECTION_ID = "CONNECTION_ID"
 
+	// Prefix of the Client Data keys which advertise a service port
+	// Example: "SERVICE:_http._tcp" = "8080"
+	KEY_SERVICE_PREFIX = "SERVICE:"
+
 	KEY_STREAM_IGNORE  = "STREAM_IGNORE"
 	KEY_STREAM_MESSAGE = "STREAM_MESSAGE"
+	KEY_STREAM_OVERLAY = "STREAM_OVERLAY"
+	// Set along with KEY_STREAM_OVERLAY on streams carrying Ethernet frames
+	KEY_STREAM_BRIDGE = "STREAM_BRIDGE"
+	// Stream exchanging route updates and forwarded packets between neighbouring peers
+	KEY_STREAM_ROUTE = "STREAM_ROUTE"
+
+	// Client Data keys of direct (Broker-less) connections
+	// The initiator signs its ID, the target ID, the connection ID and the time with its key
+	// and/or authenticates them with the pre-shared key (HMAC-SHA256),
+	// the target replies with its credentials over the acceptance of the connection
+	KEY_DIRECT_ID        = "DIRECT_ID"
+	KEY_DIRECT_TARGET    = "DIRECT_TARGET"
+	KEY_DIRECT_TIME      = "DIRECT_TIME"
+	KEY_DIRECT_KEY       = "DIRECT_KEY"
+	KEY_DIRECT_SIGNATURE = "DIRECT_SIGNATURE"
+	KEY_DIRECT_MAC       = "DIRECT_MAC"
+	// Prefix of the keys carrying the metadata of a direct or routed connection request, covered by the credentials
+	KEY_DIRECT_METADATA_PREFIX = "DIRECT_METADATA:"
+	// Set by the target on rejecting a direct or routed connection request, the value is the reason
+	KEY_DIRECT_REJECTED = "DIRECT_REJECTED"
 )
 
 type ConnectionMode string
@@ -27,6 +53,8 @@ type ConnectionMode string
 const (
 	ConnectionModeRelay ConnectionMode = "relay"
 	ConnectionModeP2P   ConnectionMode = "p2p"
+	// Multi-hop connection forwarded by intermediate peers, encrypted end-to-end
+	ConnectionModeRouted ConnectionMode = "routed"
 )
 
 type ConnectionState int
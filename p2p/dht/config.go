@@ -0,0 +1,59 @@
+package dht
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	DefaultPort = 47078
+	// Contacts per bucket and results of a lookup
+	BucketSize = 20
+	// Parallel requests of a lookup
+	Alpha = 3
+	// Records are published again after this interval
+	RepublishInterval = 10 * time.Minute
+	// Maximum lifetime of a record
+	MaxRecordTTL = time.Hour
+
+	requestTimeout  = 2 * time.Second
+	refreshInterval = 15 * time.Minute
+	maxMessageSize  = 8192
+	// Maximum age of a store request
+	storeClockSkew = time.Minute
+	// Limits of the record store
+	maxRecordsPerKey = 64
+	maxStoredKeys    = 4096
+)
+
+// DHT Node Config
+type Config struct {
+	// Listen Address
+	Addr *net.UDPAddr
+	// Addresses of known nodes to join the network through
+	Bootstrap []*net.UDPAddr
+	// Key deriving the Node ID, a random ID is used if nil
+	// Records can only be published with a key, store requests are signed with it
+	PrivateKey ed25519.PrivateKey
+	// Lifetime of the published records
+	RecordTTL time.Duration
+}
+
+func (c *Config) init() (err error) {
+	if c.Addr == nil {
+		if c.Addr, err = net.ResolveUDPAddr("udp", fmt.Sprintf(":%d", DefaultPort)); err != nil {
+			return
+		}
+	}
+
+	if c.RecordTTL == 0 {
+		c.RecordTTL = 3 * RepublishInterval
+	} else if c.RecordTTL > MaxRecordTTL {
+		err = fmt.Errorf("record ttl exceeds %v", MaxRecordTTL)
+		return
+	}
+
+	return
+}
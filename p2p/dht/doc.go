@@ -0,0 +1,3 @@
+// Package dht provides a Kademlia Distributed Hash Table of signed peer records
+// It lets peers and relays find each other and exchange requests without the Broker
+package dht
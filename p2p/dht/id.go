@@ -0,0 +1,66 @@
+package dht
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/bits"
+)
+
+// Node IDs and record keys share a 256 bit key space
+type ID [sha256.Size]byte
+
+// Key of the records of a peer
+func KeyForPeer(id string) ID {
+	return sha256.Sum256([]byte("peer:" + id))
+}
+
+// Key of the records of the peers having a tag
+func KeyForTag(tag string) ID {
+	return sha256.Sum256([]byte("tag:" + tag))
+}
+
+// Node ID derived from the key of a node
+func idFromKey(key ed25519.PublicKey) ID {
+	return sha256.Sum256(key)
+}
+
+func randomID() (id ID) {
+	rand.Read(id[:])
+	return
+}
+
+func idFromBytes(b []byte) (id ID, ok bool) {
+	if len(b) != len(id) {
+		return
+	}
+	copy(id[:], b)
+	return id, true
+}
+
+// Length of the common prefix of two IDs in bits
+func (id ID) commonPrefix(other ID) int {
+	for i := range id {
+		if x := id[i] ^ other[i]; x != 0 {
+			return i*8 + bits.LeadingZeros8(x)
+		}
+	}
+	return len(id) * 8
+}
+
+// If a is closer to the ID than b
+func (id ID) closer(a, b ID) bool {
+	for i := range id {
+		da, db := a[i]^id[i], b[i]^id[i]
+		if da != db {
+			return da < db
+		}
+	}
+	return false
+}
+
+// Stringify
+func (id ID) String() string {
+	return hex.EncodeToString(id[:])
+}
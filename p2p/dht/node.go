@@ -0,0 +1,530 @@
+package dht
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/supergiant-hq/xnet/model"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	messagePing uint32 = iota + 1
+	messageFindNode
+	messageFindValue
+	messageStore
+	messageRequest
+)
+
+// Called on a request delivered to the Node, the reply is sent back to the sender
+type RequestHandler func(addr *net.UDPAddr, payload []byte) (reply []byte, err error)
+
+// DHT Node
+type Node struct {
+	// Accessed atomically, kept first for alignment
+	nextRid uint64
+
+	config Config
+	// Node ID
+	ID ID
+
+	conn           *net.UDPConn
+	table          *table
+	store          *store
+	pending        sync.Map
+	requestHandler RequestHandler
+
+	// Exit Channel, closed when the Node is closed
+	Exit chan bool
+	// Closed Status
+	Closed bool
+	mutex  sync.Mutex
+	log    *logrus.Entry
+}
+
+// Create a DHT Node
+func New(log *logrus.Logger, config Config) (n *Node, err error) {
+	if err = config.init(); err != nil {
+		return
+	}
+
+	id := randomID()
+	if config.PrivateKey != nil {
+		id = idFromKey(config.PrivateKey.Public().(ed25519.PublicKey))
+	}
+
+	n = &Node{
+		config: config,
+		ID:     id,
+		table:  newTable(id),
+		store:  newStore(),
+
+		Exit: make(chan bool, 1),
+		log:  log.WithField("prefix", "DHT"),
+	}
+	return
+}
+
+// Set Request Handler
+func (n *Node) SetRequestHandler(handler RequestHandler) {
+	n.requestHandler = handler
+}
+
+// Start listening and join the network through the bootstrap nodes
+func (n *Node) Listen() (err error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.Closed {
+		return fmt.Errorf("node closed")
+	}
+
+	if n.conn, err = net.ListenUDP("udp", n.config.Addr); err != nil {
+		return
+	}
+
+	go n.handleMessages()
+	go n.maintain()
+
+	n.log.Infof("Node started: %s id(%s)", n.conn.LocalAddr().String(), n.ID.String())
+	return
+}
+
+// Local Address
+func (n *Node) Addr() *net.UDPAddr {
+	return n.conn.LocalAddr().(*net.UDPAddr)
+}
+
+// Lifetime of the records published by the Node
+func (n *Node) RecordTTL() time.Duration {
+	return n.config.RecordTTL
+}
+
+// No. of contacts in the routing table
+func (n *Node) Contacts() int {
+	return n.table.size()
+}
+
+// Ping the bootstrap nodes and look up the own ID to fill the routing table
+func (n *Node) Bootstrap() (err error) {
+	for _, addr := range n.config.Bootstrap {
+		if _, err := n.call(addr, &model.DHTMessage{Type: messagePing}); err != nil {
+			n.log.Warnf("Bootstrap node (%s) unreachable: %s", addr.String(), err.Error())
+		}
+	}
+
+	if n.table.size() == 0 {
+		return fmt.Errorf("no nodes reachable")
+	}
+	n.lookup(n.ID, false)
+	return
+}
+
+func (n *Node) maintain() {
+	n.Bootstrap()
+	lastRefresh := time.Now()
+
+	for {
+		select {
+		case <-time.After(time.Minute):
+		case <-n.Exit:
+			return
+		}
+
+		n.store.expire()
+		if n.table.size() == 0 {
+			n.Bootstrap()
+		} else if time.Since(lastRefresh) > refreshInterval {
+			n.lookup(n.ID, false)
+			lastRefresh = time.Now()
+		}
+	}
+}
+
+// Publish a record to the nodes closest to its keys
+// Records expire, they have to be published again within their lifetime (RepublishInterval)
+// Requires Config.PrivateKey to prove the ownership of the Node ID to the storing nodes
+func (n *Node) Publish(record *model.DHTRecord) (stored int, err error) {
+	if n.config.PrivateKey == nil {
+		return 0, fmt.Errorf("publishing requires a private key")
+	}
+	if err = VerifyRecord(record); err != nil {
+		return
+	}
+
+	for _, key := range recordKeys(record) {
+		n.store.put(key, record)
+
+		contacts, _ := n.lookup(key, false)
+		for _, c := range contacts {
+			res, err := n.call(c.addr, n.storeMessage(key, c.id, []*model.DHTRecord{record}))
+			if err == nil && len(res.Error) == 0 {
+				stored++
+			}
+		}
+	}
+
+	if stored == 0 && n.table.size() > 0 {
+		err = fmt.Errorf("record not stored by any node")
+	}
+	return
+}
+
+// Find the records of a peer
+func (n *Node) FindPeer(id string) []*model.DHTRecord {
+	return n.find(KeyForPeer(id))
+}
+
+// Find the records of the peers having a tag
+func (n *Node) FindTag(tag string) []*model.DHTRecord {
+	return n.find(KeyForTag(tag))
+}
+
+func (n *Node) find(key ID) (records []*model.DHTRecord) {
+	found := map[string]*model.DHTRecord{}
+	merge := func(list []*model.DHTRecord) {
+		for _, record := range list {
+			owner := record.Id + "\x00" + string(record.Key)
+			if current, ok := found[owner]; !ok || current.Timestamp < record.Timestamp {
+				found[owner] = record
+			}
+		}
+	}
+
+	merge(n.store.get(key))
+	_, remote := n.lookup(key, true)
+	merge(remote)
+
+	for _, record := range found {
+		records = append(records, record)
+	}
+	return
+}
+
+// Send a request to the node at an address, the payload is handled by its RequestHandler
+func (n *Node) SendRequest(addr *net.UDPAddr, payload []byte) (reply []byte, err error) {
+	res, err := n.call(addr, &model.DHTMessage{
+		Type:    messageRequest,
+		Payload: payload,
+	})
+	if err != nil {
+		return
+	}
+	if len(res.Error) > 0 {
+		err = fmt.Errorf(res.Error)
+		return
+	}
+	return res.Payload, nil
+}
+
+// Iterative lookup of the nodes closest to a target
+// Records under the target are collected from the nodes if findValue is set
+func (n *Node) lookup(target ID, findValue bool) (contacts []*contact, records []*model.DHTRecord) {
+	mtype := messageFindNode
+	if findValue {
+		mtype = messageFindValue
+	}
+
+	shortlist := n.table.closest(target, BucketSize)
+	seen := map[ID]bool{}
+	for _, c := range shortlist {
+		seen[c.id] = true
+	}
+	queried := map[ID]bool{}
+	failed := map[ID]bool{}
+
+	type result struct {
+		c   *contact
+		res *model.DHTMessage
+		err error
+	}
+
+	for {
+		batch := []*contact{}
+		for i := 0; i < len(shortlist) && i < BucketSize && len(batch) < Alpha; i++ {
+			if c := shortlist[i]; !queried[c.id] {
+				queried[c.id] = true
+				batch = append(batch, c)
+			}
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		results := make(chan result, len(batch))
+		for _, c := range batch {
+			go func(c *contact) {
+				res, err := n.call(c.addr, &model.DHTMessage{Type: mtype, Target: target[:]})
+				results <- result{c, res, err}
+			}(c)
+		}
+
+		for range batch {
+			r := <-results
+			if r.err != nil {
+				failed[r.c.id] = true
+				n.table.remove(r.c.id)
+				continue
+			}
+
+			for _, mc := range r.res.Contacts {
+				id, ok := idFromBytes(mc.Id)
+				if !ok || id == n.ID || seen[id] {
+					continue
+				}
+				addr, err := net.ResolveUDPAddr("udp", mc.Address)
+				if err != nil {
+					continue
+				}
+				seen[id] = true
+				shortlist = append(shortlist, &contact{id: id, addr: addr})
+			}
+
+			for _, record := range r.res.Records {
+				if VerifyRecord(record) == nil && recordHasKey(record, target) {
+					records = append(records, record)
+				}
+			}
+		}
+
+		alive := shortlist[:0]
+		for _, c := range shortlist {
+			if !failed[c.id] {
+				alive = append(alive, c)
+			}
+		}
+		shortlist = alive
+		sortContacts(target, shortlist)
+	}
+
+	if len(shortlist) > BucketSize {
+		shortlist = shortlist[:BucketSize]
+	}
+	return shortlist, records
+}
+
+// Send a request message and wait for its response
+func (n *Node) call(addr *net.UDPAddr, msg *model.DHTMessage) (res *model.DHTMessage, err error) {
+	msg.Rid = atomic.AddUint64(&n.nextRid, 1)
+	ch := make(chan *model.DHTMessage, 1)
+	n.pending.Store(msg.Rid, ch)
+	defer n.pending.Delete(msg.Rid)
+
+	if err = n.send(addr, msg); err != nil {
+		return
+	}
+
+	select {
+	case res = <-ch:
+	case <-time.After(requestTimeout):
+		err = fmt.Errorf("request to (%s) timeout", addr.String())
+	}
+	return
+}
+
+func (n *Node) send(addr *net.UDPAddr, msg *model.DHTMessage) (err error) {
+	msg.Sender = n.ID[:]
+
+	b, err := proto.Marshal(msg)
+	// Records are dropped from responses exceeding the message size
+	for err == nil && len(b) > maxMessageSize && len(msg.Records) > 0 {
+		msg.Records = msg.Records[:len(msg.Records)/2]
+		b, err = proto.Marshal(msg)
+	}
+	if err != nil {
+		return
+	}
+
+	_, err = n.conn.WriteToUDP(b, addr)
+	return
+}
+
+func (n *Node) handleMessages() {
+	buf := make([]byte, maxMessageSize)
+	for {
+		size, addr, err := n.conn.ReadFromUDP(buf)
+		if err != nil {
+			if !n.Closed {
+				n.log.Errorln("Error reading message:", err.Error())
+				go n.Close()
+			}
+			return
+		}
+
+		msg := &model.DHTMessage{}
+		if err := proto.Unmarshal(buf[:size], msg); err != nil {
+			continue
+		}
+		if id, ok := idFromBytes(msg.Sender); ok {
+			n.table.add(id, addr)
+		}
+
+		if msg.Response {
+			if ch, ok := n.pending.Load(msg.Rid); ok {
+				select {
+				case ch.(chan *model.DHTMessage) <- msg:
+				default:
+				}
+			}
+			continue
+		}
+
+		go n.handleRequest(msg, addr)
+	}
+}
+
+func (n *Node) handleRequest(msg *model.DHTMessage, addr *net.UDPAddr) {
+	res := &model.DHTMessage{
+		Type:     msg.Type,
+		Rid:      msg.Rid,
+		Response: true,
+	}
+
+	switch msg.Type {
+	case messagePing:
+
+	case messageFindNode, messageFindValue:
+		target, ok := idFromBytes(msg.Target)
+		if !ok {
+			res.Error = "invalid target"
+			break
+		}
+		for _, c := range n.table.closest(target, BucketSize) {
+			res.Contacts = append(res.Contacts, &model.DHTContact{Id: c.id[:], Address: c.addr.String()})
+		}
+		if msg.Type == messageFindValue {
+			res.Records = n.store.get(target)
+		}
+
+	case messageStore:
+		if err := n.handleStore(msg, addr); err != nil {
+			res.Error = err.Error()
+		}
+
+	case messageRequest:
+		if n.requestHandler == nil {
+			res.Error = "requests not accepted"
+			break
+		}
+		payload, err := n.requestHandler(addr, msg.Payload)
+		if err != nil {
+			res.Error = err.Error()
+			break
+		}
+		res.Payload = payload
+
+	default:
+		res.Error = "invalid message type"
+	}
+
+	if err := n.send(addr, res); err != nil {
+		n.log.Debugln("Error sending response:", err.Error())
+	}
+}
+
+// Store request of records under a key to a node, signed with the key of the Node
+func (n *Node) storeMessage(key ID, to ID, records []*model.DHTRecord) *model.DHTMessage {
+	msg := &model.DHTMessage{
+		Type:      messageStore,
+		Sender:    n.ID[:],
+		Target:    key[:],
+		Records:   records,
+		Key:       n.config.PrivateKey.Public().(ed25519.PublicKey),
+		Timestamp: time.Now().Unix(),
+	}
+	msg.Signature = ed25519.Sign(n.config.PrivateKey, storePayload(msg, to))
+	return msg
+}
+
+// Verify that a store request was signed for this Node by the owner of the sender ID
+// Records fetched from other nodes can not be stored under the ID of their publisher this way
+func (n *Node) verifyStore(msg *model.DHTMessage) (err error) {
+	sender, ok := idFromBytes(msg.Sender)
+	if !ok {
+		return fmt.Errorf("invalid sender")
+	}
+	if len(msg.Key) != ed25519.PublicKeySize || idFromKey(msg.Key) != sender {
+		return fmt.Errorf("key does not match sender")
+	}
+	if !ed25519.Verify(msg.Key, storePayload(msg, n.ID), msg.Signature) {
+		return fmt.Errorf("invalid signature")
+	}
+	if age := time.Since(time.Unix(msg.Timestamp, 0)); age > storeClockSkew || age < -storeClockSkew {
+		return fmt.Errorf("store request expired")
+	}
+	return
+}
+
+// Signed fields of a store request to a node separated by zero bytes
+// The records are covered by their own signatures
+func storePayload(msg *model.DHTMessage, to ID) []byte {
+	fields := []string{
+		"store",
+		string(msg.Sender),
+		string(to[:]),
+		string(msg.Target),
+		strconv.FormatInt(msg.Timestamp, 10),
+	}
+	for _, record := range msg.Records {
+		fields = append(fields, string(record.Signature))
+	}
+	return []byte(strings.Join(fields, "\x00"))
+}
+
+// Store records published by the sender
+// The address of the sender is recorded so requests can be sent to the publisher of a record
+func (n *Node) handleStore(msg *model.DHTMessage, addr *net.UDPAddr) (err error) {
+	key, ok := idFromBytes(msg.Target)
+	if !ok {
+		return fmt.Errorf("invalid target")
+	}
+	if err = n.verifyStore(msg); err != nil {
+		return
+	}
+
+	for _, record := range msg.Records {
+		if err = VerifyRecord(record); err != nil {
+			return
+		}
+		if !recordHasKey(record, key) {
+			return fmt.Errorf("record (%s) does not belong under key", record.Id)
+		}
+		if string(record.NodeId) != string(msg.Sender) {
+			return fmt.Errorf("record (%s) not published by sender", record.Id)
+		}
+
+		record.NodeAddress = addr.String()
+		if err = n.store.put(key, record); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Close Node
+func (n *Node) Close() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.Closed {
+		return
+	}
+	n.Closed = true
+
+	if n.conn != nil {
+		n.conn.Close()
+	}
+
+	// The Node is maintained and published by several loops
+	close(n.Exit)
+
+	n.log.Warnln("Node shutdown complete")
+}
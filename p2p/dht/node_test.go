@@ -0,0 +1,176 @@
+package dht
+
+import (
+	"crypto/ed25519"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/supergiant-hq/xnet/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newTestNode(t *testing.T, key ed25519.PrivateKey) *Node {
+	t.Helper()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	n, err := New(log, Config{PrivateKey: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestHandleStore(t *testing.T) {
+	_, peerKey, _ := ed25519.GenerateKey(nil)
+	_, nodeKey, _ := ed25519.GenerateKey(nil)
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+
+	publisher := newTestNode(t, nodeKey)
+	other := newTestNode(t, otherKey)
+	k := KeyForPeer("a")
+	addr := &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: DefaultPort}
+
+	// A record of the peer published through its node
+	newRecord := func() *model.DHTRecord {
+		return NewRecord(peerKey, "a", []string{"10.0.0.1:4000"}, nil, publisher.ID, time.Minute)
+	}
+
+	tests := []struct {
+		name    string
+		msg     func(to *Node) *model.DHTMessage
+		wantErr bool
+	}{
+		{
+			name: "signed by publisher",
+			msg: func(to *Node) *model.DHTMessage {
+				return publisher.storeMessage(k, to.ID, []*model.DHTRecord{newRecord()})
+			},
+		},
+		{
+			name: "record of another node",
+			msg: func(to *Node) *model.DHTMessage {
+				return other.storeMessage(k, to.ID, []*model.DHTRecord{newRecord()})
+			},
+			wantErr: true,
+		},
+		{
+			name: "sender replaced",
+			msg: func(to *Node) *model.DHTMessage {
+				msg := other.storeMessage(k, to.ID, []*model.DHTRecord{newRecord()})
+				msg.Sender = publisher.ID[:]
+				return msg
+			},
+			wantErr: true,
+		},
+		{
+			name: "unsigned",
+			msg: func(to *Node) *model.DHTMessage {
+				return &model.DHTMessage{
+					Type:    messageStore,
+					Sender:  publisher.ID[:],
+					Target:  k[:],
+					Records: []*model.DHTRecord{newRecord()},
+				}
+			},
+			wantErr: true,
+		},
+		{
+			name: "signed for another node",
+			msg: func(to *Node) *model.DHTMessage {
+				return publisher.storeMessage(k, other.ID, []*model.DHTRecord{newRecord()})
+			},
+			wantErr: true,
+		},
+		{
+			name: "record added",
+			msg: func(to *Node) *model.DHTMessage {
+				msg := publisher.storeMessage(k, to.ID, []*model.DHTRecord{newRecord()})
+				msg.Records = append(msg.Records, newRecord())
+				return msg
+			},
+			wantErr: true,
+		},
+		{
+			name: "expired",
+			msg: func(to *Node) *model.DHTMessage {
+				msg := publisher.storeMessage(k, to.ID, []*model.DHTRecord{newRecord()})
+				msg.Timestamp -= int64(2 * storeClockSkew / time.Second)
+				msg.Signature = ed25519.Sign(nodeKey, storePayload(msg, to.ID))
+				return msg
+			},
+			wantErr: true,
+		},
+		{
+			name: "other key",
+			msg: func(to *Node) *model.DHTMessage {
+				return publisher.storeMessage(KeyForPeer("b"), to.ID, []*model.DHTRecord{newRecord()})
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNode(t, nil)
+
+			err := n.handleStore(tt.msg(n), addr)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("store accepted")
+				}
+				if records := n.store.get(k); len(records) != 0 {
+					t.Fatalf("records stored: %v", records)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			records := n.store.get(k)
+			if len(records) != 1 || records[0].NodeAddress != addr.String() {
+				t.Fatalf("unexpected records: %v", records)
+			}
+		})
+	}
+}
+
+func TestHandleStoreReplay(t *testing.T) {
+	_, peerKey, _ := ed25519.GenerateKey(nil)
+	_, nodeKey, _ := ed25519.GenerateKey(nil)
+
+	publisher := newTestNode(t, nodeKey)
+	n := newTestNode(t, nil)
+	k := KeyForPeer("a")
+	addr := &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: DefaultPort}
+
+	record := NewRecord(peerKey, "a", []string{"10.0.0.1:4000"}, nil, publisher.ID, time.Minute)
+	msg := publisher.storeMessage(k, n.ID, []*model.DHTRecord{record})
+	if err := n.handleStore(msg, addr); err != nil {
+		t.Fatal(err)
+	}
+
+	// The request is replayed from another address
+	replayed := publisher.storeMessage(k, n.ID, []*model.DHTRecord{record})
+	if err := n.handleStore(replayed, &net.UDPAddr{IP: net.ParseIP("10.0.0.9"), Port: DefaultPort}); err != nil {
+		t.Fatal(err)
+	}
+	if records := n.store.get(k); len(records) != 1 || records[0].NodeAddress != addr.String() {
+		t.Fatalf("replayed request changed the stored record: %v", records)
+	}
+}
+
+func TestPublishWithoutKey(t *testing.T) {
+	_, peerKey, _ := ed25519.GenerateKey(nil)
+	n := newTestNode(t, nil)
+
+	record := NewRecord(peerKey, "a", nil, nil, n.ID, time.Minute)
+	if _, err := n.Publish(record); err == nil {
+		t.Fatal("record published without a key")
+	}
+}
@@ -0,0 +1,92 @@
+package dht
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/supergiant-hq/xnet/model"
+)
+
+// Create a peer record signed with a key
+// The record expires after ttl and is stored under KeyForPeer(id) and KeyForTag of each tag
+func NewRecord(key ed25519.PrivateKey, id string, addresses []string, tags map[string]string, nodeId ID, ttl time.Duration) *model.DHTRecord {
+	now := time.Now()
+	record := &model.DHTRecord{
+		Id:        id,
+		Key:       key.Public().(ed25519.PublicKey),
+		Addresses: addresses,
+		Tags:      tags,
+		NodeId:    nodeId[:],
+		Timestamp: now.Unix(),
+		Expires:   now.Add(ttl).Unix(),
+	}
+	record.Signature = ed25519.Sign(key, recordPayload(record))
+	return record
+}
+
+// Verify the signature and lifetime of a record
+func VerifyRecord(record *model.DHTRecord) (err error) {
+	if len(record.Id) == 0 {
+		return fmt.Errorf("record without id")
+	}
+	if len(record.Key) != ed25519.PublicKeySize || !ed25519.Verify(record.Key, recordPayload(record), record.Signature) {
+		return fmt.Errorf("invalid signature of record (%s)", record.Id)
+	}
+	if expired(record) {
+		return fmt.Errorf("record (%s) expired", record.Id)
+	}
+	if time.Unix(record.Expires, 0).Sub(time.Unix(record.Timestamp, 0)) > MaxRecordTTL {
+		return fmt.Errorf("ttl of record (%s) exceeds %v", record.Id, MaxRecordTTL)
+	}
+	return
+}
+
+func expired(record *model.DHTRecord) bool {
+	return time.Now().Unix() > record.Expires
+}
+
+// If a record belongs under a key
+func recordHasKey(record *model.DHTRecord, key ID) bool {
+	if KeyForPeer(record.Id) == key {
+		return true
+	}
+	for tag := range record.Tags {
+		if KeyForTag(tag) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Keys a record is stored under
+func recordKeys(record *model.DHTRecord) (keys []ID) {
+	keys = append(keys, KeyForPeer(record.Id))
+	for tag := range record.Tags {
+		keys = append(keys, KeyForTag(tag))
+	}
+	return
+}
+
+// Signed fields of a record separated by zero bytes, the tags are sorted
+func recordPayload(record *model.DHTRecord) []byte {
+	tags := make([]string, 0, len(record.Tags))
+	for k, v := range record.Tags {
+		tags = append(tags, k+"="+v)
+	}
+	sort.Strings(tags)
+
+	return []byte(strings.Join([]string{
+		"record",
+		record.Id,
+		string(record.Key),
+		strings.Join(record.Addresses, ","),
+		strings.Join(tags, ","),
+		string(record.NodeId),
+		strconv.FormatInt(record.Timestamp, 10),
+		strconv.FormatInt(record.Expires, 10),
+	}, "\x00"))
+}
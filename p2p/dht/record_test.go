@@ -0,0 +1,121 @@
+package dht
+
+import (
+	"crypto/ed25519"
+	"testing"
+	"time"
+
+	"github.com/supergiant-hq/xnet/model"
+)
+
+func newTestRecord(t *testing.T, key ed25519.PrivateKey, id string, ttl time.Duration) *model.DHTRecord {
+	t.Helper()
+	return NewRecord(key, id, []string{"10.0.0.1:4000"}, map[string]string{"role": "db"}, ID{1}, ttl)
+}
+
+func TestVerifyRecord(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(nil)
+	_, other, _ := ed25519.GenerateKey(nil)
+
+	// Signs a record after changing it
+	resign := func(record *model.DHTRecord, key ed25519.PrivateKey) {
+		record.Signature = ed25519.Sign(key, recordPayload(record))
+	}
+
+	tests := []struct {
+		name    string
+		change  func(record *model.DHTRecord)
+		wantErr bool
+	}{
+		{
+			name:   "valid",
+			change: func(record *model.DHTRecord) {},
+		},
+		{
+			name:   "node address is not signed",
+			change: func(record *model.DHTRecord) { record.NodeAddress = "10.0.0.9:47078" },
+		},
+		{
+			name:    "without id",
+			change:  func(record *model.DHTRecord) { record.Id = ""; resign(record, key) },
+			wantErr: true,
+		},
+		{
+			name:    "address changed",
+			change:  func(record *model.DHTRecord) { record.Addresses = []string{"10.0.0.9:4000"} },
+			wantErr: true,
+		},
+		{
+			name:    "tag added",
+			change:  func(record *model.DHTRecord) { record.Tags["role"] = "web" },
+			wantErr: true,
+		},
+		{
+			name:    "node changed",
+			change:  func(record *model.DHTRecord) { record.NodeId = make([]byte, len(ID{})) },
+			wantErr: true,
+		},
+		{
+			name:    "timestamp changed",
+			change:  func(record *model.DHTRecord) { record.Timestamp++ },
+			wantErr: true,
+		},
+		{
+			name: "key replaced",
+			change: func(record *model.DHTRecord) {
+				record.Key = other.Public().(ed25519.PublicKey)
+			},
+			wantErr: true,
+		},
+		{
+			name:    "invalid key",
+			change:  func(record *model.DHTRecord) { record.Key = record.Key[:16] },
+			wantErr: true,
+		},
+		{
+			name: "expired",
+			change: func(record *model.DHTRecord) {
+				record.Timestamp -= 2 * 3600
+				record.Expires = record.Timestamp + 60
+				resign(record, key)
+			},
+			wantErr: true,
+		},
+		{
+			name: "ttl exceeded",
+			change: func(record *model.DHTRecord) {
+				record.Expires = record.Timestamp + int64(2*MaxRecordTTL/time.Second)
+				resign(record, key)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := newTestRecord(t, key, "a", time.Minute)
+			tt.change(record)
+			if err := VerifyRecord(record); (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRecordKeys(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(nil)
+	record := newTestRecord(t, key, "a", time.Minute)
+
+	keys := recordKeys(record)
+	if len(keys) != 2 || keys[0] != KeyForPeer("a") || keys[1] != KeyForTag("role") {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+	for _, k := range keys {
+		if !recordHasKey(record, k) {
+			t.Fatalf("record does not belong under key (%s)", k)
+		}
+	}
+	if recordHasKey(record, KeyForTag("db")) {
+		t.Fatal("record belongs under the key of a tag value")
+	}
+}
@@ -0,0 +1,79 @@
+package dht
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/supergiant-hq/xnet/model"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Records stored by a node
+// A key holds the newest record of each peer ID and public key pair
+// A record is only replaced by a newer one, a replayed record does not change the stored one
+type store struct {
+	records map[ID]map[string]*model.DHTRecord
+	mutex   sync.Mutex
+}
+
+func newStore() *store {
+	return &store{records: map[ID]map[string]*model.DHTRecord{}}
+}
+
+// Store a verified record under a key
+func (s *store) put(key ID, record *model.DHTRecord) (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	records, ok := s.records[key]
+	if !ok {
+		if len(s.records) >= maxStoredKeys {
+			return fmt.Errorf("store full")
+		}
+		records = map[string]*model.DHTRecord{}
+		s.records[key] = records
+	}
+
+	owner := record.Id + "\x00" + string(record.Key)
+	if current, ok := records[owner]; ok {
+		if current.Timestamp >= record.Timestamp {
+			return
+		}
+	} else if len(records) >= maxRecordsPerKey {
+		return fmt.Errorf("too many records under key")
+	}
+
+	records[owner] = proto.Clone(record).(*model.DHTRecord)
+	return
+}
+
+// Records under a key which have not expired
+func (s *store) get(key ID) (records []*model.DHTRecord) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, record := range s.records[key] {
+		if !expired(record) {
+			records = append(records, proto.Clone(record).(*model.DHTRecord))
+		}
+	}
+	return
+}
+
+// Remove the expired records
+func (s *store) expire() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for key, records := range s.records {
+		for owner, record := range records {
+			if expired(record) {
+				delete(records, owner)
+			}
+		}
+		if len(records) == 0 {
+			delete(s.records, key)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package dht
+
+import (
+	"crypto/ed25519"
+	"testing"
+	"time"
+
+	"github.com/supergiant-hq/xnet/model"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestStorePut(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(nil)
+	_, other, _ := ed25519.GenerateKey(nil)
+	k := KeyForPeer("a")
+
+	stored := newTestRecord(t, key, "a", time.Minute)
+	stored.NodeAddress = "10.0.0.1:47078"
+
+	// A copy of the stored record with a timestamp moved by d, sent from another node
+	at := func(d int64, key ed25519.PrivateKey) *model.DHTRecord {
+		record := proto.Clone(stored).(*model.DHTRecord)
+		record.Key = key.Public().(ed25519.PublicKey)
+		record.Timestamp += d
+		record.Signature = ed25519.Sign(key, recordPayload(record))
+		record.NodeAddress = "10.0.0.9:47078"
+		return record
+	}
+
+	tests := []struct {
+		name        string
+		record      *model.DHTRecord
+		wantAddress string
+		wantRecords int
+	}{
+		{
+			name:        "replayed",
+			record:      at(0, key),
+			wantAddress: "10.0.0.1:47078",
+			wantRecords: 1,
+		},
+		{
+			name:        "older",
+			record:      at(-1, key),
+			wantAddress: "10.0.0.1:47078",
+			wantRecords: 1,
+		},
+		{
+			name:        "newer",
+			record:      at(1, key),
+			wantAddress: "10.0.0.9:47078",
+			wantRecords: 1,
+		},
+		{
+			name:        "other key",
+			record:      at(0, other),
+			wantAddress: "10.0.0.1:47078",
+			wantRecords: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStore()
+			if err := s.put(k, stored); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.put(k, tt.record); err != nil {
+				t.Fatal(err)
+			}
+
+			records := s.get(k)
+			if len(records) != tt.wantRecords {
+				t.Fatalf("%d records, want %d", len(records), tt.wantRecords)
+			}
+			for _, record := range records {
+				if string(record.Key) == string(stored.Key) && record.NodeAddress != tt.wantAddress {
+					t.Fatalf("node address = %s, want %s", record.NodeAddress, tt.wantAddress)
+				}
+			}
+		})
+	}
+}
+
+func TestStoreLimits(t *testing.T) {
+	s := newStore()
+	k := KeyForTag("role")
+
+	for i := 0; i < maxRecordsPerKey; i++ {
+		_, key, _ := ed25519.GenerateKey(nil)
+		if err := s.put(k, newTestRecord(t, key, "a", time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, key, _ := ed25519.GenerateKey(nil)
+	if err := s.put(k, newTestRecord(t, key, "a", time.Minute)); err == nil {
+		t.Fatal("record stored beyond the limit of the key")
+	}
+}
+
+func TestStoreExpire(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(nil)
+	s := newStore()
+	k := KeyForPeer("a")
+
+	record := newTestRecord(t, key, "a", time.Minute)
+	record.Expires = time.Now().Unix() - 1
+	if err := s.put(k, record); err != nil {
+		t.Fatal(err)
+	}
+	if records := s.get(k); len(records) != 0 {
+		t.Fatalf("expired record returned: %v", records)
+	}
+
+	s.expire()
+	if _, ok := s.records[k]; ok {
+		t.Fatal("expired record kept")
+	}
+}
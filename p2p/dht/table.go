@@ -0,0 +1,104 @@
+package dht
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+type contact struct {
+	id       ID
+	addr     *net.UDPAddr
+	lastSeen time.Time
+}
+
+// Kademlia routing table, a bucket per common prefix length with the own ID
+// Buckets keep the least recently seen contacts first, new contacts replace them only when they are stale
+type table struct {
+	self    ID
+	buckets [len(ID{}) * 8][]*contact
+	mutex   sync.Mutex
+}
+
+func newTable(self ID) *table {
+	return &table{self: self}
+}
+
+// Add a contact or mark it as seen
+func (t *table) add(id ID, addr *net.UDPAddr) {
+	if id == t.self {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	i := t.self.commonPrefix(id)
+	bucket := t.buckets[i]
+	for j, c := range bucket {
+		if c.id == id {
+			c.addr = addr
+			c.lastSeen = time.Now()
+			t.buckets[i] = append(append(bucket[:j:j], bucket[j+1:]...), c)
+			return
+		}
+	}
+
+	c := &contact{id: id, addr: addr, lastSeen: time.Now()}
+	if len(bucket) < BucketSize {
+		t.buckets[i] = append(bucket, c)
+	} else if time.Since(bucket[0].lastSeen) > refreshInterval {
+		t.buckets[i] = append(bucket[1:], c)
+	}
+}
+
+// Remove a contact which did not respond
+func (t *table) remove(id ID) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	i := t.self.commonPrefix(id)
+	bucket := t.buckets[i]
+	for j, c := range bucket {
+		if c.id == id {
+			t.buckets[i] = append(bucket[:j:j], bucket[j+1:]...)
+			return
+		}
+	}
+}
+
+// Contacts closest to a target
+func (t *table) closest(target ID, count int) (contacts []*contact) {
+	t.mutex.Lock()
+	for _, bucket := range t.buckets {
+		for _, c := range bucket {
+			cc := *c
+			contacts = append(contacts, &cc)
+		}
+	}
+	t.mutex.Unlock()
+
+	sortContacts(target, contacts)
+	if len(contacts) > count {
+		contacts = contacts[:count]
+	}
+	return
+}
+
+// No. of contacts
+func (t *table) size() (n int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for _, bucket := range t.buckets {
+		n += len(bucket)
+	}
+	return
+}
+
+func sortContacts(target ID, contacts []*contact) {
+	sort.Slice(contacts, func(i, j int) bool {
+		return target.closer(contacts[i].id, contacts[j].id)
+	})
+}
@@ -3,8 +3,11 @@ package relay
 import (
 	"fmt"
 	"net"
+	"strings"
 
 	"github.com/supergiant-hq/xnet/p2p"
+	"github.com/supergiant-hq/xnet/p2p/dht"
+	"github.com/supergiant-hq/xnet/tun"
 	udpc "github.com/supergiant-hq/xnet/udp/client"
 	udps "github.com/supergiant-hq/xnet/udp/server"
 )
@@ -14,11 +17,18 @@ type Config struct {
 	// Debug Mode
 	Debug bool
 	// Listen Address
+	// Listening on an unspecified address (":port") accepts IPv4 and IPv6 connections
 	Addr *net.UDPAddr
+	// Public addresses (IPv4 or IPv6) advertised to Clients along with the address seen by the Broker
+	// If not set, the global IPv6 addresses of the interfaces are advertised when listening on an unspecified address
+	PublicIPs []net.IP
 	// Broker Address
 	BrokerAddr *net.UDPAddr
 	// Broker Validation Token
 	BrokerToken string
+	// Config of a DHT Node run along with the Relay, disabled if nil
+	// Relays are long lived and make good bootstrap nodes for Clients
+	DHT *dht.Config
 
 	udpsConfig udps.Config
 	udpcConfig udpc.Config
@@ -26,8 +36,9 @@ type Config struct {
 
 func (c *Config) init() (err error) {
 	c.udpsConfig = udps.Config{
-		Tag:  "Relay",
-		Addr: c.Addr,
+		Tag:       "Relay",
+		Addr:      c.Addr,
+		Datagrams: true,
 	}
 
 	c.udpcConfig = udpc.Config{
@@ -42,5 +53,42 @@ func (c *Config) init() (err error) {
 		},
 	}
 
+	ips, err := c.publicIPs()
+	if err != nil {
+		return
+	}
+	if len(ips) > 0 {
+		addrs := []string{}
+		for _, ip := range ips {
+			addrs = append(addrs, ip.String())
+		}
+		c.udpcConfig.Data[p2p.KEY_ADDRESSES] = strings.Join(addrs, ",")
+	}
+
+	return
+}
+
+func (c *Config) publicIPs() (ips []net.IP, err error) {
+	if len(c.PublicIPs) > 0 {
+		return c.PublicIPs, nil
+	}
+
+	if c.Addr.IP != nil && !c.Addr.IP.IsUnspecified() {
+		if c.Addr.IP.To4() == nil {
+			ips = append(ips, c.Addr.IP)
+		}
+		return
+	}
+
+	addrs, err := tun.GetInterfaceAddresses()
+	if err != nil {
+		return
+	}
+	for _, ip := range addrs {
+		// Unique local addresses (fc00::/7) are not reachable from the internet
+		if ip.To4() == nil && ip.IsGlobalUnicast() && ip[0]&0xfe != 0xfc {
+			ips = append(ips, ip)
+		}
+	}
 	return
 }
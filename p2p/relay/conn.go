@@ -200,6 +200,26 @@ func (c *Connection) openStream(client *udps.Client, streamInfo *model.P2PRelayO
 	return
 }
 
+// Forward the Datagrams of a client to its peer until its session is closed
+func (c *Connection) forwardDatagrams(client *udps.Client) {
+	if !client.DatagramsSupported() {
+		return
+	}
+
+	for {
+		b, err := client.ReceiveDatagram()
+		if err != nil {
+			return
+		}
+
+		peerClient, err := c.getPeerClient(client)
+		if err != nil {
+			continue
+		}
+		peerClient.SendDatagram(b)
+	}
+}
+
 func (c *Connection) stopTicker() {
 	c.tickerTimer.Stop()
 	c.ticker.Stop()
@@ -113,7 +113,10 @@ func (s *Server) awaitPeerConnection(c *udps.Client, msg *network.Message) {
 		return
 	}
 
-	err = conn.awaitPeer(c)
+	if err = conn.awaitPeer(c); err != nil {
+		return
+	}
+	go conn.forwardDatagrams(c)
 }
 
 func (s *Server) openPeerStreamHandler(c *udps.Client, msg *network.Message) {
@@ -4,6 +4,7 @@ import (
 	"sync"
 
 	"github.com/supergiant-hq/xnet/model"
+	"github.com/supergiant-hq/xnet/p2p/dht"
 	udpc "github.com/supergiant-hq/xnet/udp/client"
 	udps "github.com/supergiant-hq/xnet/udp/server"
 	"github.com/supergiant-hq/xnet/util"
@@ -16,6 +17,7 @@ type Server struct {
 	config    Config
 	udpClient *udpc.Client
 	udpServer *udps.Server
+	dhtNode   *dht.Node
 
 	conns      sync.Map
 	connsMutex sync.Mutex
@@ -54,6 +56,12 @@ func New(config Config) (s *Server, err error) {
 		return
 	}
 
+	if config.DHT != nil {
+		if s.dhtNode, err = dht.New(s.log, *config.DHT); err != nil {
+			return
+		}
+	}
+
 	s.registerHandlers()
 
 	return
@@ -74,6 +82,12 @@ func (s *Server) Listen() (err error) {
 		return
 	}
 
+	if s.dhtNode != nil {
+		if err = s.dhtNode.Listen(); err != nil {
+			return
+		}
+	}
+
 	go s.udpClient.Connect()
 
 	go func() {
@@ -97,6 +111,9 @@ func (s *Server) Close() {
 
 	s.udpClient.Close(0, "Relay Server Shutdown")
 	s.udpServer.Close(0, "Relay Server Shutdown")
+	if s.dhtNode != nil {
+		s.dhtNode.Close()
+	}
 
 	select {
 	case s.Exit <- true:
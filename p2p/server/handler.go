@@ -1,7 +1,10 @@
 package p2ps
 
 import (
+	"errors"
 	"fmt"
+	"net"
+	"strings"
 
 	"github.com/supergiant-hq/xnet/model"
 	"github.com/supergiant-hq/xnet/network"
@@ -23,9 +26,11 @@ func (m *Manager) connectionRequestHandler(c *udps.Client, msg *network.Message)
 		if err != nil {
 			m.log.Errorln("Error creating connection:", err.Error())
 
+			var rejected *rejectedError
 			resMsg = model.P2PConnectionData{
-				Status:  false,
-				Message: err.Error(),
+				Status:   false,
+				Message:  err.Error(),
+				Rejected: errors.As(err, &rejected),
 			}
 		} else {
 			resMsg = model.P2PConnectionData{
@@ -60,6 +65,15 @@ func (m *Manager) connectionRequestHandler(c *udps.Client, msg *network.Message)
 	}
 }
 
+// Connection request the target peer rejected
+type rejectedError struct {
+	reason string
+}
+
+func (e *rejectedError) Error() string {
+	return e.reason
+}
+
 func (m *Manager) createConnection(sourceId string, req *model.P2PConnectionRequest) (c *Connection, pd *model.P2PPeerData, err error) {
 	defer func() {
 		if err != nil && c != nil {
@@ -101,7 +115,10 @@ func (m *Manager) createConnection(sourceId string, req *model.P2PConnectionRequ
 				Id:        c.sourcePeer.id,
 				Address:   c.sourcePeer.client.Addr.String(),
 				Addresses: util.RemoveDuplicatesFromSlice(append(req.Peer.Addresses, req.Peer.Address, c.sourcePeer.client.Addr.String())),
+				Tags:      c.sourcePeer.client.Meta.Tags,
 			},
+			Bond:     req.Bond,
+			Metadata: req.Metadata,
 		},
 		p2p.RequestTimeout,
 	)
@@ -110,7 +127,10 @@ func (m *Manager) createConnection(sourceId string, req *model.P2PConnectionRequ
 		return
 	}
 	rcd := rmsg.Body.(*model.P2PConnectionData)
-	if !rcd.Status {
+	if rcd.Rejected {
+		err = &rejectedError{reason: rcd.Message}
+		return
+	} else if !rcd.Status {
 		err = fmt.Errorf(rcd.Message)
 		return
 	}
@@ -162,7 +182,16 @@ func (m *Manager) getRelaysHandler(c *udps.Client, msg *network.Message) {
 			m.log.Warnln("Relay did not send the PORT key in DATA")
 			continue
 		}
-		servers = append(servers, fmt.Sprintf("%s:%s", relay.Addr.IP.String(), port))
+		servers = append(servers, net.JoinHostPort(relay.Addr.IP.String(), port))
+
+		// Additional (IPv6) addresses of the relay
+		if addrs, ok := relay.Meta.Data[p2p.KEY_ADDRESSES]; ok {
+			for _, addr := range strings.Split(addrs, ",") {
+				if ip := net.ParseIP(addr); ip != nil && !ip.Equal(relay.Addr.IP) {
+					servers = append(servers, net.JoinHostPort(ip.String(), port))
+				}
+			}
+		}
 	}
 	rmsg, err := msg.GenReply(model.MessageTypeP2PRelayServers, &model.P2PRelayServers{
 		Servers: servers,
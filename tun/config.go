@@ -13,10 +13,24 @@ type TunConfig struct {
 	IP net.IP
 	// CIDR network of the Device
 	CIDR *net.IPNet
+	// Additional addresses (IPv4 or IPv6) of the Device
+	// Dual-stack Devices have an IPv4 primary address and IPv6 additional addresses (required on Windows)
+	Addresses []*net.IPNet
+	// Create a TAP (layer 2) Device exchanging Ethernet frames instead of IP packets
+	// Supported on Linux
+	TAP bool
+	// No. of queues which can be read and written in parallel
+	// Supported on Linux
+	Queues int
+	// Exchange packets with the kernel along with a virtio-net header,
+	// enabling checksum offload and TCP segmentation offload (GSO/GRO) of large packets
+	// Supported for TUN Devices on Linux
+	Offload bool
 }
 
 // Create Tun Config
-func NewTunConfig(mtu int, address string) (config TunConfig, err error) {
+// The first address is the primary address, the rest are added as additional addresses
+func NewTunConfig(mtu int, address string, addresses ...string) (config TunConfig, err error) {
 	ip, cidr, err := net.ParseCIDR(address)
 	if err != nil {
 		return TunConfig{}, fmt.Errorf("tunconfig: err with %v", err)
@@ -26,9 +40,29 @@ func NewTunConfig(mtu int, address string) (config TunConfig, err error) {
 		IP:   ip,
 		CIDR: cidr,
 	}
+
+	for _, address := range addresses {
+		ip, cidr, err := net.ParseCIDR(address)
+		if err != nil {
+			return TunConfig{}, fmt.Errorf("tunconfig: err with %v", err)
+		}
+		cidr.IP = ip
+		config.Addresses = append(config.Addresses, cidr)
+	}
 	return
 }
 
+// All addresses of the Device including the primary address
+func (tc *TunConfig) AllAddresses() (addrs []*net.IPNet) {
+	if tc.IP != nil && tc.CIDR != nil {
+		addrs = append(addrs, &net.IPNet{
+			IP:   tc.IP,
+			Mask: tc.CIDR.Mask,
+		})
+	}
+	return append(addrs, tc.Addresses...)
+}
+
 // Stringify
 func (tc *TunConfig) String() string {
 	return fmt.Sprintf("%v", *tc)
@@ -2,32 +2,44 @@ package tun
 
 import (
 	"fmt"
-	"net"
 
 	"github.com/songgao/water"
 )
 
-// Get Device Network Interfaces
-func GetInterfaceAddresses() (addrs []net.IP, err error) {
-	ifs, err := net.Interfaces()
-	if err != nil {
-		return
-	}
+// Packet Device
+// Each Read and Write transfers a single IP packet, or an Ethernet frame for TAP Devices
+type Device interface {
+	// Read the next packet
+	Read(b []byte) (n int, err error)
+	// Write a packet
+	Write(b []byte) (n int, err error)
+	// Maximum transmission unit
+	MTU() int
+	// Name of the Device
+	Name() string
+	// Close the Device
+	Close() error
+}
 
-	for _, ifc := range ifs {
-		iaddrs, err := ifc.Addrs()
-		if err != nil {
-			return nil, err
-		}
-		for _, addr := range iaddrs {
-			ip, _, _ := net.ParseCIDR(addr.String())
-			if ip.To4() != nil {
-				addrs = append(addrs, ip.To4())
-			}
-		}
-	}
+// Device transferring multiple packets per call
+// It is safe to read and write concurrently
+type BatchDevice interface {
+	Device
+	// Read packets into bufs along with their sizes
+	// Returns the no. of packets read
+	ReadBatch(bufs [][]byte, sizes []int) (n int, err error)
+	// Write packets
+	// Returns the no. of packets written
+	WriteBatch(pkts [][]byte) (n int, err error)
+	// Maximum no. of packets returned by ReadBatch
+	BatchSize() int
+}
 
-	return
+// Device with queues which can be read and written in parallel
+type MultiQueueDevice interface {
+	Device
+	// Queues of the Device
+	Queues() []BatchDevice
 }
 
 // Tun Device
@@ -36,10 +48,13 @@ type TunDevice struct {
 	Config TunConfig
 	// Name
 	Name string
-	// IO Device
+	// IO Device, nil if the Device was opened with multiple queues or offloads (see PacketDevice)
 	Device *water.Interface
 	// Active Status
 	Active bool
+
+	// Queues of a Linux Device with multiple queues or offloads
+	queues *QueueSet
 }
 
 func (td *TunDevice) initTunDevice(config *TunConfig, wi *water.Interface) {
@@ -47,11 +62,57 @@ func (td *TunDevice) initTunDevice(config *TunConfig, wi *water.Interface) {
 	td.Name = wi.Name()
 	td.Device = wi
 	td.Active = true
+	ownDevices.Store(td.Name, true)
+}
+
+// Packet Device of an active TunDevice
+// Closing it closes the underlying interface, use TunDevice.Close to reset the TunDevice
+// A QueueSet is returned if the Device was opened with multiple queues or offloads
+func (td *TunDevice) PacketDevice() Device {
+	if td.queues != nil {
+		return td.queues
+	}
+	return &waterDevice{
+		iface: td.Device,
+		mtu:   td.Config.MTU,
+	}
+}
+
+// Device backed by a water interface
+type waterDevice struct {
+	iface *water.Interface
+	mtu   int
+}
+
+func (wd *waterDevice) Read(b []byte) (int, error) {
+	return wd.iface.Read(b)
+}
+
+func (wd *waterDevice) Write(b []byte) (int, error) {
+	return wd.iface.Write(b)
+}
+
+func (wd *waterDevice) MTU() int {
+	return wd.mtu
+}
+
+func (wd *waterDevice) Name() string {
+	return wd.iface.Name()
+}
+
+func (wd *waterDevice) Close() error {
+	return wd.iface.Close()
 }
 
 // Close Device
 func (td *TunDevice) Close() {
-	td.Device.Close()
+	if td.queues != nil {
+		td.queues.Close()
+		td.queues = nil
+	} else {
+		td.Device.Close()
+	}
+	ownDevices.Delete(td.Name)
 	td.Name = ""
 	td.Device = nil
 	td.Active = false
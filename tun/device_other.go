@@ -0,0 +1,46 @@
+//go:build !linux
+// +build !linux
+
+package tun
+
+import "net"
+
+// Set the MTU of the Device
+func (td *TunDevice) SetMTU(mtu int) error {
+	return ErrorUnsupported
+}
+
+// Set the link state of the Device
+func (td *TunDevice) SetLinkUp(up bool) error {
+	return ErrorUnsupported
+}
+
+// Add an address to the Device
+func (td *TunDevice) AddAddress(addr *net.IPNet) error {
+	return ErrorUnsupported
+}
+
+// Remove an address from the Device
+func (td *TunDevice) RemoveAddress(addr *net.IPNet) error {
+	return ErrorUnsupported
+}
+
+// Add a route through the Device
+func (td *TunDevice) AddRoute(route Route) error {
+	return ErrorUnsupported
+}
+
+// Remove a route through the Device
+func (td *TunDevice) RemoveRoute(route Route) error {
+	return ErrorUnsupported
+}
+
+// Add a policy routing rule
+func (td *TunDevice) AddRule(rule Rule) error {
+	return ErrorUnsupported
+}
+
+// Remove a policy routing rule
+func (td *TunDevice) RemoveRule(rule Rule) error {
+	return ErrorUnsupported
+}
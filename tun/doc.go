@@ -1,2 +1,3 @@
 // Package tun provides functionality to create and manage TUN Devices
+// along with in-memory and pcap file backed packet Devices for testing
 package tun
@@ -0,0 +1,59 @@
+package tun
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+var (
+	ErrorAlreadyActive = errors.New("tun: already active")
+	ErrorNotActive     = errors.New("tun: not active")
+	ErrorUnsupported   = errors.New("tun: not supported on this platform")
+	ErrorExists        = errors.New("tun: already exists")
+	ErrorNotFound      = errors.New("tun: not found")
+	ErrorPermission    = errors.New("tun: permission denied")
+	ErrorInvalidConfig = errors.New("tun: invalid config")
+	ErrorClosed        = errors.New("tun: device closed")
+)
+
+// Error returned when configuring a Device fails
+type ConfigError struct {
+	// Operation that failed
+	Op string
+	// Underlying error
+	Err error
+}
+
+func newConfigError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ConfigError{Op: op, Err: err}
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("tun: %s: %v", e.Op, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// Matches the generic errors of the package
+func (e *ConfigError) Is(target error) bool {
+	var errno syscall.Errno
+	if !errors.As(e.Err, &errno) {
+		return false
+	}
+
+	switch target {
+	case ErrorExists:
+		return errno == syscall.EEXIST
+	case ErrorNotFound:
+		return errno == syscall.ENOENT || errno == syscall.ESRCH || errno == syscall.ENODEV
+	case ErrorPermission:
+		return errno == syscall.EPERM || errno == syscall.EACCES
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package tun
+
+import (
+	"net"
+	"sync"
+)
+
+// Type of a Network Interface
+// Types are bit flags so that sets of types can be combined
+type InterfaceType int
+
+const (
+	// Interface backed by hardware
+	InterfaceTypePhysical InterfaceType = 1 << iota
+	// Loopback Interface
+	InterfaceTypeLoopback
+	// Point-to-point, TUN and TAP Interfaces, usually VPN tunnels
+	InterfaceTypeTunnel
+	// Software Interfaces like bridges and veth pairs, usually created for containers and VMs
+	InterfaceTypeVirtual
+)
+
+// Network Interface along with its addresses
+type Interface struct {
+	// Name
+	Name string
+	// Index
+	Index int
+	// Type
+	Type InterfaceType
+	// Created by a TunDevice of this process
+	Own bool
+	// IPv4 and IPv6 addresses, link-local IPv6 addresses are scoped with the name as the zone
+	Addrs []net.IPAddr
+}
+
+// Names of the Devices opened by TunDevices
+var ownDevices sync.Map
+
+// If a TunDevice of this process created the Interface
+func IsOwnDevice(name string) bool {
+	_, ok := ownDevices.Load(name)
+	return ok
+}
+
+// Get the Network Interfaces which are up
+func GetInterfaces() (interfaces []Interface, err error) {
+	ifs, err := net.Interfaces()
+	if err != nil {
+		return
+	}
+
+	for _, ifc := range ifs {
+		if ifc.Flags&net.FlagUp == 0 {
+			continue
+		}
+		iaddrs, err := ifc.Addrs()
+		if err != nil {
+			return nil, err
+		}
+
+		i := Interface{
+			Name:  ifc.Name,
+			Index: ifc.Index,
+			Type:  interfaceType(ifc),
+			Own:   IsOwnDevice(ifc.Name),
+		}
+		for _, addr := range iaddrs {
+			ip, _, err := net.ParseCIDR(addr.String())
+			if err != nil {
+				continue
+			}
+			switch {
+			case ip.To4() != nil:
+				i.Addrs = append(i.Addrs, net.IPAddr{IP: ip.To4()})
+			case ip.IsLinkLocalUnicast():
+				i.Addrs = append(i.Addrs, net.IPAddr{IP: ip, Zone: ifc.Name})
+			case ip.IsGlobalUnicast() || ip.IsLoopback():
+				i.Addrs = append(i.Addrs, net.IPAddr{IP: ip})
+			}
+		}
+		interfaces = append(interfaces, i)
+	}
+
+	return
+}
+
+// Get Device Network Interfaces
+// IPv4 and IPv6 addresses of the interfaces which are up are returned
+// IPv6 link-local addresses are excluded as they are only usable with the zone of their interface,
+// use GetScopedInterfaceAddresses to include them
+func GetInterfaceAddresses() (addrs []net.IP, err error) {
+	scoped, err := GetScopedInterfaceAddresses()
+	if err != nil {
+		return
+	}
+
+	for _, addr := range scoped {
+		if len(addr.Zone) == 0 {
+			addrs = append(addrs, addr.IP)
+		}
+	}
+	return
+}
+
+// Get Device Network Interfaces including IPv6 link-local addresses
+// Link-local addresses are scoped with the name of their interface as the zone
+func GetScopedInterfaceAddresses() (addrs []net.IPAddr, err error) {
+	interfaces, err := GetInterfaces()
+	if err != nil {
+		return
+	}
+
+	for _, i := range interfaces {
+		addrs = append(addrs, i.Addrs...)
+	}
+	return
+}
+
+// Type from the Interface flags
+func flagsInterfaceType(ifc net.Interface) (InterfaceType, bool) {
+	switch {
+	case ifc.Flags&net.FlagLoopback != 0:
+		return InterfaceTypeLoopback, true
+	case ifc.Flags&net.FlagPointToPoint != 0:
+		return InterfaceTypeTunnel, true
+	}
+	return 0, false
+}
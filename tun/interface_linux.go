@@ -0,0 +1,24 @@
+package tun
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// Type of an Interface using sysfs
+// TUN and TAP Devices have tun_flags, Interfaces without a backing device are virtual
+func interfaceType(ifc net.Interface) InterfaceType {
+	if t, ok := flagsInterfaceType(ifc); ok {
+		return t
+	}
+
+	dir := filepath.Join("/sys/class/net", ifc.Name)
+	if _, err := os.Stat(filepath.Join(dir, "tun_flags")); err == nil {
+		return InterfaceTypeTunnel
+	}
+	if _, err := os.Stat(filepath.Join(dir, "device")); err != nil {
+		return InterfaceTypeVirtual
+	}
+	return InterfaceTypePhysical
+}
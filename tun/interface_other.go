@@ -0,0 +1,28 @@
+//go:build !linux
+// +build !linux
+
+package tun
+
+import (
+	"net"
+	"strings"
+)
+
+// Type of an Interface using its flags and name
+func interfaceType(ifc net.Interface) InterfaceType {
+	if t, ok := flagsInterfaceType(ifc); ok {
+		return t
+	}
+
+	for _, prefix := range []string{"utun", "tun", "tap", "ipsec", "ppp", "wg"} {
+		if strings.HasPrefix(ifc.Name, prefix) {
+			return InterfaceTypeTunnel
+		}
+	}
+	for _, prefix := range []string{"bridge", "docker", "vboxnet", "vmnet", "veth", "vEthernet"} {
+		if strings.HasPrefix(ifc.Name, prefix) {
+			return InterfaceTypeVirtual
+		}
+	}
+	return InterfaceTypePhysical
+}
@@ -0,0 +1,318 @@
+package tun
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Netlink attributes which are not part of x/sys/unix
+const (
+	fraDst      = 1
+	fraSrc      = 2
+	fraPriority = 6
+	fraFwmark   = 10
+	fraTable    = 15
+
+	frActToTbl     = 1
+	fibRuleInvert  = 0x2
+	sizeofFibRule  = 12
+	netlinkBufSize = 1 << 16
+)
+
+var netlinkSeq uint32
+
+// Netlink request builder
+type netlinkRequest struct {
+	msgType uint16
+	flags   uint16
+	data    []byte
+}
+
+func newNetlinkRequest(msgType uint16, flags uint16) *netlinkRequest {
+	return &netlinkRequest{
+		msgType: msgType,
+		flags:   unix.NLM_F_REQUEST | unix.NLM_F_ACK | flags,
+	}
+}
+
+func (r *netlinkRequest) append(b []byte) {
+	r.data = append(r.data, b...)
+}
+
+// Append a route attribute padded to the attribute alignment
+func (r *netlinkRequest) attr(attrType uint16, value []byte) {
+	length := unix.SizeofRtAttr + len(value)
+	b := make([]byte, (length+unix.RTA_ALIGNTO-1) & ^(unix.RTA_ALIGNTO-1))
+	nativeEndian.PutUint16(b[0:2], uint16(length))
+	nativeEndian.PutUint16(b[2:4], attrType)
+	copy(b[unix.SizeofRtAttr:], value)
+	r.append(b)
+}
+
+func (r *netlinkRequest) attrUint32(attrType uint16, value uint32) {
+	b := make([]byte, 4)
+	nativeEndian.PutUint32(b, value)
+	r.attr(attrType, b)
+}
+
+// Netlink message of the request
+func (r *netlinkRequest) message(seq uint32) []byte {
+	msg := make([]byte, unix.SizeofNlMsghdr+len(r.data))
+	nativeEndian.PutUint32(msg[0:4], uint32(len(msg)))
+	nativeEndian.PutUint16(msg[4:6], r.msgType)
+	nativeEndian.PutUint16(msg[6:8], r.flags)
+	nativeEndian.PutUint32(msg[8:12], seq)
+	copy(msg[unix.SizeofNlMsghdr:], r.data)
+	return msg
+}
+
+// Send the request and wait for the kernel's acknowledgement
+func (r *netlinkRequest) execute() (err error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return
+	}
+	defer unix.Close(fd)
+
+	if err = unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return
+	}
+
+	seq := atomic.AddUint32(&netlinkSeq, 1)
+	if err = unix.Sendto(fd, r.message(seq), 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return
+	}
+
+	buf := make([]byte, netlinkBufSize)
+	for {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return err
+		}
+
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			if m.Header.Seq != seq || m.Header.Type != unix.NLMSG_ERROR {
+				continue
+			}
+			if len(m.Data) < 4 {
+				return fmt.Errorf("netlink: short error message")
+			}
+			if code := int32(nativeEndian.Uint32(m.Data[0:4])); code != 0 {
+				return syscall.Errno(-code)
+			}
+			return nil
+		}
+	}
+}
+
+func ipFamily(ip net.IP) (family uint8, addr net.IP) {
+	if ip4 := ip.To4(); ip4 != nil {
+		return unix.AF_INET, ip4
+	}
+	return unix.AF_INET6, ip.To16()
+}
+
+func prefixLength(ipnet *net.IPNet) uint8 {
+	ones, _ := ipnet.Mask.Size()
+	return uint8(ones)
+}
+
+func linkIndex(name string) (index int, err error) {
+	ifc, err := net.InterfaceByName(name)
+	if err != nil {
+		return 0, syscall.ENODEV
+	}
+	return ifc.Index, nil
+}
+
+// Request changing the flags of a link selected by the change mask
+func linkRequest(index int, flags uint32, change uint32) *netlinkRequest {
+	req := newNetlinkRequest(unix.RTM_NEWLINK, 0)
+	ifinfo := make([]byte, unix.SizeofIfInfomsg)
+	ifinfo[0] = unix.AF_UNSPEC
+	nativeEndian.PutUint32(ifinfo[4:8], uint32(index))
+	nativeEndian.PutUint32(ifinfo[8:12], flags)
+	nativeEndian.PutUint32(ifinfo[12:16], change)
+	req.append(ifinfo)
+	return req
+}
+
+// Request setting the MTU of a link, its state is unchanged
+func linkMTURequest(index int, mtu int) *netlinkRequest {
+	req := linkRequest(index, 0, 0)
+	req.attrUint32(unix.IFLA_MTU, uint32(mtu))
+	return req
+}
+
+// Request setting the state of a link
+func linkStateRequest(index int, up bool) *netlinkRequest {
+	var flags uint32
+	if up {
+		flags = unix.IFF_UP
+	}
+	return linkRequest(index, flags, unix.IFF_UP)
+}
+
+// Set the MTU of a link
+func netlinkSetMTU(index int, mtu int) error {
+	return linkMTURequest(index, mtu).execute()
+}
+
+// Set the state of a link
+func netlinkSetLinkState(index int, up bool) error {
+	return linkStateRequest(index, up).execute()
+}
+
+// Add or remove an address of a link
+func netlinkAddress(msgType uint16, index int, addr *net.IPNet) error {
+	return addressRequest(msgType, index, addr).execute()
+}
+
+func addressRequest(msgType uint16, index int, addr *net.IPNet) *netlinkRequest {
+	family, ip := ipFamily(addr.IP)
+
+	flags := uint16(0)
+	if msgType == unix.RTM_NEWADDR {
+		flags = unix.NLM_F_CREATE | unix.NLM_F_EXCL
+	}
+	req := newNetlinkRequest(msgType, flags)
+
+	ifaddr := make([]byte, unix.SizeofIfAddrmsg)
+	ifaddr[0] = family
+	ifaddr[1] = prefixLength(addr)
+	if family == unix.AF_INET6 {
+		ifaddr[2] = unix.IFA_F_NODAD
+	}
+	ifaddr[3] = unix.RT_SCOPE_UNIVERSE
+	nativeEndian.PutUint32(ifaddr[4:8], uint32(index))
+	req.append(ifaddr)
+
+	req.attr(unix.IFA_LOCAL, ip)
+	req.attr(unix.IFA_ADDRESS, ip)
+
+	return req
+}
+
+// Add or remove a route through a link
+func netlinkRoute(msgType uint16, index int, route *Route) error {
+	req, err := routeRequest(msgType, index, route)
+	if err != nil {
+		return err
+	}
+	return req.execute()
+}
+
+func routeRequest(msgType uint16, index int, route *Route) (req *netlinkRequest, err error) {
+	if route.Destination == nil {
+		return nil, ErrorInvalidConfig
+	}
+	family, dst := ipFamily(route.Destination.IP)
+
+	flags := uint16(0)
+	if msgType == unix.RTM_NEWROUTE {
+		flags = unix.NLM_F_CREATE | unix.NLM_F_EXCL
+	}
+	req = newNetlinkRequest(msgType, flags)
+
+	table := route.Table
+	if table == 0 {
+		table = unix.RT_TABLE_MAIN
+	}
+	scope := uint8(unix.RT_SCOPE_LINK)
+	if route.Gateway != nil {
+		scope = unix.RT_SCOPE_UNIVERSE
+	}
+
+	rtmsg := make([]byte, unix.SizeofRtMsg)
+	rtmsg[0] = family
+	rtmsg[1] = prefixLength(route.Destination)
+	if table < 256 {
+		rtmsg[4] = uint8(table)
+	}
+	rtmsg[5] = unix.RTPROT_STATIC
+	rtmsg[6] = scope
+	rtmsg[7] = unix.RTN_UNICAST
+	req.append(rtmsg)
+
+	req.attr(unix.RTA_DST, dst.Mask(route.Destination.Mask))
+	req.attrUint32(unix.RTA_OIF, uint32(index))
+	if route.Gateway != nil {
+		_, gw := ipFamily(route.Gateway)
+		req.attr(unix.RTA_GATEWAY, gw)
+	}
+	if route.Metric > 0 {
+		req.attrUint32(unix.RTA_PRIORITY, uint32(route.Metric))
+	}
+	req.attrUint32(unix.RTA_TABLE, uint32(table))
+
+	return
+}
+
+// Add or remove a policy routing rule
+func netlinkRule(msgType uint16, rule *Rule) error {
+	req, err := ruleRequest(msgType, rule)
+	if err != nil {
+		return err
+	}
+	return req.execute()
+}
+
+func ruleRequest(msgType uint16, rule *Rule) (req *netlinkRequest, err error) {
+	if rule.Table == 0 {
+		return nil, ErrorInvalidConfig
+	}
+
+	flags := uint16(0)
+	if msgType == unix.RTM_NEWRULE {
+		flags = unix.NLM_F_CREATE | unix.NLM_F_EXCL
+	}
+	req = newNetlinkRequest(msgType, flags)
+
+	family := uint8(unix.AF_INET)
+	if rule.isIPv6() {
+		family = unix.AF_INET6
+	}
+
+	hdr := make([]byte, sizeofFibRule)
+	hdr[0] = family
+	if rule.Dst != nil {
+		hdr[1] = prefixLength(rule.Dst)
+	}
+	if rule.Src != nil {
+		hdr[2] = prefixLength(rule.Src)
+	}
+	if rule.Table < 256 {
+		hdr[4] = uint8(rule.Table)
+	}
+	hdr[7] = frActToTbl
+	if rule.Invert {
+		nativeEndian.PutUint32(hdr[8:12], fibRuleInvert)
+	}
+	req.append(hdr)
+
+	if rule.Dst != nil {
+		_, ip := ipFamily(rule.Dst.IP)
+		req.attr(fraDst, ip.Mask(rule.Dst.Mask))
+	}
+	if rule.Src != nil {
+		_, ip := ipFamily(rule.Src.IP)
+		req.attr(fraSrc, ip.Mask(rule.Src.Mask))
+	}
+	if rule.Priority > 0 {
+		req.attrUint32(fraPriority, uint32(rule.Priority))
+	}
+	if rule.Mark > 0 {
+		req.attrUint32(fraFwmark, rule.Mark)
+	}
+	req.attrUint32(fraTable, uint32(rule.Table))
+
+	return
+}
@@ -0,0 +1,300 @@
+package tun
+
+import (
+	"bytes"
+	"net"
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func mustCIDR(t *testing.T, cidr string) *net.IPNet {
+	t.Helper()
+
+	ip, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	network.IP = ip
+	return network
+}
+
+func u32(v uint32) []byte {
+	b := make([]byte, 4)
+	nativeEndian.PutUint32(b, v)
+	return b
+}
+
+// Parses the message of a request into its header and the attributes following a family header
+func parseTestRequest(t *testing.T, req *netlinkRequest, hdrLen int) (msg syscall.NetlinkMessage, hdr []byte, attrs map[uint16][]byte) {
+	t.Helper()
+
+	msgs, err := syscall.ParseNetlinkMessage(req.message(7))
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("messages %v, %v", msgs, err)
+	}
+	msg = msgs[0]
+	if msg.Header.Seq != 7 || msg.Header.Flags&(unix.NLM_F_REQUEST|unix.NLM_F_ACK) != unix.NLM_F_REQUEST|unix.NLM_F_ACK {
+		t.Fatalf("unexpected header: %+v", msg.Header)
+	}
+	if len(msg.Data) < hdrLen {
+		t.Fatalf("short message: %d bytes", len(msg.Data))
+	}
+
+	hdr, b := msg.Data[:hdrLen], msg.Data[hdrLen:]
+	attrs = map[uint16][]byte{}
+	for len(b) >= unix.SizeofRtAttr {
+		length := int(nativeEndian.Uint16(b[0:2]))
+		if length < unix.SizeofRtAttr || length > len(b) {
+			t.Fatalf("invalid attribute length %d", length)
+		}
+		attrs[nativeEndian.Uint16(b[2:4])] = b[unix.SizeofRtAttr:length]
+
+		aligned := (length + unix.RTA_ALIGNTO - 1) & ^(unix.RTA_ALIGNTO - 1)
+		if aligned > len(b) {
+			aligned = len(b)
+		}
+		b = b[aligned:]
+	}
+	if len(b) != 0 {
+		t.Fatalf("%d trailing bytes", len(b))
+	}
+	return
+}
+
+func checkAttrs(t *testing.T, attrs map[uint16][]byte, want map[uint16][]byte) {
+	t.Helper()
+
+	if len(attrs) != len(want) {
+		t.Fatalf("attributes %v, want %v", attrs, want)
+	}
+	for k, v := range want {
+		if !bytes.Equal(attrs[k], v) {
+			t.Fatalf("attribute %d = %v, want %v", k, attrs[k], v)
+		}
+	}
+}
+
+func TestLinkRequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		req        *netlinkRequest
+		wantFlags  uint32
+		wantChange uint32
+		wantAttrs  map[uint16][]byte
+	}{
+		{
+			name:      "mtu keeps the link state",
+			req:       linkMTURequest(3, 1400),
+			wantAttrs: map[uint16][]byte{unix.IFLA_MTU: u32(1400)},
+		},
+		{
+			name:       "up",
+			req:        linkStateRequest(3, true),
+			wantFlags:  unix.IFF_UP,
+			wantChange: unix.IFF_UP,
+			wantAttrs:  map[uint16][]byte{},
+		},
+		{
+			name:       "down",
+			req:        linkStateRequest(3, false),
+			wantChange: unix.IFF_UP,
+			wantAttrs:  map[uint16][]byte{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, hdr, attrs := parseTestRequest(t, tt.req, unix.SizeofIfInfomsg)
+			if msg.Header.Type != unix.RTM_NEWLINK {
+				t.Fatalf("type = %d, want %d", msg.Header.Type, unix.RTM_NEWLINK)
+			}
+			if hdr[0] != unix.AF_UNSPEC || nativeEndian.Uint32(hdr[4:8]) != 3 {
+				t.Fatalf("unexpected ifinfomsg: %v", hdr)
+			}
+			if flags, change := nativeEndian.Uint32(hdr[8:12]), nativeEndian.Uint32(hdr[12:16]); flags != tt.wantFlags || change != tt.wantChange {
+				t.Fatalf("flags(%#x) change(%#x), want flags(%#x) change(%#x)", flags, change, tt.wantFlags, tt.wantChange)
+			}
+			checkAttrs(t, attrs, tt.wantAttrs)
+		})
+	}
+}
+
+func TestAddressRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		msgType   uint16
+		addr      string
+		wantHdr   []byte
+		wantFlags uint16
+		wantIP    net.IP
+	}{
+		{
+			name:      "add ipv4",
+			msgType:   unix.RTM_NEWADDR,
+			addr:      "10.0.0.1/24",
+			wantHdr:   []byte{unix.AF_INET, 24, 0, unix.RT_SCOPE_UNIVERSE, 5, 0, 0, 0},
+			wantFlags: unix.NLM_F_CREATE | unix.NLM_F_EXCL,
+			wantIP:    net.ParseIP("10.0.0.1").To4(),
+		},
+		{
+			name:    "remove ipv6",
+			msgType: unix.RTM_DELADDR,
+			addr:    "fd00::1/64",
+			wantHdr: []byte{unix.AF_INET6, 64, unix.IFA_F_NODAD, unix.RT_SCOPE_UNIVERSE, 5, 0, 0, 0},
+			wantIP:  net.ParseIP("fd00::1"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, hdr, attrs := parseTestRequest(t, addressRequest(tt.msgType, 5, mustCIDR(t, tt.addr)), unix.SizeofIfAddrmsg)
+			if msg.Header.Type != tt.msgType || msg.Header.Flags&^(unix.NLM_F_REQUEST|unix.NLM_F_ACK) != tt.wantFlags {
+				t.Fatalf("unexpected header: %+v", msg.Header)
+			}
+			// The index is in native byte order
+			want := append([]byte{}, tt.wantHdr...)
+			copy(want[4:8], u32(5))
+			if !bytes.Equal(hdr, want) {
+				t.Fatalf("ifaddrmsg = %v, want %v", hdr, want)
+			}
+			checkAttrs(t, attrs, map[uint16][]byte{
+				unix.IFA_LOCAL:   tt.wantIP,
+				unix.IFA_ADDRESS: tt.wantIP,
+			})
+		})
+	}
+}
+
+func TestRouteRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		route     *Route
+		wantHdr   []byte
+		wantAttrs map[uint16][]byte
+		wantErr   bool
+	}{
+		{
+			name:    "link route",
+			route:   &Route{Destination: mustCIDR(t, "10.1.2.3/16")},
+			wantHdr: []byte{unix.AF_INET, 16, 0, 0, unix.RT_TABLE_MAIN, unix.RTPROT_STATIC, unix.RT_SCOPE_LINK, unix.RTN_UNICAST, 0, 0, 0, 0},
+			wantAttrs: map[uint16][]byte{
+				unix.RTA_DST:   net.ParseIP("10.1.0.0").To4(),
+				unix.RTA_OIF:   u32(4),
+				unix.RTA_TABLE: u32(unix.RT_TABLE_MAIN),
+			},
+		},
+		{
+			name: "gateway, metric and large table",
+			route: &Route{
+				Destination: mustCIDR(t, "fd01::/48"),
+				Gateway:     net.ParseIP("fd00::1"),
+				Metric:      100,
+				Table:       1000,
+			},
+			wantHdr: []byte{unix.AF_INET6, 48, 0, 0, 0, unix.RTPROT_STATIC, unix.RT_SCOPE_UNIVERSE, unix.RTN_UNICAST, 0, 0, 0, 0},
+			wantAttrs: map[uint16][]byte{
+				unix.RTA_DST:      net.ParseIP("fd01::"),
+				unix.RTA_OIF:      u32(4),
+				unix.RTA_GATEWAY:  net.ParseIP("fd00::1"),
+				unix.RTA_PRIORITY: u32(100),
+				unix.RTA_TABLE:    u32(1000),
+			},
+		},
+		{
+			name:    "without destination",
+			route:   &Route{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := routeRequest(unix.RTM_NEWROUTE, 4, tt.route)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("request built")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			msg, hdr, attrs := parseTestRequest(t, req, unix.SizeofRtMsg)
+			if msg.Header.Type != unix.RTM_NEWROUTE || msg.Header.Flags&unix.NLM_F_CREATE == 0 {
+				t.Fatalf("unexpected header: %+v", msg.Header)
+			}
+			if !bytes.Equal(hdr, tt.wantHdr) {
+				t.Fatalf("rtmsg = %v, want %v", hdr, tt.wantHdr)
+			}
+			checkAttrs(t, attrs, tt.wantAttrs)
+		})
+	}
+}
+
+func TestRuleRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		rule      *Rule
+		wantHdr   []byte
+		wantAttrs map[uint16][]byte
+		wantErr   bool
+	}{
+		{
+			name: "source and mark",
+			rule: &Rule{Priority: 100, Table: 200, Src: mustCIDR(t, "10.0.0.5/24"), Mark: 0x10},
+			wantHdr: []byte{
+				unix.AF_INET, 0, 24, 0, 200, 0, 0, frActToTbl,
+				0, 0, 0, 0,
+			},
+			wantAttrs: map[uint16][]byte{
+				fraSrc:      net.ParseIP("10.0.0.0").To4(),
+				fraPriority: u32(100),
+				fraFwmark:   u32(0x10),
+				fraTable:    u32(200),
+			},
+		},
+		{
+			name: "inverted ipv6 destination in a large table",
+			rule: &Rule{Table: 1000, Dst: mustCIDR(t, "fd00::/8"), Invert: true},
+			wantHdr: append([]byte{
+				unix.AF_INET6, 8, 0, 0, 0, 0, 0, frActToTbl,
+			}, u32(fibRuleInvert)...),
+			wantAttrs: map[uint16][]byte{
+				fraDst:   net.ParseIP("fd00::"),
+				fraTable: u32(1000),
+			},
+		},
+		{
+			name:    "without table",
+			rule:    &Rule{Priority: 100},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := ruleRequest(unix.RTM_NEWRULE, tt.rule)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("request built")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			msg, hdr, attrs := parseTestRequest(t, req, sizeofFibRule)
+			if msg.Header.Type != unix.RTM_NEWRULE {
+				t.Fatalf("type = %d, want %d", msg.Header.Type, unix.RTM_NEWRULE)
+			}
+			if !bytes.Equal(hdr, tt.wantHdr) {
+				t.Fatalf("fib_rule_hdr = %v, want %v", hdr, tt.wantHdr)
+			}
+			checkAttrs(t, attrs, tt.wantAttrs)
+		})
+	}
+}
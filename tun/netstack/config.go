@@ -0,0 +1,88 @@
+package netstack
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	DefaultMTU            = 1280
+	DefaultQueueSize      = 1024
+	DefaultForwardTimeout = time.Minute
+	DefaultMaxFlows       = 4096
+	DefaultFinTimeout     = time.Minute
+)
+
+// Stack Config
+type Config struct {
+	// Addresses of the Stack
+	Addresses []net.IP
+	// Maximum transmission unit
+	MTU int
+	// No. of outbound packets buffered before they are dropped
+	QueueSize int
+
+	// Forward TCP, UDP and ICMP echo traffic to addresses which are not local
+	// using sockets of the host, which acts as a source NAT
+	// Used by exit nodes
+	Forward bool
+	// Idle timeout of forwarded UDP and ICMP flows
+	ForwardTimeout time.Duration
+	// Maximum no. of forwarded flows
+	MaxFlows int
+
+	// Time a TCP connection closed by the application waits in FIN-WAIT-2 for the FIN of the peer
+	FinTimeout time.Duration
+}
+
+// Create a Stack Config from CIDR strings
+func NewConfig(mtu int, addresses ...string) (config Config, err error) {
+	config.MTU = mtu
+	for _, address := range addresses {
+		ip, _, err := net.ParseCIDR(address)
+		if err != nil {
+			if ip = net.ParseIP(address); ip == nil {
+				return Config{}, fmt.Errorf("netstack: invalid address: %s", address)
+			}
+		}
+		config.Addresses = append(config.Addresses, ip)
+	}
+	return
+}
+
+func (c *Config) init() (err error) {
+	if len(c.Addresses) == 0 && !c.Forward {
+		return fmt.Errorf("netstack: at least one address is required")
+	}
+
+	for i, ip := range c.Addresses {
+		if ip4 := ip.To4(); ip4 != nil {
+			c.Addresses[i] = ip4
+		}
+	}
+
+	if c.MTU == 0 {
+		c.MTU = DefaultMTU
+	}
+	if c.MTU < 576 {
+		return fmt.Errorf("netstack: mtu(%d) too small", c.MTU)
+	}
+
+	if c.QueueSize == 0 {
+		c.QueueSize = DefaultQueueSize
+	}
+
+	if c.ForwardTimeout == 0 {
+		c.ForwardTimeout = DefaultForwardTimeout
+	}
+	if c.MaxFlows == 0 {
+		c.MaxFlows = DefaultMaxFlows
+	}
+
+	if c.FinTimeout == 0 {
+		c.FinTimeout = DefaultFinTimeout
+	}
+
+	return
+}
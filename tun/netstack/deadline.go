@@ -0,0 +1,32 @@
+package netstack
+
+import (
+	"time"
+)
+
+// Timer which never fires when the deadline is zero
+type deadlineTimer struct {
+	C     <-chan time.Time
+	timer *time.Timer
+}
+
+func newDeadlineTimer(deadline time.Time) (t *deadlineTimer, err error) {
+	t = &deadlineTimer{}
+	if deadline.IsZero() {
+		return
+	}
+
+	d := time.Until(deadline)
+	if d <= 0 {
+		return nil, ErrorTimeout
+	}
+	t.timer = time.NewTimer(d)
+	t.C = t.timer.C
+	return
+}
+
+func (t *deadlineTimer) Stop() {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+}
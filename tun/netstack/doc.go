@@ -0,0 +1,6 @@
+// Package netstack provides a userspace TCP/IP stack which can be used in place of a TUN Device
+//
+// The Stack exchanges raw IP packets through Read and Write like a TUN Device,
+// and exposes Dial and Listen functions for its addresses directly to Go code.
+// It does not need elevated privileges or a kernel device.
+package netstack
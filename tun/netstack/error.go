@@ -0,0 +1,25 @@
+package netstack
+
+import (
+	"errors"
+)
+
+var (
+	ErrorClosed            = errors.New("netstack: closed")
+	ErrorConnectionReset   = errors.New("netstack: connection reset")
+	ErrorConnectionRefused = errors.New("netstack: connection refused")
+	ErrorTimeout           = &timeoutError{}
+	ErrorAddressInUse      = errors.New("netstack: address in use")
+	ErrorNoRoute           = errors.New("netstack: no local address for destination")
+	ErrorInvalidPacket     = errors.New("netstack: invalid packet")
+	ErrorNotConnected      = errors.New("netstack: not connected")
+	ErrorUnsupported       = errors.New("netstack: unsupported network")
+	ErrorMessageTooLong    = errors.New("netstack: message too long")
+)
+
+// Satisfies net.Error so that callers can check for timeouts
+type timeoutError struct{}
+
+func (e *timeoutError) Error() string   { return "netstack: i/o timeout" }
+func (e *timeoutError) Timeout() bool   { return true }
+func (e *timeoutError) Temporary() bool { return true }
@@ -0,0 +1,395 @@
+package netstack
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/supergiant-hq/xnet/util"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	forwardDialTimeout  = time.Second * 10
+	forwardReapInterval = time.Second * 10
+)
+
+// Forwards traffic to addresses which are not local using sockets of the host
+// Connections are tracked in userspace, so no firewall rules are needed
+type forwarder struct {
+	stack *Stack
+	// TCP connections being dialed by the host
+	pending map[flowKey]bool
+
+	// Source endpoint -> *forwardFlow
+	udpFlows map[endpoint]*forwardFlow
+	// Source IP and echo ID -> *forwardFlow
+	icmpFlows map[endpoint]*forwardFlow
+	tcpFlows  int
+	mutex     sync.Mutex
+
+	ticker *util.Ticker
+}
+
+// UDP or ICMP flow forwarded using a socket of the host
+type forwardFlow struct {
+	src      endpoint
+	conn     net.PacketConn
+	lastSeen time.Time
+	// Remote addresses the flow sent to, replies from other addresses are dropped
+	remotes map[endpoint]bool
+	// ICMP socket is a raw socket receiving all echo replies
+	raw bool
+}
+
+func newForwarder(s *Stack) *forwarder {
+	f := &forwarder{
+		stack:     s,
+		pending:   make(map[flowKey]bool),
+		udpFlows:  make(map[endpoint]*forwardFlow),
+		icmpFlows: make(map[endpoint]*forwardFlow),
+	}
+
+	f.ticker = util.NewTicker(forwardReapInterval, f.reap)
+	f.ticker.Start()
+
+	return f
+}
+
+// Destinations which are never forwarded as they would reach the host itself
+func forwardable(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsUnspecified() && !ip.IsMulticast() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.Equal(net.IPv4bcast)
+}
+
+// No. of forwarded flows
+func (f *forwarder) flows() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.tcpFlows + len(f.udpFlows) + len(f.icmpFlows)
+}
+
+func (f *forwarder) full() bool {
+	return f.tcpFlows+len(f.udpFlows)+len(f.icmpFlows) >= f.stack.config.MaxFlows
+}
+
+// Dial the destination of a SYN using the host
+// The handshake is completed once the host is connected, or the SYN is reset if it fails
+func (f *forwarder) handleSyn(key flowKey, seg tcpSegment) {
+	f.mutex.Lock()
+	if f.pending[key] {
+		// Retransmitted SYN
+		f.mutex.Unlock()
+		return
+	}
+	if f.full() {
+		f.mutex.Unlock()
+		f.stack.log.Debugln("Forward error: too many flows")
+		f.stack.writeTCPReset(key, seg)
+		return
+	}
+	f.pending[key] = true
+	f.tcpFlows++
+	f.mutex.Unlock()
+
+	go func() {
+		defer func() {
+			f.mutex.Lock()
+			f.tcpFlows--
+			f.mutex.Unlock()
+		}()
+
+		dst := (&net.TCPAddr{IP: key.local.IP(), Port: int(key.local.port)}).String()
+		rconn, err := net.DialTimeout("tcp", dst, forwardDialTimeout)
+
+		f.mutex.Lock()
+		delete(f.pending, key)
+		f.mutex.Unlock()
+
+		if err != nil {
+			f.stack.log.Debugf("Forward error: dialing %s: %s", dst, err.Error())
+			f.stack.writeTCPReset(key, seg)
+			return
+		}
+		remote := rconn.(*net.TCPConn)
+		defer remote.Close()
+
+		c := f.stack.acceptSyn(key, seg, nil)
+		if c == nil {
+			return
+		}
+		defer c.Close()
+
+		c.mutex.Lock()
+		var deadline time.Time
+		c.wait(func() bool {
+			return c.state != tcpStateSynReceived
+		}, &deadline, nil)
+		established := c.state == tcpStateEstablished
+		c.mutex.Unlock()
+		if !established {
+			return
+		}
+
+		f.splice(c, remote)
+	}()
+}
+
+// Copy data between a connection of the Stack and a connection of the host
+func (f *forwarder) splice(c *TCPConn, remote *net.TCPConn) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		io.Copy(remote, c)
+		remote.CloseWrite()
+	}()
+	go func() {
+		defer wg.Done()
+		io.Copy(c, remote)
+		c.CloseWrite()
+	}()
+	wg.Wait()
+}
+
+func (f *forwarder) handleUDP(pkt ipPacket) {
+	if len(pkt.payload) < udpHeaderLen {
+		return
+	}
+	srcPort := binary.BigEndian.Uint16(pkt.payload[0:2])
+	dstPort := binary.BigEndian.Uint16(pkt.payload[2:4])
+	length := int(binary.BigEndian.Uint16(pkt.payload[4:6]))
+	if length < udpHeaderLen || length > len(pkt.payload) {
+		return
+	}
+
+	src := newEndpoint(pkt.src, srcPort)
+	dst := newEndpoint(pkt.dst, dstPort)
+
+	f.mutex.Lock()
+	flow, ok := f.udpFlows[src]
+	if !ok {
+		if f.full() {
+			f.mutex.Unlock()
+			f.stack.log.Debugln("Forward error: too many flows")
+			return
+		}
+
+		network := "udp6"
+		if pkt.dst.To4() != nil {
+			network = "udp4"
+		}
+		conn, err := net.ListenUDP(network, nil)
+		if err != nil {
+			f.mutex.Unlock()
+			f.stack.log.Errorln("Forward error: opening udp socket:", err.Error())
+			return
+		}
+
+		flow = &forwardFlow{
+			src:     src,
+			conn:    conn,
+			remotes: map[endpoint]bool{},
+		}
+		f.udpFlows[src] = flow
+		go f.udpLoop(flow)
+	}
+	flow.lastSeen = time.Now()
+	flow.remotes[dst] = true
+	f.mutex.Unlock()
+
+	flow.conn.WriteTo(pkt.payload[udpHeaderLen:length], &net.UDPAddr{IP: pkt.dst, Port: int(dstPort)})
+}
+
+// Send replies received by the host back to the source
+func (f *forwarder) udpLoop(flow *forwardFlow) {
+	buf := make([]byte, f.stack.config.MTU)
+	for {
+		n, addr, err := flow.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		raddr := addr.(*net.UDPAddr)
+		remote := newEndpoint(raddr.IP, uint16(raddr.Port))
+
+		f.mutex.Lock()
+		allowed := flow.remotes[remote]
+		if allowed {
+			flow.lastSeen = time.Now()
+		}
+		f.mutex.Unlock()
+		if !allowed {
+			continue
+		}
+
+		f.stack.writeUDP(remote, flow.src, buf[:n])
+	}
+}
+
+func (f *forwarder) handleICMP(pkt ipPacket) {
+	if len(pkt.payload) < 8 {
+		return
+	}
+
+	var (
+		echo     icmp.Type
+		networks []string
+		address  string
+	)
+	switch {
+	case pkt.proto == protoICMP && pkt.payload[0] == icmpv4EchoRequest:
+		echo = ipv4.ICMPTypeEcho
+		networks = []string{"udp4", "ip4:icmp"}
+		address = "0.0.0.0"
+	case pkt.proto == protoICMPv6 && pkt.payload[0] == icmpv6EchoRequest:
+		echo = ipv6.ICMPTypeEchoRequest
+		networks = []string{"udp6", "ip6:ipv6-icmp"}
+		address = "::"
+	default:
+		return
+	}
+	id := binary.BigEndian.Uint16(pkt.payload[4:6])
+	seq := binary.BigEndian.Uint16(pkt.payload[6:8])
+	src := newEndpoint(pkt.src, id)
+	dst := newEndpoint(pkt.dst, 0)
+
+	f.mutex.Lock()
+	flow, ok := f.icmpFlows[src]
+	if !ok {
+		if f.full() {
+			f.mutex.Unlock()
+			f.stack.log.Debugln("Forward error: too many flows")
+			return
+		}
+
+		var (
+			conn *icmp.PacketConn
+			err  error
+		)
+		// Unprivileged ping sockets are preferred over raw sockets
+		for _, network := range networks {
+			if conn, err = icmp.ListenPacket(network, address); err == nil {
+				flow = &forwardFlow{
+					src:     src,
+					conn:    conn,
+					remotes: map[endpoint]bool{},
+					raw:     network != networks[0],
+				}
+				break
+			}
+		}
+		if flow == nil {
+			f.mutex.Unlock()
+			f.stack.log.Errorln("Forward error: opening icmp socket:", err.Error())
+			return
+		}
+
+		f.icmpFlows[src] = flow
+		go f.icmpLoop(flow, echo.Protocol())
+	}
+	flow.lastSeen = time.Now()
+	flow.remotes[dst] = true
+	f.mutex.Unlock()
+
+	msg := icmp.Message{
+		Type: echo,
+		Body: &icmp.Echo{
+			ID:   int(id),
+			Seq:  int(seq),
+			Data: pkt.payload[8:],
+		},
+	}
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return
+	}
+
+	var addr net.Addr = &net.UDPAddr{IP: pkt.dst}
+	if flow.raw {
+		addr = &net.IPAddr{IP: pkt.dst}
+	}
+	flow.conn.WriteTo(b, addr)
+}
+
+// Send echo replies received by the host back to the source
+func (f *forwarder) icmpLoop(flow *forwardFlow, proto int) {
+	buf := make([]byte, f.stack.config.MTU)
+	for {
+		n, addr, err := flow.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		msg, err := icmp.ParseMessage(proto, buf[:n])
+		if err != nil {
+			continue
+		}
+		echo, ok := msg.Body.(*icmp.Echo)
+		if !ok || (msg.Type != ipv4.ICMPTypeEchoReply && msg.Type != ipv6.ICMPTypeEchoReply) {
+			continue
+		}
+		// Raw sockets receive the replies of all flows
+		if flow.raw && uint16(echo.ID) != flow.src.port {
+			continue
+		}
+
+		var ip net.IP
+		switch raddr := addr.(type) {
+		case *net.UDPAddr:
+			ip = raddr.IP
+		case *net.IPAddr:
+			ip = raddr.IP
+		default:
+			continue
+		}
+		remote := newEndpoint(ip, 0)
+
+		f.mutex.Lock()
+		allowed := flow.remotes[remote]
+		if allowed {
+			flow.lastSeen = time.Now()
+		}
+		f.mutex.Unlock()
+		if !allowed {
+			continue
+		}
+
+		f.stack.writeEchoReply(remote.IP(), flow.src.IP(), flow.src.port, uint16(echo.Seq), echo.Data)
+	}
+}
+
+// Close idle flows
+func (f *forwarder) reap() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	deadline := time.Now().Add(-f.stack.config.ForwardTimeout)
+	for _, flows := range []map[endpoint]*forwardFlow{f.udpFlows, f.icmpFlows} {
+		for key, flow := range flows {
+			if flow.lastSeen.Before(deadline) {
+				flow.conn.Close()
+				delete(flows, key)
+			}
+		}
+	}
+}
+
+func (f *forwarder) close() {
+	f.ticker.Stop()
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for _, flows := range []map[endpoint]*forwardFlow{f.udpFlows, f.icmpFlows} {
+		for key, flow := range flows {
+			flow.conn.Close()
+			delete(flows, key)
+		}
+	}
+}
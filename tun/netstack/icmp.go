@@ -0,0 +1,56 @@
+package netstack
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+const (
+	icmpv4EchoRequest = 8
+	icmpv4EchoReply   = 0
+	icmpv6EchoRequest = 128
+	icmpv6EchoReply   = 129
+)
+
+// Replies to echo requests addressed to the Stack
+func (s *Stack) handleICMP(pkt ipPacket) {
+	if len(pkt.payload) < 8 {
+		return
+	}
+
+	reply := append([]byte(nil), pkt.payload...)
+	reply[2], reply[3] = 0, 0
+
+	switch {
+	case pkt.proto == protoICMP && pkt.payload[0] == icmpv4EchoRequest:
+		reply[0] = icmpv4EchoReply
+		binary.BigEndian.PutUint16(reply[2:4], checksum(reply, 0))
+	case pkt.proto == protoICMPv6 && pkt.payload[0] == icmpv6EchoRequest:
+		reply[0] = icmpv6EchoReply
+		binary.BigEndian.PutUint16(reply[2:4], transportChecksum(pkt.dst, pkt.src, protoICMPv6, reply))
+	default:
+		return
+	}
+
+	s.send(pkt.dst, pkt.src, pkt.proto, reply)
+}
+
+// Send an echo reply from src to dst
+func (s *Stack) writeEchoReply(src, dst net.IP, id, seq uint16, data []byte) {
+	reply := make([]byte, 8+len(data))
+	binary.BigEndian.PutUint16(reply[4:6], id)
+	binary.BigEndian.PutUint16(reply[6:8], seq)
+	copy(reply[8:], data)
+
+	proto := uint8(protoICMP)
+	if dst.To4() != nil {
+		reply[0] = icmpv4EchoReply
+		binary.BigEndian.PutUint16(reply[2:4], checksum(reply, 0))
+	} else {
+		proto = protoICMPv6
+		reply[0] = icmpv6EchoReply
+		binary.BigEndian.PutUint16(reply[2:4], transportChecksum(src, dst, protoICMPv6, reply))
+	}
+
+	s.send(src, dst, proto, reply)
+}
@@ -0,0 +1,179 @@
+package netstack
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+const (
+	protoICMP   = 1
+	protoTCP    = 6
+	protoUDP    = 17
+	protoICMPv6 = 58
+
+	ipv4HeaderLen = 20
+	ipv6HeaderLen = 40
+	defaultTTL    = 64
+)
+
+// Parsed IP packet
+type ipPacket struct {
+	version int
+	src     net.IP
+	dst     net.IP
+	proto   uint8
+	payload []byte
+}
+
+func parseIPPacket(b []byte) (p ipPacket, err error) {
+	if len(b) < 1 {
+		err = ErrorInvalidPacket
+		return
+	}
+
+	switch b[0] >> 4 {
+	case 4:
+		if len(b) < ipv4HeaderLen {
+			return p, ErrorInvalidPacket
+		}
+		ihl := int(b[0]&0x0f) * 4
+		total := int(binary.BigEndian.Uint16(b[2:4]))
+		if ihl < ipv4HeaderLen || total < ihl || total > len(b) {
+			return p, ErrorInvalidPacket
+		}
+		// Fragments are not reassembled
+		if flags := binary.BigEndian.Uint16(b[6:8]); flags&0x2000 != 0 || flags&0x1fff != 0 {
+			return p, ErrorInvalidPacket
+		}
+		p = ipPacket{
+			version: 4,
+			src:     net.IP(b[12:16]),
+			dst:     net.IP(b[16:20]),
+			proto:   b[9],
+			payload: b[ihl:total],
+		}
+
+	case 6:
+		if len(b) < ipv6HeaderLen {
+			return p, ErrorInvalidPacket
+		}
+		total := ipv6HeaderLen + int(binary.BigEndian.Uint16(b[4:6]))
+		if total > len(b) {
+			return p, ErrorInvalidPacket
+		}
+		// Extension headers are not supported
+		p = ipPacket{
+			version: 6,
+			src:     net.IP(b[8:24]),
+			dst:     net.IP(b[24:40]),
+			proto:   b[6],
+			payload: b[ipv6HeaderLen:total],
+		}
+
+	default:
+		err = ErrorInvalidPacket
+	}
+
+	return
+}
+
+// Builds an IP packet around the payload
+// The payload checksum should already be filled
+func buildIPPacket(src, dst net.IP, proto uint8, payload []byte) (b []byte) {
+	if src4, dst4 := src.To4(), dst.To4(); src4 != nil && dst4 != nil {
+		b = make([]byte, ipv4HeaderLen+len(payload))
+		b[0] = 0x45
+		binary.BigEndian.PutUint16(b[2:4], uint16(len(b)))
+		// Don't Fragment
+		binary.BigEndian.PutUint16(b[6:8], 0x4000)
+		b[8] = defaultTTL
+		b[9] = proto
+		copy(b[12:16], src4)
+		copy(b[16:20], dst4)
+		binary.BigEndian.PutUint16(b[10:12], checksum(b[:ipv4HeaderLen], 0))
+		copy(b[ipv4HeaderLen:], payload)
+		return
+	}
+
+	b = make([]byte, ipv6HeaderLen+len(payload))
+	b[0] = 0x60
+	binary.BigEndian.PutUint16(b[4:6], uint16(len(payload)))
+	b[6] = proto
+	b[7] = defaultTTL
+	copy(b[8:24], src.To16())
+	copy(b[24:40], dst.To16())
+	copy(b[ipv6HeaderLen:], payload)
+	return
+}
+
+// Internet checksum (RFC 1071)
+func checksum(b []byte, initial uint32) uint16 {
+	sum := initial
+	for len(b) >= 2 {
+		sum += uint32(b[0])<<8 | uint32(b[1])
+		b = b[2:]
+	}
+	if len(b) == 1 {
+		sum += uint32(b[0]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = (sum & 0xffff) + (sum >> 16)
+	}
+	return ^uint16(sum)
+}
+
+// Sum of the pseudo header used in TCP, UDP and ICMPv6 checksums
+func pseudoHeaderSum(src, dst net.IP, proto uint8, length int) (sum uint32) {
+	add := func(b []byte) {
+		for i := 0; i+1 < len(b); i += 2 {
+			sum += uint32(b[i])<<8 | uint32(b[i+1])
+		}
+	}
+
+	if src4, dst4 := src.To4(), dst.To4(); src4 != nil && dst4 != nil {
+		add(src4)
+		add(dst4)
+	} else {
+		add(src.To16())
+		add(dst.To16())
+	}
+	sum += uint32(proto)
+	sum += uint32(length)
+	return
+}
+
+// Transport checksum over the pseudo header and the segment
+func transportChecksum(src, dst net.IP, proto uint8, segment []byte) uint16 {
+	return checksum(segment, pseudoHeaderSum(src, dst, proto, len(segment)))
+}
+
+// Local or remote endpoint of a flow
+type endpoint struct {
+	ip   [net.IPv6len]byte
+	port uint16
+}
+
+func newEndpoint(ip net.IP, port uint16) (e endpoint) {
+	copy(e.ip[:], ip.To16())
+	e.port = port
+	return
+}
+
+func (e endpoint) IP() net.IP {
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, e.ip[:])
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
+}
+
+func (e endpoint) unspecified() bool {
+	return e.IP().IsUnspecified()
+}
+
+// Identifies a flow by its local and remote endpoints
+type flowKey struct {
+	local  endpoint
+	remote endpoint
+}
@@ -0,0 +1,377 @@
+package netstack
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ephemeralPortMin = 32768
+	ephemeralPortMax = 60999
+)
+
+// Port of a transport protocol
+type stackPort struct {
+	proto uint8
+	port  uint16
+}
+
+// Userspace TCP/IP Stack
+type Stack struct {
+	config Config
+
+	outbound chan []byte
+	closed   chan struct{}
+
+	tcpConns     *sync.Map
+	tcpListeners *sync.Map
+	udpConns     *sync.Map
+	ports        map[stackPort]bool
+	pmutex       sync.Mutex
+	rnd          *rand.Rand
+	forwarder    *forwarder
+
+	// Exit Channel
+	Exit chan bool
+	// Closed Status
+	Closed bool
+	mutex  sync.Mutex
+	log    *logrus.Entry
+}
+
+// Create a Stack
+func New(log *logrus.Logger, config Config) (s *Stack, err error) {
+	if err = config.init(); err != nil {
+		return
+	}
+
+	s = &Stack{
+		config:   config,
+		outbound: make(chan []byte, config.QueueSize),
+		closed:   make(chan struct{}),
+
+		tcpConns:     new(sync.Map),
+		tcpListeners: new(sync.Map),
+		udpConns:     new(sync.Map),
+		ports:        make(map[stackPort]bool),
+		rnd:          rand.New(rand.NewSource(time.Now().UnixNano())),
+
+		Exit: make(chan bool, 1),
+		log:  log.WithField("prefix", "NETSTACK"),
+	}
+
+	if config.Forward {
+		s.forwarder = newForwarder(s)
+	}
+
+	s.log.Infof("Stack created: addresses(%v) mtu(%d) forward(%v)", config.Addresses, config.MTU, config.Forward)
+	return
+}
+
+// Addresses of the Stack
+func (s *Stack) Addresses() []net.IP {
+	return s.config.Addresses
+}
+
+// Maximum transmission unit
+func (s *Stack) MTU() int {
+	return s.config.MTU
+}
+
+// Name of the Stack
+func (s *Stack) Name() string {
+	return "netstack"
+}
+
+// Read the next packet sent by the Stack
+// Blocks until a packet is available, packets sent before the Stack was closed are still read
+func (s *Stack) Read(b []byte) (n int, err error) {
+	var pkt []byte
+	select {
+	case pkt = <-s.outbound:
+	case <-s.closed:
+		select {
+		case pkt = <-s.outbound:
+		default:
+			return 0, io.EOF
+		}
+	}
+	if len(b) < len(pkt) {
+		return 0, io.ErrShortBuffer
+	}
+	return copy(b, pkt), nil
+}
+
+// Deliver a packet to the Stack
+// Packets which are invalid or not addressed to the Stack are dropped
+func (s *Stack) Write(b []byte) (n int, err error) {
+	select {
+	case <-s.closed:
+		return 0, ErrorClosed
+	default:
+	}
+
+	pkt, err := parseIPPacket(b)
+	if err != nil {
+		s.log.Debugln("Dropping packet:", err.Error())
+		return len(b), nil
+	}
+	local := s.isLocal(pkt.dst)
+	if !local && s.forwarder == nil {
+		s.log.Debugf("Dropping packet to (%s): not local", pkt.dst.String())
+		return len(b), nil
+	}
+
+	// The buffer belongs to the caller
+	pkt.payload = append([]byte(nil), pkt.payload...)
+	pkt.src = append(net.IP(nil), pkt.src...)
+	pkt.dst = append(net.IP(nil), pkt.dst...)
+
+	switch pkt.proto {
+	case protoTCP:
+		s.handleTCP(pkt)
+	case protoUDP:
+		if local {
+			s.handleUDP(pkt)
+		} else if forwardable(pkt.dst) {
+			s.forwarder.handleUDP(pkt)
+		}
+	case protoICMP, protoICMPv6:
+		if local {
+			s.handleICMP(pkt)
+		} else if forwardable(pkt.dst) {
+			s.forwarder.handleICMP(pkt)
+		}
+	default:
+		s.log.Debugf("Dropping packet with protocol(%d)", pkt.proto)
+	}
+
+	return len(b), nil
+}
+
+func (s *Stack) isLocal(ip net.IP) bool {
+	for _, addr := range s.config.Addresses {
+		if addr.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Pick the Stack address to use when sending to a remote address
+func (s *Stack) sourceFor(remote net.IP) (ip net.IP, err error) {
+	v4 := remote.To4() != nil
+	for _, addr := range s.config.Addresses {
+		if (addr.To4() != nil) == v4 {
+			return addr, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrorNoRoute, remote.String())
+}
+
+func (s *Stack) send(src, dst net.IP, proto uint8, payload []byte) {
+	select {
+	case <-s.closed:
+		return
+	default:
+	}
+
+	pkt := buildIPPacket(src, dst, proto, payload)
+	select {
+	case s.outbound <- pkt:
+	default:
+		s.log.Debugln("Outbound queue full: dropping packet")
+	}
+}
+
+// Allocate a port of a protocol, an ephemeral port if it is 0
+// Ports in use are not allocated again
+func (s *Stack) allocatePort(proto uint8, port uint16) (uint16, error) {
+	s.pmutex.Lock()
+	defer s.pmutex.Unlock()
+
+	if port != 0 {
+		if s.ports[stackPort{proto, port}] {
+			return 0, ErrorAddressInUse
+		}
+		s.ports[stackPort{proto, port}] = true
+		return port, nil
+	}
+
+	span := ephemeralPortMax - ephemeralPortMin
+	start := s.rnd.Intn(span)
+	for i := 0; i < span; i++ {
+		port = uint16(ephemeralPortMin + (start+i)%span)
+		if !s.ports[stackPort{proto, port}] {
+			s.ports[stackPort{proto, port}] = true
+			return port, nil
+		}
+	}
+	return 0, ErrorAddressInUse
+}
+
+func (s *Stack) releasePort(proto uint8, port uint16) {
+	s.pmutex.Lock()
+	defer s.pmutex.Unlock()
+
+	delete(s.ports, stackPort{proto, port})
+}
+
+func (s *Stack) resolve(network, address string) (ip net.IP, port uint16, err error) {
+	host, sport, err := net.SplitHostPort(address)
+	if err != nil {
+		return
+	}
+
+	if len(host) > 0 {
+		if ip = net.ParseIP(host); ip == nil {
+			err = fmt.Errorf("netstack: invalid ip: %s", host)
+			return
+		}
+	} else if network == "tcp6" || network == "udp6" {
+		ip = net.IPv6unspecified
+	} else {
+		ip = net.IPv4zero
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+
+	p, err := strconv.ParseUint(sport, 10, 16)
+	if err != nil {
+		err = fmt.Errorf("netstack: invalid port: %s", sport)
+		return
+	}
+	port = uint16(p)
+
+	return
+}
+
+// No. of flows forwarded using sockets of the host
+func (s *Stack) ForwardedFlows() int {
+	if s.forwarder == nil {
+		return 0
+	}
+	return s.forwarder.flows()
+}
+
+// Dial connects to an address on the overlay
+// Supported networks are "tcp", "tcp4", "tcp6", "udp", "udp4" and "udp6"
+func (s *Stack) Dial(network, address string) (net.Conn, error) {
+	return s.DialContext(context.Background(), network, address)
+}
+
+// DialContext connects to an address on the overlay using the provided context
+func (s *Stack) DialContext(ctx context.Context, network, address string) (conn net.Conn, err error) {
+	ip, port, err := s.resolve(network, address)
+	if err != nil {
+		return
+	}
+
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		var c *TCPConn
+		if c, err = s.dialTCP(ctx, ip, port); err != nil {
+			return
+		}
+		return c, nil
+	case "udp", "udp4", "udp6":
+		var c *UDPConn
+		if c, err = s.dialUDP(ip, port); err != nil {
+			return
+		}
+		return c, nil
+	default:
+		err = fmt.Errorf("%w: %s", ErrorUnsupported, network)
+		return
+	}
+}
+
+// Listen for TCP connections on a local address
+// Supported networks are "tcp", "tcp4" and "tcp6"
+func (s *Stack) Listen(network, address string) (l net.Listener, err error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		err = fmt.Errorf("%w: %s", ErrorUnsupported, network)
+		return
+	}
+
+	ip, port, err := s.resolve(network, address)
+	if err != nil {
+		return
+	}
+
+	tl, err := s.listenTCP(ip, port)
+	if err != nil {
+		return
+	}
+	return tl, nil
+}
+
+// ListenPacket listens for UDP datagrams on a local address
+// Supported networks are "udp", "udp4" and "udp6"
+func (s *Stack) ListenPacket(network, address string) (conn net.PacketConn, err error) {
+	switch network {
+	case "udp", "udp4", "udp6":
+	default:
+		err = fmt.Errorf("%w: %s", ErrorUnsupported, network)
+		return
+	}
+
+	ip, port, err := s.resolve(network, address)
+	if err != nil {
+		return
+	}
+
+	uc, err := s.listenUDP(ip, port)
+	if err != nil {
+		return
+	}
+	return uc, nil
+}
+
+// Close the Stack along with all its connections
+func (s *Stack) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.Closed {
+		return nil
+	}
+	s.Closed = true
+
+	s.tcpListeners.Range(func(key, value interface{}) bool {
+		value.(*TCPListener).Close()
+		return true
+	})
+	s.tcpConns.Range(func(key, value interface{}) bool {
+		value.(*TCPConn).reset(ErrorClosed)
+		return true
+	})
+	s.udpConns.Range(func(key, value interface{}) bool {
+		value.(*UDPConn).Close()
+		return true
+	})
+	if s.forwarder != nil {
+		s.forwarder.close()
+	}
+	// Packets sent while closing are still read
+	close(s.closed)
+
+	select {
+	case s.Exit <- true:
+	default:
+	}
+
+	s.log.Warnln("Stack closed")
+	return nil
+}
@@ -0,0 +1,85 @@
+package netstack
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestStackPorts(t *testing.T) {
+	s := newTestStack(t, Config{Addresses: []net.IP{net.ParseIP("10.0.0.1")}})
+
+	l, err := s.Listen("tcp", ":80")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		listen  func() (io.Closer, error)
+		wantErr error
+	}{
+		{
+			name:    "tcp port in use",
+			listen:  func() (io.Closer, error) { return s.Listen("tcp", "10.0.0.1:80") },
+			wantErr: ErrorAddressInUse,
+		},
+		{
+			name:   "udp port of tcp listener",
+			listen: func() (io.Closer, error) { return s.ListenPacket("udp", ":80") },
+		},
+		{
+			name:   "free tcp port",
+			listen: func() (io.Closer, error) { return s.Listen("tcp", ":81") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := tt.listen()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if c != nil {
+				c.Close()
+			}
+		})
+	}
+
+	// Ports are released on close
+	l.Close()
+	l, err = s.Listen("tcp", ":80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+}
+
+func TestStackClose(t *testing.T) {
+	s := newTestStack(t, Config{Addresses: []net.IP{net.ParseIP("10.0.0.1")}})
+
+	c, err := s.Dial("udp", "10.0.0.2:53")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Write([]byte("query")); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// Sending after close is a no-op
+	s.send(net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2"), protoUDP, []byte("late"))
+	if _, err := s.Write(make([]byte, 20)); !errors.Is(err, ErrorClosed) {
+		t.Fatalf("write err = %v, want %v", err, ErrorClosed)
+	}
+
+	// Packets sent before closing are still read
+	buf := make([]byte, 2048)
+	if _, err := s.Read(buf); err != nil {
+		t.Fatalf("read err = %v, want queued packet", err)
+	}
+	if _, err := s.Read(buf); err != io.EOF {
+		t.Fatalf("read err = %v, want %v", err, io.EOF)
+	}
+}
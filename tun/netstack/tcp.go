@@ -0,0 +1,1150 @@
+package netstack
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	tcpHeaderLen = 20
+
+	tcpFlagFIN = 0x01
+	tcpFlagSYN = 0x02
+	tcpFlagRST = 0x04
+	tcpFlagPSH = 0x08
+	tcpFlagACK = 0x10
+
+	tcpOptionEnd         = 0
+	tcpOptionNOP         = 1
+	tcpOptionMSS         = 2
+	tcpOptionWindowScale = 3
+
+	tcpDefaultMSS     = 536
+	tcpMaxWindow      = 1<<16 - 1
+	tcpMaxWindowShift = 14
+	// The receive buffer exceeds the window field, it is scaled if the peer supports it
+	tcpRecvBufferSize = 1 << 20
+	tcpWindowShift    = 5
+	// Segments received ahead of a gap which are queued until it is filled
+	tcpMaxOutOfOrder  = 64
+	tcpSendBufferSize = 1 << 18
+	tcpInitialRTO     = time.Second
+	tcpMaxRTO         = time.Second * 30
+	tcpMaxRetries     = 10
+	tcpMaxSynRetries  = 5
+	tcpDupAckLimit    = 3
+	tcpTimeWait       = time.Second * 5
+	tcpBacklog        = 128
+)
+
+type tcpState int
+
+const (
+	tcpStateClosed tcpState = iota
+	tcpStateSynSent
+	tcpStateSynReceived
+	tcpStateEstablished
+	tcpStateFinWait1
+	tcpStateFinWait2
+	tcpStateCloseWait
+	tcpStateClosing
+	tcpStateLastAck
+	tcpStateTimeWait
+)
+
+type tcpSegment struct {
+	srcPort uint16
+	dstPort uint16
+	seq     uint32
+	ack     uint32
+	flags   uint8
+	window  uint16
+	mss     uint16
+	// Window scale option, if wscaleOK
+	wscale   uint8
+	wscaleOK bool
+	payload  []byte
+}
+
+// Segment received ahead of the next expected sequence number
+type tcpQueuedSegment struct {
+	seq     uint32
+	payload []byte
+	fin     bool
+}
+
+func parseTCPSegment(b []byte) (seg tcpSegment, err error) {
+	if len(b) < tcpHeaderLen {
+		return seg, ErrorInvalidPacket
+	}
+	offset := int(b[12]>>4) * 4
+	if offset < tcpHeaderLen || offset > len(b) {
+		return seg, ErrorInvalidPacket
+	}
+
+	seg = tcpSegment{
+		srcPort: binary.BigEndian.Uint16(b[0:2]),
+		dstPort: binary.BigEndian.Uint16(b[2:4]),
+		seq:     binary.BigEndian.Uint32(b[4:8]),
+		ack:     binary.BigEndian.Uint32(b[8:12]),
+		flags:   b[13],
+		window:  binary.BigEndian.Uint16(b[14:16]),
+		payload: b[offset:],
+	}
+
+	options := b[tcpHeaderLen:offset]
+	for i := 0; i < len(options); {
+		switch options[i] {
+		case tcpOptionEnd:
+			return
+		case tcpOptionNOP:
+			i++
+		default:
+			if i+1 >= len(options) {
+				return
+			}
+			length := int(options[i+1])
+			if length < 2 || i+length > len(options) {
+				return
+			}
+			switch {
+			case options[i] == tcpOptionMSS && length == 4:
+				seg.mss = binary.BigEndian.Uint16(options[i+2 : i+4])
+			case options[i] == tcpOptionWindowScale && length == 3:
+				seg.wscale, seg.wscaleOK = options[i+2], true
+				if seg.wscale > tcpMaxWindowShift {
+					seg.wscale = tcpMaxWindowShift
+				}
+			}
+			i += length
+		}
+	}
+
+	return
+}
+
+// Length of the segment in sequence space
+func (seg *tcpSegment) seqLen() uint32 {
+	n := uint32(len(seg.payload))
+	if seg.flags&tcpFlagSYN != 0 {
+		n++
+	}
+	if seg.flags&tcpFlagFIN != 0 {
+		n++
+	}
+	return n
+}
+
+func seqLT(a, b uint32) bool {
+	return int32(a-b) < 0
+}
+
+func seqLEQ(a, b uint32) bool {
+	return int32(a-b) <= 0
+}
+
+// Options are padded to a multiple of 4 bytes by the caller
+func (s *Stack) writeTCP(key flowKey, seq, ack uint32, flags uint8, window uint16, options []byte, payload []byte) {
+	headerLen := tcpHeaderLen + len(options)
+
+	segment := make([]byte, headerLen+len(payload))
+	binary.BigEndian.PutUint16(segment[0:2], key.local.port)
+	binary.BigEndian.PutUint16(segment[2:4], key.remote.port)
+	binary.BigEndian.PutUint32(segment[4:8], seq)
+	binary.BigEndian.PutUint32(segment[8:12], ack)
+	segment[12] = byte(headerLen/4) << 4
+	segment[13] = flags
+	binary.BigEndian.PutUint16(segment[14:16], window)
+	copy(segment[tcpHeaderLen:], options)
+	copy(segment[headerLen:], payload)
+
+	src, dst := key.local.IP(), key.remote.IP()
+	binary.BigEndian.PutUint16(segment[16:18], transportChecksum(src, dst, protoTCP, segment))
+
+	s.send(src, dst, protoTCP, segment)
+}
+
+// Reset a segment which does not belong to any connection
+func (s *Stack) writeTCPReset(key flowKey, seg tcpSegment) {
+	if seg.flags&tcpFlagRST != 0 {
+		return
+	}
+
+	if seg.flags&tcpFlagACK != 0 {
+		s.writeTCP(key, seg.ack, 0, tcpFlagRST, 0, nil, nil)
+	} else {
+		s.writeTCP(key, 0, seg.seq+seg.seqLen(), tcpFlagRST|tcpFlagACK, 0, nil, nil)
+	}
+}
+
+func (s *Stack) handleTCP(pkt ipPacket) {
+	if transportChecksum(pkt.src, pkt.dst, protoTCP, pkt.payload) != 0 {
+		s.log.Debugln("Dropping tcp segment: invalid checksum")
+		return
+	}
+	seg, err := parseTCPSegment(pkt.payload)
+	if err != nil {
+		return
+	}
+
+	key := flowKey{
+		local:  newEndpoint(pkt.dst, seg.dstPort),
+		remote: newEndpoint(pkt.src, seg.srcPort),
+	}
+
+	if rconn, ok := s.tcpConns.Load(key); ok {
+		rconn.(*TCPConn).handleSegment(seg)
+		return
+	}
+
+	if seg.flags&(tcpFlagSYN|tcpFlagACK|tcpFlagRST) == tcpFlagSYN {
+		if listener, ok := s.getTCPListener(key.local); ok {
+			listener.handleSyn(key, seg)
+			return
+		}
+
+		// Connections to addresses which are not local are forwarded
+		if s.forwarder != nil && !s.isLocal(pkt.dst) && forwardable(pkt.dst) {
+			s.forwarder.handleSyn(key, seg)
+			return
+		}
+	}
+
+	s.writeTCPReset(key, seg)
+}
+
+func (s *Stack) getTCPListener(local endpoint) (l *TCPListener, ok bool) {
+	rlistener, ok := s.tcpListeners.Load(local)
+	if !ok {
+		rlistener, ok = s.tcpListeners.Load(newEndpoint(net.IPv6unspecified, local.port))
+	}
+	if ok {
+		return rlistener.(*TCPListener), true
+	}
+	return
+}
+
+func (s *Stack) dialTCP(ctx context.Context, ip net.IP, port uint16) (conn *TCPConn, err error) {
+	src, err := s.sourceFor(ip)
+	if err != nil {
+		return
+	}
+	lport, err := s.allocatePort(protoTCP, 0)
+	if err != nil {
+		return
+	}
+
+	c := s.newTCPConn(flowKey{
+		local:  newEndpoint(src, lport),
+		remote: newEndpoint(ip, port),
+	}, true)
+	s.tcpConns.Store(c.key, c)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.state = tcpStateSynSent
+	c.sndUna = c.iss
+	c.sndNxt = c.iss + 1
+	c.sndMax = c.sndNxt
+	c.sendSegment(tcpFlagSYN, c.iss, nil)
+	c.armRTO()
+
+	deadline, _ := ctx.Deadline()
+	err = c.wait(func() bool {
+		return c.state != tcpStateSynSent
+	}, &deadline, ctx.Done())
+	if err == nil && c.state != tcpStateEstablished {
+		err = c.err
+	}
+	if err != nil {
+		c.finish(err)
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (s *Stack) listenTCP(ip net.IP, port uint16) (l *TCPListener, err error) {
+	if !ip.IsUnspecified() && !s.isLocal(ip) {
+		return nil, ErrorNoRoute
+	}
+	if port == 0 {
+		return nil, &net.AddrError{Err: "port required", Addr: ip.String()}
+	}
+
+	local := newEndpoint(ip, port)
+	if local.unspecified() {
+		local = newEndpoint(net.IPv6unspecified, port)
+	}
+
+	if _, err = s.allocatePort(protoTCP, port); err != nil {
+		return
+	}
+
+	l = &TCPListener{
+		stack:  s,
+		local:  local,
+		accept: make(chan *TCPConn, tcpBacklog),
+		closed: make(chan struct{}),
+	}
+	if _, loaded := s.tcpListeners.LoadOrStore(local, l); loaded {
+		s.releasePort(protoTCP, port)
+		return nil, ErrorAddressInUse
+	}
+
+	return
+}
+
+// TCP Listener
+// Satisfies net.Listener
+type TCPListener struct {
+	stack  *Stack
+	local  endpoint
+	accept chan *TCPConn
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (l *TCPListener) handleSyn(key flowKey, seg tcpSegment) {
+	select {
+	case <-l.closed:
+		l.stack.writeTCPReset(key, seg)
+		return
+	default:
+	}
+
+	l.stack.acceptSyn(key, seg, l)
+}
+
+// Create a connection in the SYN-RECEIVED state
+// Returns nil if the connection exists already
+func (s *Stack) acceptSyn(key flowKey, seg tcpSegment, l *TCPListener) *TCPConn {
+	c := s.newTCPConn(key, false)
+	c.listener = l
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, loaded := s.tcpConns.LoadOrStore(key, c); loaded {
+		return nil
+	}
+
+	c.state = tcpStateSynReceived
+	c.irs = seg.seq
+	c.rcvNxt = seg.seq + 1
+	c.sndWnd = uint32(seg.window)
+	c.setPeerMSS(seg.mss)
+	c.setPeerWindowScale(seg)
+	c.sndUna = c.iss
+	c.sndNxt = c.iss + 1
+	c.sndMax = c.sndNxt
+	c.sendSegment(tcpFlagSYN|tcpFlagACK, c.iss, nil)
+	c.armRTO()
+
+	return c
+}
+
+// Accept the next connection
+func (l *TCPListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.accept:
+		return c, nil
+	case <-l.closed:
+		return nil, ErrorClosed
+	}
+}
+
+// Close the Listener
+// Connections which were not accepted yet are reset
+func (l *TCPListener) Close() error {
+	l.once.Do(func() {
+		close(l.closed)
+		l.stack.tcpListeners.Delete(l.local)
+		l.stack.releasePort(protoTCP, l.local.port)
+
+		for {
+			select {
+			case c := <-l.accept:
+				c.reset(ErrorClosed)
+			default:
+				return
+			}
+		}
+	})
+	return nil
+}
+
+// Listen Address
+func (l *TCPListener) Addr() net.Addr {
+	return &net.TCPAddr{IP: l.local.IP(), Port: int(l.local.port)}
+}
+
+// TCP Connection
+// Satisfies net.Conn
+type TCPConn struct {
+	stack    *Stack
+	key      flowKey
+	ownsPort bool
+	listener *TCPListener
+	state    tcpState
+	err      error
+
+	iss    uint32
+	sndUna uint32
+	sndNxt uint32
+	sndMax uint32
+	sndWnd uint32
+	// Window scale of the segments sent by the peer
+	sndShift uint8
+	sndBuf   []byte
+	sndMSS   int
+	finSent  bool
+	// Application closed the write side
+	finQueued bool
+
+	irs    uint32
+	rcvNxt uint32
+	rcvBuf []byte
+	rcvMSS int
+	// Window scale of the segments sent to the peer
+	rcvShift uint8
+	rcvQueue []tcpQueuedSegment
+	finRcvd  bool
+	// Application closed the connection
+	readClosed bool
+	lastWnd    uint32
+
+	dupAcks int
+
+	rto       time.Duration
+	rtoTimer  *time.Timer
+	rtoArmed  bool
+	retries   int
+	waitTimer *time.Timer
+
+	readDeadline  time.Time
+	writeDeadline time.Time
+	notify        chan struct{}
+	mutex         sync.Mutex
+}
+
+func (s *Stack) newTCPConn(key flowKey, ownsPort bool) *TCPConn {
+	ipHeaderLen := ipv4HeaderLen
+	if key.local.IP().To4() == nil {
+		ipHeaderLen = ipv6HeaderLen
+	}
+
+	s.pmutex.Lock()
+	iss := s.rnd.Uint32()
+	s.pmutex.Unlock()
+
+	mss := s.config.MTU - ipHeaderLen - tcpHeaderLen
+	return &TCPConn{
+		stack:    s,
+		key:      key,
+		ownsPort: ownsPort,
+
+		iss:    iss,
+		sndMSS: tcpDefaultMSS,
+		rcvMSS: mss,
+		rto:    tcpInitialRTO,
+		notify: make(chan struct{}),
+	}
+}
+
+func (c *TCPConn) setPeerMSS(mss uint16) {
+	c.sndMSS = tcpDefaultMSS
+	if mss > 0 {
+		c.sndMSS = int(mss)
+	}
+	if c.sndMSS > c.rcvMSS {
+		c.sndMSS = c.rcvMSS
+	}
+}
+
+// Windows are scaled if both SYN segments carried the option
+func (c *TCPConn) setPeerWindowScale(seg tcpSegment) {
+	c.sndShift, c.rcvShift = 0, 0
+	if seg.wscaleOK {
+		c.sndShift, c.rcvShift = seg.wscale, tcpWindowShift
+	}
+}
+
+// Window of a segment sent by the peer
+func (c *TCPConn) peerWindow(seg tcpSegment) uint32 {
+	if seg.flags&tcpFlagSYN != 0 {
+		return uint32(seg.window)
+	}
+	return uint32(seg.window) << c.sndShift
+}
+
+func (c *TCPConn) recvWindow() uint32 {
+	window := uint32(tcpRecvBufferSize - len(c.rcvBuf))
+	if max := uint32(tcpMaxWindow) << c.rcvShift; window > max {
+		window = max
+	}
+	return window
+}
+
+func (c *TCPConn) sendSegment(flags uint8, seq uint32, payload []byte) {
+	var ack uint32
+	if flags&tcpFlagACK != 0 {
+		ack = c.rcvNxt
+	}
+
+	// The window of SYN segments is not scaled
+	shift := c.rcvShift
+	var options []byte
+	if flags&tcpFlagSYN != 0 {
+		shift = 0
+		options = []byte{tcpOptionMSS, 4, 0, 0}
+		binary.BigEndian.PutUint16(options[2:4], uint16(c.rcvMSS))
+		// The SYN-ACK carries the option only if the SYN did
+		if flags&tcpFlagACK == 0 || c.rcvShift > 0 {
+			options = append(options, tcpOptionNOP, tcpOptionWindowScale, 3, tcpWindowShift)
+		}
+	}
+
+	window := c.recvWindow() >> shift
+	if window > tcpMaxWindow {
+		window = tcpMaxWindow
+	}
+	c.lastWnd = window << shift
+	c.stack.writeTCP(c.key, seq, ack, flags, uint16(window), options, payload)
+}
+
+func (c *TCPConn) sendAck() {
+	c.sendSegment(tcpFlagACK, c.sndNxt, nil)
+}
+
+// Wake up goroutines waiting on the connection
+func (c *TCPConn) broadcast() {
+	close(c.notify)
+	c.notify = make(chan struct{})
+}
+
+// Wait until cond is satisfied
+// Must be called with the mutex held
+func (c *TCPConn) wait(cond func() bool, deadline *time.Time, done <-chan struct{}) error {
+	for !cond() {
+		timer, err := newDeadlineTimer(*deadline)
+		if err != nil {
+			return err
+		}
+
+		notify := c.notify
+		c.mutex.Unlock()
+		select {
+		case <-notify:
+		case <-timer.C:
+			err = ErrorTimeout
+		case <-done:
+			err = context.Canceled
+		}
+		timer.Stop()
+		c.mutex.Lock()
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *TCPConn) armRTO() {
+	c.rtoArmed = true
+	if c.rtoTimer == nil {
+		c.rtoTimer = time.AfterFunc(c.rto, c.handleRTO)
+	} else {
+		c.rtoTimer.Reset(c.rto)
+	}
+}
+
+func (c *TCPConn) stopRTO() {
+	c.rtoArmed = false
+	if c.rtoTimer != nil {
+		c.rtoTimer.Stop()
+	}
+}
+
+func (c *TCPConn) handleRTO() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.rtoArmed || c.state == tcpStateClosed || c.state == tcpStateTimeWait {
+		return
+	}
+
+	c.retries++
+	maxRetries := tcpMaxRetries
+	if c.state == tcpStateSynSent || c.state == tcpStateSynReceived {
+		maxRetries = tcpMaxSynRetries
+	}
+	if c.retries > maxRetries {
+		c.abort(ErrorTimeout)
+		return
+	}
+
+	c.rto *= 2
+	if c.rto > tcpMaxRTO {
+		c.rto = tcpMaxRTO
+	}
+
+	switch c.state {
+	case tcpStateSynSent:
+		c.sendSegment(tcpFlagSYN, c.iss, nil)
+	case tcpStateSynReceived:
+		c.sendSegment(tcpFlagSYN|tcpFlagACK, c.iss, nil)
+	default:
+		// Go back to the first unacknowledged byte
+		c.sndNxt = c.sndUna
+		c.finSent = false
+		if c.sndWnd == 0 && len(c.sndBuf) > 0 {
+			// Zero window probe
+			c.sendSegment(tcpFlagACK, c.sndUna, c.sndBuf[:1])
+			c.sndNxt = c.sndUna + 1
+		} else {
+			c.output()
+		}
+	}
+	c.armRTO()
+}
+
+// Send as much buffered data as the peer's window allows
+func (c *TCPConn) output() {
+	switch c.state {
+	case tcpStateEstablished, tcpStateCloseWait, tcpStateFinWait1, tcpStateClosing, tcpStateLastAck:
+	default:
+		return
+	}
+
+	inflight := int(c.sndNxt - c.sndUna)
+	if c.finSent {
+		inflight--
+	}
+	window := int(c.sndWnd)
+
+	for inflight < len(c.sndBuf) && inflight < window {
+		n := len(c.sndBuf) - inflight
+		if n > c.sndMSS {
+			n = c.sndMSS
+		}
+		if n > window-inflight {
+			n = window - inflight
+		}
+		c.sendSegment(tcpFlagACK|tcpFlagPSH, c.sndUna+uint32(inflight), c.sndBuf[inflight:inflight+n])
+		inflight += n
+		c.sndNxt = c.sndUna + uint32(inflight)
+	}
+
+	if c.finQueued && !c.finSent && inflight == len(c.sndBuf) {
+		finSeq := c.sndUna + uint32(len(c.sndBuf))
+		c.sendSegment(tcpFlagFIN|tcpFlagACK, finSeq, nil)
+		c.finSent = true
+		c.sndNxt = finSeq + 1
+
+		switch c.state {
+		case tcpStateEstablished:
+			c.state = tcpStateFinWait1
+		case tcpStateCloseWait:
+			c.state = tcpStateLastAck
+		}
+	}
+
+	if seqLT(c.sndMax, c.sndNxt) {
+		c.sndMax = c.sndNxt
+	}
+	if !c.rtoArmed && (c.sndNxt != c.sndUna || len(c.sndBuf) > inflight) {
+		c.armRTO()
+	}
+}
+
+func (c *TCPConn) handleSegment(seg tcpSegment) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	defer c.broadcast()
+
+	switch c.state {
+	case tcpStateClosed:
+		return
+
+	case tcpStateSynSent:
+		if seg.flags&tcpFlagACK != 0 && seg.ack != c.iss+1 {
+			c.stack.writeTCPReset(c.key, seg)
+			return
+		}
+		if seg.flags&tcpFlagRST != 0 {
+			if seg.flags&tcpFlagACK != 0 {
+				c.finish(ErrorConnectionRefused)
+			}
+			return
+		}
+		if seg.flags&tcpFlagSYN == 0 || seg.flags&tcpFlagACK == 0 {
+			return
+		}
+
+		c.irs = seg.seq
+		c.rcvNxt = seg.seq + 1
+		c.sndUna = seg.ack
+		c.sndWnd = uint32(seg.window)
+		c.setPeerMSS(seg.mss)
+		c.setPeerWindowScale(seg)
+		c.state = tcpStateEstablished
+		c.retries = 0
+		c.rto = tcpInitialRTO
+		c.stopRTO()
+		c.sendAck()
+		return
+	}
+
+	if seg.flags&tcpFlagRST != 0 {
+		if seqLEQ(c.rcvNxt, seg.seq) && seqLT(seg.seq, c.rcvNxt+c.recvWindow()+1) {
+			c.finish(ErrorConnectionReset)
+		}
+		return
+	}
+
+	if seg.flags&tcpFlagSYN != 0 {
+		if c.state == tcpStateSynReceived && seg.seq == c.irs {
+			c.sendSegment(tcpFlagSYN|tcpFlagACK, c.iss, nil)
+		} else {
+			c.sendAck()
+		}
+		return
+	}
+
+	if seg.flags&tcpFlagACK == 0 {
+		return
+	}
+
+	if c.state == tcpStateSynReceived {
+		if seg.ack != c.iss+1 {
+			c.stack.writeTCPReset(c.key, seg)
+			return
+		}
+
+		c.sndUna = seg.ack
+		c.sndWnd = c.peerWindow(seg)
+		c.state = tcpStateEstablished
+		c.retries = 0
+		c.rto = tcpInitialRTO
+		c.stopRTO()
+
+		// Forwarded connections are not accepted by a listener
+		if c.listener != nil {
+			select {
+			case <-c.listener.closed:
+				c.abort(ErrorClosed)
+				return
+			default:
+			}
+			select {
+			case c.listener.accept <- c:
+			default:
+				c.abort(ErrorConnectionRefused)
+				return
+			}
+		}
+	}
+
+	c.handleAck(seg)
+	if c.state == tcpStateClosed {
+		return
+	}
+
+	c.handleData(seg)
+	c.output()
+}
+
+func (c *TCPConn) handleAck(seg tcpSegment) {
+	if seqLT(c.sndUna, seg.ack) && seqLEQ(seg.ack, c.sndMax) {
+		acked := int(seg.ack - c.sndUna)
+		// The FIN sequence number is fixed once the write side is closed
+		finAcked := c.finQueued && seg.ack == c.sndUna+uint32(len(c.sndBuf))+1
+		if finAcked {
+			acked--
+		}
+		if acked > len(c.sndBuf) {
+			acked = len(c.sndBuf)
+		}
+
+		c.dupAcks = 0
+		c.sndBuf = c.sndBuf[acked:]
+		if len(c.sndBuf) == 0 {
+			c.sndBuf = nil
+		}
+		c.sndUna = seg.ack
+		if seqLT(c.sndNxt, c.sndUna) {
+			c.sndNxt = c.sndUna
+		}
+		c.retries = 0
+		c.rto = tcpInitialRTO
+		if c.sndUna == c.sndNxt {
+			c.stopRTO()
+		} else {
+			c.armRTO()
+		}
+
+		if finAcked {
+			switch c.state {
+			case tcpStateFinWait1:
+				c.state = tcpStateFinWait2
+				c.armFinTimeout()
+			case tcpStateClosing:
+				c.startTimeWait()
+			case tcpStateLastAck:
+				c.finish(nil)
+				return
+			}
+		}
+	} else if seg.ack == c.sndUna && c.sndUna != c.sndNxt && len(seg.payload) == 0 &&
+		seg.flags&tcpFlagFIN == 0 {
+		// Fast retransmit
+		// Without SACK the segments queued by the peer after a lost one are unknown, so go back to the first unacknowledged byte
+		c.dupAcks++
+		if c.dupAcks == tcpDupAckLimit {
+			c.sndNxt = c.sndUna
+			c.finSent = false
+		}
+	}
+
+	if seqLEQ(c.sndUna, seg.ack) {
+		c.sndWnd = c.peerWindow(seg)
+	}
+}
+
+func (c *TCPConn) handleData(seg tcpSegment) {
+	fin := seg.flags&tcpFlagFIN != 0
+	if len(seg.payload) == 0 && !fin {
+		return
+	}
+
+	switch c.state {
+	case tcpStateEstablished, tcpStateFinWait1, tcpStateFinWait2:
+	default:
+		// Retransmission from the peer after its FIN was received
+		c.sendAck()
+		return
+	}
+
+	payload := seg.payload
+	if seqLT(seg.seq, c.rcvNxt) {
+		skip := int(c.rcvNxt - seg.seq)
+		if skip > len(payload) {
+			c.sendAck()
+			return
+		}
+		payload = payload[skip:]
+	} else if seg.seq != c.rcvNxt {
+		// Out of order segments are queued, the duplicate ACK lets the peer retransmit the missing data
+		c.queueSegment(seg.seq, payload, fin)
+		c.sendAck()
+		return
+	}
+
+	if c.receive(payload, fin) {
+		c.dequeueSegments()
+	} else {
+		c.rcvQueue = nil
+	}
+	c.sendAck()
+}
+
+// Append in order data to the receive buffer
+// Returns false if it did not fit
+func (c *TCPConn) receive(payload []byte, fin bool) bool {
+	n := len(payload)
+	if space := tcpRecvBufferSize - len(c.rcvBuf); n > space {
+		n = space
+	}
+	c.rcvBuf = append(c.rcvBuf, payload[:n]...)
+	c.rcvNxt += uint32(n)
+
+	if fin && n == len(payload) {
+		c.rcvNxt++
+		c.finRcvd = true
+		c.rcvQueue = nil
+
+		switch c.state {
+		case tcpStateEstablished:
+			c.state = tcpStateCloseWait
+		case tcpStateFinWait1:
+			c.state = tcpStateClosing
+		case tcpStateFinWait2:
+			c.startTimeWait()
+		}
+	}
+
+	return n == len(payload)
+}
+
+// Queue a segment received ahead of a gap, segments outside the receive window are dropped
+func (c *TCPConn) queueSegment(seq uint32, payload []byte, fin bool) {
+	if int(seq-c.rcvNxt)+len(payload) > tcpRecvBufferSize-len(c.rcvBuf) {
+		return
+	}
+
+	i := 0
+	for ; i < len(c.rcvQueue); i++ {
+		queued := c.rcvQueue[i]
+		if queued.seq == seq {
+			// Retransmissions may carry more data
+			if len(payload) > len(queued.payload) || fin {
+				c.rcvQueue[i] = tcpQueuedSegment{seq: seq, payload: payload, fin: fin}
+			}
+			return
+		}
+		if seqLT(seq, queued.seq) {
+			break
+		}
+	}
+	if len(c.rcvQueue) >= tcpMaxOutOfOrder {
+		return
+	}
+
+	c.rcvQueue = append(c.rcvQueue, tcpQueuedSegment{})
+	copy(c.rcvQueue[i+1:], c.rcvQueue[i:])
+	c.rcvQueue[i] = tcpQueuedSegment{seq: seq, payload: payload, fin: fin}
+}
+
+// Receive the queued segments which follow the received data
+func (c *TCPConn) dequeueSegments() {
+	for len(c.rcvQueue) > 0 && !c.finRcvd {
+		queued := c.rcvQueue[0]
+		if seqLT(c.rcvNxt, queued.seq) {
+			return
+		}
+		c.rcvQueue = c.rcvQueue[1:]
+
+		// Data received already is skipped
+		skip := int(c.rcvNxt - queued.seq)
+		if skip > len(queued.payload) || (skip == len(queued.payload) && !queued.fin) {
+			continue
+		}
+		if !c.receive(queued.payload[skip:], queued.fin) {
+			c.rcvQueue = nil
+			return
+		}
+	}
+	if len(c.rcvQueue) == 0 {
+		c.rcvQueue = nil
+	}
+}
+
+// Reset the connection if the peer does not send its FIN within Config.FinTimeout
+// It applies once the application closed the connection, half-closed connections wait for the peer
+func (c *TCPConn) armFinTimeout() {
+	if c.state != tcpStateFinWait2 || !c.readClosed || c.waitTimer != nil {
+		return
+	}
+
+	c.waitTimer = time.AfterFunc(c.stack.config.FinTimeout, func() {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+
+		if c.state == tcpStateFinWait2 {
+			c.abort(ErrorTimeout)
+		}
+	})
+}
+
+func (c *TCPConn) startTimeWait() {
+	c.state = tcpStateTimeWait
+	c.stopRTO()
+	if c.waitTimer != nil {
+		c.waitTimer.Stop()
+	}
+	c.waitTimer = time.AfterFunc(tcpTimeWait, func() {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		c.finish(nil)
+	})
+}
+
+func (c *TCPConn) reset(err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.abort(err)
+}
+
+// Reset the connection
+// Must be called with the mutex held
+func (c *TCPConn) abort(err error) {
+	switch c.state {
+	case tcpStateClosed, tcpStateSynSent, tcpStateTimeWait:
+	default:
+		c.stack.writeTCP(c.key, c.sndNxt, 0, tcpFlagRST, 0, nil, nil)
+	}
+	c.finish(err)
+}
+
+// Move to the closed state and release resources
+func (c *TCPConn) finish(err error) {
+	if c.state == tcpStateClosed {
+		return
+	}
+
+	c.state = tcpStateClosed
+	if c.err == nil {
+		c.err = err
+	}
+	c.stopRTO()
+	if c.waitTimer != nil {
+		c.waitTimer.Stop()
+	}
+
+	c.stack.tcpConns.Delete(c.key)
+	if c.ownsPort {
+		c.stack.releasePort(protoTCP, c.key.local.port)
+	}
+	c.broadcast()
+}
+
+// Read data from the connection
+func (c *TCPConn) Read(b []byte) (n int, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err = c.wait(func() bool {
+		return len(c.rcvBuf) > 0 || c.finRcvd || c.readClosed || c.state == tcpStateClosed
+	}, &c.readDeadline, nil); err != nil {
+		return
+	}
+
+	if len(c.rcvBuf) > 0 && !c.readClosed {
+		n = copy(b, c.rcvBuf)
+		c.rcvBuf = c.rcvBuf[n:]
+		if len(c.rcvBuf) == 0 {
+			c.rcvBuf = nil
+		}
+
+		// Let the peer know that the window opened up
+		if c.lastWnd < uint32(c.rcvMSS) && c.recvWindow() >= uint32(c.rcvMSS) && c.state != tcpStateClosed {
+			c.sendAck()
+		}
+		return
+	}
+
+	switch {
+	case c.readClosed:
+		err = ErrorClosed
+	case c.finRcvd:
+		err = io.EOF
+	case c.err != nil:
+		err = c.err
+	default:
+		err = ErrorClosed
+	}
+	return
+}
+
+// Write data to the connection
+func (c *TCPConn) Write(b []byte) (n int, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for len(b) > 0 {
+		if err = c.wait(func() bool {
+			return c.finQueued || c.state == tcpStateClosed || len(c.sndBuf) < tcpSendBufferSize
+		}, &c.writeDeadline, nil); err != nil {
+			return
+		}
+
+		if c.finQueued || (c.state != tcpStateEstablished && c.state != tcpStateCloseWait) {
+			if err = c.err; err == nil {
+				err = ErrorClosed
+			}
+			return
+		}
+
+		k := tcpSendBufferSize - len(c.sndBuf)
+		if k > len(b) {
+			k = len(b)
+		}
+		c.sndBuf = append(c.sndBuf, b[:k]...)
+		b = b[k:]
+		n += k
+
+		c.output()
+	}
+
+	return
+}
+
+// Close the write side of the connection
+// The peer reads EOF once the buffered data is delivered
+func (c *TCPConn) CloseWrite() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.closeWrite()
+	return nil
+}
+
+func (c *TCPConn) closeWrite() {
+	switch c.state {
+	case tcpStateSynReceived, tcpStateEstablished, tcpStateCloseWait:
+		c.finQueued = true
+		c.output()
+		c.broadcast()
+	}
+}
+
+// Close the connection
+func (c *TCPConn) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.readClosed = true
+	if c.state == tcpStateSynSent {
+		c.finish(ErrorClosed)
+	} else {
+		c.closeWrite()
+		c.armFinTimeout()
+	}
+	c.broadcast()
+	return nil
+}
+
+// Local Address
+func (c *TCPConn) LocalAddr() net.Addr {
+	return &net.TCPAddr{IP: c.key.local.IP(), Port: int(c.key.local.port)}
+}
+
+// Remote Address
+func (c *TCPConn) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: c.key.remote.IP(), Port: int(c.key.remote.port)}
+}
+
+// Set Read and Write Deadlines
+func (c *TCPConn) SetDeadline(t time.Time) error {
+	c.SetReadDeadline(t)
+	return c.SetWriteDeadline(t)
+}
+
+// Set Read Deadline
+func (c *TCPConn) SetReadDeadline(t time.Time) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.readDeadline = t
+	c.broadcast()
+	return nil
+}
+
+// Set Write Deadline
+func (c *TCPConn) SetWriteDeadline(t time.Time) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.writeDeadline = t
+	c.broadcast()
+	return nil
+}
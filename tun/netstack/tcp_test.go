@@ -0,0 +1,480 @@
+package netstack
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Packets delivered for a packet read from one Stack to the other
+type testLink func(pkt []byte, seg tcpSegment) [][]byte
+
+// Deliver all packets
+func lossless(pkt []byte, seg tcpSegment) [][]byte {
+	return [][]byte{pkt}
+}
+
+// Drop every nth data segment
+func dropEvery(n int) testLink {
+	var mutex sync.Mutex
+	i := 0
+	return func(pkt []byte, seg tcpSegment) [][]byte {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if len(seg.payload) > 0 {
+			if i++; i%n == 0 {
+				return nil
+			}
+		}
+		return [][]byte{pkt}
+	}
+}
+
+// Deliver every nth data segment after the one following it
+func reorderEvery(n int) testLink {
+	var mutex sync.Mutex
+	var held []byte
+	i := 0
+	return func(pkt []byte, seg tcpSegment) [][]byte {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if held != nil {
+			pkts := [][]byte{pkt, held}
+			held = nil
+			return pkts
+		}
+		if len(seg.payload) > 0 {
+			if i++; i%n == 0 {
+				held = pkt
+				return nil
+			}
+		}
+		return [][]byte{pkt}
+	}
+}
+
+func newTestStack(t *testing.T, config Config) *Stack {
+	t.Helper()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	s, err := New(log, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// Connect two Stacks in memory
+func newTestStacks(t *testing.T, ab, ba testLink) (a, b *Stack) {
+	t.Helper()
+
+	ca, err := NewConfig(1280, "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cb, err := NewConfig(1280, "10.0.0.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca.FinTimeout = 200 * time.Millisecond
+	cb.FinTimeout = 200 * time.Millisecond
+
+	a, b = newTestStack(t, ca), newTestStack(t, cb)
+	go pump(a, b, ab)
+	go pump(b, a, ba)
+	return
+}
+
+func pump(from, to *Stack, link testLink) {
+	buf := make([]byte, 2048)
+	for {
+		n, err := from.Read(buf)
+		if err != nil {
+			return
+		}
+		pkt := append([]byte(nil), buf[:n]...)
+
+		var seg tcpSegment
+		if ip, err := parseIPPacket(pkt); err == nil && ip.proto == protoTCP {
+			seg, _ = parseTCPSegment(ip.payload)
+		}
+		for _, p := range link(pkt, seg) {
+			to.Write(p)
+		}
+	}
+}
+
+// Dial b from a and accept the connection
+func connect(t *testing.T, a, b *Stack) (client, server *TCPConn) {
+	t.Helper()
+
+	l, err := b.Listen("tcp", ":80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			t.Error(err)
+		}
+		accepted <- c
+	}()
+
+	c, err := a.Dial("tcp", "10.0.0.2:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case s := <-accepted:
+		return c.(*TCPConn), s.(*TCPConn)
+	case <-time.After(5 * time.Second):
+		t.Fatal("accept timed out")
+	}
+	return
+}
+
+func (c *TCPConn) testState() tcpState {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.state
+}
+
+// Wait until the connection is in the state
+func awaitState(t *testing.T, c *TCPConn, state tcpState, timeout time.Duration) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for c.testState() != state {
+		if time.Now().After(deadline) {
+			t.Fatalf("state = %d, want %d", c.testState(), state)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTCPHandshake(t *testing.T) {
+	a, b := newTestStacks(t, lossless, lossless)
+	client, server := connect(t, a, b)
+
+	for _, c := range []*TCPConn{client, server} {
+		if state := c.testState(); state != tcpStateEstablished {
+			t.Fatalf("state = %d, want established", state)
+		}
+		if c.sndShift != tcpWindowShift || c.rcvShift != tcpWindowShift {
+			t.Fatalf("window scale = %d/%d, want %d", c.sndShift, c.rcvShift, tcpWindowShift)
+		}
+		if want := 1280 - ipv4HeaderLen - tcpHeaderLen; c.sndMSS != want {
+			t.Fatalf("mss = %d, want %d", c.sndMSS, want)
+		}
+	}
+
+	// The window of the ACK completing the handshake is scaled
+	server.mutex.Lock()
+	window := server.sndWnd
+	server.mutex.Unlock()
+	if window <= tcpMaxWindow {
+		t.Fatalf("send window = %d, want scaled", window)
+	}
+}
+
+func TestTCPTransfer(t *testing.T) {
+	tests := []struct {
+		name string
+		link func() testLink
+	}{
+		{"lossless", func() testLink { return lossless }},
+		{"loss", func() testLink { return dropEvery(13) }},
+		{"reordering", func() testLink { return reorderEvery(5) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := newTestStacks(t, tt.link(), tt.link())
+			client, server := connect(t, a, b)
+
+			data := make([]byte, 1<<20)
+			rand.Read(data)
+
+			go func() {
+				io.Copy(server, server)
+				server.Close()
+			}()
+			go func() {
+				client.Write(data)
+				client.CloseWrite()
+			}()
+
+			client.SetReadDeadline(time.Now().Add(30 * time.Second))
+			got, err := io.ReadAll(client)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("received %d bytes, want %d equal bytes", len(got), len(data))
+			}
+		})
+	}
+}
+
+func TestTCPRetransmit(t *testing.T) {
+	var mutex sync.Mutex
+	sent := 0
+	dropFirst := func(pkt []byte, seg tcpSegment) [][]byte {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if len(seg.payload) > 0 {
+			if sent++; sent == 1 {
+				return nil
+			}
+		}
+		return [][]byte{pkt}
+	}
+
+	a, b := newTestStacks(t, dropFirst, lossless)
+	client, server := connect(t, a, b)
+
+	if _, err := client.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 16)
+	n, err := server.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "hello" {
+		t.Fatalf("read %q, want hello", buf[:n])
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if sent < 2 {
+		t.Fatalf("data sent %d times, want a retransmission", sent)
+	}
+}
+
+func TestTCPOutOfOrder(t *testing.T) {
+	s := newTestStack(t, Config{Addresses: []net.IP{net.ParseIP("10.0.0.1")}})
+
+	tests := []struct {
+		name     string
+		segments []tcpQueuedSegment
+		want     string
+		fin      bool
+		queued   int
+	}{
+		{
+			name:     "in order",
+			segments: []tcpQueuedSegment{{seq: 0, payload: []byte("ab")}, {seq: 2, payload: []byte("cd")}},
+			want:     "abcd",
+		},
+		{
+			name:     "gap filled",
+			segments: []tcpQueuedSegment{{seq: 4, payload: []byte("ef")}, {seq: 2, payload: []byte("cd")}, {seq: 0, payload: []byte("ab")}},
+			want:     "abcdef",
+		},
+		{
+			name:     "gap open",
+			segments: []tcpQueuedSegment{{seq: 0, payload: []byte("ab")}, {seq: 4, payload: []byte("ef")}},
+			want:     "ab",
+			queued:   1,
+		},
+		{
+			name:     "overlapping",
+			segments: []tcpQueuedSegment{{seq: 3, payload: []byte("def")}, {seq: 2, payload: []byte("cd")}, {seq: 0, payload: []byte("abc")}},
+			want:     "abcdef",
+		},
+		{
+			name:     "duplicate",
+			segments: []tcpQueuedSegment{{seq: 2, payload: []byte("cd")}, {seq: 2, payload: []byte("cd")}, {seq: 0, payload: []byte("ab")}, {seq: 0, payload: []byte("ab")}},
+			want:     "abcd",
+		},
+		{
+			name:     "queued fin",
+			segments: []tcpQueuedSegment{{seq: 2, payload: []byte("cd"), fin: true}, {seq: 0, payload: []byte("ab")}},
+			want:     "abcd",
+			fin:      true,
+		},
+		{
+			name:     "outside window",
+			segments: []tcpQueuedSegment{{seq: tcpRecvBufferSize, payload: []byte("x")}, {seq: 0, payload: []byte("ab")}},
+			want:     "ab",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := s.newTCPConn(flowKey{
+				local:  newEndpoint(net.ParseIP("10.0.0.1"), 80),
+				remote: newEndpoint(net.ParseIP("10.0.0.2"), 40000),
+			}, false)
+			c.state = tcpStateEstablished
+			c.irs = 999
+			c.rcvNxt = 1000
+
+			for _, seg := range tt.segments {
+				flags := uint8(tcpFlagACK)
+				if seg.fin {
+					flags |= tcpFlagFIN
+				}
+				c.handleData(tcpSegment{seq: 1000 + seg.seq, flags: flags, payload: seg.payload})
+			}
+
+			if string(c.rcvBuf) != tt.want {
+				t.Fatalf("received %q, want %q", c.rcvBuf, tt.want)
+			}
+			if c.finRcvd != tt.fin {
+				t.Fatalf("fin received = %v, want %v", c.finRcvd, tt.fin)
+			}
+			if len(c.rcvQueue) != tt.queued {
+				t.Fatalf("queued %d segments, want %d", len(c.rcvQueue), tt.queued)
+			}
+		})
+	}
+}
+
+func TestTCPClose(t *testing.T) {
+	a, b := newTestStacks(t, lossless, lossless)
+	client, server := connect(t, a, b)
+
+	if _, err := client.Write([]byte("bye")); err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+
+	server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	got, err := io.ReadAll(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "bye" {
+		t.Fatalf("read %q, want bye", got)
+	}
+	awaitState(t, client, tcpStateFinWait2, time.Second)
+
+	server.Close()
+	awaitState(t, server, tcpStateClosed, time.Second)
+	awaitState(t, client, tcpStateTimeWait, time.Second)
+
+	if _, ok := b.tcpConns.Load(server.key); ok {
+		t.Fatal("closed connection not removed")
+	}
+	if _, err := client.Write([]byte("x")); err == nil {
+		t.Fatal("write after close succeeded")
+	}
+}
+
+func TestTCPFinTimeout(t *testing.T) {
+	a, b := newTestStacks(t, lossless, lossless)
+	client, server := connect(t, a, b)
+
+	// The server never closes its side
+	client.Close()
+	awaitState(t, client, tcpStateFinWait2, time.Second)
+	awaitState(t, client, tcpStateClosed, time.Second)
+
+	if _, ok := a.tcpConns.Load(client.key); ok {
+		t.Fatal("timed out connection not removed")
+	}
+	a.pmutex.Lock()
+	ports := len(a.ports)
+	a.pmutex.Unlock()
+	if ports != 0 {
+		t.Fatalf("%d ports held, want 0", ports)
+	}
+
+	// The server is reset
+	awaitState(t, server, tcpStateClosed, time.Second)
+}
+
+func TestTCPHalfClose(t *testing.T) {
+	a, b := newTestStacks(t, lossless, lossless)
+	client, server := connect(t, a, b)
+
+	// Half-closed connections are not timed out
+	client.CloseWrite()
+	awaitState(t, client, tcpStateFinWait2, time.Second)
+	time.Sleep(400 * time.Millisecond)
+	if state := client.testState(); state != tcpStateFinWait2 {
+		t.Fatalf("state = %d, want fin-wait-2", state)
+	}
+
+	if _, err := server.Write([]byte("late")); err != nil {
+		t.Fatal(err)
+	}
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 16)
+	n, err := client.Read(buf)
+	if err != nil || string(buf[:n]) != "late" {
+		t.Fatalf("read %q, %v, want late", buf[:n], err)
+	}
+}
+
+func TestTCPReset(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, a, b *Stack) error
+		want error
+	}{
+		{
+			name: "no listener",
+			run: func(t *testing.T, a, b *Stack) error {
+				_, err := a.Dial("tcp", "10.0.0.2:81")
+				return err
+			},
+			want: ErrorConnectionRefused,
+		},
+		{
+			name: "aborted by peer",
+			run: func(t *testing.T, a, b *Stack) error {
+				client, server := connect(t, a, b)
+				server.reset(ErrorClosed)
+
+				client.SetReadDeadline(time.Now().Add(5 * time.Second))
+				_, err := client.Read(make([]byte, 16))
+				return err
+			},
+			want: ErrorConnectionReset,
+		},
+		{
+			name: "stack closed",
+			run: func(t *testing.T, a, b *Stack) error {
+				client, _ := connect(t, a, b)
+				b.Close()
+
+				client.SetReadDeadline(time.Now().Add(5 * time.Second))
+				_, err := client.Read(make([]byte, 16))
+				return err
+			},
+			want: ErrorConnectionReset,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := newTestStacks(t, lossless, lossless)
+			if err := tt.run(t, a, b); !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package netstack
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	udpHeaderLen = 8
+	udpQueueSize = 256
+)
+
+type udpDatagram struct {
+	addr    *net.UDPAddr
+	payload []byte
+}
+
+// UDP Connection
+// Satisfies net.Conn when dialed, and net.PacketConn when listening
+type UDPConn struct {
+	stack  *Stack
+	local  endpoint
+	remote *net.UDPAddr
+
+	queue chan udpDatagram
+
+	readDeadline  time.Time
+	writeDeadline time.Time
+	deadline      chan struct{}
+	closed        chan struct{}
+	mutex         sync.Mutex
+}
+
+func (s *Stack) newUDPConn(ip net.IP, port uint16, remote *net.UDPAddr) (c *UDPConn, err error) {
+	if port, err = s.allocatePort(protoUDP, port); err != nil {
+		return
+	}
+
+	c = &UDPConn{
+		stack:    s,
+		local:    newEndpoint(ip, port),
+		remote:   remote,
+		queue:    make(chan udpDatagram, udpQueueSize),
+		deadline: make(chan struct{}),
+		closed:   make(chan struct{}),
+	}
+	if c.local.unspecified() {
+		c.local = newEndpoint(net.IPv6unspecified, port)
+	}
+
+	if _, loaded := s.udpConns.LoadOrStore(c.local, c); loaded {
+		s.releasePort(protoUDP, port)
+		return nil, ErrorAddressInUse
+	}
+
+	return
+}
+
+func (s *Stack) listenUDP(ip net.IP, port uint16) (c *UDPConn, err error) {
+	if !ip.IsUnspecified() && !s.isLocal(ip) {
+		return nil, ErrorNoRoute
+	}
+	return s.newUDPConn(ip, port, nil)
+}
+
+func (s *Stack) dialUDP(ip net.IP, port uint16) (c *UDPConn, err error) {
+	src, err := s.sourceFor(ip)
+	if err != nil {
+		return
+	}
+	return s.newUDPConn(src, 0, &net.UDPAddr{IP: ip, Port: int(port)})
+}
+
+func (s *Stack) writeUDP(src, dst endpoint, payload []byte) {
+	segment := make([]byte, udpHeaderLen+len(payload))
+	binary.BigEndian.PutUint16(segment[0:2], src.port)
+	binary.BigEndian.PutUint16(segment[2:4], dst.port)
+	binary.BigEndian.PutUint16(segment[4:6], uint16(len(segment)))
+	copy(segment[udpHeaderLen:], payload)
+	sum := transportChecksum(src.IP(), dst.IP(), protoUDP, segment)
+	if sum == 0 {
+		sum = 0xffff
+	}
+	binary.BigEndian.PutUint16(segment[6:8], sum)
+
+	s.send(src.IP(), dst.IP(), protoUDP, segment)
+}
+
+func (s *Stack) handleUDP(pkt ipPacket) {
+	if len(pkt.payload) < udpHeaderLen {
+		return
+	}
+	srcPort := binary.BigEndian.Uint16(pkt.payload[0:2])
+	dstPort := binary.BigEndian.Uint16(pkt.payload[2:4])
+	length := int(binary.BigEndian.Uint16(pkt.payload[4:6]))
+	if length < udpHeaderLen || length > len(pkt.payload) {
+		return
+	}
+
+	rconn, ok := s.udpConns.Load(newEndpoint(pkt.dst, dstPort))
+	if !ok {
+		if rconn, ok = s.udpConns.Load(newEndpoint(net.IPv6unspecified, dstPort)); !ok {
+			s.log.Debugf("Dropping udp datagram to port(%d): no listener", dstPort)
+			return
+		}
+	}
+	conn := rconn.(*UDPConn)
+
+	addr := &net.UDPAddr{IP: pkt.src, Port: int(srcPort)}
+	if conn.remote != nil && (!conn.remote.IP.Equal(addr.IP) || conn.remote.Port != addr.Port) {
+		return
+	}
+
+	select {
+	case conn.queue <- udpDatagram{addr: addr, payload: pkt.payload[udpHeaderLen:length]}:
+	default:
+		s.log.Debugf("Dropping udp datagram to port(%d): queue full", dstPort)
+	}
+}
+
+// Read a datagram from the connection
+func (c *UDPConn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
+	for {
+		c.mutex.Lock()
+		deadline, deadlineChan := c.readDeadline, c.deadline
+		c.mutex.Unlock()
+
+		timer, err := newDeadlineTimer(deadline)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		select {
+		case datagram := <-c.queue:
+			timer.Stop()
+			return copy(b, datagram.payload), datagram.addr, nil
+		case <-c.closed:
+			timer.Stop()
+			return 0, nil, ErrorClosed
+		case <-timer.C:
+			return 0, nil, ErrorTimeout
+		case <-deadlineChan:
+			// Deadline changed
+			timer.Stop()
+		}
+	}
+}
+
+// Write a datagram to an address
+func (c *UDPConn) WriteTo(b []byte, addr net.Addr) (n int, err error) {
+	select {
+	case <-c.closed:
+		return 0, ErrorClosed
+	default:
+	}
+
+	c.mutex.Lock()
+	deadline := c.writeDeadline
+	c.mutex.Unlock()
+	if !deadline.IsZero() && time.Now().After(deadline) {
+		return 0, ErrorTimeout
+	}
+
+	raddr, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return 0, &net.AddrError{Err: "invalid address type", Addr: addr.String()}
+	}
+	if len(b) > c.stack.config.MTU-ipv6HeaderLen-udpHeaderLen {
+		return 0, &net.OpError{Op: "write", Net: "udp", Addr: addr, Err: ErrorMessageTooLong}
+	}
+
+	src := c.local.IP()
+	if src.IsUnspecified() {
+		if src, err = c.stack.sourceFor(raddr.IP); err != nil {
+			return
+		}
+	}
+
+	c.stack.writeUDP(newEndpoint(src, c.local.port), newEndpoint(raddr.IP, uint16(raddr.Port)), b)
+	return len(b), nil
+}
+
+// Read a datagram from the connected address
+func (c *UDPConn) Read(b []byte) (n int, err error) {
+	if c.remote == nil {
+		return 0, ErrorNotConnected
+	}
+	n, _, err = c.ReadFrom(b)
+	return
+}
+
+// Write a datagram to the connected address
+func (c *UDPConn) Write(b []byte) (n int, err error) {
+	if c.remote == nil {
+		return 0, ErrorNotConnected
+	}
+	return c.WriteTo(b, c.remote)
+}
+
+// Local Address
+func (c *UDPConn) LocalAddr() net.Addr {
+	return &net.UDPAddr{IP: c.local.IP(), Port: int(c.local.port)}
+}
+
+// Remote Address
+// Returns nil if the connection is not dialed
+func (c *UDPConn) RemoteAddr() net.Addr {
+	if c.remote == nil {
+		return nil
+	}
+	return c.remote
+}
+
+// Set Read and Write Deadlines
+func (c *UDPConn) SetDeadline(t time.Time) error {
+	c.SetReadDeadline(t)
+	return c.SetWriteDeadline(t)
+}
+
+// Set Read Deadline
+func (c *UDPConn) SetReadDeadline(t time.Time) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.readDeadline = t
+	close(c.deadline)
+	c.deadline = make(chan struct{})
+	return nil
+}
+
+// Set Write Deadline
+func (c *UDPConn) SetWriteDeadline(t time.Time) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.writeDeadline = t
+	return nil
+}
+
+// Close the connection
+func (c *UDPConn) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	select {
+	case <-c.closed:
+		return nil
+	default:
+	}
+	close(c.closed)
+
+	c.stack.udpConns.Delete(c.local)
+	c.stack.releasePort(protoUDP, c.local.port)
+	return nil
+}
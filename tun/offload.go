@@ -0,0 +1,374 @@
+package tun
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unsafe"
+)
+
+// virtio-net header flags and GSO types
+const (
+	VirtioNetHdrFNeedsCsum = 1
+
+	VirtioNetHdrGSONone  = 0
+	VirtioNetHdrGSOTCPv4 = 1
+	VirtioNetHdrGSOTCPv6 = 4
+
+	// Size of the virtio-net header
+	VirtioNetHdrSize = 10
+)
+
+const (
+	ipProtoTCP     = 6
+	tcpFlagFIN     = 0x01
+	tcpFlagPSH     = 0x08
+	tcpFlagACK     = 0x10
+	tcpFlagCWR     = 0x80
+	tcpCsumOffset  = 16
+	maxOffloadSize = 1<<16 - 1
+)
+
+// Byte order of the host, used by netlink and the virtio-net header
+var nativeEndian binary.ByteOrder = binary.LittleEndian
+
+func init() {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 0 {
+		nativeEndian = binary.BigEndian
+	}
+}
+
+// virtio-net header describing the offloads of a packet
+// Devices with offloads read and write it in front of every packet
+type VirtioNetHeader struct {
+	Flags      uint8
+	GSOType    uint8
+	HdrLen     uint16
+	GSOSize    uint16
+	CsumStart  uint16
+	CsumOffset uint16
+}
+
+// Packet along with its offloads
+type OffloadPacket struct {
+	Header VirtioNetHeader
+	Packet []byte
+}
+
+// Split a packet read from a Device with offloads into packets with complete checksums
+// GSO packets are segmented into TCP segments of the GSO size
+// Returns the no. of packets written to bufs along with their sizes
+func SplitGSO(hdr VirtioNetHeader, pkt []byte, bufs [][]byte, sizes []int) (n int, err error) {
+	if hdr.GSOType == VirtioNetHdrGSONone {
+		if len(bufs) == 0 || len(bufs[0]) < len(pkt) {
+			return 0, fmt.Errorf("tun: buffer too small for packet(%d)", len(pkt))
+		}
+		if hdr.Flags&VirtioNetHdrFNeedsCsum != 0 {
+			start, offset := int(hdr.CsumStart), int(hdr.CsumStart)+int(hdr.CsumOffset)
+			if offset+2 > len(pkt) {
+				return 0, fmt.Errorf("tun: invalid checksum offset(%d)", offset)
+			}
+			// The field holds the pseudo header checksum
+			binary.BigEndian.PutUint16(pkt[offset:], ^checksum(pkt[start:], 0))
+		}
+		sizes[0] = copy(bufs[0], pkt)
+		return 1, nil
+	}
+
+	if hdr.GSOType != VirtioNetHdrGSOTCPv4 && hdr.GSOType != VirtioNetHdrGSOTCPv6 {
+		return 0, fmt.Errorf("tun: unsupported gso type(%d)", hdr.GSOType)
+	}
+
+	ipv4 := hdr.GSOType == VirtioNetHdrGSOTCPv4
+	ipLen := int(hdr.CsumStart)
+	if ipLen+20 > len(pkt) || (ipv4 && ipLen < 20) || (!ipv4 && ipLen < 40) {
+		return 0, fmt.Errorf("tun: invalid gso packet")
+	}
+	// The header length of the virtio-net header is not reliable
+	tcpLen := int(pkt[ipLen+12]>>4) * 4
+	hdrLen := ipLen + tcpLen
+	if tcpLen < 20 || hdrLen > len(pkt) || hdr.GSOSize == 0 {
+		return 0, fmt.Errorf("tun: invalid gso packet")
+	}
+
+	src, dst := pkt[12:16], pkt[16:20]
+	if !ipv4 {
+		src, dst = pkt[8:24], pkt[24:40]
+	}
+	id := binary.BigEndian.Uint16(pkt[4:6])
+	seq := binary.BigEndian.Uint32(pkt[ipLen+4:])
+	flags := pkt[ipLen+13]
+
+	for offset := hdrLen; offset < len(pkt); offset += int(hdr.GSOSize) {
+		end := offset + int(hdr.GSOSize)
+		if end > len(pkt) {
+			end = len(pkt)
+		}
+		if n >= len(bufs) {
+			return n, fmt.Errorf("tun: not enough buffers for gso packet")
+		}
+		size := hdrLen + end - offset
+		if len(bufs[n]) < size {
+			return n, fmt.Errorf("tun: buffer too small for segment(%d)", size)
+		}
+
+		seg := bufs[n][:size]
+		copy(seg, pkt[:hdrLen])
+		copy(seg[hdrLen:], pkt[offset:end])
+
+		if ipv4 {
+			binary.BigEndian.PutUint16(seg[2:4], uint16(size))
+			binary.BigEndian.PutUint16(seg[4:6], id+uint16(n))
+			seg[10], seg[11] = 0, 0
+			binary.BigEndian.PutUint16(seg[10:12], ^checksum(seg[:ipLen], 0))
+		} else {
+			binary.BigEndian.PutUint16(seg[4:6], uint16(size-40))
+		}
+
+		tcp := seg[ipLen:]
+		binary.BigEndian.PutUint32(tcp[4:8], seq+uint32(offset-hdrLen))
+		tcp[13] = flags
+		if end != len(pkt) {
+			tcp[13] &^= tcpFlagFIN | tcpFlagPSH
+		}
+		if n > 0 {
+			tcp[13] &^= tcpFlagCWR
+		}
+		tcp[16], tcp[17] = 0, 0
+		binary.BigEndian.PutUint16(tcp[16:18], ^checksum(tcp, pseudoHeaderSum(src, dst, ipProtoTCP, len(tcp))))
+
+		sizes[n] = size
+		n++
+	}
+
+	return
+}
+
+// Coalesce consecutive TCP segments of the same flow into GSO packets
+// Packets which can not be coalesced are returned without offloads
+// Coalesced packets are copied, the packets are not modified
+func CoalesceTCP(pkts [][]byte) (out []OffloadPacket) {
+	flows := map[tcpFlow]*gsoItem{}
+
+	for _, pkt := range pkts {
+		seg, ok := parseTCPSegment(pkt)
+		if !ok {
+			out = append(out, OffloadPacket{Packet: pkt})
+			continue
+		}
+
+		if item, ok := flows[seg.flow]; ok && item.coalesce(&out[item.index], seg) {
+			continue
+		}
+		delete(flows, seg.flow)
+
+		out = append(out, OffloadPacket{Packet: pkt})
+		// Only segments carrying data without control flags other than ACK start a GSO packet
+		if seg.flags == tcpFlagACK && seg.payload > 0 {
+			flows[seg.flow] = &gsoItem{
+				index:   len(out) - 1,
+				first:   seg,
+				gsoSize: seg.payload,
+			}
+		}
+	}
+
+	for i := range out {
+		out[i].finish()
+	}
+	return
+}
+
+type tcpFlow struct {
+	ipv4     bool
+	src, dst [16]byte
+	sport    uint16
+	dport    uint16
+}
+
+type tcpSegment struct {
+	pkt     []byte
+	flow    tcpFlow
+	ipLen   int
+	tcpLen  int
+	seq     uint32
+	ack     uint32
+	flags   uint8
+	payload int
+}
+
+func parseTCPSegment(pkt []byte) (seg tcpSegment, ok bool) {
+	if len(pkt) < 20 {
+		return
+	}
+
+	switch pkt[0] >> 4 {
+	case 4:
+		seg.ipLen = int(pkt[0]&0x0f) * 4
+		// Fragments and packets with options are not coalesced
+		if seg.ipLen != 20 || pkt[9] != ipProtoTCP || binary.BigEndian.Uint16(pkt[6:8])&0x3fff != 0 ||
+			int(binary.BigEndian.Uint16(pkt[2:4])) != len(pkt) {
+			return
+		}
+		seg.flow.ipv4 = true
+		copy(seg.flow.src[:], pkt[12:16])
+		copy(seg.flow.dst[:], pkt[16:20])
+	case 6:
+		seg.ipLen = 40
+		// Packets with extension headers are not coalesced
+		if len(pkt) < 40 || pkt[6] != ipProtoTCP || int(binary.BigEndian.Uint16(pkt[4:6]))+40 != len(pkt) {
+			return
+		}
+		copy(seg.flow.src[:], pkt[8:24])
+		copy(seg.flow.dst[:], pkt[24:40])
+	default:
+		return
+	}
+
+	if len(pkt) < seg.ipLen+20 {
+		return
+	}
+	tcp := pkt[seg.ipLen:]
+	seg.tcpLen = int(tcp[12]>>4) * 4
+	if seg.tcpLen < 20 || len(tcp) < seg.tcpLen {
+		return
+	}
+
+	seg.pkt = pkt
+	seg.flow.sport = binary.BigEndian.Uint16(tcp[0:2])
+	seg.flow.dport = binary.BigEndian.Uint16(tcp[2:4])
+	seg.seq = binary.BigEndian.Uint32(tcp[4:8])
+	seg.ack = binary.BigEndian.Uint32(tcp[8:12])
+	seg.flags = tcp[13]
+	seg.payload = len(tcp) - seg.tcpLen
+	return seg, true
+}
+
+// GSO packet being coalesced
+type gsoItem struct {
+	// Index in the coalesced packets
+	index   int
+	first   tcpSegment
+	gsoSize int
+	// A segment smaller than the GSO size or with the PSH flag ends the GSO packet
+	closed bool
+}
+
+// Append a segment to the packet if it continues the flow
+func (item *gsoItem) coalesce(op *OffloadPacket, seg tcpSegment) bool {
+	first := item.first
+	hdrLen := first.ipLen + first.tcpLen
+
+	switch {
+	case item.closed:
+		return false
+	case seg.ipLen != first.ipLen || seg.tcpLen != first.tcpLen:
+		return false
+	case seg.seq != first.seq+uint32(len(op.Packet)-hdrLen) || seg.ack != first.ack:
+		return false
+	case seg.flags&^tcpFlagPSH != tcpFlagACK || seg.payload == 0 || seg.payload > item.gsoSize:
+		return false
+	case len(op.Packet)+seg.payload > maxOffloadSize:
+		return false
+	}
+
+	// TCP options and IP header fields other than the length, ID and checksum have to match
+	tcp, stcp := op.Packet[first.ipLen:], seg.pkt[seg.ipLen:]
+	if string(tcp[20:first.tcpLen]) != string(stcp[20:seg.tcpLen]) {
+		return false
+	}
+	if first.flow.ipv4 {
+		if op.Packet[1] != seg.pkt[1] || op.Packet[8] != seg.pkt[8] || op.Packet[6] != seg.pkt[6] {
+			return false
+		}
+	} else if string(op.Packet[0:4]) != string(seg.pkt[0:4]) || op.Packet[7] != seg.pkt[7] {
+		return false
+	}
+
+	if op.Header.GSOType == VirtioNetHdrGSONone {
+		op.Packet = append(make([]byte, 0, maxOffloadSize), op.Packet...)
+	}
+	op.Packet = append(op.Packet, seg.pkt[hdrLen:]...)
+	op.Packet[first.ipLen+13] |= seg.flags & tcpFlagPSH
+	item.closed = seg.payload < item.gsoSize || seg.flags&tcpFlagPSH != 0
+
+	op.Header = VirtioNetHeader{
+		Flags:      VirtioNetHdrFNeedsCsum,
+		GSOType:    VirtioNetHdrGSOTCPv6,
+		HdrLen:     uint16(hdrLen),
+		GSOSize:    uint16(item.gsoSize),
+		CsumStart:  uint16(first.ipLen),
+		CsumOffset: tcpCsumOffset,
+	}
+	if first.flow.ipv4 {
+		op.Header.GSOType = VirtioNetHdrGSOTCPv4
+	}
+	return true
+}
+
+// Update the lengths and checksums of a coalesced packet
+func (op *OffloadPacket) finish() {
+	if op.Header.GSOType == VirtioNetHdrGSONone {
+		return
+	}
+
+	pkt := op.Packet
+	ipLen := int(op.Header.CsumStart)
+	src, dst := pkt[8:24], pkt[24:40]
+	if op.Header.GSOType == VirtioNetHdrGSOTCPv4 {
+		src, dst = pkt[12:16], pkt[16:20]
+		binary.BigEndian.PutUint16(pkt[2:4], uint16(len(pkt)))
+		pkt[10], pkt[11] = 0, 0
+		binary.BigEndian.PutUint16(pkt[10:12], ^checksum(pkt[:ipLen], 0))
+	} else {
+		binary.BigEndian.PutUint16(pkt[4:6], uint16(len(pkt)-40))
+	}
+
+	// The Device completes the checksum starting with the pseudo header checksum
+	binary.BigEndian.PutUint16(pkt[ipLen+tcpCsumOffset:], checksum(nil, pseudoHeaderSum(src, dst, ipProtoTCP, len(pkt)-ipLen)))
+}
+
+// Encode the header
+func (h *VirtioNetHeader) encode(b []byte) {
+	b[0] = h.Flags
+	b[1] = h.GSOType
+	nativeEndian.PutUint16(b[2:4], h.HdrLen)
+	nativeEndian.PutUint16(b[4:6], h.GSOSize)
+	nativeEndian.PutUint16(b[6:8], h.CsumStart)
+	nativeEndian.PutUint16(b[8:10], h.CsumOffset)
+}
+
+// Decode the header
+func (h *VirtioNetHeader) decode(b []byte) {
+	h.Flags = b[0]
+	h.GSOType = b[1]
+	h.HdrLen = nativeEndian.Uint16(b[2:4])
+	h.GSOSize = nativeEndian.Uint16(b[4:6])
+	h.CsumStart = nativeEndian.Uint16(b[6:8])
+	h.CsumOffset = nativeEndian.Uint16(b[8:10])
+}
+
+func pseudoHeaderSum(src, dst []byte, proto uint8, length int) uint32 {
+	sum := sum16(src, 0)
+	sum = sum16(dst, sum)
+	return sum + uint32(proto) + uint32(length)
+}
+
+func sum16(b []byte, sum uint32) uint32 {
+	for ; len(b) >= 2; b = b[2:] {
+		sum += uint32(b[0])<<8 | uint32(b[1])
+	}
+	if len(b) == 1 {
+		sum += uint32(b[0]) << 8
+	}
+	return sum
+}
+
+// Folded one's complement sum, complement it for the checksum field
+func checksum(b []byte, initial uint32) uint16 {
+	sum := sum16(b, initial)
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return uint16(sum)
+}
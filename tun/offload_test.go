@@ -0,0 +1,108 @@
+package tun
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+const benchMSS = 1400
+
+// TCP segment of a flow from 10.0.0.1:1000 to 10.0.0.2:2000
+func testTCPSegment(seq uint32, payload int) []byte {
+	pkt := make([]byte, 40+payload)
+	pkt[0] = 0x45
+	binary.BigEndian.PutUint16(pkt[2:4], uint16(len(pkt)))
+	pkt[6] = 0x40
+	pkt[8] = 64
+	pkt[9] = ipProtoTCP
+	copy(pkt[12:16], net.IPv4(10, 0, 0, 1).To4())
+	copy(pkt[16:20], net.IPv4(10, 0, 0, 2).To4())
+	binary.BigEndian.PutUint16(pkt[20:22], 1000)
+	binary.BigEndian.PutUint16(pkt[22:24], 2000)
+	binary.BigEndian.PutUint32(pkt[24:28], seq)
+	pkt[32] = 5 << 4
+	pkt[33] = tcpFlagACK
+	return pkt
+}
+
+func testGSOHeader() VirtioNetHeader {
+	return VirtioNetHeader{
+		GSOType:    VirtioNetHdrGSOTCPv4,
+		HdrLen:     40,
+		GSOSize:    benchMSS,
+		CsumStart:  20,
+		CsumOffset: tcpCsumOffset,
+	}
+}
+
+func TestSplitCoalesce(t *testing.T) {
+	segments := 46
+	pkt := testTCPSegment(0, segments*benchMSS)
+
+	bufs := make([][]byte, segments)
+	for i := range bufs {
+		bufs[i] = make([]byte, 1500)
+	}
+	sizes := make([]int, segments)
+
+	n, err := SplitGSO(testGSOHeader(), pkt, bufs, sizes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != segments {
+		t.Fatalf("split into %d segments, want %d", n, segments)
+	}
+
+	pkts := make([][]byte, n)
+	for i := range pkts {
+		if sizes[i] != 40+benchMSS {
+			t.Fatalf("segment %d has %d bytes, want %d", i, sizes[i], 40+benchMSS)
+		}
+		pkts[i] = bufs[i][:sizes[i]]
+	}
+
+	out := CoalesceTCP(pkts)
+	if len(out) != 1 {
+		t.Fatalf("coalesced into %d packets, want 1", len(out))
+	}
+	if len(out[0].Packet) != len(pkt) || out[0].Header.GSOSize != benchMSS {
+		t.Fatalf("coalesced %d bytes with gso size %d, want %d with %d", len(out[0].Packet), out[0].Header.GSOSize, len(pkt), benchMSS)
+	}
+}
+
+func BenchmarkSplitGSO(b *testing.B) {
+	segments := 46
+	pkt := testTCPSegment(0, segments*benchMSS)
+	hdr := testGSOHeader()
+	bufs := make([][]byte, segments)
+	for i := range bufs {
+		bufs[i] = make([]byte, 1500)
+	}
+	sizes := make([]int, segments)
+
+	b.SetBytes(int64(len(pkt)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := SplitGSO(hdr, pkt, bufs, sizes); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCoalesceTCP(b *testing.B) {
+	pkts := make([][]byte, 46)
+	for i := range pkts {
+		pkts[i] = testTCPSegment(uint32(i*benchMSS), benchMSS)
+	}
+
+	b.SetBytes(int64(len(pkts) * benchMSS))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if out := CoalesceTCP(pkts); len(out) != 1 {
+			b.Fatalf("coalesced into %d packets", len(out))
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package tun
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Link types of pcap files
+const (
+	// Ethernet frames of TAP Devices
+	LinkTypeEthernet = 1
+	// IP packets of TUN Devices
+	LinkTypeRaw = 101
+)
+
+const (
+	pcapMagicMicro   = 0xa1b2c3d4
+	pcapMagicNano    = 0xa1b23c4d
+	pcapSnapLen      = 65535
+	pcapHeaderSize   = 24
+	pcapRecordHeader = 16
+	// Largest packet accepted when reading
+	pcapMaxPacket = 262144
+)
+
+// Reader of pcap files
+type PcapReader struct {
+	r         io.Reader
+	order     binary.ByteOrder
+	nano      bool
+	linkType  uint32
+	snapLen   uint32
+	recHeader [pcapRecordHeader]byte
+}
+
+// Create a PcapReader reading the file header from r
+func NewPcapReader(r io.Reader) (pr *PcapReader, err error) {
+	var header [pcapHeaderSize]byte
+	if _, err = io.ReadFull(r, header[:]); err != nil {
+		err = fmt.Errorf("pcap: error reading header: %v", err)
+		return
+	}
+
+	pr = &PcapReader{r: r}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch order.Uint32(header[0:4]) {
+		case pcapMagicMicro:
+			pr.order = order
+		case pcapMagicNano:
+			pr.order, pr.nano = order, true
+		}
+		if pr.order != nil {
+			break
+		}
+	}
+	if pr.order == nil {
+		return nil, fmt.Errorf("pcap: invalid magic: %x", header[0:4])
+	}
+
+	pr.snapLen = pr.order.Uint32(header[16:20])
+	pr.linkType = pr.order.Uint32(header[20:24])
+	return
+}
+
+// Link type of the packets
+func (pr *PcapReader) LinkType() uint32 {
+	return pr.linkType
+}
+
+// Read the next packet
+// Returns io.EOF at the end of the file
+func (pr *PcapReader) ReadPacket() (data []byte, ts time.Time, err error) {
+	if _, err = io.ReadFull(pr.r, pr.recHeader[:]); err != nil {
+		return
+	}
+
+	sec := int64(pr.order.Uint32(pr.recHeader[0:4]))
+	frac := int64(pr.order.Uint32(pr.recHeader[4:8]))
+	if !pr.nano {
+		frac *= int64(time.Microsecond)
+	}
+	ts = time.Unix(sec, frac)
+
+	size := pr.order.Uint32(pr.recHeader[8:12])
+	if size > pcapMaxPacket {
+		err = fmt.Errorf("pcap: invalid packet size: %d", size)
+		return
+	}
+	data = make([]byte, size)
+	if _, err = io.ReadFull(pr.r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return
+	}
+	return
+}
+
+// Writer of pcap files with microsecond timestamps
+type PcapWriter struct {
+	w     io.Writer
+	mutex sync.Mutex
+}
+
+// Create a PcapWriter writing the file header to w
+func NewPcapWriter(w io.Writer, linkType uint32) (pw *PcapWriter, err error) {
+	var header [pcapHeaderSize]byte
+	binary.LittleEndian.PutUint32(header[0:4], pcapMagicMicro)
+	binary.LittleEndian.PutUint16(header[4:6], 2)
+	binary.LittleEndian.PutUint16(header[6:8], 4)
+	binary.LittleEndian.PutUint32(header[16:20], pcapSnapLen)
+	binary.LittleEndian.PutUint32(header[20:24], linkType)
+	if _, err = w.Write(header[:]); err != nil {
+		return
+	}
+
+	return &PcapWriter{w: w}, nil
+}
+
+// Write a packet
+// Packets larger than the snapshot length are truncated
+func (pw *PcapWriter) WritePacket(ts time.Time, data []byte) (err error) {
+	captured := data
+	if len(captured) > pcapSnapLen {
+		captured = captured[:pcapSnapLen]
+	}
+
+	record := make([]byte, pcapRecordHeader+len(captured))
+	binary.LittleEndian.PutUint32(record[0:4], uint32(ts.Unix()))
+	binary.LittleEndian.PutUint32(record[4:8], uint32(ts.Nanosecond()/int(time.Microsecond)))
+	binary.LittleEndian.PutUint32(record[8:12], uint32(len(captured)))
+	binary.LittleEndian.PutUint32(record[12:16], uint32(len(data)))
+	copy(record[pcapRecordHeader:], captured)
+
+	pw.mutex.Lock()
+	defer pw.mutex.Unlock()
+
+	_, err = pw.w.Write(record)
+	return
+}
+
+// Device replaying packets from a pcap file and recording written packets to another
+// Useful to exercise packet processing without a kernel Device
+type PcapDevice struct {
+	name   string
+	mtu    int
+	reader *PcapReader
+	writer *PcapWriter
+	files  []*os.File
+	exit   chan bool
+	once   sync.Once
+}
+
+// Open a PcapDevice
+// Packets are read from the input file, Read blocks until the Device is closed if it is empty
+// Written packets are recorded to the output file using the link type, they are discarded if it is empty
+func OpenPcapDevice(mtu int, input, output string, linkType uint32) (pd *PcapDevice, err error) {
+	pd = &PcapDevice{
+		name: "pcap",
+		mtu:  mtu,
+		exit: make(chan bool),
+	}
+	defer func() {
+		if err != nil {
+			pd.Close()
+			pd = nil
+		}
+	}()
+
+	if len(input) > 0 {
+		var file *os.File
+		if file, err = os.Open(input); err != nil {
+			return
+		}
+		pd.files = append(pd.files, file)
+		if pd.reader, err = NewPcapReader(file); err != nil {
+			return
+		}
+		pd.name = input
+	}
+
+	if len(output) > 0 {
+		var file *os.File
+		if file, err = os.Create(output); err != nil {
+			return
+		}
+		pd.files = append(pd.files, file)
+		if pd.writer, err = NewPcapWriter(file, linkType); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// Read the next packet of the input file
+// Returns io.EOF at the end of the file
+func (pd *PcapDevice) Read(b []byte) (n int, err error) {
+	if pd.reader == nil {
+		<-pd.exit
+		return 0, io.EOF
+	}
+
+	select {
+	case <-pd.exit:
+		return 0, ErrorClosed
+	default:
+	}
+
+	data, _, err := pd.reader.ReadPacket()
+	if err != nil {
+		return
+	}
+	if len(b) < len(data) {
+		return 0, io.ErrShortBuffer
+	}
+	return copy(b, data), nil
+}
+
+// Record a packet to the output file
+func (pd *PcapDevice) Write(b []byte) (n int, err error) {
+	select {
+	case <-pd.exit:
+		return 0, ErrorClosed
+	default:
+	}
+
+	if pd.writer != nil {
+		if err = pd.writer.WritePacket(time.Now(), b); err != nil {
+			return
+		}
+	}
+	return len(b), nil
+}
+
+// Maximum transmission unit
+func (pd *PcapDevice) MTU() int {
+	return pd.mtu
+}
+
+// Name of the Device
+func (pd *PcapDevice) Name() string {
+	return pd.name
+}
+
+// Close the Device along with its files
+func (pd *PcapDevice) Close() (err error) {
+	pd.once.Do(func() {
+		close(pd.exit)
+		for _, file := range pd.files {
+			if ferr := file.Close(); ferr != nil && err == nil {
+				err = ferr
+			}
+		}
+	})
+	return
+}
@@ -0,0 +1,124 @@
+package tun
+
+import (
+	"encoding/binary"
+	"io"
+	"sync"
+	"time"
+)
+
+// Direction of a captured packet
+type PacketDirection uint32
+
+const (
+	PacketDirectionUnknown PacketDirection = iota
+	PacketDirectionInbound
+	PacketDirectionOutbound
+)
+
+const (
+	pcapngBlockSection   = 0x0a0d0d0a
+	pcapngBlockInterface = 0x00000001
+	pcapngBlockPacket    = 0x00000006
+	pcapngByteOrderMagic = 0x1a2b3c4d
+
+	pcapngOptionEnd     = 0
+	pcapngOptionComment = 1
+	pcapngOptionIfName  = 2
+	pcapngOptionFlags   = 2
+)
+
+// Writer of pcapng files with a single interface and microsecond timestamps
+// Packets can be annotated with their direction and a comment
+type PcapngWriter struct {
+	w     io.Writer
+	mutex sync.Mutex
+}
+
+// Create a PcapngWriter writing the section and interface headers to w
+func NewPcapngWriter(w io.Writer, linkType uint32, name string) (pw *PcapngWriter, err error) {
+	pw = &PcapngWriter{w: w}
+
+	section := make([]byte, 16)
+	binary.LittleEndian.PutUint32(section[0:4], pcapngByteOrderMagic)
+	binary.LittleEndian.PutUint16(section[4:6], 1)
+	binary.LittleEndian.PutUint16(section[6:8], 0)
+	// Section length is not specified
+	binary.LittleEndian.PutUint64(section[8:16], ^uint64(0))
+	if _, err = pw.writeBlock(pcapngBlockSection, section, nil); err != nil {
+		return nil, err
+	}
+
+	iface := make([]byte, 8)
+	binary.LittleEndian.PutUint16(iface[0:2], uint16(linkType))
+	binary.LittleEndian.PutUint32(iface[4:8], pcapSnapLen)
+	options := pcapngOption(nil, pcapngOptionIfName, []byte(name))
+	if _, err = pw.writeBlock(pcapngBlockInterface, iface, options); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// Write a packet
+// Returns the no. of bytes written to the file
+func (pw *PcapngWriter) WritePacket(ts time.Time, data []byte, direction PacketDirection, comment string) (n int, err error) {
+	captured := data
+	if len(captured) > pcapSnapLen {
+		captured = captured[:pcapSnapLen]
+	}
+
+	micros := uint64(ts.UnixNano() / int64(time.Microsecond))
+	body := make([]byte, 20, 20+len(captured)+3)
+	binary.LittleEndian.PutUint32(body[4:8], uint32(micros>>32))
+	binary.LittleEndian.PutUint32(body[8:12], uint32(micros))
+	binary.LittleEndian.PutUint32(body[12:16], uint32(len(captured)))
+	binary.LittleEndian.PutUint32(body[16:20], uint32(len(data)))
+	body = append(body, captured...)
+	body = append(body, make([]byte, pad4(len(captured)))...)
+
+	var options []byte
+	if direction != PacketDirectionUnknown {
+		flags := make([]byte, 4)
+		binary.LittleEndian.PutUint32(flags, uint32(direction))
+		options = pcapngOption(options, pcapngOptionFlags, flags)
+	}
+	if len(comment) > 0 {
+		options = pcapngOption(options, pcapngOptionComment, []byte(comment))
+	}
+
+	return pw.writeBlock(pcapngBlockPacket, body, options)
+}
+
+func (pw *PcapngWriter) writeBlock(blockType uint32, body []byte, options []byte) (n int, err error) {
+	if len(options) > 0 {
+		options = pcapngOption(options, pcapngOptionEnd, nil)
+	}
+
+	size := 12 + len(body) + len(options)
+	block := make([]byte, 8, size)
+	binary.LittleEndian.PutUint32(block[0:4], blockType)
+	binary.LittleEndian.PutUint32(block[4:8], uint32(size))
+	block = append(block, body...)
+	block = append(block, options...)
+	block = append(block, block[4:8]...)
+
+	pw.mutex.Lock()
+	defer pw.mutex.Unlock()
+
+	return pw.w.Write(block)
+}
+
+// Append an option padded to 32 bits
+func pcapngOption(b []byte, code uint16, value []byte) []byte {
+	header := make([]byte, 4)
+	binary.LittleEndian.PutUint16(header[0:2], code)
+	binary.LittleEndian.PutUint16(header[2:4], uint16(len(value)))
+	b = append(b, header...)
+	b = append(b, value...)
+	return append(b, make([]byte, pad4(len(value)))...)
+}
+
+func pad4(n int) int {
+	return (4 - n%4) % 4
+}
@@ -0,0 +1,73 @@
+package tun
+
+import (
+	"io"
+	"sync"
+)
+
+const pipeQueueSize = 256
+
+// In-memory Device connected to another Pipe
+// Packets written to one end are read from the other
+type Pipe struct {
+	name   string
+	mtu    int
+	rx     chan []byte
+	peer   *Pipe
+	closed chan bool
+	once   *sync.Once
+}
+
+// Create a connected pair of Pipes
+// Writes block when the queue of the other end is full
+func NewPipe(mtu int) (a, b *Pipe) {
+	closed := make(chan bool)
+	once := new(sync.Once)
+
+	a = &Pipe{name: "pipe0", mtu: mtu, rx: make(chan []byte, pipeQueueSize), closed: closed, once: once}
+	b = &Pipe{name: "pipe1", mtu: mtu, rx: make(chan []byte, pipeQueueSize), closed: closed, once: once}
+	a.peer, b.peer = b, a
+	return
+}
+
+// Read the next packet written to the other end
+// Returns io.EOF when the Pipe is closed
+func (p *Pipe) Read(b []byte) (n int, err error) {
+	select {
+	case pkt := <-p.rx:
+		if len(b) < len(pkt) {
+			return 0, io.ErrShortBuffer
+		}
+		return copy(b, pkt), nil
+	case <-p.closed:
+		return 0, io.EOF
+	}
+}
+
+// Write a packet to the other end
+func (p *Pipe) Write(b []byte) (n int, err error) {
+	select {
+	case p.peer.rx <- append([]byte(nil), b...):
+		return len(b), nil
+	case <-p.closed:
+		return 0, ErrorClosed
+	}
+}
+
+// Maximum transmission unit
+func (p *Pipe) MTU() int {
+	return p.mtu
+}
+
+// Name of the Pipe
+func (p *Pipe) Name() string {
+	return p.name
+}
+
+// Close both ends of the Pipe
+func (p *Pipe) Close() error {
+	p.once.Do(func() {
+		close(p.closed)
+	})
+	return nil
+}
@@ -0,0 +1,108 @@
+package tun
+
+import (
+	"io"
+	"sync"
+)
+
+// Room for the Ethernet header of TAP Devices in buffers sized using the MTU
+const queueHeadroom = 64
+
+// Queues of a Device
+// Read returns the packets of all queues and Write uses the first queue,
+// use Queues to read and write the queues in parallel
+type QueueSet struct {
+	name    string
+	mtu     int
+	queues  []BatchDevice
+	packets chan []byte
+	start   sync.Once
+	exit    chan bool
+	close   sync.Once
+}
+
+// Create a QueueSet
+func NewQueueSet(name string, mtu int, queues []BatchDevice) *QueueSet {
+	return &QueueSet{
+		name:    name,
+		mtu:     mtu,
+		queues:  queues,
+		packets: make(chan []byte, pipeQueueSize),
+		exit:    make(chan bool),
+	}
+}
+
+// Queues of the Device
+func (qs *QueueSet) Queues() []BatchDevice {
+	return qs.queues
+}
+
+// Read the next packet of any queue
+func (qs *QueueSet) Read(b []byte) (n int, err error) {
+	qs.start.Do(func() {
+		for _, queue := range qs.queues {
+			go qs.readLoop(queue)
+		}
+	})
+
+	select {
+	case pkt := <-qs.packets:
+		if len(b) < len(pkt) {
+			return 0, io.ErrShortBuffer
+		}
+		return copy(b, pkt), nil
+	case <-qs.exit:
+		return 0, io.EOF
+	}
+}
+
+func (qs *QueueSet) readLoop(queue BatchDevice) {
+	bufs := make([][]byte, queue.BatchSize())
+	for i := range bufs {
+		bufs[i] = make([]byte, qs.mtu+queueHeadroom)
+	}
+	sizes := make([]int, len(bufs))
+
+	for {
+		n, err := queue.ReadBatch(bufs, sizes)
+		if err != nil {
+			qs.Close()
+			return
+		}
+		for i := 0; i < n; i++ {
+			select {
+			case qs.packets <- append([]byte(nil), bufs[i][:sizes[i]]...):
+			case <-qs.exit:
+				return
+			}
+		}
+	}
+}
+
+// Write a packet to the first queue
+func (qs *QueueSet) Write(b []byte) (n int, err error) {
+	return qs.queues[0].Write(b)
+}
+
+// Maximum transmission unit
+func (qs *QueueSet) MTU() int {
+	return qs.mtu
+}
+
+// Name of the Device
+func (qs *QueueSet) Name() string {
+	return qs.name
+}
+
+// Close all queues
+func (qs *QueueSet) Close() (err error) {
+	qs.close.Do(func() {
+		close(qs.exit)
+		for _, queue := range qs.queues {
+			if qerr := queue.Close(); qerr != nil && err == nil {
+				err = qerr
+			}
+		}
+	})
+	return
+}
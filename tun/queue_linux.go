@@ -0,0 +1,281 @@
+package tun
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// Maximum no. of segments of a GSO packet returned by ReadBatch
+	queueBatchSize = 64
+
+	tunFCsum = 0x01
+	tunFTSO4 = 0x02
+	tunFTSO6 = 0x04
+)
+
+// Queue of a Linux TUN/TAP Device
+// With offloads a read can return a GSO packet of up to 64KB which is split into segments,
+// and consecutive TCP segments written in a batch are coalesced into GSO packets
+type Queue struct {
+	file    *os.File
+	rc      syscall.RawConn
+	name    string
+	mtu     int
+	offload bool
+
+	// Read buffer including the virtio-net header
+	rbuf   []byte
+	rmutex sync.Mutex
+	// Segments of the last GSO packet not returned by Read
+	pending     [][]byte
+	pendingBufs [][]byte
+	pendingSize []int
+}
+
+type ifreqFlags struct {
+	name  [unix.IFNAMSIZ]byte
+	flags uint16
+	_     [22]byte
+}
+
+// Open the queues of a TUN/TAP Device
+func openQueues(config *TunConfig) (name string, queues []BatchDevice, err error) {
+	if config.Offload && config.TAP {
+		err = fmt.Errorf("%w: offload requires a TUN device", ErrorInvalidConfig)
+		return
+	}
+
+	count := config.Queues
+	if count < 1 {
+		count = 1
+	}
+
+	var flags uint16 = unix.IFF_NO_PI | unix.IFF_TUN
+	if config.TAP {
+		flags = unix.IFF_NO_PI | unix.IFF_TAP
+	}
+	if count > 1 {
+		flags |= unix.IFF_MULTI_QUEUE
+	}
+	if config.Offload {
+		flags |= unix.IFF_VNET_HDR
+	}
+
+	defer func() {
+		if err != nil {
+			for _, queue := range queues {
+				queue.Close()
+			}
+			queues = nil
+		}
+	}()
+
+	for i := 0; i < count; i++ {
+		var queue *Queue
+		if queue, err = openQueue(name, flags, config); err != nil {
+			return
+		}
+		name = queue.name
+		queues = append(queues, queue)
+	}
+
+	return
+}
+
+func openQueue(name string, flags uint16, config *TunConfig) (q *Queue, err error) {
+	fd, err := unix.Open("/dev/net/tun", unix.O_RDWR|unix.O_CLOEXEC|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, newConfigError("open /dev/net/tun", err)
+	}
+
+	req := ifreqFlags{flags: flags}
+	copy(req.name[:unix.IFNAMSIZ-1], name)
+	if err = ioctl(fd, unix.TUNSETIFF, uintptr(unsafe.Pointer(&req))); err != nil {
+		unix.Close(fd)
+		return nil, newConfigError("create device", err)
+	}
+	if config.Offload {
+		if err = ioctl(fd, unix.TUNSETOFFLOAD, tunFCsum|tunFTSO4|tunFTSO6); err != nil {
+			unix.Close(fd)
+			return nil, newConfigError("set offload", err)
+		}
+	}
+
+	q = &Queue{
+		file:    os.NewFile(uintptr(fd), "/dev/net/tun"),
+		name:    string(req.name[:clen(req.name[:])]),
+		mtu:     config.MTU,
+		offload: config.Offload,
+	}
+	if q.rc, err = q.file.SyscallConn(); err != nil {
+		q.file.Close()
+		return nil, err
+	}
+
+	if q.offload {
+		q.rbuf = make([]byte, VirtioNetHdrSize+maxOffloadSize)
+		q.pendingBufs = make([][]byte, queueBatchSize)
+		for i := range q.pendingBufs {
+			q.pendingBufs[i] = make([]byte, q.mtu+queueHeadroom)
+		}
+		q.pendingSize = make([]int, queueBatchSize)
+	}
+
+	return
+}
+
+func ioctl(fd int, req uint, arg uintptr) error {
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), uintptr(req), arg); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func clen(b []byte) int {
+	for i, c := range b {
+		if c == 0 {
+			return i
+		}
+	}
+	return len(b)
+}
+
+// Read a packet
+func (q *Queue) Read(b []byte) (n int, err error) {
+	if !q.offload {
+		return q.file.Read(b)
+	}
+
+	q.rmutex.Lock()
+	defer q.rmutex.Unlock()
+
+	if len(q.pending) == 0 {
+		count, err := q.readBatch(q.pendingBufs, q.pendingSize)
+		if err != nil {
+			return 0, err
+		}
+		for i := 0; i < count; i++ {
+			q.pending = append(q.pending, q.pendingBufs[i][:q.pendingSize[i]])
+		}
+	}
+
+	pkt := q.pending[0]
+	q.pending = q.pending[1:]
+	if len(b) < len(pkt) {
+		return 0, fmt.Errorf("tun: buffer too small for packet(%d)", len(pkt))
+	}
+	return copy(b, pkt), nil
+}
+
+// Read the packets of a single read from the Device
+// With offloads a GSO packet is split into segments
+func (q *Queue) ReadBatch(bufs [][]byte, sizes []int) (n int, err error) {
+	if !q.offload {
+		if sizes[0], err = q.file.Read(bufs[0]); err != nil {
+			return
+		}
+		return 1, nil
+	}
+
+	q.rmutex.Lock()
+	defer q.rmutex.Unlock()
+
+	return q.readBatch(bufs, sizes)
+}
+
+func (q *Queue) readBatch(bufs [][]byte, sizes []int) (n int, err error) {
+	for {
+		size, err := q.file.Read(q.rbuf)
+		if err != nil {
+			return 0, err
+		}
+		if size < VirtioNetHdrSize {
+			continue
+		}
+
+		var hdr VirtioNetHeader
+		hdr.decode(q.rbuf)
+		if n, err = SplitGSO(hdr, q.rbuf[VirtioNetHdrSize:size], bufs, sizes); err != nil {
+			// Drop invalid packets
+			continue
+		}
+		return n, nil
+	}
+}
+
+// Write a packet
+func (q *Queue) Write(b []byte) (n int, err error) {
+	if !q.offload {
+		return q.file.Write(b)
+	}
+
+	var hdr [VirtioNetHdrSize]byte
+	if err = q.writev(hdr[:], b); err != nil {
+		return
+	}
+	return len(b), nil
+}
+
+// Write packets
+// With offloads consecutive TCP segments of the same flow are written as GSO packets
+func (q *Queue) WriteBatch(pkts [][]byte) (n int, err error) {
+	if !q.offload {
+		for _, pkt := range pkts {
+			if _, err = q.file.Write(pkt); err != nil {
+				return
+			}
+			n++
+		}
+		return
+	}
+
+	var hdr [VirtioNetHdrSize]byte
+	for _, op := range CoalesceTCP(pkts) {
+		op.Header.encode(hdr[:])
+		if err = q.writev(hdr[:], op.Packet); err != nil {
+			return
+		}
+	}
+	return len(pkts), nil
+}
+
+func (q *Queue) writev(hdr, pkt []byte) (err error) {
+	iovs := [][]byte{hdr, pkt}
+	werr := q.rc.Write(func(fd uintptr) bool {
+		_, err = unix.Writev(int(fd), iovs)
+		return err != unix.EAGAIN
+	})
+	if werr != nil {
+		return werr
+	}
+	return
+}
+
+// Maximum no. of packets returned by ReadBatch
+func (q *Queue) BatchSize() int {
+	if q.offload {
+		return queueBatchSize
+	}
+	return 1
+}
+
+// Maximum transmission unit
+func (q *Queue) MTU() int {
+	return q.mtu
+}
+
+// Name of the Device
+func (q *Queue) Name() string {
+	return q.name
+}
+
+// Close the Queue
+func (q *Queue) Close() error {
+	return q.file.Close()
+}
@@ -0,0 +1,58 @@
+package tun
+
+import (
+	"fmt"
+	"net"
+)
+
+// Route through a Device
+type Route struct {
+	// Destination network
+	Destination *net.IPNet
+	// Optional next hop
+	Gateway net.IP
+	// Route metric (priority)
+	Metric int
+	// Routing table
+	// The main table is used if zero
+	Table int
+}
+
+// Stringify
+func (r *Route) String() string {
+	return fmt.Sprintf("dst(%v) gw(%v) metric(%d) table(%d)", r.Destination, r.Gateway, r.Metric, r.Table)
+}
+
+// Policy routing rule which looks up a routing table
+type Rule struct {
+	// Rule priority
+	Priority int
+	// Routing table to look up
+	Table int
+	// Optional source network to match
+	Src *net.IPNet
+	// Optional destination network to match
+	Dst *net.IPNet
+	// Optional firewall mark to match
+	Mark uint32
+	// Match packets not matching the selectors
+	Invert bool
+	// Use IPv6 when neither Src nor Dst is set
+	IPv6 bool
+}
+
+// Stringify
+func (r *Rule) String() string {
+	return fmt.Sprintf("priority(%d) table(%d) src(%v) dst(%v) mark(%d) invert(%v)", r.Priority, r.Table, r.Src, r.Dst, r.Mark, r.Invert)
+}
+
+func (r *Rule) isIPv6() bool {
+	switch {
+	case r.Src != nil:
+		return r.Src.IP.To4() == nil
+	case r.Dst != nil:
+		return r.Dst.IP.To4() == nil
+	default:
+		return r.IPv6
+	}
+}
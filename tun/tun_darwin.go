@@ -2,6 +2,7 @@ package tun
 
 import (
 	"fmt"
+	"net"
 
 	"os/exec"
 
@@ -11,7 +12,10 @@ import (
 // Open TunDevice on Darwin
 func (td *TunDevice) Open(config TunConfig) (err error) {
 	if td.Active {
-		return fmt.Errorf("tun: already active")
+		return ErrorAlreadyActive
+	}
+	if config.TAP || config.Queues > 1 || config.Offload {
+		return ErrorUnsupported
 	}
 
 	tun, err := water.New(water.Config{
@@ -27,11 +31,22 @@ func (td *TunDevice) Open(config TunConfig) (err error) {
 	// 	err = fmt.Errorf("tun: failed to run 'ifconfig': %s", err)
 	// 	return
 	// }
-	if err = exec.Command("/sbin/ifconfig", tun.Name(), config.IP.String(), config.IP.String()).Run(); err != nil {
-		return fmt.Errorf("tun: failed to run 'ifconfig': %s", err)
+	if config.IP.To4() == nil {
+		if err = addDarwinAddress(tun.Name(), &net.IPNet{IP: config.IP, Mask: config.CIDR.Mask}); err != nil {
+			return
+		}
+	} else {
+		if err = exec.Command("/sbin/ifconfig", tun.Name(), config.IP.String(), config.IP.String()).Run(); err != nil {
+			return fmt.Errorf("tun: failed to run 'ifconfig': %s", err)
+		}
+		if err = exec.Command("/sbin/route", "-n", "add", "-net", config.CIDR.String(), "-interface", tun.Name()).Run(); err != nil {
+			return fmt.Errorf("tun: failed to run 'route add': %s", err)
+		}
 	}
-	if err = exec.Command("/sbin/route", "-n", "add", "-net", config.CIDR.String(), "-interface", tun.Name()).Run(); err != nil {
-		return fmt.Errorf("tun: failed to run 'route add': %s", err)
+	for _, addr := range config.Addresses {
+		if err = addDarwinAddress(tun.Name(), addr); err != nil {
+			return
+		}
 	}
 	if err = exec.Command("/sbin/ifconfig", tun.Name(), "mtu", fmt.Sprintf("%d", config.MTU)).Run(); err != nil {
 		return fmt.Errorf("tun: failed to run 'ifconfig': %s", err)
@@ -45,3 +60,27 @@ func (td *TunDevice) Open(config TunConfig) (err error) {
 	fmt.Printf("tun: name(%s) active\n", td.Name)
 	return
 }
+
+// Add an additional IPv4 or IPv6 address and the route to its network
+func addDarwinAddress(name string, addr *net.IPNet) (err error) {
+	network := &net.IPNet{IP: addr.IP.Mask(addr.Mask), Mask: addr.Mask}
+	ones, _ := addr.Mask.Size()
+
+	if addr.IP.To4() != nil {
+		if err = exec.Command("/sbin/ifconfig", name, "inet", addr.IP.String(), addr.IP.String(), "alias").Run(); err != nil {
+			return fmt.Errorf("tun: failed to run 'ifconfig': %s", err)
+		}
+		if err = exec.Command("/sbin/route", "-n", "add", "-net", network.String(), "-interface", name).Run(); err != nil {
+			return fmt.Errorf("tun: failed to run 'route add': %s", err)
+		}
+		return
+	}
+
+	if err = exec.Command("/sbin/ifconfig", name, "inet6", addr.IP.String(), "prefixlen", fmt.Sprint(ones), "alias").Run(); err != nil {
+		return fmt.Errorf("tun: failed to run 'ifconfig': %s", err)
+	}
+	if err = exec.Command("/sbin/route", "-n", "add", "-inet6", network.String(), "-interface", name).Run(); err != nil {
+		return fmt.Errorf("tun: failed to run 'route add': %s", err)
+	}
+	return
+}
@@ -2,40 +2,193 @@ package tun
 
 import (
 	"fmt"
-
-	"os/exec"
+	"net"
 
 	"github.com/songgao/water"
+	"golang.org/x/sys/unix"
 )
 
 // Open TunDevice on Linux
+// The Device is configured using netlink
 func (td *TunDevice) Open(config TunConfig) (err error) {
 	if td.Active {
-		return fmt.Errorf("tun: already active")
+		return ErrorAlreadyActive
+	}
+
+	if config.Queues > 1 || config.Offload {
+		return td.openQueues(config)
+	}
+
+	deviceType := water.DeviceType(water.TUN)
+	if config.TAP {
+		deviceType = water.TAP
 	}
 
 	tun, err := water.New(water.Config{
-		DeviceType: water.TUN,
+		DeviceType: deviceType,
 	})
 	if err != nil {
 		return fmt.Errorf("tun: activation error: %v", err)
 	}
 
-	if err = exec.Command("/sbin/ip", "link", "set", "dev", tun.Name(), "mtu", fmt.Sprintf("%d", config.MTU)).Run(); err != nil {
-		return fmt.Errorf("tun: failed to run 'ifconfig': %s", err)
+	if err = configureLink(tun.Name(), &config); err != nil {
+		tun.Close()
+		return
+	}
+
+	td.initTunDevice(&config, tun)
+	fmt.Printf("tun: name(%s) active\n", td.Name)
+	return
+}
+
+func (td *TunDevice) openQueues(config TunConfig) (err error) {
+	name, queues, err := openQueues(&config)
+	if err != nil {
+		return
+	}
+	qs := NewQueueSet(name, config.MTU, queues)
+
+	if err = configureLink(name, &config); err != nil {
+		qs.Close()
+		return
+	}
+
+	td.Config = config
+	td.Name = name
+	td.queues = qs
+	td.Active = true
+	ownDevices.Store(td.Name, true)
+	fmt.Printf("tun: name(%s) queues(%d) offload(%v) active\n", td.Name, len(queues), config.Offload)
+	return
+}
+
+func configureLink(name string, config *TunConfig) (err error) {
+	index, err := linkIndex(name)
+	if err != nil {
+		return newConfigError("find link", err)
+	}
+
+	if config.MTU > 0 {
+		if err = netlinkSetMTU(index, config.MTU); err != nil {
+			return newConfigError("set mtu", err)
+		}
+	}
+	for _, addr := range config.AllAddresses() {
+		if err = netlinkAddress(unix.RTM_NEWADDR, index, addr); err != nil {
+			return newConfigError(fmt.Sprintf("add address %s", addr.String()), err)
+		}
 	}
-	if err = exec.Command("/sbin/ip", "addr", "add", config.IP.String(), "dev", tun.Name()).Run(); err != nil {
-		return fmt.Errorf("tun: failed to run 'ifconfig': %s", err)
+	if err = netlinkSetLinkState(index, true); err != nil {
+		return newConfigError("set link up", err)
+	}
+
+	return
+}
+
+func (td *TunDevice) linkIndex() (index int, err error) {
+	if !td.Active {
+		return 0, ErrorNotActive
 	}
-	if err = exec.Command("/sbin/ip", "addr", "add", config.CIDR.String(), "dev", tun.Name()).Run(); err != nil {
-		return fmt.Errorf("tun: failed to run 'ifconfig': %s", err)
+	if index, err = linkIndex(td.Name); err != nil {
+		return 0, newConfigError("find link", err)
 	}
-	if err = exec.Command("/sbin/ip", "link", "set", "dev", tun.Name(), "up").Run(); err != nil {
-		err = fmt.Errorf("tun: failed to run 'ifconfig': %s", err)
+	return
+}
+
+// Set the MTU of the Device
+func (td *TunDevice) SetMTU(mtu int) (err error) {
+	index, err := td.linkIndex()
+	if err != nil {
 		return
 	}
 
-	td.initTunDevice(&config, tun)
-	fmt.Printf("tun: name(%s) active\n", td.Name)
+	if err = netlinkSetMTU(index, mtu); err != nil {
+		return newConfigError("set mtu", err)
+	}
+	td.Config.MTU = mtu
 	return
 }
+
+// Set the link state of the Device
+func (td *TunDevice) SetLinkUp(up bool) (err error) {
+	index, err := td.linkIndex()
+	if err != nil {
+		return
+	}
+
+	return newConfigError("set link state", netlinkSetLinkState(index, up))
+}
+
+// Add an address to the Device
+func (td *TunDevice) AddAddress(addr *net.IPNet) (err error) {
+	index, err := td.linkIndex()
+	if err != nil {
+		return
+	}
+
+	if err = netlinkAddress(unix.RTM_NEWADDR, index, addr); err != nil {
+		return newConfigError(fmt.Sprintf("add address %s", addr.String()), err)
+	}
+	td.Config.Addresses = append(td.Config.Addresses, addr)
+	return
+}
+
+// Remove an address from the Device
+func (td *TunDevice) RemoveAddress(addr *net.IPNet) (err error) {
+	index, err := td.linkIndex()
+	if err != nil {
+		return
+	}
+
+	if err = netlinkAddress(unix.RTM_DELADDR, index, addr); err != nil {
+		return newConfigError(fmt.Sprintf("remove address %s", addr.String()), err)
+	}
+
+	addrs := td.Config.Addresses[:0]
+	for _, a := range td.Config.Addresses {
+		if !a.IP.Equal(addr.IP) {
+			addrs = append(addrs, a)
+		}
+	}
+	td.Config.Addresses = addrs
+	return
+}
+
+// Add a route through the Device
+func (td *TunDevice) AddRoute(route Route) (err error) {
+	index, err := td.linkIndex()
+	if err != nil {
+		return
+	}
+
+	return newConfigError(fmt.Sprintf("add route %s", route.String()), netlinkRoute(unix.RTM_NEWROUTE, index, &route))
+}
+
+// Remove a route through the Device
+func (td *TunDevice) RemoveRoute(route Route) (err error) {
+	index, err := td.linkIndex()
+	if err != nil {
+		return
+	}
+
+	return newConfigError(fmt.Sprintf("remove route %s", route.String()), netlinkRoute(unix.RTM_DELROUTE, index, &route))
+}
+
+// Add a policy routing rule
+// Rules are global and not bound to the Device, they usually look up a table holding routes through the Device
+func (td *TunDevice) AddRule(rule Rule) (err error) {
+	if !td.Active {
+		return ErrorNotActive
+	}
+
+	return newConfigError(fmt.Sprintf("add rule %s", rule.String()), netlinkRule(unix.RTM_NEWRULE, &rule))
+}
+
+// Remove a policy routing rule
+func (td *TunDevice) RemoveRule(rule Rule) (err error) {
+	if !td.Active {
+		return ErrorNotActive
+	}
+
+	return newConfigError(fmt.Sprintf("remove rule %s", rule.String()), netlinkRule(unix.RTM_DELRULE, &rule))
+}
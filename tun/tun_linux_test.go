@@ -0,0 +1,229 @@
+package tun
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/supergiant-hq/xnet/tun/netstack"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Exchange TCP traffic between the kernel and a netstack through a TUN Device
+// Requires root, the traffic runs over 10.250.0.0/24
+func BenchmarkTunTCP(b *testing.B) {
+	if os.Geteuid() != 0 {
+		b.Skip("requires root")
+	}
+
+	for _, queues := range []int{1, 4} {
+		for _, offload := range []bool{false, true} {
+			b.Run(fmt.Sprintf("queues=%d/offload=%v", queues, offload), func(b *testing.B) {
+				benchmarkTun(b, queues, offload)
+			})
+		}
+	}
+}
+
+func benchmarkTun(b *testing.B, queues int, offload bool) {
+	const mtu = 1500
+
+	config, err := NewTunConfig(mtu, "10.250.0.1/24")
+	if err != nil {
+		b.Fatal(err)
+	}
+	config.Queues = queues
+	config.Offload = offload
+
+	td := &TunDevice{}
+	if err = td.Open(config); err != nil {
+		b.Skip("opening tun device failed:", err)
+	}
+	defer td.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	scfg, err := netstack.NewConfig(mtu, "10.250.0.2")
+	if err != nil {
+		b.Fatal(err)
+	}
+	scfg.QueueSize = 8192
+	stack, err := netstack.New(log, scfg)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer stack.Close()
+
+	pumpStack(td.PacketDevice(), stack)
+
+	b.Run("kernel-netstack", func(b *testing.B) {
+		l, err := stack.Listen("tcp", ":5001")
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer l.Close()
+
+		benchmarkTransfer(b, l, func() (net.Conn, error) {
+			return net.Dial("tcp", "10.250.0.2:5001")
+		})
+	})
+
+	b.Run("netstack-kernel", func(b *testing.B) {
+		l, err := net.Listen("tcp", "10.250.0.1:5002")
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer l.Close()
+
+		benchmarkTransfer(b, l, func() (net.Conn, error) {
+			return stack.Dial("tcp", "10.250.0.1:5002")
+		})
+	})
+}
+
+// Move packets between the Device and the Stack
+func pumpStack(device Device, stack *netstack.Stack) {
+	queues := []BatchDevice{}
+	if mq, ok := device.(MultiQueueDevice); ok {
+		queues = mq.Queues()
+	}
+
+	for _, queue := range queues {
+		go func(queue BatchDevice) {
+			bufs := make([][]byte, queue.BatchSize())
+			for i := range bufs {
+				bufs[i] = make([]byte, queue.MTU()+64)
+			}
+			sizes := make([]int, len(bufs))
+			for {
+				n, err := queue.ReadBatch(bufs, sizes)
+				if err != nil {
+					return
+				}
+				for i := 0; i < n; i++ {
+					stack.Write(bufs[i][:sizes[i]])
+				}
+			}
+		}(queue)
+	}
+	if len(queues) == 0 {
+		go io.Copy(stack, device)
+	}
+
+	packets := make(chan []byte, 1024)
+	go func() {
+		defer close(packets)
+		buf := make([]byte, 65535)
+		for {
+			n, err := stack.Read(buf)
+			if err != nil {
+				return
+			}
+			packets <- append([]byte(nil), buf[:n]...)
+		}
+	}()
+
+	go func() {
+		for pkt := range packets {
+			if len(queues) == 0 {
+				device.Write(pkt)
+				continue
+			}
+
+			// Write the packets queued by the Stack in a batch
+			pkts := [][]byte{pkt}
+		batch:
+			for len(pkts) < 64 {
+				select {
+				case pkt, ok := <-packets:
+					if !ok {
+						break batch
+					}
+					pkts = append(pkts, pkt)
+				default:
+					break batch
+				}
+			}
+			queues[0].WriteBatch(pkts)
+		}
+	}()
+}
+
+// Send b.N buffers over a connection accepted by the listener
+func benchmarkTransfer(b *testing.B, l net.Listener, dial func() (net.Conn, error)) {
+	buf := make([]byte, 64<<10)
+	size := int64(b.N) * int64(len(buf))
+
+	var wg sync.WaitGroup
+	var received int64
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		received, _ = io.Copy(io.Discard, conn)
+	}()
+
+	conn, err := dial()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(int64(len(buf)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err = conn.Write(buf); err != nil {
+			conn.Close()
+			b.Fatal(err)
+		}
+	}
+	conn.Close()
+	wg.Wait()
+	b.StopTimer()
+
+	if received < size {
+		b.Fatalf("received %d of %d bytes", received, size)
+	}
+}
+
+// Requires root
+func TestSetMTU(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("requires root")
+	}
+
+	config, err := NewTunConfig(1500, "10.251.0.1/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	td := &TunDevice{}
+	if err = td.Open(config); err != nil {
+		t.Skip("opening tun device failed:", err)
+	}
+	defer td.Close()
+
+	// The link state is kept when changing the MTU
+	for _, up := range []bool{false, true} {
+		if err := td.SetLinkUp(up); err != nil {
+			t.Fatal(err)
+		}
+		if err := td.SetMTU(1400); err != nil {
+			t.Fatal(err)
+		}
+
+		ifc, err := net.InterfaceByName(td.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ifc.MTU != 1400 || (ifc.Flags&net.FlagUp != 0) != up {
+			t.Fatalf("mtu(%d) up(%v), want mtu(1400) up(%v)", ifc.MTU, ifc.Flags&net.FlagUp != 0, up)
+		}
+	}
+}
@@ -13,7 +13,11 @@ import (
 // TODO Implement and Test
 func (td *TunDevice) Open(config TunConfig) (err error) {
 	if td.Active {
-		return fmt.Errorf("tun: already active")
+		return ErrorAlreadyActive
+	}
+	// The TAP driver requires an IPv4 primary address, IPv6 addresses can be added as additional addresses
+	if config.TAP || config.Queues > 1 || config.Offload || config.IP.To4() == nil {
+		return ErrorUnsupported
 	}
 
 	tun, err := water.New(water.Config{
@@ -46,6 +50,19 @@ func (td *TunDevice) Open(config TunConfig) (err error) {
 	).Run(); err != nil {
 		return fmt.Errorf("tun: failed to run 'netsh' to set MTU: %s", err)
 	}
+	for _, addr := range config.Addresses {
+		family := "ipv6"
+		if addr.IP.To4() != nil {
+			family = "ipv4"
+		}
+		if err = exec.Command(
+			`C:\Windows\System32\netsh.exe`, "interface", family, "add", "address",
+			tun.Name(),
+			addr.String(),
+		).Run(); err != nil {
+			return fmt.Errorf("tun: failed to run 'netsh' to add address %s: %s", addr.String(), err)
+		}
+	}
 	if _, err = net.InterfaceByName(tun.Name()); err != nil {
 		return fmt.Errorf("tun: failed to find interface named %s: %v", tun.Name(), err)
 	}
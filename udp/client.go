@@ -1,6 +1,8 @@
 package udp
 
 import (
+	"time"
+
 	"github.com/supergiant-hq/xnet/network"
 )
 
@@ -12,6 +14,8 @@ type Client interface {
 	String() string
 	// Closes the client
 	Close(code int, reason string)
+	// Round-trip time to the peer, measured with pings, 0 if unknown
+	RTT() time.Duration
 
 	// Sends a Message to the Server
 	Send(msg *network.Message) (rmsg *network.Message, err error)
@@ -19,4 +23,11 @@ type Client interface {
 	OpenStream(metadata map[string]string, data map[string]string) (stream *Stream, err error)
 	// Closes a Stream
 	CloseStream(id string)
+
+	// Sends a QUIC Datagram
+	SendDatagram(b []byte) (err error)
+	// Receives a QUIC Datagram
+	ReceiveDatagram() (b []byte, err error)
+	// If both sides enabled Datagrams
+	DatagramsSupported() bool
 }
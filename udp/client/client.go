@@ -33,6 +33,13 @@ type ClosedHandler func(reason string)
 // Called when a Server sends a message
 type MessageHandler func(*Client, *network.Message)
 
+// Called before each ping, returns the load reported to the Server
+type LoadHandler func() uint32
+
+// Called after the handshake, returns Data sent to the Server in addition to Config.Data
+// Used to bind credentials to the TLS session
+type SessionDataHandler func(state quic.ConnectionState) (data map[string]string, err error)
+
 // Client
 type Client struct {
 	// Config
@@ -42,6 +49,8 @@ type Client struct {
 	canReconnect        CanReconnect
 	disconnectedHandler DisconnectedHandler
 	closedHandler       ClosedHandler
+	sessionDataHandler  SessionDataHandler
+	loadHandler         LoadHandler
 	messageHandler      map[network.MessageType]MessageHandler
 	streamHandler       udp.StreamHandler
 
@@ -50,24 +59,31 @@ type Client struct {
 	// UDP Connection
 	// It's exposed as it's needed in the p2pc package
 	UDPConn *net.UDPConn
+	conn    net.PacketConn
 	session quic.Session
 	channel *network.Channel
 	streams *sync.Map
 
 	// ID
-	Id        string
-	sessionId string
+	Id string
 	// Metadata
 	Data *model.ClientData
 	init bool
 
+	// Smoothed round-trip time of the pings to the Server
+	rtt    time.Duration
+	rmutex sync.Mutex
+
 	// Connected Status
 	Connected bool
 	// Exit Channel
 	Exit chan bool
 	// Closed Status
 	Closed bool
-	mutex  sync.Mutex
+	// Held while connecting and closing
+	mutex sync.Mutex
+	// Guards the session, the channel and the status for readers while connecting
+	smutex sync.Mutex
 	log    *logrus.Entry
 }
 
@@ -86,13 +102,16 @@ func New(log *logrus.Logger, cfg Config) (c *Client, err error) {
 		log:  log.WithField("prefix", fmt.Sprintf("UDPC-%s", cfg.Tag)),
 	}
 
-	if c.Addr, err = net.ResolveUDPAddr("udp", ":0"); err != nil {
-		return
+	if c.Addr = cfg.ListenAddr; c.Addr == nil {
+		if c.Addr, err = net.ResolveUDPAddr("udp", ":0"); err != nil {
+			return
+		}
 	}
 
 	if c.UDPConn, err = net.ListenUDP("udp", c.Addr); err != nil {
 		return
 	}
+	c.conn = c.UDPConn
 
 	return
 }
@@ -110,6 +129,29 @@ func NewWithConnection(log *logrus.Logger, cfg Config, addr *net.UDPAddr, udpCon
 
 		Addr:    addr,
 		UDPConn: udpConn,
+		conn:    udpConn,
+
+		Exit: make(chan bool, 1),
+		log:  log.WithField("prefix", fmt.Sprintf("UDPC-%s", cfg.Tag)),
+	}
+
+	return
+}
+
+// Creates a new Client using an existing net.PacketConn connection
+// UDPConn is nil, the connection is not closed with the Client
+func NewWithPacketConn(log *logrus.Logger, cfg Config, addr *net.UDPAddr, conn net.PacketConn) (c *Client, err error) {
+	if err = cfg.init(true); err != nil {
+		return
+	}
+
+	c = &Client{
+		Cfg:            cfg,
+		messageHandler: make(map[network.MessageType]MessageHandler),
+		streams:        new(sync.Map),
+
+		Addr: addr,
+		conn: conn,
 
 		Exit: make(chan bool, 1),
 		log:  log.WithField("prefix", fmt.Sprintf("UDPC-%s", cfg.Tag)),
@@ -143,6 +185,16 @@ func (c *Client) SetClosedHandler(handler ClosedHandler) {
 	c.closedHandler = handler
 }
 
+// Set Session Data Handler
+func (c *Client) SetSessionDataHandler(handler SessionDataHandler) {
+	c.sessionDataHandler = handler
+}
+
+// Set Load Handler
+func (c *Client) SetLoadHandler(handler LoadHandler) {
+	c.loadHandler = handler
+}
+
 // Set New Stream Handler
 func (c *Client) SetStreamHandler(handler udp.StreamHandler) {
 	c.streamHandler = handler
@@ -209,27 +261,40 @@ func (c *Client) Connect() (err error) {
 func (c *Client) connect() (err error) {
 	c.reset()
 
-	if c.session, err = quic.Dial(
-		c.UDPConn,
+	session, err := quic.Dial(
+		c.conn,
 		c.Cfg.ServerAddr,
 		c.Cfg.ServerAddr.String(),
 		c.Cfg.TLS.Clone(),
 		c.Cfg.Quic.Clone(),
-	); err != nil {
+	)
+	if err != nil {
 		return
 	}
+	c.smutex.Lock()
+	c.session = session
+	c.smutex.Unlock()
 
-	stream, err := c.session.OpenStream()
+	stream, err := session.OpenStream()
 	if err != nil {
 		return
 	}
 
+	c.smutex.Lock()
 	c.channel = network.NewChannel(c.log.Logger, stream, c.Cfg.Unmarshalers())
-	c.sessionId = time.Now().UTC().String()
+	c.smutex.Unlock()
 
 	return
 }
 
+// Session and channel of the current connection, nil if not connected
+func (c *Client) current() (session quic.Session, channel *network.Channel) {
+	c.smutex.Lock()
+	defer c.smutex.Unlock()
+
+	return c.session, c.channel
+}
+
 func (c *Client) canConnectHandler(tries int) bool {
 	return c.Cfg.ConnectTries == 0 || tries < c.Cfg.ConnectTries
 }
@@ -276,11 +341,27 @@ func (c *Client) reconnect() {
 }
 
 func (c *Client) initialize() (err error) {
+	session, channel := c.current()
+	data := c.Cfg.Data
+	if c.sessionDataHandler != nil {
+		var sdata map[string]string
+		if sdata, err = c.sessionDataHandler(session.ConnectionState()); err != nil {
+			return
+		}
+		data = map[string]string{}
+		for k, v := range c.Cfg.Data {
+			data[k] = v
+		}
+		for k, v := range sdata {
+			data[k] = v
+		}
+	}
+
 	msg := network.NewMessageWithAck(
 		model.MessageTypeClientValidate,
 		&model.ClientValidateData{
 			Token: c.Cfg.Token,
-			Data:  c.Cfg.Data,
+			Data:  data,
 		},
 		p2p.RequestTimeout,
 	)
@@ -294,11 +375,13 @@ func (c *Client) initialize() (err error) {
 		return
 	}
 
-	go c.handlePings(c.sessionId)
-	go c.handleMessages(c.sessionId)
-	go c.handleStreams(c.sessionId)
+	go c.handlePings(session, channel)
+	go c.handleMessages(channel)
+	go c.handleStreams(session)
 
+	c.smutex.Lock()
 	c.Connected = true
+	c.smutex.Unlock()
 
 	return
 }
@@ -308,39 +391,69 @@ func (c *Client) ID() string {
 	return c.Id
 }
 
+// State of the QUIC session with the Server
+func (c *Client) ConnectionState() (state quic.ConnectionState, err error) {
+	session, _ := c.current()
+	if session == nil {
+		err = udp.ErrorNotConnected
+		return
+	}
+	return session.ConnectionState(), nil
+}
+
+// Round-trip time to the Server, measured with pings, 0 if unknown
+func (c *Client) RTT() time.Duration {
+	c.rmutex.Lock()
+	defer c.rmutex.Unlock()
+
+	return c.rtt
+}
+
 // Send a Message to the Server
 func (c *Client) Send(msg *network.Message) (rmsg *network.Message, err error) {
-	if c.channel == nil {
+	_, channel := c.current()
+	if channel == nil {
 		err = udp.ErrorNotConnected
 		return
 	}
-	return c.channel.Send(msg)
+	return channel.Send(msg)
 }
 
 func (c *Client) sendAndRead(msg *network.Message) (rmsg *network.Message, err error) {
-	if c.channel == nil {
+	_, channel := c.current()
+	if channel == nil {
 		err = udp.ErrorNotConnected
 		return
 	}
-	return c.channel.SendAndRead(msg)
+	return channel.SendAndRead(msg)
 }
 
 func (c *Client) reset() {
+	c.smutex.Lock()
+	session, channel := c.session, c.channel
+	c.session, c.channel = nil, nil
 	c.Connected = false
+	c.smutex.Unlock()
 
-	if c.channel != nil {
-		c.channel.Close()
-		c.channel = nil
+	if channel != nil {
+		channel.Close()
 	}
 
 	c.CloseAllStreams()
 
-	if c.session != nil {
-		c.session.CloseWithError(quic.ApplicationErrorCode(0), "Reset")
-		c.session = nil
+	if session != nil {
+		session.CloseWithError(quic.ApplicationErrorCode(0), "Reset")
 	}
 }
 
+// If the Client was closed
+func (c *Client) closed() bool {
+	c.smutex.Lock()
+	defer c.smutex.Unlock()
+
+	return c.Closed
+}
+
 // Close connection with Server
 func (c *Client) Close(code int, reason string) {
 	c.mutex.Lock()
@@ -359,7 +472,9 @@ func (c *Client) Close(code int, reason string) {
 	case c.Exit <- true:
 	default:
 	}
+	c.smutex.Lock()
 	c.Closed = true
+	c.smutex.Unlock()
 
 	if c.closedHandler != nil {
 		go c.closedHandler(reason)
@@ -18,6 +18,9 @@ type Config struct {
 	Tag string
 	// Server Address
 	ServerAddr *net.UDPAddr
+	// Local Address to listen on, a random port if nil
+	// Peers connecting directly to the P2P Manager of the Client need a known port
+	ListenAddr *net.UDPAddr
 	// No. of times to try to connect
 	ConnectTries int
 	// No. of times to try to reconnect
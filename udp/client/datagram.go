@@ -0,0 +1,31 @@
+package udpc
+
+import (
+	"github.com/supergiant-hq/xnet/udp"
+)
+
+// Send a QUIC Datagram to the Server
+// Delivery is not guaranteed and the size is limited to a single QUIC packet
+func (c *Client) SendDatagram(b []byte) (err error) {
+	session, _ := c.current()
+	if session == nil || !c.DatagramsSupported() {
+		return udp.ErrorDatagramsUnsupported
+	}
+	return session.SendMessage(b)
+}
+
+// Receive a QUIC Datagram from the Server
+// Returns an error once the session is closed
+func (c *Client) ReceiveDatagram() (b []byte, err error) {
+	session, _ := c.current()
+	if session == nil {
+		return nil, udp.ErrorNotConnected
+	}
+	return session.ReceiveMessage()
+}
+
+// If both sides enabled Datagrams
+func (c *Client) DatagramsSupported() bool {
+	session, _ := c.current()
+	return session != nil && session.ConnectionState().SupportsDatagrams
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/supergiant-hq/xnet/network"
 
 	"github.com/supergiant-hq/xnet/model"
+
+	"github.com/lucas-clemente/quic-go"
 )
 
 func (c *Client) handleInitData(data *model.ClientData) (err error) {
@@ -25,48 +27,70 @@ func (c *Client) handleInitData(data *model.ClientData) (err error) {
 	return
 }
 
-func (c *Client) handlePings(sessionId string) {
+// Ping the Server until the session is closed
+func (c *Client) handlePings(session quic.Session, channel *network.Channel) {
 	defer func() {
 		recover()
 	}()
 
 	for {
-		msg := network.NewMessage(
+		var load uint32
+		if c.loadHandler != nil {
+			load = c.loadHandler()
+		}
+
+		msg := network.NewMessageWithAck(
 			model.MessageTypeClientPing,
 			&model.ClientPing{
 				Time: time.Now().String(),
+				Rtt:  int64(c.RTT() / time.Microsecond),
+				Load: load,
 			},
+			network.RequestTimeout,
 		)
-		_, err := c.Send(msg)
-		if err != nil {
+		sent := time.Now()
+		_, err := channel.Send(msg)
+		if err == nil {
+			c.measure(time.Since(sent))
+		} else if err != network.ErrorTimeout {
 			c.log.Warnf("Error sending ping to server: %s", err.Error())
 			return
 		}
 
-		if c.Closed || c.sessionId != sessionId {
+		select {
+		case <-time.After(time.Second * 15):
+		case <-session.Context().Done():
 			return
 		}
-		<-time.After(time.Second * 15)
 	}
 }
 
-func (c *Client) handleMessages(sessionId string) {
+// Add a round-trip time sample to the smoothed round-trip time
+func (c *Client) measure(sample time.Duration) {
+	c.rmutex.Lock()
+	defer c.rmutex.Unlock()
+
+	if c.rtt == 0 {
+		c.rtt = sample
+	} else {
+		c.rtt = (7*c.rtt + sample) / 8
+	}
+}
+
+// Handle the Messages of a session, reconnecting when it ends unless the Client was closed
+func (c *Client) handleMessages(channel *network.Channel) {
 	defer func() {
 		recover()
 		if c.disconnectedHandler != nil {
 			go c.disconnectedHandler()
 		}
-		if !c.Closed {
+		if !c.closed() {
 			c.reconnect()
 		}
 	}()
 
 	for {
-		if c.Closed {
-			return
-		}
-
-		msg, err := c.channel.Read(true)
+		msg, err := channel.Read(true)
 		if err != nil {
 			c.log.Warnln("Closing message stream:", c.String(), err.Error())
 			return
@@ -83,6 +107,35 @@ func (c *Client) handleMessages(sessionId string) {
 
 // Search for Clients based on certain parameters
 func (c *Client) SearchClients(params *model.ClientSearch) (clients []string, err error) {
+	rdata, err := c.search(params)
+	if err != nil {
+		return
+	}
+	clients = rdata.Clients
+
+	return
+}
+
+// Search for Clients based on certain parameters, with their addresses and load
+// Servers which do not report them return matches with the IDs only
+func (c *Client) SearchClientMatches(params *model.ClientSearch) (matches []*model.ClientMatch, err error) {
+	rdata, err := c.search(params)
+	if err != nil {
+		return
+	}
+
+	matches = rdata.Matches
+	if len(matches) != len(rdata.Clients) {
+		matches = []*model.ClientMatch{}
+		for _, id := range rdata.Clients {
+			matches = append(matches, &model.ClientMatch{Id: id})
+		}
+	}
+
+	return
+}
+
+func (c *Client) search(params *model.ClientSearch) (rdata *model.Clients, err error) {
 	msg := network.NewMessageWithAck(
 		model.MessageTypeClientSearch,
 		params,
@@ -93,12 +146,11 @@ func (c *Client) SearchClients(params *model.ClientSearch) (clients []string, er
 		return
 	}
 
-	rdata := rmsg.Body.(*model.Clients)
+	rdata = rmsg.Body.(*model.Clients)
 	if !rdata.Status {
 		err = fmt.Errorf(rdata.Message)
 		return
 	}
-	clients = rdata.Clients
 
 	return
 }
@@ -6,21 +6,23 @@ import (
 
 	"github.com/supergiant-hq/xnet/network"
 	"github.com/supergiant-hq/xnet/udp"
+
+	"github.com/lucas-clemente/quic-go"
 )
 
-func (c *Client) handleStreams(sessionId string) {
+// Accept the Streams of a session until it is closed
+func (c *Client) handleStreams(session quic.Session) {
 	defer func() {
 		recover()
 	}()
 
 	for {
-		if c.Closed || c.sessionId != sessionId {
-			return
-		}
-
-		stream, err := c.session.AcceptStream(context.Background())
+		stream, err := session.AcceptStream(context.Background())
 		if err != nil {
 			c.log.Debugln("Error accepting stream:", err.Error())
+			if session.Context().Err() != nil {
+				return
+			}
 			continue
 		}
 		c.log.Infoln("Incoming stream...")
@@ -44,12 +46,15 @@ func (c *Client) handleStreams(sessionId string) {
 // Open a new Stream to the Server
 func (c *Client) OpenStream(metadata map[string]string, data map[string]string) (cstream *udp.Stream, err error) {
 	c.log.Infoln("Opening stream to: ", c.Cfg.ServerAddr.String())
-	if !c.Connected {
+	c.smutex.Lock()
+	session, connected := c.session, c.Connected
+	c.smutex.Unlock()
+	if !connected || session == nil {
 		err = udp.ErrorNotConnected
 		return
 	}
 
-	stream, err := c.session.OpenStream()
+	stream, err := session.OpenStream()
 	if err != nil {
 		return
 	}
@@ -5,5 +5,6 @@ import (
 )
 
 var (
-	ErrorNotConnected = errors.New("not connected")
+	ErrorNotConnected         = errors.New("not connected")
+	ErrorDatagramsUnsupported = errors.New("datagrams not supported")
 )
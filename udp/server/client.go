@@ -19,6 +19,8 @@ import (
 
 const (
 	tickerInterval time.Duration = time.Second * 20
+	// Time the Client has to read the status of a failed initialization
+	initStatusTimeout time.Duration = time.Second * 2
 )
 
 // Client
@@ -41,12 +43,19 @@ type Client struct {
 	Tags           map[string]string
 	messageHandler map[network.MessageType]MessageHandler
 
+	// Round-trip time and load reported by the Client in its pings
+	rtt    time.Duration
+	load   uint32
+	rmutex sync.Mutex
+
 	tickerTimer *time.Timer
 	ticker      *util.Ticker
 	exit        chan bool
 	// Closed Status
 	Closed bool
-	log    *logrus.Entry
+	// Guards the channel, the handlers and the status
+	mutex sync.Mutex
+	log   *logrus.Entry
 }
 
 func (s *Server) newClient(log *logrus.Logger, session quic.Session) (client *Client, err error) {
@@ -79,6 +88,9 @@ func (s *Server) newClient(log *logrus.Logger, session quic.Session) (client *Cl
 
 // Register Client level MessageHandler
 func (c *Client) RegisterHandler(mtype network.MessageType, handler MessageHandler) (err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
 	if _, ok := c.messageHandler[mtype]; ok {
 		err = fmt.Errorf("handler with message type (%v) already exists", mtype)
 		return
@@ -90,6 +102,9 @@ func (c *Client) RegisterHandler(mtype network.MessageType, handler MessageHandl
 }
 
 func (c *Client) initialize(data *model.ClientData) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
 	c.Id = data.Id
 	c.Tags = data.Tags
 	c.Meta = data
@@ -101,38 +116,75 @@ func (c *Client) ID() string {
 	return c.Id
 }
 
+// State of the QUIC session with the Client
+func (c *Client) ConnectionState() quic.ConnectionState {
+	return c.session.ConnectionState()
+}
+
+// Round-trip time to the Client, as measured by the Client with its pings, 0 if unknown
+func (c *Client) RTT() time.Duration {
+	c.rmutex.Lock()
+	defer c.rmutex.Unlock()
+
+	return c.rtt
+}
+
+// Load reported by the Client in its pings, 0 if unknown
+func (c *Client) Load() uint32 {
+	c.rmutex.Lock()
+	defer c.rmutex.Unlock()
+
+	return c.load
+}
+
 // Send a Message to Client
 func (c *Client) Send(msg *network.Message) (rmsg *network.Message, err error) {
-	if c.channel == nil {
+	c.mutex.Lock()
+	channel := c.channel
+	c.mutex.Unlock()
+
+	if channel == nil {
 		err = udp.ErrorNotConnected
 		return
 	}
-	return c.channel.Send(msg)
+	return channel.Send(msg)
+}
+
+// If the Client was closed
+func (c *Client) closed() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.Closed
 }
 
 // Close Client
 func (c *Client) Close(code int, reason string) {
+	c.mutex.Lock()
 	if c.Closed {
+		c.mutex.Unlock()
 		return
 	}
+	c.Closed = true
+	channel := c.channel
+	c.channel = nil
+	c.messageHandler = make(map[network.MessageType]MessageHandler)
+	c.mutex.Unlock()
 
 	c.CloseAllStreams()
 
-	if c.channel != nil {
-		c.channel.Close()
-		c.channel = nil
+	if channel != nil {
+		channel.Close()
 	}
 
 	c.session.CloseWithError(quic.ApplicationErrorCode(code), reason)
 	c.tickerTimer.Stop()
 	c.ticker.Stop()
-	c.messageHandler = make(map[network.MessageType]MessageHandler)
 
 	select {
 	case c.exit <- true:
 	default:
 	}
-	c.Closed = true
 
 	if ec, ok := c.server.clients.Load(c.Id); ok && ec.(*Client).sessionId == c.sessionId {
 		c.server.clients.Delete(c.Id)
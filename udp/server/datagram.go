@@ -0,0 +1,25 @@
+package udps
+
+import (
+	"github.com/supergiant-hq/xnet/udp"
+)
+
+// Send a QUIC Datagram to the Client
+// Delivery is not guaranteed and the size is limited to a single QUIC packet
+func (c *Client) SendDatagram(b []byte) (err error) {
+	if !c.DatagramsSupported() {
+		return udp.ErrorDatagramsUnsupported
+	}
+	return c.session.SendMessage(b)
+}
+
+// Receive a QUIC Datagram from the Client
+// Returns an error once the session is closed
+func (c *Client) ReceiveDatagram() (b []byte, err error) {
+	return c.session.ReceiveMessage()
+}
+
+// If both sides enabled Datagrams
+func (c *Client) DatagramsSupported() bool {
+	return c.session.ConnectionState().SupportsDatagrams
+}
@@ -2,31 +2,54 @@ package udps
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/supergiant-hq/xnet/model"
 	"github.com/supergiant-hq/xnet/network"
 )
 
 func (c *Client) handleTick() {
-	if c.Closed {
+	c.mutex.Lock()
+	closed, initialized := c.Closed, c.initialized
+	c.mutex.Unlock()
+
+	if closed {
 		c.ticker.Stop()
 	}
 
-	if !c.initialized {
+	if !initialized {
 		c.Close(401, "Client initialization incomplete")
 	}
 }
 
 func (c *Client) handlePing(msg *network.Message) {
 	c.log.Debugf("Ping from: %s", c.String())
+
+	ping := msg.Body.(*model.ClientPing)
+	c.rmutex.Lock()
+	if ping.Rtt > 0 {
+		c.rtt = time.Duration(ping.Rtt) * time.Microsecond
+	}
+	c.load = ping.Load
+	c.rmutex.Unlock()
+
+	if !msg.Ctx.Ack {
+		return
+	}
+	rmsg, err := msg.GenReply(model.MessageTypeClientPing, &model.ClientPing{Time: ping.Time})
+	if err != nil {
+		return
+	}
+	if _, err = c.Send(rmsg); err != nil {
+		c.log.Warnf("Error replying to ping: %s", err.Error())
+	}
 }
 
+// Handle the Messages of the Client until its channel is closed
 func (c *Client) handleMessages(s *Server) {
+	channel := c.channel
 	for {
-		if c.Closed {
-			return
-		}
-		msg, err := c.channel.Read(true)
+		msg, err := channel.Read(true)
 
 		if err != nil {
 			c.log.Warnln("Closing message stream:", c.String(), err.Error())
@@ -55,6 +78,11 @@ func (c *Client) handleMessages(s *Server) {
 			}
 			c.sendInitStatus(msg, clientData)
 			if err != nil {
+				// Closing the session discards the status not yet received, the Client closes it after reading the status
+				select {
+				case <-c.session.Context().Done():
+				case <-time.After(initStatusTimeout):
+				}
 				c.Close(401, fmt.Sprintf("Initialization Failed: %s", err.Error()))
 				return
 			}
@@ -82,7 +110,10 @@ func (c *Client) handleMessages(s *Server) {
 			}
 
 			// Client Level Handler
-			if handler, ok := c.messageHandler[msg.Ctx.Type]; ok {
+			c.mutex.Lock()
+			handler, ok := c.messageHandler[msg.Ctx.Type]
+			c.mutex.Unlock()
+			if ok {
 				go handler(c, msg)
 				continue
 			}
@@ -107,15 +138,26 @@ func (c *Client) sendInitStatus(msg *network.Message, clientData *model.ClientDa
 	c.Send(rmsg)
 }
 
+// Details of the Client returned by searches
+func (c *Client) match() *model.ClientMatch {
+	return &model.ClientMatch{
+		Id:      c.Id,
+		Address: c.Addr.String(),
+		Load:    c.Load(),
+	}
+}
+
 func (c *Client) handleClientSearch(msg *network.Message) {
 	var err error
 	clients := []string{}
+	matches := []*model.ClientMatch{}
 
-	c.log.Infoln("Search clients (%s)", c.Id)
+	c.log.Infof("Search clients (%s)", c.Id)
 
 	defer func() {
 		rdata := &model.Clients{
 			Clients: clients,
+			Matches: matches,
 		}
 
 		if err != nil {
@@ -125,7 +167,7 @@ func (c *Client) handleClientSearch(msg *network.Message) {
 		} else {
 			rdata.Status = true
 			rdata.Message = "Ok"
-			c.log.Infoln("Search clients res (%s): %d", c.Id, len(clients))
+			c.log.Infof("Search clients res (%s): %d", c.Id, len(clients))
 		}
 
 		rmsg, _ := msg.GenReply(model.MessageTypeClients, rdata)
@@ -141,6 +183,7 @@ func (c *Client) handleClientSearch(msg *network.Message) {
 			return
 		}
 		clients = append(clients, client.Id)
+		matches = append(matches, client.match())
 	} else if len(params.Tag) > 0 {
 		sClients := c.server.GetClientsWithTag(params.Tag)
 		if len(sClients) == 0 {
@@ -149,6 +192,7 @@ func (c *Client) handleClientSearch(msg *network.Message) {
 		}
 		for _, client := range sClients {
 			clients = append(clients, client.Id)
+			matches = append(matches, client.match())
 		}
 	} else {
 		err = fmt.Errorf("invalid search params")
@@ -20,6 +20,10 @@ type ClientConnectedHandler func(*Client)
 // Called when a new client connects to the server
 type ClientValidateHandler func(*net.UDPAddr, *model.ClientValidateData) (cd *model.ClientData, err error)
 
+// Called when a new client connects to the server, instead of the ClientValidateHandler if set
+// The Client gives access to the state of its QUIC session
+type ClientSessionValidateHandler func(*Client, *model.ClientValidateData) (cd *model.ClientData, err error)
+
 // Called when a client disconnects
 type ClientDisconnectedHandler func(*Client)
 
@@ -39,14 +43,16 @@ type Server struct {
 
 	clientConnectedHandler ClientConnectedHandler
 	// Client Validation Handler
-	ClientValidateHandler     ClientValidateHandler
-	clientDisconnectedHandler ClientDisconnectedHandler
+	ClientValidateHandler        ClientValidateHandler
+	clientSessionValidateHandler ClientSessionValidateHandler
+	clientDisconnectedHandler    ClientDisconnectedHandler
 	// New Stream Handler
 	StreamHandler  udp.StreamHandler
 	messageHandler map[network.MessageType]MessageHandler
 
 	// UDP Connection
 	UDPConn  *net.UDPConn
+	conn     net.PacketConn
 	listener quic.Listener
 
 	// Exit Channel
@@ -99,12 +105,39 @@ func NewWithConnection(
 		messageHandler:        make(map[network.MessageType]MessageHandler),
 
 		UDPConn: udpConn,
+		conn:    udpConn,
 		Exit:    make(chan bool, 1),
 		log:     log.WithField("prefix", fmt.Sprintf("UDPS-%s", cfg.Tag)),
 	}
 	return
 }
 
+// Creates a Server using an existing net.PacketConn connection
+// UDPConn is nil, the connection is not closed with the Server
+func NewWithPacketConn(
+	log *logrus.Logger,
+	cfg Config,
+	conn net.PacketConn,
+	clientValidateHandler ClientValidateHandler,
+) (s *Server, err error) {
+	if err = cfg.init(true); err != nil {
+		return
+	}
+
+	s = &Server{
+		Cfg:     cfg,
+		clients: new(sync.Map),
+
+		ClientValidateHandler: clientValidateHandler,
+		messageHandler:        make(map[network.MessageType]MessageHandler),
+
+		conn: conn,
+		Exit: make(chan bool, 1),
+		log:  log.WithField("prefix", fmt.Sprintf("UDPS-%s", cfg.Tag)),
+	}
+	return
+}
+
 // Start listening for connections
 func (s *Server) Listen() (err error) {
 	s.mutex.Lock()
@@ -114,17 +147,18 @@ func (s *Server) Listen() (err error) {
 		return fmt.Errorf("server closed")
 	}
 
-	if s.UDPConn == nil {
+	if s.conn == nil {
 		if s.UDPConn, err = net.ListenUDP("udp", s.Cfg.Addr); err != nil {
 			return err
 		}
+		s.conn = s.UDPConn
 	}
 
-	if s.listener, err = quic.Listen(s.UDPConn, s.Cfg.TLS.Clone(), s.Cfg.Quic.Clone()); err != nil {
+	if s.listener, err = quic.Listen(s.conn, s.Cfg.TLS.Clone(), s.Cfg.Quic.Clone()); err != nil {
 		return err
 	}
 
-	go s.handleSessions()
+	go s.handleSessions(s.listener)
 
 	s.log.Infof("Server started: %v", s.Cfg.Addr.String())
 
@@ -136,6 +170,11 @@ func (s *Server) SetClientConnectedHandler(handler ClientConnectedHandler) {
 	s.clientConnectedHandler = handler
 }
 
+// Set Client Session Validate Handler
+func (s *Server) SetClientSessionValidateHandler(handler ClientSessionValidateHandler) {
+	s.clientSessionValidateHandler = handler
+}
+
 // Set Client Disconnect Handler
 func (s *Server) SetClientDisconnectedHandler(handler ClientDisconnectedHandler) {
 	s.clientDisconnectedHandler = handler
@@ -158,9 +197,10 @@ func (s *Server) RegisterHandler(mtype network.MessageType, handler MessageHandl
 	return
 }
 
-func (s *Server) handleSessions() {
+// Accept sessions until the listener is closed
+func (s *Server) handleSessions(listener quic.Listener) {
 	for {
-		session, err := s.listener.Accept(context.Background())
+		session, err := listener.Accept(context.Background())
 		if err != nil {
 			s.log.Errorln(err)
 			return
@@ -186,7 +226,11 @@ func (s *Server) validateClient(c *Client, data *model.ClientValidateData) (cdat
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	cdata, err = s.ClientValidateHandler(c.Addr, data)
+	if s.clientSessionValidateHandler != nil {
+		cdata, err = s.clientSessionValidateHandler(c, data)
+	} else {
+		cdata, err = s.ClientValidateHandler(c.Addr, data)
+	}
 	if err != nil {
 		return
 	}
@@ -212,6 +256,16 @@ func (s *Server) GetClient(id string) (c *Client, err error) {
 	return
 }
 
+// Get all connected Clients
+func (s *Server) GetClients() (clients []*Client) {
+	clients = []*Client{}
+	s.clients.Range(func(key, value interface{}) bool {
+		clients = append(clients, value.(*Client))
+		return true
+	})
+	return
+}
+
 // Get Clients by Tag
 func (s *Server) GetClientsWithTag(tag string) (clients []*Client) {
 	clients = []*Client{}
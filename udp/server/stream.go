@@ -8,15 +8,15 @@ import (
 	"github.com/supergiant-hq/xnet/udp"
 )
 
+// Accept the Streams of the Client until its session is closed
 func (c *Client) handleStreams() {
 	for {
-		if c.Closed {
-			return
-		}
-
 		stream, err := c.session.AcceptStream(context.Background())
 		if err != nil {
 			c.log.Debugln("Error accepting stream:", err.Error())
+			if c.closed() || c.session.Context().Err() != nil {
+				return
+			}
 			continue
 		}
 		c.log.Infoln("Incoming stream...")
@@ -140,6 +140,16 @@ func (s *Stream) Stream() quic.Stream {
 	return s.channel.Stream()
 }
 
+// Number of bytes received on the Stream
+func (s *Stream) BytesRead() uint64 {
+	return s.channel.BytesRead()
+}
+
+// Number of bytes sent on the Stream
+func (s *Stream) BytesWritten() uint64 {
+	return s.channel.BytesWritten()
+}
+
 // Closes the Stream
 func (s *Stream) Close() {
 	s.mutex.Lock()
@@ -2,6 +2,7 @@
 package util
 
 import (
+	"sync"
 	"time"
 )
 
@@ -11,6 +12,7 @@ type Ticker struct {
 	d      time.Duration
 	ticker *time.Ticker
 	exit   chan bool
+	mutex  sync.Mutex
 }
 
 type TickerFunction func()
@@ -25,17 +27,19 @@ func NewTicker(d time.Duration, f TickerFunction) *Ticker {
 
 // Start the ticker
 func (t *Ticker) Start() {
-	t.ticker = time.NewTicker(t.d)
-	t.exit = make(chan bool, 1)
+	t.mutex.Lock()
+	ticker, exit := time.NewTicker(t.d), make(chan bool, 1)
+	t.ticker, t.exit = ticker, exit
+	t.mutex.Unlock()
 
 	go func() {
 		defer func() {
 			recover()
 		}()
-		defer t.ticker.Stop()
+		defer ticker.Stop()
 		for {
 			select {
-			case <-t.ticker.C:
+			case <-ticker.C:
 				func() {
 					defer func() {
 						if r := recover(); r != nil {
@@ -45,7 +49,7 @@ func (t *Ticker) Start() {
 
 					t.f()
 				}()
-			case <-t.exit:
+			case <-exit:
 				return
 			}
 		}
@@ -58,6 +62,9 @@ func (t *Ticker) Stop() {
 		recover()
 	}()
 
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
 	if t.ticker == nil {
 		return
 	}